@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// allCmd makes the poster, contact sheet, sprites and manifest of a video from one probe,
+// extracting each frame once even if several outputs use it
+type allCmd struct {
+	VideoPath       string   `arg:"" help:"Path to video"`
+	From            Duration `help:"Starting point of the contact sheet. Defaults to 10s, or the beginning of videos shorter than that"`
+	To              Duration `help:"Stopping point of the contact sheet"`
+	Columns         int      `default:"3" help:"Columns of the contact sheet"`
+	TileWidth       int      `default:"${default_tile_width}" help:"Tile width of the contact sheet in px"`
+	IntervalSeconds int      `help:"Interval between tiles of the contact sheet in seconds. Picked from the video duration by default"`
+	PosterAt        Duration `default:"10" help:"Timestamp of the poster frame, videos shorter than this use their first frame"`
+	PosterWidth     int      `default:"1280" help:"Poster width in px"`
+	SpriteInterval  Duration `default:"10" help:"Interval between sprites"`
+	SpriteWidth     int      `default:"160" help:"Sprite width in px"`
+	SpriteGrid      string   `default:"10x10" placeholder:"CxR" help:"Columns and rows of each sprite sheet"`
+	JPEGQuality     int      `name:"quality" default:"80" help:"JPEG quality"`
+	Seek            string   `default:"accurate" enum:"accurate,fast" help:"How to seek to frames, one of accurate, fast"`
+	Concurrency     int      `default:"4" help:"How many frames to extract in parallel"`
+}
+
+func (c allCmd) Run(ctx context.Context) error {
+	from, err := c.From.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := c.To.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid to: %w", err)
+	}
+	posterAt, err := c.PosterAt.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid poster timestamp: %w", err)
+	}
+	spriteInterval, err := c.SpriteInterval.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid sprite interval: %w", err)
+	}
+	spriteColumns, spriteRows, err := parseSpriteGrid(c.SpriteGrid)
+	if err != nil {
+		return err
+	}
+	if c.PosterWidth < 1 || c.TileWidth < 1 || c.SpriteWidth < 1 {
+		return fmt.Errorf("poster, tile and sprite widths must be positive")
+	}
+
+	started := time.Now()
+	media, err := thumber.ProbeMedia(ctx, c.VideoPath, false)
+	if err != nil {
+		return err
+	}
+	if posterAt >= media.Duration {
+		posterAt = 0
+	}
+
+	base := thumber.ThumbOptions{
+		Seek:             thumber.SeekMode(c.Seek),
+		Concurrency:      c.Concurrency,
+		ShortVideoPolicy: thumber.ShortVideoSpread,
+		Media:            &media,
+	}
+	sheetOpts := base
+	sheetOpts.From, sheetOpts.To = from, to
+	sheetOpts.DefaultFrom = defaultFrom(c.From)
+	sheetOpts.TileColumns = c.Columns
+	sheetOpts.TileWidth = c.TileWidth
+	sheetOpts.Interval = time.Duration(c.IntervalSeconds) * time.Second
+	spriteOpts := base
+	spriteOpts.TileColumns = spriteColumns
+	spriteOpts.MaxTilesPerSheet = spriteColumns * spriteRows
+	spriteOpts.TileWidth = c.SpriteWidth
+	spriteOpts.Interval = spriteInterval
+
+	sheetTimes, err := thumber.PlanTimestamps(ctx, c.VideoPath, sheetOpts)
+	if err != nil {
+		return fmt.Errorf("failed to plan contact sheet: %w", err)
+	}
+	spriteTimes, err := thumber.PlanTimestamps(ctx, c.VideoPath, spriteOpts)
+	if err != nil {
+		return fmt.Errorf("failed to plan sprites: %w", err)
+	}
+
+	frames := newSharedFrames()
+	frames.need([]time.Duration{posterAt}, c.PosterWidth)
+	frames.need(sheetTimes, c.TileWidth)
+	frames.need(spriteTimes, c.SpriteWidth)
+	if err := frames.extract(ctx, c.VideoPath, base); err != nil {
+		return fmt.Errorf("failed to extract frames: %w", err)
+	}
+
+	posterPath := defaultOutputPath(c.VideoPath, "poster")
+	poster := frames.get([]time.Duration{posterAt}, c.PosterWidth)[0]
+	if err := writeJPEG(posterPath, c.VideoPath, poster.Image, c.JPEGQuality); err != nil {
+		return fmt.Errorf("failed to write poster: %w", err)
+	}
+
+	sheetPath := defaultOutputPath(c.VideoPath, "thumbs")
+	sheets, err := thumber.ComposeSheets(ctx, frames.get(sheetTimes, c.TileWidth), sheetOpts)
+	if err != nil {
+		return fmt.Errorf("failed to compose contact sheet: %w", err)
+	}
+	if err := writeJPEG(sheetPath, c.VideoPath, sheets[0], c.JPEGQuality); err != nil {
+		return fmt.Errorf("failed to write contact sheet: %w", err)
+	}
+	manifestPath := strings.TrimSuffix(sheetPath, filepath.Ext(sheetPath)) + ".json"
+	if err := writeManifest(manifestPath, c.VideoPath, []string{sheetPath}, sheets, manifestExtras{}); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	sprites, err := thumber.ComposeSheets(ctx, frames.get(spriteTimes, c.SpriteWidth), spriteOpts)
+	if err != nil {
+		return fmt.Errorf("failed to compose sprites: %w", err)
+	}
+	spritePath := defaultOutputPath(c.VideoPath, "sprites")
+	var spritePaths []string
+	for i, sprite := range sprites {
+		path := spritePath
+		if len(sprites) > 1 {
+			path = pagePath(spritePath, i+1)
+		}
+		if err := writeJPEG(path, c.VideoPath, sprite, c.JPEGQuality); err != nil {
+			return fmt.Errorf("failed to write sprites: %w", err)
+		}
+		spritePaths = append(spritePaths, path)
+	}
+	vttPath := strings.TrimSuffix(spritePath, filepath.Ext(spritePath)) + ".vtt"
+	if err := writeVTT(vttPath, c.VideoPath, spritePaths, sprites); err != nil {
+		return fmt.Errorf("failed to write vtt: %w", err)
+	}
+
+	slog.Info("generated poster, contact sheet and sprites",
+		"stage", "total",
+		"duration_ms", time.Since(started).Milliseconds(),
+		"path", c.VideoPath,
+		"poster", posterPath,
+		"sheet", sheetPath,
+		"manifest", manifestPath,
+		"sprites", len(spritePaths),
+		"vtt", vttPath,
+		"frames_used", 1+len(sheetTimes)+len(spriteTimes),
+		"frames_extracted", len(frames.widths),
+	)
+	return nil
+}
+
+// sharedFrames extracts every timestamp needed by several outputs once, at the largest width any of them needs.
+// Outputs that need smaller frames get them scaled down.
+type sharedFrames struct {
+	widths map[time.Duration]int
+	frames map[time.Duration]thumber.Thumbnail
+}
+
+func newSharedFrames() *sharedFrames {
+	return &sharedFrames{
+		widths: map[time.Duration]int{},
+		frames: map[time.Duration]thumber.Thumbnail{},
+	}
+}
+
+func (s *sharedFrames) need(timestamps []time.Duration, width int) {
+	for _, t := range timestamps {
+		if width > s.widths[t] {
+			s.widths[t] = width
+		}
+	}
+}
+
+// extract extracts the frames in one batch per width
+func (s *sharedFrames) extract(ctx context.Context, videoPath string, opts thumber.ThumbOptions) error {
+	byWidth := map[int][]time.Duration{}
+	for t, w := range s.widths {
+		byWidth[w] = append(byWidth[w], t)
+	}
+	for w, timestamps := range byWidth {
+		slices.Sort(timestamps)
+		opts.TileWidth = w
+		thumbs, err := thumber.ExtractThumbnails(ctx, videoPath, timestamps, opts)
+		if err != nil {
+			return err
+		}
+		for _, th := range thumbs {
+			s.frames[th.RequestedTimestamp] = th
+		}
+	}
+	return nil
+}
+
+// get returns the frames at timestamps scaled to width, all with the same height so they tile evenly
+func (s *sharedFrames) get(timestamps []time.Duration, width int) []thumber.Thumbnail {
+	thumbs := make([]thumber.Thumbnail, 0, len(timestamps))
+	height := 0
+	for _, t := range timestamps {
+		th := s.frames[t]
+		if height == 0 {
+			b := th.Bounds()
+			height = int(float64(b.Dy())*float64(width)/float64(b.Dx()) + 0.5)
+		}
+		if th.Bounds().Dx() != width || th.Bounds().Dy() != height {
+			th.Image = imaging.Resize(th.Image, width, height, imaging.Lanczos)
+			// the original encoding is no longer the tile
+			th.JPEG = nil
+		}
+		thumbs = append(thumbs, th)
+	}
+	return thumbs
+}
+
+func writeJPEG(path, videoPath string, img image.Image, quality int) error {
+	f, err := createOutput(path, videoPath, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	return f.Commit()
+}
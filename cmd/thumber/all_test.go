@@ -0,0 +1,28 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+func TestSharedFrames(t *testing.T) {
+	s := newSharedFrames()
+	s.need([]time.Duration{10 * time.Second}, 1280)
+	s.need([]time.Duration{0, 10 * time.Second, 20 * time.Second}, 160)
+	assert.Equal(t, map[time.Duration]int{0: 160, 10 * time.Second: 1280, 20 * time.Second: 160}, s.widths)
+
+	s.frames[0] = thumber.Thumbnail{Image: image.NewRGBA(image.Rect(0, 0, 160, 90)), JPEG: []byte{1}}
+	s.frames[10*time.Second] = thumber.Thumbnail{Image: image.NewRGBA(image.Rect(0, 0, 1280, 720)), JPEG: []byte{1}}
+
+	thumbs := s.get([]time.Duration{0, 10 * time.Second}, 160)
+	for _, th := range thumbs {
+		assert.Equal(t, image.Rect(0, 0, 160, 90), th.Bounds())
+	}
+	assert.NotNil(t, thumbs[0].JPEG, "frames extracted at the right size keep their encoding")
+	assert.Nil(t, thumbs[1].JPEG, "scaled frames drop their encoding")
+}
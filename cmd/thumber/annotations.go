@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// readAnnotations reads a JSON object mapping timestamps to labels e.g. {"12:05": "goal", "1:02:03": "scene 12"}.
+// Timestamps are in any format accepted by duration flags.
+func readAnnotations(path string) ([]thumber.Annotation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("expected an object of timestamps to labels: %w", err)
+	}
+
+	annotations := make([]thumber.Annotation, 0, len(labels))
+	for at, text := range labels {
+		d, err := Duration(at).Duration()
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, thumber.Annotation{At: d, Text: text})
+	}
+	return annotations, nil
+}
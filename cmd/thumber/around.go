@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// aroundCmd makes a dense sheet of the moments around a timestamp, e.g. a hit of a transcript search
+type aroundCmd struct {
+	VideoPath         string   `arg:"" help:"Path to video"`
+	Center            Duration `required:"" placeholder:"TIMESTAMP" help:"Moment of interest to center the sheet on"`
+	Window            Duration `default:"2m" help:"Length of video the sheet covers, shifted to stay within the video near its start and end"`
+	OutputPath        string   `short:"o" help:"Output path to save JPEG, use - for stdout. Defaults to $filename.around-hh-mm-ss.jpg"`
+	Tiles             int      `default:"12" help:"Number of tiles spread over the window"`
+	Columns           int      `default:"4" help:"Columns of tile grid"`
+	TileWidth         int      `default:"320" help:"Tile width in px"`
+	Padding           int      `default:"4" help:"Padding around tiles in px"`
+	OverlayTimestamps bool     `default:"true" negatable:"" help:"Overlay timestamp on each tile"`
+	JPEGQuality       int      `name:"quality" default:"80" help:"JPEG quality"`
+	Concurrency       int      `default:"4" help:"How many frames to extract in parallel"`
+}
+
+func (c aroundCmd) Run(ctx context.Context) error {
+	center, err := c.Center.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid center: %w", err)
+	}
+	window, err := c.Window.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid window: %w", err)
+	}
+	if window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+
+	media, err := thumber.ProbeMedia(ctx, c.VideoPath, false)
+	if err != nil {
+		return err
+	}
+	if center > media.Duration {
+		return fmt.Errorf("center %s is past the end of the video at %s", thumber.FormatDuration(center), thumber.FormatDuration(media.Duration))
+	}
+	from, to := aroundWindow(center, window, media.Duration)
+
+	opts := thumber.ThumbOptions{
+		From:              from,
+		To:                to,
+		TileCount:         c.Tiles,
+		TileColumns:       c.Columns,
+		TileWidth:         c.TileWidth,
+		Padding:           c.Padding,
+		OverlayTimestamps: c.OverlayTimestamps,
+		Concurrency:       c.Concurrency,
+		ShortVideoPolicy:  thumber.ShortVideoSpread,
+		Media:             &media,
+	}
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	sheets, err := thumber.GenerateSheets(ctx, c.VideoPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate thumbnails: %w", err)
+	}
+
+	outputPath := c.OutputPath
+	if outputPath == "" {
+		outputPath = defaultOutputPath(c.VideoPath, "around-"+strings.ReplaceAll(thumber.FormatDuration(center), ":", "-"))
+	}
+	if err := writeJPEG(outputPath, c.VideoPath, sheets[0], c.JPEGQuality); err != nil {
+		return err
+	}
+	slog.Info("generated sheet around timestamp", "path", c.VideoPath, "output", outputPath, "from", from, "to", to)
+	return nil
+}
+
+// aroundWindow centers a window of the given length on center, shifting it to fit between the start and end of the video
+func aroundWindow(center, window, duration time.Duration) (from, to time.Duration) {
+	from = center - window/2
+	if from+window > duration {
+		from = duration - window
+	}
+	if from < 0 {
+		from = 0
+	}
+	to = from + window
+	if to > duration {
+		to = duration
+	}
+	return from, to
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAroundWindow(t *testing.T) {
+	tests := []struct {
+		name             string
+		center, duration time.Duration
+		from, to         time.Duration
+	}{
+		{"centered", 42*time.Minute + 17*time.Second, time.Hour, 41*time.Minute + 17*time.Second, 43*time.Minute + 17*time.Second},
+		{"near the start", 30 * time.Second, time.Hour, 0, 2 * time.Minute},
+		{"near the end", 59 * time.Minute, time.Hour, 58 * time.Minute, time.Hour},
+		{"short video", 30 * time.Second, 90 * time.Second, 0, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := aroundWindow(tt.center, 2*time.Minute, tt.duration)
+			assert.Equal(t, tt.from, from)
+			assert.Equal(t, tt.to, to)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileAttrs are applied to outputs before they're moved into place
+type fileAttrs struct {
+	mode os.FileMode
+	// uid and gid are -1 to keep the current owner
+	uid int
+	gid int
+	// modTime is left alone when zero
+	modTime time.Time
+}
+
+func defaultFileAttrs() fileAttrs {
+	return fileAttrs{mode: 0o644, uid: -1, gid: -1}
+}
+
+func (a fileAttrs) apply(path string) error {
+	if err := os.Chmod(path, a.mode); err != nil {
+		return err
+	}
+	if a.uid != -1 || a.gid != -1 {
+		if err := os.Chown(path, a.uid, a.gid); err != nil {
+			return err
+		}
+	}
+	if !a.modTime.IsZero() {
+		if err := os.Chtimes(path, a.modTime, a.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFileMode parses octal permissions such as 0640 or 644
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q, must be octal permissions e.g. 0644", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseOwner parses user, user:group or :group, as names or numeric ids.
+// Missing parts are returned as -1.
+func parseOwner(s string) (uid, gid int, err error) {
+	userPart, groupPart, _ := strings.Cut(s, ":")
+	uid, gid = -1, -1
+	if userPart != "" {
+		if uid, err = lookupID(userPart, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return -1, -1, fmt.Errorf("unknown user %q: %w", userPart, err)
+		}
+	}
+	if groupPart != "" {
+		if gid, err = lookupID(groupPart, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return -1, -1, fmt.Errorf("unknown group %q: %w", groupPart, err)
+		}
+	}
+	if uid == -1 && gid == -1 {
+		return -1, -1, fmt.Errorf("invalid owner %q, must be user, user:group or :group", s)
+	}
+	return uid, gid, nil
+}
+
+func lookupID(s string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil && id >= 0 {
+		return id, nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// benchCmd times each stage of generation locally, nothing is sent anywhere
+type benchCmd struct {
+	VideoPath   string `arg:"" help:"Path to video"`
+	Frames      int    `default:"12" help:"Number of frames to extract in each run"`
+	TileWidth   int    `default:"${default_tile_width}" help:"Tile width in px"`
+	Concurrency []int  `placeholder:"N,..." help:"Concurrency levels to compare. Defaults to 1 and the number of CPUs"`
+	JPEGQuality int    `name:"quality" default:"80" help:"JPEG quality"`
+}
+
+type benchResult struct {
+	stage    string
+	settings string
+	total    time.Duration
+	frames   int
+}
+
+func (c benchCmd) Run() error {
+	ctx := context.Background()
+	if c.Frames < 1 {
+		return fmt.Errorf("frames must be positive")
+	}
+	levels := c.Concurrency
+	if len(levels) == 0 {
+		levels = []int{1}
+		if n := runtime.NumCPU(); n > 1 {
+			levels = append(levels, n)
+		}
+	}
+
+	var results []benchResult
+	fmt.Fprintln(os.Stderr, "probing...")
+	started := time.Now()
+	media, err := thumber.ProbeMedia(ctx, c.VideoPath, false)
+	if err != nil {
+		return err
+	}
+	results = append(results, benchResult{stage: "probe", total: time.Since(started)})
+
+	opts := thumber.ThumbOptions{
+		TileCount:   c.Frames,
+		TileColumns: 4,
+		TileWidth:   c.TileWidth,
+		Media:       &media,
+	}
+	var thumbs []thumber.Thumbnail
+	for _, seek := range []thumber.SeekMode{thumber.SeekAccurate, thumber.SeekFast} {
+		for _, n := range levels {
+			fmt.Fprintf(os.Stderr, "extracting %d frames with %s seek and concurrency %d...\n", c.Frames, seek, n)
+			runOpts := opts
+			runOpts.Seek = seek
+			runOpts.Concurrency = n
+			started := time.Now()
+			thumbs, err = thumber.MakeThumbnails(ctx, c.VideoPath, runOpts)
+			if err != nil {
+				return fmt.Errorf("failed to extract frames: %w", err)
+			}
+			results = append(results, benchResult{
+				stage:    "extract",
+				settings: fmt.Sprintf("seek=%s concurrency=%d", seek, n),
+				total:    time.Since(started),
+				frames:   len(thumbs),
+			})
+		}
+	}
+
+	started = time.Now()
+	img, err := thumber.MakeContactSheet(thumbs, opts)
+	if err != nil {
+		return err
+	}
+	results = append(results, benchResult{stage: "compose", total: time.Since(started), frames: len(thumbs)})
+
+	started = time.Now()
+	if err := jpeg.Encode(io.Discard, img, &jpeg.Options{Quality: c.JPEGQuality}); err != nil {
+		return err
+	}
+	results = append(results, benchResult{
+		stage:    "encode",
+		settings: fmt.Sprintf("%dx%d quality=%d", img.Bounds().Dx(), img.Bounds().Dy(), c.JPEGQuality),
+		total:    time.Since(started),
+	})
+
+	printBenchTable(os.Stdout, results)
+	return nil
+}
+
+func printBenchTable(w io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tSETTINGS\tTOTAL\tPER FRAME")
+	for _, r := range results {
+		perFrame := "-"
+		if r.frames > 0 {
+			perFrame = (r.total / time.Duration(r.frames)).Round(time.Millisecond).String()
+		}
+		settings := r.settings
+		if settings == "" {
+			settings = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.stage, settings, r.total.Round(time.Millisecond), perFrame)
+	}
+	tw.Flush()
+}
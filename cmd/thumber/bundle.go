@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+	"github.com/abdusco/thumber/version"
+)
+
+// bundleFormat returns the archive format for the output path, or "" if it isn't an archive
+func bundleFormat(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+type bundleMetadata struct {
+	Video        string      `json:"video"`
+	VideoSize    int64       `json:"video_size"`
+	VideoModTime time.Time   `json:"video_mod_time"`
+	GeneratedAt  time.Time   `json:"generated_at"`
+	Version      string      `json:"version"`
+	Options      generateCmd `json:"options"`
+}
+
+// generateBundle generates the sheet, tiles, manifest and whatever else is enabled into a temporary directory,
+// and packs them into an archive along with metadata.json
+func (a generateCmd) generateBundle(ctx context.Context, videoPath, archivePath string, opts thumber.ThumbOptions) (string, error) {
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return "", err
+	}
+	attrs, err := a.fileAttrs(videoPath)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(a.TmpDir, "thumber-bundle-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	b := a
+	b.TilesDir = filepath.Join(dir, "tiles")
+	b.Manifest = true
+	b.PreserveTimes, b.Chmod, b.Chown = false, "", ""
+	b.Checksum, b.Sign = "none", "none"
+	sheetPath := filepath.Join(dir, defaultOutputPath(filepath.Base(videoPath), "thumbs"))
+	if _, err := b.generate(ctx, videoPath, sheetPath, opts); err != nil {
+		return "", err
+	}
+
+	metadata, err := json.MarshalIndent(bundleMetadata{
+		Video:        videoPath,
+		VideoSize:    info.Size(),
+		VideoModTime: info.ModTime().UTC(),
+		GeneratedAt:  time.Now().UTC(),
+		Version:      version.Version.String(),
+		Options:      a,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), metadata, 0o644); err != nil {
+		return "", err
+	}
+
+	f, err := createOutput(archivePath, videoPath, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for writing: %w", err)
+	}
+	defer f.Close()
+	f.SetAttrs(attrs)
+
+	if bundleFormat(archivePath) == "zip" {
+		err = writeZip(f, dir)
+	} else {
+		err = writeTar(f, dir, bundleFormat(archivePath) == "tar.gz")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("failed to save output: %w", err)
+	}
+	return f.Path(), nil
+}
+
+// walkFiles calls fn with the slash separated path relative to dir of every regular file in it
+func walkFiles(dir string, fn func(name, path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), path, info)
+	})
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	err := walkFiles(dir, func(name, path string, info fs.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		// images and the like are already compressed
+		header.Method = zip.Store
+		if strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".vtt") || strings.HasSuffix(name, ".html") {
+			header.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		return copyFile(fw, path)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTar(w io.Writer, dir string, compress bool) error {
+	if !compress {
+		return writeTarFiles(w, dir)
+	}
+	gw := gzip.NewWriter(w)
+	if err := writeTarFiles(gw, dir); err != nil {
+		return err
+	}
+	// flushes the rest of the archive and the gzip trailer
+	return gw.Close()
+}
+
+func writeTarFiles(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := walkFiles(dir, func(name, path string, info fs.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		return copyFile(tw, path)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
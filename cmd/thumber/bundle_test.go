@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bundleDir(t *testing.T) (string, map[string]string) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"video.thumbs.jpg":   "sheet",
+		"metadata.json":      `{"video":"video.mp4"}`,
+		"tiles/tile-001.jpg": "tile",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return dir, files
+}
+
+func TestWriteTar(t *testing.T) {
+	dir, files := bundleDir(t)
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		require.NoError(t, writeTar(&buf, dir, compress))
+
+		var r io.Reader = &buf
+		if compress {
+			gr, err := gzip.NewReader(r)
+			require.NoError(t, err)
+			r = gr
+		}
+		got := map[string]string{}
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			got[header.Name] = string(content)
+		}
+		if compress {
+			// reading to the end checks the gzip trailer
+			_, err := io.ReadAll(r)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, files, got)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	dir, files := bundleDir(t)
+	var buf bytes.Buffer
+	require.NoError(t, writeZip(&buf, dir))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		got[f.Name] = string(content)
+	}
+	assert.Equal(t, files, got)
+}
+
+func TestWriteTarFailsOnWriteError(t *testing.T) {
+	dir, _ := bundleDir(t)
+	// the archive is smaller than gzip's buffer, so only its header is written before closing it
+	assert.Error(t, writeTar(&failingWriter{n: 10}, dir, true))
+}
+
+// failingWriter fails writes past its first n bytes
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, io.ErrShortWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
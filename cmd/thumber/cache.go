@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// cachePruneInterval is how often storing a sheet prunes the cache
+const cachePruneInterval = time.Minute
+
+// sheetCache keeps sheets on disk for clients that fetch them later by a signed URL.
+// Sheets are removed once their URLs have expired, or earlier, oldest first, to keep the cache under maxSize.
+type sheetCache struct {
+	dir    string
+	key    []byte
+	expiry time.Duration
+	// maxSize is zero for no limit
+	maxSize int64
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func newSheetCache(dir, key string, expiry Duration, maxSize int64) (*sheetCache, error) {
+	d, err := expiry.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid url expiry: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &sheetCache{dir: dir, key: []byte(key), expiry: d, maxSize: maxSize}
+	if key == "" {
+		// URLs signed with a random key are invalid after a restart
+		c.key = make([]byte, 32)
+		if _, err := rand.Read(c.key); err != nil {
+			return nil, err
+		}
+	}
+	c.prune(time.Now())
+	return c, nil
+}
+
+// store saves a JPEG under the hash of its content and returns its name. A sheet that's stored again
+// is rewritten, so it's kept for as long as its latest URL is valid.
+func (c *sheetCache) store(data []byte) (string, error) {
+	c.mu.Lock()
+	if now := time.Now(); now.Sub(c.lastPruned) >= cachePruneInterval {
+		c.lastPruned = now
+		c.mu.Unlock()
+		c.prune(now)
+	} else {
+		c.mu.Unlock()
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:16]) + ".jpg"
+	f, err := createOutput(filepath.Join(c.dir, name), "", "")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+	return name, f.Commit()
+}
+
+// prune removes sheets whose URLs have all expired, which is when their files are older than the expiry,
+// then the oldest sheets until the rest fit in maxSize
+func (c *sheetCache) prune(now time.Time) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		slog.Warn("failed to prune cache", "dir", c.dir, "error", err)
+		return
+	}
+	var sheets []os.FileInfo
+	var size int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() || filepath.Ext(e.Name()) != ".jpg" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > c.expiry {
+			c.remove(info.Name())
+			continue
+		}
+		sheets = append(sheets, info)
+		size += info.Size()
+	}
+	if c.maxSize <= 0 || size <= c.maxSize {
+		return
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].ModTime().Before(sheets[j].ModTime()) })
+	for _, info := range sheets {
+		if size <= c.maxSize {
+			break
+		}
+		c.remove(info.Name())
+		size -= info.Size()
+	}
+}
+
+func (c *sheetCache) remove(name string) {
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("failed to remove cached sheet", "name", name, "error", err)
+	}
+}
+
+// signedURL is the path a cached sheet can be fetched from until the URL expires
+func (c *sheetCache) signedURL(name string, now time.Time) string {
+	expires := now.Add(c.expiry).Unix()
+	return fmt.Sprintf("/cache/%s?expires=%d&signature=%s", name, expires, c.sign(name, expires))
+}
+
+func (c *sheetCache) sign(name string, expires int64) string {
+	mac := hmac.New(sha256.New, c.key)
+	fmt.Fprintf(mac, "%s\n%d", name, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handleCache serves a cached sheet if its URL is signed and hasn't expired
+func (c *sheetCache) handleCache(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/cache/")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	signature := r.URL.Query().Get("signature")
+	if err != nil || !hmac.Equal([]byte(signature), []byte(c.sign(name, expires))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "url expired", http.StatusForbidden)
+		return
+	}
+	// names are only signed as they were stored, but a path can't get out of the cache either way
+	if name != filepath.Base(name) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeFile(w, r, filepath.Join(c.dir, name))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSheetCache(t *testing.T) {
+	c, err := newSheetCache(t.TempDir(), "secret", "1h", 0)
+	require.NoError(t, err)
+	name, err := c.store([]byte("jpeg"))
+	require.NoError(t, err)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c.handleCache(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+	url := c.signedURL(name, time.Now())
+	rec := get(url)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "jpeg", rec.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, get(strings.Replace(url, "signature=", "signature=0", 1)).Code, "tampered signature")
+	assert.Equal(t, http.StatusForbidden, get(c.signedURL(name, time.Now().Add(-2*time.Hour))).Code, "expired")
+
+	other, err := newSheetCache(c.dir, "other", "1h", 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, get(other.signedURL(name, time.Now())).Code, "signed with another key")
+}
+
+func TestSheetCachePrune(t *testing.T) {
+	c, err := newSheetCache(t.TempDir(), "secret", "1h", 11)
+	require.NoError(t, err)
+	now := time.Now()
+	stored := func(data string, age time.Duration) string {
+		name, err := c.store([]byte(data))
+		require.NoError(t, err)
+		modTime := now.Add(-age)
+		require.NoError(t, os.Chtimes(filepath.Join(c.dir, name), modTime, modTime))
+		return name
+	}
+	expired := stored("expired", 2*time.Hour)
+	oldest := stored("oldest", 3*time.Minute)
+	older := stored("older", 2*time.Minute)
+	recent := stored("recent", time.Minute)
+	require.NoError(t, os.Mkdir(filepath.Join(c.dir, "jobs"), 0o755))
+
+	c.prune(now)
+	assert.NoFileExists(t, filepath.Join(c.dir, expired))
+	assert.NoFileExists(t, filepath.Join(c.dir, oldest), "over the size limit")
+	assert.FileExists(t, filepath.Join(c.dir, older))
+	assert.FileExists(t, filepath.Join(c.dir, recent))
+	assert.DirExists(t, filepath.Join(c.dir, "jobs"))
+}
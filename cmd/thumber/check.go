@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type checkCmd struct {
+	VideoPath string `arg:"" help:"Path to video"`
+}
+
+func (c checkCmd) Run() error {
+	v, err := thumber.Verify(context.Background(), c.VideoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check video: %w", err)
+	}
+
+	fmt.Printf("duration: %s\n", v.Duration)
+	fmt.Println("readable ranges:")
+	for _, r := range v.Readable {
+		fmt.Printf("  %s\n", r)
+	}
+	if v.Truncated {
+		fmt.Println("truncated: decoding stopped before the reported duration")
+	}
+	if len(v.Errors) > 0 {
+		fmt.Printf("errors (%d):\n", len(v.Errors))
+		for _, e := range v.Errors {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+
+	if !v.OK() {
+		return fmt.Errorf("video has unreadable portions")
+	}
+	return nil
+}
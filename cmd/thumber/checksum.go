@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// sealOutputs writes checksum files and signatures next to each output, as set by --checksum and --sign
+func (a generateCmd) sealOutputs(ctx context.Context, paths []string) error {
+	for _, path := range paths {
+		if path == "-" {
+			continue
+		}
+		if a.Checksum == "sha256" {
+			if err := writeChecksumFile(path); err != nil {
+				return fmt.Errorf("failed to write checksum of %s: %w", path, err)
+			}
+		}
+		if a.Sign != "none" && a.Sign != "" {
+			if err := a.signFile(ctx, path); err != nil {
+				return fmt.Errorf("failed to sign %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeChecksumFile writes path.sha256 in the format of sha256sum, so it can be verified with sha256sum -c
+func writeChecksumFile(path string) error {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	f, err := createOutput(path+".sha256", path, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "%s  %s\n", sum, filepath.Base(path)); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// signFile signs path with minisign into path.minisig, or with cosign into path.sig
+func (a generateCmd) signFile(ctx context.Context, path string) error {
+	if a.SignKey == "" {
+		return fmt.Errorf("--sign requires --sign-key")
+	}
+	var cmd *exec.Cmd
+	switch a.Sign {
+	case "minisign":
+		cmd = exec.CommandContext(ctx, "minisign", "-S", "-s", a.SignKey, "-m", path)
+	case "cosign":
+		cmd = exec.CommandContext(ctx, "cosign", "sign-blob", "--yes", "--key", a.SignKey, "--output-signature", path+".sig", path)
+	default:
+		return fmt.Errorf("unknown signing tool %q", a.Sign)
+	}
+	// both prompt for the key password if there's one
+	cmd.Stdin = os.Stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", a.Sign, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type coverCmd struct {
+	VideoPath   string   `arg:"" help:"Path to an MP4, MOV or MKV video"`
+	OutputPath  string   `short:"o" help:"Output path for the video with cover art. Defaults to $filename.cover.$ext"`
+	InPlace     bool     `help:"Replace the video instead of writing a copy"`
+	At          Duration `default:"10" help:"Timestamp of the poster frame"`
+	Width       int      `default:"1280" help:"Cover width in px"`
+	JPEGQuality int      `name:"quality" default:"90" help:"JPEG quality"`
+}
+
+func (c coverCmd) Run() error {
+	ctx := context.Background()
+
+	if c.InPlace && c.OutputPath != "" {
+		return fmt.Errorf("cannot use --output-path together with --in-place")
+	}
+	if c.OutputPath == "-" {
+		return fmt.Errorf("cannot write video to stdout")
+	}
+	if _, err := thumber.CoverFormat(c.VideoPath); err != nil {
+		return err
+	}
+	at, err := c.At.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	outputPath := c.OutputPath
+	switch {
+	case c.InPlace:
+		outputPath = c.VideoPath
+	case outputPath == "":
+		ext := filepath.Ext(c.VideoPath)
+		outputPath = strings.TrimSuffix(c.VideoPath, ext) + ".cover" + ext
+	}
+
+	frame, err := thumber.ExtractFrame(ctx, c.VideoPath, at, thumber.ThumbOptions{TileWidth: c.Width, TileColumns: 1})
+	if err != nil {
+		return fmt.Errorf("failed to extract poster frame: %w", err)
+	}
+
+	f, err := createOutput(outputPath, c.VideoPath, "cover")
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing: %w", err)
+	}
+	defer f.Close()
+	if info, err := os.Stat(c.VideoPath); err == nil {
+		attrs := defaultFileAttrs()
+		attrs.mode = info.Mode().Perm()
+		f.SetAttrs(attrs)
+	}
+
+	// ffmpeg needs to seek in the output, so it writes to the temporary file by name
+	if err := thumber.EmbedCover(ctx, c.VideoPath, f.Name(), frame.Image, c.JPEGQuality); err != nil {
+		return fmt.Errorf("failed to embed cover: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to save output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "embedded frame at %s as cover art into %s\n", frame.Timestamp, f.Path())
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// datasetCmd exports frames of many videos into a directory with an index of their details, for training data pipelines
+type datasetCmd struct {
+	VideoPaths      []string `arg:"" help:"Paths to videos"`
+	OutputDir       string   `short:"o" required:"" placeholder:"DIR" help:"Directory for the frames and the index, frames of each video go into a directory named after it"`
+	Format          string   `default:"jsonl" enum:"jsonl,csv" help:"Format of the index, one of jsonl, csv"`
+	Tiles           int      `default:"20" help:"How many frames to export from each video, spread evenly"`
+	TileWidth       int      `default:"320" help:"Width of the frames in px"`
+	Scenes          bool     `help:"Detect scenes in an extra pass, and record the scene of each frame"`
+	SceneThreshold  float64  `default:"0.4" help:"Scene change score from 0 to 1 that counts as a cut, lower finds more scenes"`
+	JPEGQuality     int      `name:"quality" default:"90" help:"JPEG quality"`
+	Concurrency     int      `default:"4" help:"How many frames to extract in parallel"`
+	Jitter          Duration `placeholder:"DURATION" help:"Move each frame by a random offset within ±this, at most half the interval"`
+	Seed            *int64   `help:"Seed of random choices such as --jitter, so the dataset is the same on every run"`
+	ContinueOnError bool     `help:"Skip videos that fail instead of stopping"`
+}
+
+// datasetRow describes one exported frame. Paths are relative to the index, so the dataset can be moved.
+type datasetRow struct {
+	File        string `json:"file"`
+	Video       string `json:"video"`
+	TimestampMs int64  `json:"timestamp_ms"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// PHash is the perceptual hash of the frame as 16 hex digits
+	PHash string `json:"phash"`
+	// Scene is the index of the scene from 1, 0 without --scenes
+	Scene int `json:"scene,omitempty"`
+}
+
+func (c datasetCmd) Run(ctx context.Context) error {
+	jitter, err := c.Jitter.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid jitter: %w", err)
+	}
+	opts := thumber.ThumbOptions{
+		TileCount:   c.Tiles,
+		TileWidth:   c.TileWidth,
+		Concurrency: c.Concurrency,
+		Jitter:      jitter,
+		Seed:        c.Seed,
+	}
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if err := os.MkdirAll(c.OutputDir, 0o755); err != nil {
+		return err
+	}
+
+	indexPath := filepath.Join(c.OutputDir, "index."+c.Format)
+	f, err := createOutput(indexPath, "", "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := newDatasetWriter(f, c.Format)
+
+	dirs := map[string]bool{}
+	failed := 0
+	for i, videoPath := range c.VideoPaths {
+		slog.Info("exporting frames", "path", videoPath, "current", i+1, "total", len(c.VideoPaths))
+		rows, err := c.export(ctx, videoPath, datasetDir(videoPath, dirs), opts)
+		if err != nil {
+			if !c.ContinueOnError {
+				return fmt.Errorf("%s: %w", videoPath, err)
+			}
+			slog.Error("failed to export frames", "path", videoPath, "error", err)
+			failed++
+			continue
+		}
+		for _, r := range rows {
+			if err := w.Write(r); err != nil {
+				return fmt.Errorf("failed to write index: %w", err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return err
+	}
+	slog.Info("exported dataset", "videos", len(c.VideoPaths)-failed, "index", indexPath)
+	if failed > 0 {
+		return fmt.Errorf("failed to export %d of %d videos", failed, len(c.VideoPaths))
+	}
+	return nil
+}
+
+// export saves the frames of a video into dir under the output directory, and returns their rows
+func (c datasetCmd) export(ctx context.Context, videoPath, dir string, opts thumber.ThumbOptions) ([]datasetRow, error) {
+	thumbs, err := thumber.MakeThumbnails(ctx, videoPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	var scenes []thumber.Scene
+	if c.Scenes {
+		if scenes, err = thumber.DetectScenes(ctx, videoPath, c.SceneThreshold, thumber.ThumbOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to detect scenes: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.OutputDir, dir), 0o755); err != nil {
+		return nil, err
+	}
+	rows := make([]datasetRow, len(thumbs))
+	for i, th := range thumbs {
+		rel := filepath.Join(dir, fmt.Sprintf("%04d.jpg", i+1))
+		if err := writeJPEG(filepath.Join(c.OutputDir, rel), videoPath, th.Image, c.JPEGQuality); err != nil {
+			return nil, err
+		}
+		rows[i] = datasetRow{
+			File:        filepath.ToSlash(rel),
+			Video:       videoPath,
+			TimestampMs: th.Timestamp.Milliseconds(),
+			Width:       th.Bounds().Dx(),
+			Height:      th.Bounds().Dy(),
+			PHash:       fmt.Sprintf("%016x", thumber.PerceptualHash(th.Image)),
+			Scene:       sceneIndex(scenes, th.Timestamp),
+		}
+	}
+	return rows, nil
+}
+
+// datasetDir names the directory of a video's frames after the video, numbering videos with the same name
+func datasetDir(videoPath string, taken map[string]bool) string {
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	dir := base
+	for n := 2; taken[dir]; n++ {
+		dir = fmt.Sprintf("%s-%d", base, n)
+	}
+	taken[dir] = true
+	return dir
+}
+
+// sceneIndex is the index of the scene containing t from 1, or 0 if there are no scenes
+func sceneIndex(scenes []thumber.Scene, t time.Duration) int {
+	for i, s := range scenes {
+		if t < s.End || i == len(scenes)-1 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+type datasetWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func newDatasetWriter(w io.Writer, format string) *datasetWriter {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"file", "video", "timestamp_ms", "width", "height", "phash", "scene"})
+		return &datasetWriter{csv: cw}
+	}
+	return &datasetWriter{json: json.NewEncoder(w)}
+}
+
+func (w *datasetWriter) Write(r datasetRow) error {
+	if w.json != nil {
+		return w.json.Encode(r)
+	}
+	return w.csv.Write([]string{
+		r.File,
+		r.Video,
+		strconv.FormatInt(r.TimestampMs, 10),
+		strconv.Itoa(r.Width),
+		strconv.Itoa(r.Height),
+		r.PHash,
+		strconv.Itoa(r.Scene),
+	})
+}
+
+func (w *datasetWriter) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
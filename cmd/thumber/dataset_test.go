@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+func TestSceneIndex(t *testing.T) {
+	scenes := []thumber.Scene{{Start: 0, End: 4 * time.Second}, {Start: 4 * time.Second, End: 10 * time.Second}}
+	assert.Equal(t, 1, sceneIndex(scenes, time.Second))
+	assert.Equal(t, 2, sceneIndex(scenes, 4*time.Second))
+	assert.Equal(t, 2, sceneIndex(scenes, 11*time.Second), "frames past the end are in the last scene")
+	assert.Equal(t, 0, sceneIndex(nil, time.Second))
+}
+
+func TestDatasetDir(t *testing.T) {
+	taken := map[string]bool{}
+	assert.Equal(t, "video", datasetDir("a/video.mp4", taken))
+	assert.Equal(t, "video-2", datasetDir("b/video.mkv", taken))
+	assert.Equal(t, "other", datasetDir("other.mp4", taken))
+}
+
+func TestDatasetWriter(t *testing.T) {
+	row := datasetRow{File: "video/0001.jpg", Video: "video.mp4", TimestampMs: 1500, Width: 320, Height: 180, PHash: "00ff00ff00ff00ff", Scene: 2}
+
+	var csv strings.Builder
+	w := newDatasetWriter(&csv, "csv")
+	require.NoError(t, w.Write(row))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "file,video,timestamp_ms,width,height,phash,scene\nvideo/0001.jpg,video.mp4,1500,320,180,00ff00ff00ff00ff,2\n", csv.String())
+
+	var jsonl strings.Builder
+	w = newDatasetWriter(&jsonl, "jsonl")
+	require.NoError(t, w.Write(row))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, `{"file":"video/0001.jpg","video":"video.mp4","timestamp_ms":1500,"width":320,"height":180,"phash":"00ff00ff00ff00ff","scene":2}`+"\n", jsonl.String())
+}
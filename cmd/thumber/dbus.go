@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+const (
+	thumbnailerName = "org.freedesktop.thumbnails.Thumbnailer1"
+	thumbnailerPath = dbus.ObjectPath("/org/freedesktop/thumbnails/Thumbnailer1")
+)
+
+// error codes of the Error signal, as defined by the thumbnail management D-Bus spec
+const (
+	thumbnailerErrUnsupported       = 0
+	thumbnailerErrInvalidFormat     = 2
+	thumbnailerErrSaveFailed        = 4
+	thumbnailerErrUnsupportedFlavor = 5
+)
+
+// thumbnailerMimeTypes are advertised by GetSupported, ffmpeg reads many more
+var thumbnailerMimeTypes = []string{
+	"video/mp4",
+	"video/x-m4v",
+	"video/quicktime",
+	"video/x-matroska",
+	"video/webm",
+	"video/x-msvideo",
+	"video/mpeg",
+	"video/mp2t",
+	"video/x-flv",
+	"video/ogg",
+	"video/3gpp",
+	"video/x-ms-wmv",
+}
+
+type dbusCmd struct {
+	System      bool     `help:"Connect to the system bus instead of the session bus"`
+	At          Duration `default:"10" help:"Timestamp of the thumbnail frame, videos shorter than this use their first frame"`
+	Concurrency int      `default:"2" help:"How many videos to thumbnail in parallel"`
+}
+
+func (c dbusCmd) Run(ctx context.Context) error {
+	at, err := c.At.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+
+	connect := dbus.ConnectSessionBus
+	if c.System {
+		connect = dbus.ConnectSystemBus
+	}
+	conn, err := connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to d-bus: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// ffmpeg processes of a previous thumbnailer that crashed would otherwise keep running
+	go thumber.WatchOrphans(ctx, time.Minute)
+
+	t := &thumbnailer{
+		ctx:       ctx,
+		conn:      conn,
+		at:        at,
+		slots:     make(chan struct{}, c.Concurrency),
+		dequeued:  map[uint32]bool{},
+		nextQueue: 1,
+	}
+	if err := conn.Export(t, thumbnailerPath, thumbnailerName); err != nil {
+		return err
+	}
+	if err := conn.Export(introspect.NewIntrospectable(t.introspection()), thumbnailerPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return err
+	}
+
+	reply, err := conn.RequestName(thumbnailerName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("failed to request name: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s is already provided by another thumbnailer", thumbnailerName)
+	}
+
+	slog.Info("serving thumbnails over d-bus", "name", thumbnailerName)
+	<-ctx.Done()
+	t.wg.Wait()
+	return nil
+}
+
+// thumbnailer implements org.freedesktop.thumbnails.Thumbnailer1,
+// writing thumbnails into the freedesktop.org thumbnail cache
+type thumbnailer struct {
+	ctx   context.Context
+	conn  *dbus.Conn
+	at    time.Duration
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu        sync.Mutex
+	nextQueue uint32
+	dequeued  map[uint32]bool
+}
+
+func (t *thumbnailer) introspection() *introspect.Node {
+	return &introspect.Node{
+		Name: string(thumbnailerPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    thumbnailerName,
+				Methods: introspect.Methods(t),
+				Signals: []introspect.Signal{
+					{Name: "Started", Args: []introspect.Arg{{Name: "handle", Type: "u"}}},
+					{Name: "Finished", Args: []introspect.Arg{{Name: "handle", Type: "u"}}},
+					{Name: "Ready", Args: []introspect.Arg{{Name: "handle", Type: "u"}, {Name: "uris", Type: "as"}}},
+					{Name: "Error", Args: []introspect.Arg{
+						{Name: "handle", Type: "u"},
+						{Name: "failed_uris", Type: "as"},
+						{Name: "error_code", Type: "i"},
+						{Name: "message", Type: "s"},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// Queue schedules thumbnails for the URIs and returns a handle that identifies the request in signals.
+// Schedulers aren't supported, every request is handled in the order it arrives.
+func (t *thumbnailer) Queue(uris, mimeTypes []string, flavor, scheduler string, handleToUnqueue uint32) (uint32, *dbus.Error) {
+	t.mu.Lock()
+	handle := t.nextQueue
+	t.nextQueue++
+	if handleToUnqueue != 0 {
+		t.dequeued[handleToUnqueue] = true
+	}
+	t.mu.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.process(handle, uris, flavor)
+	}()
+	return handle, nil
+}
+
+// Dequeue cancels a queued request, thumbnails already being generated are still written
+func (t *thumbnailer) Dequeue(handle uint32) *dbus.Error {
+	t.mu.Lock()
+	t.dequeued[handle] = true
+	t.mu.Unlock()
+	return nil
+}
+
+// GetSupported returns pairs of URI schemes and MIME types that can be thumbnailed
+func (t *thumbnailer) GetSupported() ([]string, []string, *dbus.Error) {
+	schemes := make([]string, len(thumbnailerMimeTypes))
+	for i := range schemes {
+		schemes[i] = "file"
+	}
+	return schemes, thumbnailerMimeTypes, nil
+}
+
+func (t *thumbnailer) GetSchedulers() ([]string, *dbus.Error) {
+	return []string{"default"}, nil
+}
+
+func (t *thumbnailer) GetFlavors() ([]string, *dbus.Error) {
+	return []string{"normal", "large", "x-large", "xx-large"}, nil
+}
+
+func (t *thumbnailer) isDequeued(handle uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dequeued[handle]
+}
+
+func (t *thumbnailer) emit(signal string, args ...any) {
+	if err := t.conn.Emit(thumbnailerPath, thumbnailerName+"."+signal, args...); err != nil {
+		slog.Warn("failed to emit signal", "signal", signal, "error", err)
+	}
+}
+
+func (t *thumbnailer) process(handle uint32, uris []string, flavor string) {
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	case <-t.ctx.Done():
+		return
+	}
+	if t.isDequeued(handle) {
+		return
+	}
+
+	t.emit("Started", handle)
+	defer t.emit("Finished", handle)
+
+	if _, ok := freedesktopFlavors[flavor]; !ok {
+		t.emit("Error", handle, uris, int32(thumbnailerErrUnsupportedFlavor), fmt.Sprintf("unsupported flavor %q", flavor))
+		return
+	}
+
+	for _, uri := range uris {
+		if t.ctx.Err() != nil || t.isDequeued(handle) {
+			return
+		}
+		code, err := t.thumbnail(uri, flavor)
+		if err != nil {
+			slog.Error("failed to thumbnail", "uri", uri, "error", err)
+			t.emit("Error", handle, []string{uri}, int32(code), err.Error())
+			continue
+		}
+		t.emit("Ready", handle, []string{uri})
+	}
+}
+
+// thumbnail writes the thumbnail of uri, returning the spec's error code on failure
+func (t *thumbnailer) thumbnail(uri, flavor string) (int, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return thumbnailerErrUnsupported, fmt.Errorf("unsupported uri %q, only local files are supported", uri)
+	}
+
+	if _, err := writeFreedesktopThumbs(t.ctx, u.Path, []string{flavor}, thumber.ThumbOptions{From: t.at}); err != nil {
+		var cmdErr *thumber.CommandError
+		if errors.As(err, &cmdErr) {
+			return thumbnailerErrInvalidFormat, err
+		}
+		return thumbnailerErrSaveFailed, err
+	}
+	return 0, nil
+}
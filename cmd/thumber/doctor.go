@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type doctorCmd struct {
+	OutputDir string `default:"." help:"Directory to check write permissions for"`
+}
+
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+func (c doctorCmd) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var env thumber.Environment
+	checks := []doctorCheck{
+		{"ffmpeg", func(ctx context.Context) (string, error) {
+			var err error
+			env, err = thumber.ProbeEnvironment(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("ffmpeg %s, ffprobe %s", env.FfmpegVersion, env.FfprobeVersion), nil
+		}},
+		{"encoders", func(ctx context.Context) (string, error) {
+			if len(env.Encoders) == 0 {
+				return "", fmt.Errorf("no image encoders found")
+			}
+			return joinOrNone(env.Encoders), nil
+		}},
+		{"output dir", func(ctx context.Context) (string, error) {
+			f, err := os.CreateTemp(c.OutputDir, ".thumber-doctor-*")
+			if err != nil {
+				return "", err
+			}
+			f.Close()
+			return c.OutputDir, os.Remove(f.Name())
+		}},
+		{"fonts", func(ctx context.Context) (string, error) {
+			return "bundled font renders", thumber.CheckFonts()
+		}},
+		{"extraction", smokeTest},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		detail, err := check.run(ctx)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %-12s %s\n", check.name, err)
+			if hint := errorHint(err); hint != "" {
+				fmt.Printf("      %-12s hint: %s\n", "", hint)
+			}
+			continue
+		}
+		fmt.Printf("PASS  %-12s %s\n", check.name, detail)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// smokeTest generates a short test clip and extracts a single frame from it
+func smokeTest(ctx context.Context) (string, error) {
+	dir, err := os.MkdirTemp("", "thumber-doctor-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	clip := filepath.Join(dir, "test.mp4")
+	cmd := exec.CommandContext(ctx,
+		"ffmpeg", "-hide_banner", "-v", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=320x240:rate=10",
+		"-pix_fmt", "yuv420p",
+		clip,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", &thumber.CommandError{Command: "ffmpeg", Stderr: string(out), Err: err}
+	}
+
+	thumbs, err := thumber.MakeThumbnails(ctx, clip, thumber.ThumbOptions{
+		TileCount:   1,
+		TileColumns: 1,
+		TileWidth:   160,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(thumbs) != 1 {
+		return "", fmt.Errorf("expected 1 frame, got %d", len(thumbs))
+	}
+	b := thumbs[0].Bounds()
+	return fmt.Sprintf("extracted a %dx%d frame", b.Dx(), b.Dy()), nil
+}
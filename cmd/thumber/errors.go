@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+const (
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiDim    = "\033[2m"
+	ansiReset  = "\033[0m"
+)
+
+// presentError prints a concise error with a hint on how to fix it.
+// Full ffmpeg output is only printed in debug mode.
+func presentError(w io.Writer, err error, debug bool) {
+	color := useColor(w)
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	fmt.Fprintf(w, "%s %s\n", paint(ansiRed, "error:"), err)
+	if hint := errorHint(err); hint != "" {
+		fmt.Fprintf(w, "%s %s\n", paint(ansiYellow, "hint:"), hint)
+	}
+
+	var cmdErr *thumber.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Stderr != "" {
+		if debug {
+			fmt.Fprintf(w, "%s\n%s\n", paint(ansiDim, cmdErr.Command+" output:"), cmdErr.Stderr)
+		} else {
+			fmt.Fprintln(w, paint(ansiDim, fmt.Sprintf("run with --debug to see the full %s output", cmdErr.Command)))
+		}
+	}
+
+	var panicErr *thumber.PanicError
+	if errors.As(err, &panicErr) && debug {
+		fmt.Fprintf(w, "%s\n%s\n", paint(ansiDim, "stack:"), panicErr.Stack)
+	}
+}
+
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, thumber.ErrFfmpegNotFound), errors.Is(err, thumber.ErrFfprobeNotFound):
+		switch runtime.GOOS {
+		case "darwin":
+			return "install ffmpeg: brew install ffmpeg"
+		case "windows":
+			return "install ffmpeg: winget install ffmpeg"
+		default:
+			return "install ffmpeg with your package manager, e.g. apt install ffmpeg"
+		}
+	case errors.Is(err, thumber.ErrTesseractNotFound):
+		switch runtime.GOOS {
+		case "darwin":
+			return "install tesseract: brew install tesseract"
+		case "windows":
+			return "install tesseract: winget install tesseract-ocr.tesseract"
+		default:
+			return "install tesseract with your package manager, e.g. apt install tesseract-ocr"
+		}
+	case errors.Is(err, os.ErrNotExist):
+		return "check that the path exists"
+	case errors.Is(err, os.ErrPermission):
+		return "check file permissions of the video and the output directory"
+	case errors.As(err, new(*thumber.PanicError)):
+		return "this is a bug, please report it along with the output of --debug"
+	}
+	return ""
+}
+
+func useColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
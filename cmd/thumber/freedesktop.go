@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// freedesktopFlavors are the thumbnail cache directories of the freedesktop.org thumbnail spec,
+// with the length of their longest side
+var freedesktopFlavors = map[string]int{
+	"normal":   128,
+	"large":    256,
+	"x-large":  512,
+	"xx-large": 1024,
+}
+
+// freedesktopCacheDir returns $XDG_CACHE_HOME/thumbnails, defaulting to ~/.cache/thumbnails
+func freedesktopCacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "thumbnails"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "thumbnails"), nil
+}
+
+// fileURI builds the file:// URI of path escaped the same way as GLib's g_filename_to_uri,
+// since file managers look thumbnails up by the MD5 of this exact string
+func fileURI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// the characters of the UNSAFE_PATH set of g_escape_uri_string, which unlike RFC 3986 escapes ;
+	const allowed = "-._~!$&'()*+,=:@/"
+	var b strings.Builder
+	b.WriteString("file://")
+	for _, c := range []byte(filepath.ToSlash(abs)) {
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte(allowed, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String(), nil
+}
+
+// freedesktopThumbPath returns where the thumbnail of uri is cached in the given flavor
+func freedesktopThumbPath(cacheDir, flavor, uri string) string {
+	sum := md5.Sum([]byte(uri))
+	return filepath.Join(cacheDir, flavor, hex.EncodeToString(sum[:])+".png")
+}
+
+// writeFreedesktopThumbs extracts a frame at the starting point and caches it in each flavor,
+// returning the path of the last thumbnail written
+func writeFreedesktopThumbs(ctx context.Context, videoPath string, flavors []string, opts thumber.ThumbOptions) (string, error) {
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return "", err
+	}
+	uri, err := fileURI(videoPath)
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := freedesktopCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find thumbnail cache: %w", err)
+	}
+
+	opts.TileWidth, opts.TileHeight = 0, 0
+	frame, err := extractPosterFrame(ctx, videoPath, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract frame: %w", err)
+	}
+
+	text := [][2]string{
+		{"Thumb::URI", uri},
+		{"Thumb::MTime", strconv.FormatInt(info.ModTime().Unix(), 10)},
+		{"Thumb::Size", strconv.FormatInt(info.Size(), 10)},
+		{"Software", "thumber"},
+	}
+
+	var last string
+	for _, flavor := range flavors {
+		size, ok := freedesktopFlavors[flavor]
+		if !ok {
+			return "", fmt.Errorf("unknown thumbnail flavor %q", flavor)
+		}
+		path := freedesktopThumbPath(cacheDir, flavor, uri)
+		img := frame.Image
+		if b := img.Bounds(); b.Dx() > size || b.Dy() > size {
+			img = imaging.Fit(img, size, size, imaging.Lanczos)
+		}
+		if err := writePNGWithText(path, img, text); err != nil {
+			return "", fmt.Errorf("failed to save %s thumbnail: %w", flavor, err)
+		}
+		last = path
+	}
+	return last, nil
+}
+
+// writePNGWithText atomically writes img as PNG with tEXt chunks,
+// with the private permissions the spec asks for
+func writePNGWithText(path string, img image.Image, text [][2]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := insertPNGText(buf.Bytes(), text)
+
+	f, err := createOutput(path, "", "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.SetAttrs(fileAttrs{mode: 0o600, uid: -1, gid: -1})
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// pngHeaderLength is the length of the PNG signature and the IHDR chunk, which must come first
+const pngHeaderLength = 8 + 4 + 4 + 13 + 4
+
+// insertPNGText adds tEXt chunks right after the IHDR chunk of an encoded PNG
+func insertPNGText(data []byte, text [][2]string) []byte {
+	out := append([]byte{}, data[:pngHeaderLength]...)
+	for _, kv := range text {
+		chunk := append([]byte("tEXt"+kv[0]+"\x00"), kv[1]...)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)-4))
+		out = append(out, chunk...)
+		out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(chunk))
+	}
+	return append(out, data[pngHeaderLength:]...)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileURI(t *testing.T) {
+	// as returned by g_filename_to_uri
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/user/video.mp4", "file:///home/user/video.mp4"},
+		{"/home/user/My Videos/a;b#c.mp4", "file:///home/user/My%20Videos/a%3Bb%23c.mp4"},
+		{"/tmp/x+y=z&w$,@:!'()*~_-.mp4", "file:///tmp/x+y=z&w$,@:!'()*~_-.mp4"},
+		{"/tmp/[1] {x}?%^`|\\\"<>.mp4", "file:///tmp/%5B1%5D%20%7Bx%7D%3F%25%5E%60%7C%5C%22%3C%3E.mp4"},
+		{"/tmp/ä.mp4", "file:///tmp/%C3%A4.mp4"},
+	}
+	for _, tt := range tests {
+		uri, err := fileURI(tt.path)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, uri)
+	}
+}
@@ -0,0 +1,1237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+	"github.com/sourcegraph/conc/pool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type generateCmd struct {
+	VideoPath          string   `arg:"" optional:"" help:"Path to video, use - to read from stdin"`
+	FilesFrom          string   `json:"-" placeholder:"PATH" help:"Read video paths from a file, one per line, use - for stdin"`
+	Null               bool     `json:"-" short:"0" help:"Paths in --files-from are separated by null characters, as in find -print0"`
+	JobsFile           string   `json:"-" placeholder:"PATH" help:"Read jobs from a JSON or CSV file, each with an input, output and flag overrides"`
+	Summary            string   `json:"-" placeholder:"PATH" help:"Write a JSON or CSV summary of a batch run with per-file status, timings and errors"`
+	State              string   `json:"-" placeholder:"PATH" help:"Record batch progress in a state file. Defaults to .thumber-state.json with --resume"`
+	Resume             bool     `json:"-" help:"Skip jobs recorded as completed in the state file, if their inputs, options and outputs are unchanged"`
+	PerFileTimeout     Duration `json:"-" placeholder:"DURATION" help:"Give up on a file in a batch after this long e.g. 10m"`
+	Placeholder        bool     `json:"-" help:"Save a grey placeholder with the file name and error in place of sheets that fail in a batch, so galleries don't show broken images"`
+	Deadline           Duration `json:"-" placeholder:"DURATION" help:"Stop a batch after this long e.g. 2h, remaining files are marked as timed out"`
+	OutputPath         string   `short:"o" help:"Output path to save JPEG, use - for stdout. Paths ending in .zip, .tar or .tar.gz bundle the sheet, tiles, manifest and metadata into an archive. Defaults to $filename.thumbs.jpg"`
+	HTML               bool     `name:"html" help:"Also write an HTML page next to the sheet, where clicking a tile opens the video at its timestamp"`
+	LinkTemplate       string   `default:"{uri}#t={seconds}" help:"Link of each tile in --html pages. Placeholders: {path}, {uri}, {seconds}, {ms}, {timestamp} e.g. https://jellyfin.local/web/#/details?id=ID&t={seconds}"`
+	MaxTilesPerSheet   int      `placeholder:"N" help:"Split tiles over several numbered sheets of at most N tiles e.g. $filename.thumbs.001.jpg"`
+	SheetDuration      Duration `placeholder:"DURATION" help:"Split tiles into one sheet per window of this length e.g. 10m, named by range e.g. $filename.thumbs.00-10-00_00-20-00.jpg, for storyboards of long recordings"`
+	TilesDir           string   `placeholder:"DIR" help:"Also save each tile as a separate JPEG in this directory, {name} is replaced with the video name e.g. tiles/{name}"`
+	TilesFormat        string   `default:"jpeg" enum:"jpeg,png16,tiff,exr" help:"Format of --tiles-dir tiles. png16, tiff and exr are encoded by ffmpeg at the resolution of the video in 16-bit or float, for color grading, one of jpeg, png16, tiff, exr"`
+	VTT                bool     `name:"vtt" help:"Also write a WebVTT thumbnail track for video players, as $filename.thumbs.vtt"`
+	SpriteGrid         string   `placeholder:"CxR" help:"Split sheets into sprites of C columns and R rows e.g. 10x10, to stay within texture size limits of players"`
+	Manifest           bool     `help:"Also write a JSON manifest listing the sheets and where each tile is, as $filename.thumbs.json"`
+	HoverClips         bool     `help:"Also write a short animated WebP around each tile into $filename.thumbs.clips, for galleries that play tiles on hover. Implies --manifest"`
+	ClipDuration       Duration `default:"1" help:"Length of each hover clip"`
+	PHash              bool     `name:"phash" help:"Record a perceptual hash of each tile in the --manifest, for duplicate detection"`
+	Features           bool     `help:"Record an 8x8 grayscale feature vector of each tile in the --manifest, for visual search"`
+	Checksum           string   `default:"none" enum:"none,sha256" help:"Write a checksum file next to each output e.g. $filename.thumbs.jpg.sha256, one of none, sha256"`
+	Sign               string   `default:"none" enum:"none,minisign,cosign" help:"Sign each output with minisign or cosign, one of none, minisign, cosign"`
+	SignKey            string   `placeholder:"PATH" help:"Secret key for --sign"`
+	Naming             string   `default:"default" enum:"default,synology,freedesktop" help:"Where to write outputs, one of default (next to the video or --output-path), synology (poster frames in @eaDir for Synology Photos and Video Station), freedesktop (normal and large thumbnails in ~/.cache/thumbnails for Linux file managers)"`
+	PreserveTimes      bool     `help:"Set the modification time of the output to the video's"`
+	Chmod              string   `placeholder:"MODE" help:"Set permissions of the output as octal e.g. 0640. Defaults to 0644"`
+	Chown              string   `placeholder:"USER[:GROUP]" help:"Set owner and group of the output, as names or numeric ids"`
+	From               Duration `help:"Starting point in seconds, 11h22m33s or mm:ss or hh:mm:ss format. Defaults to 10s, or the beginning of videos and ranges shorter than that"`
+	To                 Duration `help:"Stopping point"`
+	Preset             string   `help:"Pick columns and tile width, and a tile count that follows the duration of the video: quick (3 to 9 tiles), standard (8 to 20) or dense (24 to 48). Explicit flags take precedence"`
+	Template           string   `placeholder:"NAME|PATH" help:"Draw sheets from a template of bands above and below the tiles, a JSON or YAML file or one of the shipped templates: dense, quick, standard, titled. Fills in tile count, columns and tile width like a preset"`
+	TileWidth          int      `help:"Tile width in px. Defaults to ${default_tile_width} unless --tile-height or --max-tile-dimension is set"`
+	TileHeight         int      `help:"Tile height in px. The width follows the aspect ratio unless --tile-width is also set"`
+	MaxTileDimension   int      `placeholder:"PX" help:"Keep frames at source resolution when no tile size is set, scaled down so the longer side is at most this"`
+	DimensionMultiple  int      `placeholder:"N" help:"Round tile and sheet sizes to a multiple of N px, e.g. 2 for video encoders that need even dimensions"`
+	Columns            int      `help:"Columns of tile grid. Defaults to ${default_tile_columns}"`
+	IntervalSeconds    int      `help:"Interval between tiles in seconds. Picked from the video duration by default"`
+	AtFrames           []int    `placeholder:"N,..." help:"Extract these frame numbers, counted from 0, instead of spreading tiles over the video e.g. 100,2500,60000"`
+	JPEGQuality        int      `name:"quality" default:"80" help:"JPEG or WebP quality"`
+	Format             []string `placeholder:"FORMAT,..." help:"Encode each sheet in these formats without extracting frames again e.g. jpg,webp, one of jpg, png, webp. Defaults to the extension of --output-path, or jpg"`
+	QualityDarkBoost   int      `placeholder:"N" help:"Raise the quality by N, up to 100, for sheets where at least a quarter of the tiles are dark, so grain in dark scenes doesn't turn to blocks"`
+	TargetSize         ByteSize `placeholder:"SIZE" help:"Maximum output size e.g. 2MB or 500KB, lowers JPEG quality until the sheet fits, down to 10. Only for jpg sheets"`
+	TileAspect         string   `placeholder:"W:H" help:"Derive the tile height from the width, or the other way around, e.g. 16:9, 4:3, 1:1 or source. Frames cover the tiles unless --fit is set"`
+	Fit                string   `help:"How frames fit into tiles when both width and height are set, one of stretch, contain, cover. Defaults to stretch, or cover with --tile-aspect"`
+	PadColor           string   `default:"#000000" help:"Letterbox color for --fit contain as a hex, rgb()/rgba() or named color, or \"blur\" to use a blurred copy of the frame"`
+	Layout             string   `default:"grid" enum:"grid,strip,masonry,chapters" help:"How tiles are arranged, one of grid, strip (a single row), masonry (columns of tiles that keep their aspect ratio), chapters (a grid under a title for each chapter)"`
+	Padding            int      `help:"Padding around tiles in px"`
+	ShortVideoPolicy   string   `default:"spread" enum:"error,shrink,spread" help:"What to do when the interval is longer than the video, one of error, shrink (fit a single row), spread (pick tile count from duration)"`
+	OnOversize         string   `default:"scale" enum:"scale,error" help:"What to do when the sheet exceeds JPEG size limits, one of scale, error"`
+	OverlayTimestamps  bool     `help:"Overlay timestamp on each tile"`
+	TimestampOrigin    string   `default:"absolute" enum:"absolute,relative" help:"What overlaid timestamps are measured from, one of absolute (position in the video), relative (offset from --from)"`
+	Annotations        string   `placeholder:"PATH" help:"Caption tiles from a JSON file mapping timestamps to labels e.g. {\"12:05\": \"goal\"}"`
+	QRMarkers          bool     `name:"qr-markers" help:"Overlay a QR code encoding the frame's presentation time on each tile, so tools can find tiles even after re-encoding"`
+	MotionHeatmap      bool     `help:"Measure motion between tiles in a low resolution pass, and border each tile from blue (still) to red (most motion) to spot active segments"`
+	AudioTimeline      bool     `help:"Draw a strip under the sheet with loudness over time, silent ranges in red and a tick at each tile, to spot missing audio"`
+	Header             bool     `help:"Draw the file name, size, duration, bitrate, codec, resolution, frame rate, and audio and subtitle tracks above the tiles"`
+	Locale             string   `placeholder:"LANG" help:"Language of the header and chapter titles, and the decimal separator of numbers e.g. de or fr-CA, one of en, de, es, fr, it, nl, pt, tr. Defaults to en"`
+	Fonts              []string `name:"font" sep:"none" placeholder:"PATH" help:"TrueType font for characters the bundled font lacks in the header, captions and titles e.g. Hebrew or Arabic. Can be repeated, the first font with a glyph is used"`
+	TextAlign          string   `default:"auto" enum:"auto,left,right" help:"Alignment of the header and chapter titles, one of auto (right for right to left text), left, right"`
+	OverlayBackground  string   `help:"Timestamp background color as a hex, rgb()/rgba() or named color, or \"transparent\" e.g. #FFF59D. Defaults to the badge color of the theme"`
+	Theme              string   `placeholder:"dark|light|PATH" help:"Colors of the canvas, tile borders, text and timestamp badges: dark, light or a YAML file with base, background, border, text, badge and badge_text colors"`
+	Crop               string   `placeholder:"X,Y,W,H" help:"Crop every frame to a region in source frame pixels or percentages, or center:WxH to crop around the center"`
+	OCRTimecode        string   `name:"ocr-timecode" placeholder:"X,Y,W,H" help:"Read the burned-in timecode from this region of each frame with tesseract and record it in the --manifest"`
+	DetailRow          bool     `help:"Render a 100% crop from the center of the frame under each tile"`
+	DetailRegion       string   `placeholder:"X,Y,W,H" help:"Region to render in the detail row instead of the center, implies --detail-row"`
+	DetailHeight       int      `help:"Detail row height in px. Defaults to half the tile width"`
+	BlurRegions        []string `name:"blur-region" sep:"none" placeholder:"X,Y,W,H" help:"Blur a region on every tile, in source frame pixels or percentages e.g. 10%,80%,30%,15%. Can be repeated"`
+	TmpDir             string   `json:"-" placeholder:"DIR" help:"Directory for temporary files, e.g. when reading video from stdin. Defaults to the system temp dir"`
+	MaxTmpSize         ByteSize `json:"-" placeholder:"SIZE" help:"Limit the size of temporary files e.g. 4GB"`
+	MaxDownloadRate    ByteSize `json:"-" placeholder:"SIZE" help:"Limit how fast http, https and s3 inputs are downloaded, in bytes per second e.g. 2MB. Implies --download"`
+	Download           bool     `json:"-" help:"Download http, https and s3 inputs before extracting frames. Otherwise ffmpeg reads only the parts it needs if the server supports range requests"`
+	Concurrency        int      `default:"4" help:"How many frames to extract in parallel"`
+	OverlapFiles       int      `json:"-" default:"1" help:"How many files of a batch are worked on at once, 1 processes files one after the other. With more, the next file is probed while frames of the current one are extracted, and they share --concurrency ffmpeg processes, at the cost of memory for each file in progress"`
+	ProgressFormat     string   `json:"-" default:"text" enum:"text,json" help:"How to report progress, one of text (log lines) or json (newline-delimited events on stdout with the stage, frame, percent and ETA of each file, for GUI wrappers). Logs stay on stderr"`
+	PartialOnInterrupt bool     `json:"-" help:"On the first Ctrl-C, stop extracting and save sheets of the frames extracted so far. Files of a batch that haven't started are skipped, and a second Ctrl-C quits"`
+	ThreadsPerExtract  int      `help:"Limit threads of each ffmpeg process"`
+	MaxMemory          ByteSize `placeholder:"SIZE" help:"Limit memory of each ffmpeg process e.g. 512MB, Linux only"`
+	Nice               int      `help:"Lower ffmpeg's scheduling priority, from 1 to 19, Unix only"`
+	MaxFramePixels     int64    `placeholder:"PIXELS" help:"Refuse to decode frames and cover art larger than this many pixels e.g. 36000000, so hostile inputs can't exhaust memory"`
+	MaxFrameOutput     ByteSize `placeholder:"SIZE" help:"Stop ffmpeg if it writes more than this for a single frame e.g. 64MB"`
+	Seek               string   `default:"accurate" enum:"accurate,fast" help:"How to seek to frames, one of accurate, fast (use the keyframe before each timestamp, much faster for videos with sparse keyframes)"`
+	IO                 string   `name:"io" default:"parallel" enum:"parallel,sequential,mount" help:"How frames of a file are read, one of parallel, sequential (one frame of a file at a time, faster on SMB and NFS shares), mount (one frame at a time from all files on the same mount)"`
+	Variant            string   `placeholder:"lowest|highest|HEIGHT" help:"Rendition of HLS and DASH sources to extract from: lowest, highest, or a height like 540p for the smallest rendition at least that tall. Defaults to highest"`
+	VerifyPTS          Duration `name:"verify-pts" placeholder:"TOLERANCE" help:"Warn about frames decoded further than this from their requested timestamp e.g. 0.5, useful with fast seek"`
+	Intermediate       string   `default:"jpeg" enum:"jpeg,png,ppm" help:"Codec ffmpeg pipes frames in before the sheet is encoded, one of jpeg, png, ppm. png and ppm are lossless, avoiding a second round of JPEG artifacts at the cost of speed or memory"`
+	Fallback           string   `default:"error" enum:"error,cover" help:"What to do when no frames can be extracted, one of error, cover (save the embedded cover art instead, e.g. for audio-only files)"`
+	SkipUnreadable     bool     `help:"Check the video for decode errors first, and move tiles into readable ranges"`
+	DryRun             bool     `json:"-" help:"Print the rows, columns and size of each sheet without extracting frames, fails if a sheet exceeds size limits"`
+	AlignKeyframes     bool     `help:"Move each tile to the keyframe before it, for faster extraction and timestamps that match what players show when seeking"`
+	SnapKeyframes      bool     `help:"Move each tile to the nearest keyframe within a quarter of the interval, keeping tiles evenly spaced where keyframes are sparse. Keyframes are listed in an extra ffprobe pass"`
+	Jitter             Duration `placeholder:"DURATION" help:"Move each tile by a random offset within ±this, at most half the interval e.g. 5s, so episodes of a series don't all show the same recap or title card"`
+	Seed               *int64   `help:"Seed of random choices such as --jitter, so outputs are the same on every run"`
+
+	// events report progress with --progress-format json
+	events *progressEvents
+	// interrupt is closed on the first Ctrl-C with --partial-on-interrupt
+	interrupt <-chan struct{}
+}
+
+func (a generateCmd) Run(ctx context.Context, in *interrupt) error {
+	if a.PartialOnInterrupt {
+		a.interrupt = in.soften()
+	}
+	if a.ProgressFormat == "json" {
+		if a.OutputPath == "-" {
+			return fmt.Errorf("cannot use --progress-format json when writing the sheet to stdout")
+		}
+		a.events = newProgressEvents(os.Stdout)
+	}
+	if a.FilesFrom == "" && a.JobsFile == "" {
+		if a.VideoPath == "" {
+			return fmt.Errorf("expected a video path, --files-from or --jobs-file")
+		}
+		opts, err := a.options()
+		if err != nil {
+			return err
+		}
+		slog.Debug("parsed options", "options", opts)
+
+		videoPath := a.VideoPath
+		if videoPath == "-" {
+			if a.OutputPath == "" {
+				return fmt.Errorf("--output-path is required when reading video from stdin")
+			}
+			ws, err := a.workspace()
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+
+			// ffmpeg needs to seek, so the video is spooled to disk first
+			if videoPath, err = ws.CopyFrom("stdin", os.Stdin); err != nil {
+				return fmt.Errorf("failed to read video from stdin: %w", err)
+			}
+		}
+		if a.DryRun {
+			if thumber.IsRemote(videoPath) {
+				return fmt.Errorf("cannot use --dry-run with a remote input")
+			}
+			return a.dryRun(ctx, videoPath, opts)
+		}
+		if a.Placeholder {
+			return fmt.Errorf("--placeholder only applies in batch mode")
+		}
+		output, err := a.generateInput(ctx, videoPath, a.OutputPath, opts)
+		a.events.finish(videoPath, output, err, 0, 0)
+		return err
+	}
+
+	if a.DryRun {
+		return fmt.Errorf("cannot use --dry-run in batch mode")
+	}
+	if a.VideoPath != "" {
+		return fmt.Errorf("cannot use a video path together with --files-from or --jobs-file")
+	}
+	if a.OutputPath != "" {
+		return fmt.Errorf("cannot use --output-path in batch mode, set outputs in --jobs-file instead")
+	}
+
+	jobs, err := a.batchJobs()
+	if err != nil {
+		return err
+	}
+
+	perFileTimeout, err := a.PerFileTimeout.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid per file timeout: %w", err)
+	}
+	deadline, err := a.Deadline.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid deadline: %w", err)
+	}
+
+	var state *batchState
+	if a.State != "" || a.Resume {
+		path := a.State
+		if path == "" {
+			path = ".thumber-state.json"
+		}
+		state, err = loadBatchState(path)
+		if err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+	}
+	return generateBatch(ctx, jobs, batchOptions{
+		summaryPath:    a.Summary,
+		state:          state,
+		resume:         a.Resume,
+		perFileTimeout: perFileTimeout,
+		deadline:       deadline,
+		overlap:        a.OverlapFiles,
+		workers:        a.Concurrency,
+		events:         a.events,
+	})
+}
+
+func (a generateCmd) workspace() (*thumber.Workspace, error) {
+	maxSize, err := a.MaxTmpSize.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid max tmp size: %w", err)
+	}
+	if n, err := thumber.CleanStaleWorkspaces(a.TmpDir); err != nil {
+		slog.Warn("failed to clean up stale temporary files", "error", err)
+	} else if n > 0 {
+		slog.Info("cleaned up stale temporary files", "count", n)
+	}
+	return thumber.NewWorkspace(a.TmpDir, maxSize)
+}
+
+type batchJob struct {
+	input  string
+	output string
+	cmd    generateCmd
+	opts   thumber.ThumbOptions
+}
+
+// batchJobs resolves and validates the options of every job up front,
+// so a typo in the last job doesn't fail the batch halfway through
+func (a generateCmd) batchJobs() ([]batchJob, error) {
+	var entries []job
+	if a.FilesFrom != "" {
+		paths, err := readPathList(a.FilesFrom, a.Null)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read paths: %w", err)
+		}
+		for _, p := range paths {
+			entries = append(entries, job{Input: p})
+		}
+	}
+	if a.JobsFile != "" {
+		jobs, err := readJobsFile(a.JobsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jobs file: %w", err)
+		}
+		entries = append(entries, jobs...)
+	}
+
+	var jobs []batchJob
+	for i, e := range entries {
+		cmd := a
+		if err := applyOverrides(&cmd, e.Options); err != nil {
+			return nil, fmt.Errorf("job %d (%s): %w", i+1, e.Input, err)
+		}
+		opts, err := cmd.options()
+		if err == nil {
+			err = opts.Validate()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("job %d (%s): %w", i+1, e.Input, err)
+		}
+		jobs = append(jobs, batchJob{input: e.Input, output: e.Output, cmd: cmd, opts: opts})
+	}
+	nameRemoteOutputs(jobs)
+	return jobs, nil
+}
+
+// nameRemoteOutputs sets the outputs of remote inputs without one, which are saved in the working directory
+// under the name of the remote file, so that URLs with the same file name don't overwrite each other,
+// e.g. video-2.thumbs.jpg for the second video.mp4
+func nameRemoteOutputs(jobs []batchJob) {
+	taken := map[string]bool{}
+	for _, j := range jobs {
+		if j.output != "" && j.output != "-" {
+			taken[absPath(j.output)] = true
+		} else if j.output == "" && !thumber.IsRemote(j.input) {
+			taken[absPath(defaultOutputPath(j.input, "thumbs"))] = true
+		}
+	}
+	for i, j := range jobs {
+		if j.output != "" || !thumber.IsRemote(j.input) || (j.cmd.Naming != "default" && j.cmd.Naming != "") {
+			continue
+		}
+		name := thumber.RemoteName(j.input)
+		ext := filepath.Ext(name)
+		output := defaultOutputPath(name, "thumbs")
+		for n := 2; taken[absPath(output)]; n++ {
+			output = defaultOutputPath(fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext), "thumbs")
+		}
+		taken[absPath(output)] = true
+		jobs[i].output = output
+	}
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+type batchOptions struct {
+	summaryPath    string
+	state          *batchState
+	resume         bool
+	perFileTimeout time.Duration
+	deadline       time.Duration
+	// overlap is how many files are worked on at once, sharing workers ffmpeg processes for extraction
+	overlap int
+	workers int
+	// events replace the summary table on stdout with --progress-format json
+	events *progressEvents
+}
+
+func (j batchJob) generate(ctx context.Context, timeout time.Duration) (_ string, err error) {
+	// a bug triggered by one file shouldn't stop the rest of the batch
+	defer thumber.RecoverPanic(&err)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	output, err := j.cmd.generateInput(ctx, j.input, j.output, j.opts)
+	if err != nil && ctx.Err() != nil {
+		// ffmpeg reports being killed rather than the cause
+		return "", fmt.Errorf("%w: %s", ctx.Err(), err)
+	}
+	return output, err
+}
+
+// placeholderWidth and placeholderHeight are the size of placeholders saved for failed jobs
+const (
+	placeholderWidth  = 960
+	placeholderHeight = 540
+)
+
+// writePlaceholder saves a placeholder that shows the error where the sheet would have been saved.
+// A sheet saved by an earlier run is kept, as it's more useful than the placeholder, and no path is returned.
+func (j batchJob) writePlaceholder(cause error) (string, error) {
+	output := j.output
+	if output == "-" || bundleFormat(output) != "" || (j.cmd.Naming != "default" && j.cmd.Naming != "") {
+		return "", fmt.Errorf("cannot save a placeholder for this output")
+	}
+	if output == "" {
+		output = defaultOutputPath(localName(j.input), "thumbs")
+	}
+	formats, err := sheetFormats(j.cmd.Format, output)
+	if err != nil {
+		return "", err
+	}
+	output = withExt(output, formats[0])
+	if _, err := os.Stat(output); err == nil {
+		slog.Info("keeping existing sheet instead of a placeholder", "path", output)
+		return "", nil
+	}
+	img, err := thumber.Placeholder(placeholderWidth, placeholderHeight, filepath.Base(j.input), firstLine(cause.Error()))
+	if err != nil {
+		return "", err
+	}
+	attrs, err := j.cmd.fileAttrs(j.input)
+	if err != nil {
+		// the video may be missing, which is likely why the job failed
+		attrs = defaultFileAttrs()
+	}
+	// the job's context may have timed out, which shouldn't stop the placeholder from being saved
+	return j.cmd.writeSheet(context.Background(), output, j.input, thumber.Sheet{Image: img}, formats[0], 0, attrs)
+}
+
+// checkDistinctOutputs fails if jobs write to stdout or the same file, whose output would interleave
+// when they run at once. Outputs of --naming modes other than default aren't known up front.
+func checkDistinctOutputs(jobs []batchJob) error {
+	seen := map[string]string{}
+	for _, j := range jobs {
+		output := j.output
+		if output == "" {
+			if j.cmd.Naming != "default" && j.cmd.Naming != "" {
+				continue
+			}
+			output = defaultOutputPath(localName(j.input), "thumbs")
+		}
+		if output != "-" {
+			output = absPath(output)
+		}
+		if other, ok := seen[output]; ok {
+			if output == "-" {
+				return fmt.Errorf("%s and %s both write to stdout", other, j.input)
+			}
+			return fmt.Errorf("%s and %s both write to %s", other, j.input, output)
+		}
+		seen[output] = j.input
+	}
+	return nil
+}
+
+func generateBatch(ctx context.Context, jobs []batchJob, bo batchOptions) error {
+	if bo.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bo.deadline)
+		defer cancel()
+	}
+
+	// files overlap, so the next one is probed while frames of the current one are extracted,
+	// and their extraction shares one budget of workers
+	overlap := bo.overlap
+	if overlap < 1 {
+		overlap = 1
+	}
+	if overlap > 1 {
+		if err := checkDistinctOutputs(jobs); err != nil {
+			return fmt.Errorf("cannot overlap files: %w", err)
+		}
+	}
+	workers := thumber.NewWorkers(bo.workers)
+	results := make([]batchResult, len(jobs))
+	p := pool.New().WithMaxGoroutines(overlap)
+	for i, j := range jobs {
+		i, j := i, j
+		j.opts.Workers = workers
+		// blocks until a file finishes, so files start in order
+		p.Go(func() {
+			results[i] = runBatchJob(ctx, j, i, len(jobs), bo)
+		})
+	}
+	p.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == statusFailed || r.Status == statusTimeout {
+			failed++
+		}
+	}
+
+	if bo.events == nil {
+		printSummaryTable(os.Stdout, results)
+	}
+	if bo.summaryPath != "" {
+		if err := writeSummary(bo.summaryPath, results); err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to generate %d of %d contact sheets", failed, len(jobs))
+	}
+	return nil
+}
+
+// runBatchJob generates the contact sheet of the i-th job, or skips it if it's completed, the batch ran out of time
+// or it was interrupted
+func runBatchJob(ctx context.Context, j batchJob, i, total int, bo batchOptions) batchResult {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return batchResult{Input: j.input, Status: statusTimeout, Error: "batch deadline exceeded"}
+	}
+	if ctx.Err() != nil || isClosed(j.cmd.interrupt) {
+		return batchResult{Input: j.input, Status: statusFailed, Error: "interrupted"}
+	}
+
+	var key string
+	if bo.state != nil {
+		var err error
+		if key, err = jobKey(j); err != nil {
+			slog.Warn("cannot track job progress", "path", j.input, "error", err)
+		}
+	}
+	if bo.resume && key != "" {
+		if e, ok := bo.state.Completed(key); ok {
+			slog.Info("skipping completed job", "path", j.input, "output", e.Output)
+			return batchResult{Input: j.input, Output: e.Output, Status: statusSkipped}
+		}
+	}
+
+	slog.Info("generating contact sheet", "path", j.input, "current", i+1, "total", total)
+	bo.events.start(j.input, i+1, total)
+	started := time.Now()
+	output, err := j.generate(ctx, bo.perFileTimeout)
+	bo.events.finish(j.input, output, err, i+1, total)
+	r := batchResult{
+		Input:      j.input,
+		Output:     output,
+		Status:     statusOK,
+		StartedAt:  started,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		slog.Error("failed to generate contact sheet", "path", j.input, "error", err)
+		r.Status = statusFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			r.Status = statusTimeout
+		}
+		r.Error = err.Error()
+		if j.cmd.Placeholder {
+			if r.Output, err = j.writePlaceholder(err); err != nil {
+				slog.Warn("failed to save placeholder", "path", j.input, "error", err)
+			}
+		}
+	} else if key != "" && output != "-" {
+		if err := bo.state.Record(key, j.input, output); err != nil {
+			slog.Warn("failed to save batch state", "error", err)
+		}
+	}
+	return r
+}
+
+func (a generateCmd) options() (thumber.ThumbOptions, error) {
+	from, err := a.From.Duration()
+	if err != nil {
+		return thumber.ThumbOptions{}, fmt.Errorf("invalid from: %w", err)
+	}
+
+	to, err := a.To.Duration()
+	if err != nil {
+		return thumber.ThumbOptions{}, fmt.Errorf("invalid to: %w", err)
+	}
+
+	var overlayBackground color.Color
+	if a.OverlayBackground != "" {
+		if overlayBackground, err = thumber.ParseColor(a.OverlayBackground); err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid overlay background color: %w", err)
+		}
+	}
+
+	var theme thumber.Theme
+	if a.Theme != "" {
+		if theme, err = thumber.LoadTheme(a.Theme); err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+	}
+
+	// an unset fit is left to the library, which picks it based on the tile aspect
+	var fit thumber.Fit
+	if a.Fit != "" {
+		if fit, err = thumber.ParseFit(a.Fit); err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+	}
+	tileAspect, err := thumber.ParseAspectRatio(a.TileAspect)
+	if err != nil {
+		return thumber.ThumbOptions{}, err
+	}
+	locale, err := thumber.ParseLocale(a.Locale)
+	if err != nil {
+		return thumber.ThumbOptions{}, err
+	}
+	var fonts []*truetype.Font
+	for _, path := range a.Fonts {
+		f, err := thumber.LoadFont(path)
+		if err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+		fonts = append(fonts, f)
+	}
+
+	var padColor color.Color
+	padBlur := a.PadColor == "blur"
+	if !padBlur {
+		padColor, err = thumber.ParseColor(a.PadColor)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid pad color: %w", err)
+		}
+	}
+
+	if a.TilesFormat != "jpeg" && a.TilesDir == "" {
+		return thumber.ThumbOptions{}, fmt.Errorf("--tiles-format requires --tiles-dir to save the tiles in")
+	}
+
+	if (a.PHash || a.Features) && !a.Manifest && !a.HoverClips {
+		return thumber.ThumbOptions{}, fmt.Errorf("--phash and --features require --manifest to record them in")
+	}
+
+	var timecodeRegion *thumber.Region
+	if a.OCRTimecode != "" {
+		if !a.Manifest && !a.HoverClips {
+			return thumber.ThumbOptions{}, fmt.Errorf("--ocr-timecode requires --manifest to record the timecodes in")
+		}
+		r, err := thumber.ParseRegion(a.OCRTimecode)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid timecode region: %w", err)
+		}
+		timecodeRegion = &r
+	}
+
+	var annotations []thumber.Annotation
+	if a.Annotations != "" {
+		annotations, err = readAnnotations(a.Annotations)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid annotations: %w", err)
+		}
+	}
+
+	var blurRegions []thumber.Region
+	for _, s := range a.BlurRegions {
+		r, err := thumber.ParseRegion(s)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid blur region: %w", err)
+		}
+		blurRegions = append(blurRegions, r)
+	}
+
+	var crop *thumber.Region
+	if a.Crop != "" {
+		r, err := thumber.ParseRegion(a.Crop)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid crop: %w", err)
+		}
+		crop = &r
+	}
+
+	var detailRegion *thumber.Region
+	if a.DetailRegion != "" {
+		r, err := thumber.ParseRegion(a.DetailRegion)
+		if err != nil {
+			return thumber.ThumbOptions{}, fmt.Errorf("invalid detail region: %w", err)
+		}
+		detailRegion = &r
+	}
+
+	jitter, err := a.Jitter.Duration()
+	if err != nil {
+		return thumber.ThumbOptions{}, fmt.Errorf("invalid jitter: %w", err)
+	}
+	sheetDuration, err := a.SheetDuration.Duration()
+	if err != nil {
+		return thumber.ThumbOptions{}, fmt.Errorf("invalid sheet duration: %w", err)
+	}
+
+	ptsTolerance, err := a.VerifyPTS.Duration()
+	if err != nil {
+		return thumber.ThumbOptions{}, fmt.Errorf("invalid pts tolerance: %w", err)
+	}
+
+	limits, err := a.limits()
+	if err != nil {
+		return thumber.ThumbOptions{}, err
+	}
+
+	opts := thumber.ThumbOptions{
+		From:                from,
+		DefaultFrom:         defaultFrom(a.From),
+		To:                  to,
+		TileColumns:         a.Columns,
+		Interval:            time.Second * time.Duration(a.IntervalSeconds),
+		AtFrames:            a.AtFrames,
+		TileWidth:           a.TileWidth,
+		TileHeight:          a.TileHeight,
+		MaxTileDimension:    a.MaxTileDimension,
+		DimensionMultiple:   a.DimensionMultiple,
+		TileAspect:          tileAspect,
+		Padding:             a.Padding,
+		OverlayTimestamps:   a.OverlayTimestamps,
+		TimestampBackground: overlayBackground,
+		Theme:               theme,
+		TimestampOrigin:     thumber.TimestampOrigin(a.TimestampOrigin),
+		Annotations:         annotations,
+		TimecodeRegion:      timecodeRegion,
+		QRMarkers:           a.QRMarkers,
+		MotionHeatmap:       a.MotionHeatmap,
+		AudioTimeline:       a.AudioTimeline,
+		Header:              a.Header,
+		Locale:              locale,
+		FallbackFonts:       fonts,
+		TextAlign:           thumber.TextAlign(a.TextAlign),
+		BlurRegions:         blurRegions,
+		Crop:                crop,
+		DetailRow:           a.DetailRow || detailRegion != nil,
+		DetailRegion:        detailRegion,
+		DetailHeight:        a.DetailHeight,
+		OnOversize:          thumber.Oversize(a.OnOversize),
+		MaxTilesPerSheet:    a.MaxTilesPerSheet,
+		SheetDuration:       sheetDuration,
+		ShortVideoPolicy:    thumber.ShortVideoPolicy(a.ShortVideoPolicy),
+		Seek:                thumber.SeekMode(a.Seek),
+		IO:                  thumber.IOMode(a.IO),
+		Variant:             thumber.Variant(a.Variant),
+		PTSTolerance:        ptsTolerance,
+		Jitter:              jitter,
+		Seed:                a.Seed,
+		Intermediate:        thumber.Intermediate(a.Intermediate),
+		KeepJPEG:            a.TilesDir != "" && a.TilesFormat == "jpeg",
+		SkipUnreadable:      a.SkipUnreadable,
+		AlignKeyframes:      a.AlignKeyframes,
+		SnapKeyframes:       a.SnapKeyframes,
+		Concurrency:         a.Concurrency,
+		Limits:              limits,
+		Fit:                 fit,
+		PadColor:            padColor,
+		PadBlur:             padBlur,
+	}
+	if a.events != nil {
+		opts.Progress = a.events.progress
+	}
+	opts.Interrupt = a.interrupt
+	switch a.Layout {
+	case "strip":
+		opts.Layout = thumber.StripLayout{}
+	case "masonry":
+		opts.Layout = thumber.MasonryLayout{}
+	}
+	if a.SpriteGrid != "" {
+		if a.Columns != 0 || a.MaxTilesPerSheet != 0 {
+			return thumber.ThumbOptions{}, fmt.Errorf("cannot use --sprite-grid together with --columns or --max-tiles-per-sheet")
+		}
+		columns, rows, err := parseSpriteGrid(a.SpriteGrid)
+		if err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+		opts.TileColumns = columns
+		opts.MaxTilesPerSheet = columns * rows
+	}
+	if a.Preset != "" {
+		preset, err := thumber.ParsePreset(a.Preset)
+		if err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+		if opts, err = preset.Apply(opts); err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+	}
+	if a.Template != "" {
+		t, err := thumber.LoadTemplate(a.Template)
+		if err != nil {
+			return thumber.ThumbOptions{}, err
+		}
+		opts = t.Apply(opts)
+	}
+	return opts, nil
+}
+
+// withChapters reads the chapters of the video for the chapters layout
+func (a generateCmd) withChapters(ctx context.Context, videoPath string, opts thumber.ThumbOptions) (thumber.ThumbOptions, error) {
+	if a.Layout != "chapters" {
+		return opts, nil
+	}
+	chapters, err := thumber.ReadChapters(ctx, videoPath)
+	if err != nil {
+		return opts, fmt.Errorf("failed to read chapters: %w", err)
+	}
+	opts.Layout = thumber.ChapterLayout{Chapters: chapters}
+	return opts, nil
+}
+
+// dryRun prints the geometry of the sheets generate would make
+func (a generateCmd) dryRun(ctx context.Context, videoPath string, opts thumber.ThumbOptions) error {
+	opts, err := a.withChapters(ctx, videoPath, opts)
+	if err != nil {
+		return err
+	}
+	plans, err := thumber.PlanSheets(ctx, videoPath, opts)
+	if err != nil {
+		return err
+	}
+	for i, plan := range plans {
+		fmt.Printf("sheet %d: %d tiles in %d rows and %d columns, %dx%d px", i+1, len(plan.Tiles), plan.Rows, plan.Columns, plan.Size.X, plan.Size.Y)
+		if plan.Scale < 1 {
+			fmt.Printf(", tiles scaled to %.0f%% to fit", plan.Scale*100)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// generateInput generates the outputs of a local file, or of a remote input that ffmpeg reads directly
+// or that's downloaded first
+func (a generateCmd) generateInput(ctx context.Context, input, outputPath string, opts thumber.ThumbOptions) (string, error) {
+	if !thumber.IsRemote(input) {
+		return a.generate(ctx, input, outputPath, opts)
+	}
+	if thumber.IsAdaptive(input) {
+		// ffmpeg fetches the segments it needs, of the variant picked by --variant
+		if outputPath == "" {
+			outputPath = defaultOutputPath(localName(input), "thumbs")
+		}
+		return a.generate(ctx, input, outputPath, opts)
+	}
+	if a.Naming != "default" && a.Naming != "" {
+		return "", fmt.Errorf("cannot use --naming %s with a remote input", a.Naming)
+	}
+	rate, err := a.MaxDownloadRate.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("invalid max download rate: %w", err)
+	}
+	// the modification time and metadata of bundles need a file
+	needsFile := a.Download || rate > 0 || a.PreserveTimes || bundleFormat(outputPath) != ""
+	if !needsFile && thumber.CanStream(ctx, input) {
+		src, err := thumber.RemoteURL(input)
+		if err != nil {
+			return "", err
+		}
+		if outputPath == "" {
+			outputPath = defaultOutputPath(localName(input), "thumbs")
+		}
+		slog.Debug("reading video with range requests", "url", input)
+		return a.generate(ctx, src, outputPath, opts)
+	}
+	ws, err := a.workspace()
+	if err != nil {
+		return "", err
+	}
+	defer ws.Close()
+
+	// outputs go to the working directory, as if the video were there
+	if outputPath == "" {
+		outputPath = defaultOutputPath(localName(input), "thumbs")
+	}
+	slog.Info("downloading video", "url", input)
+	started := time.Now()
+	videoPath, err := thumber.Download(ctx, ws, input, thumber.DownloadOptions{
+		MaxBytesPerSecond: rate,
+		Progress: func(read, total int64) {
+			args := []any{"url", input, "bytes", read, "bytes_per_second", int64(float64(read) / time.Since(started).Seconds())}
+			if total > 0 {
+				args = append(args, "percent", read*100/total)
+			}
+			slog.Info("download progress", args...)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return a.generate(ctx, videoPath, outputPath, opts)
+}
+
+// localName is the input, or the file name of a remote input, which outputs are named after
+func localName(input string) string {
+	if thumber.IsRemote(input) {
+		return thumber.RemoteName(input)
+	}
+	return input
+}
+
+// generate makes a contact sheet for the video and returns where it was saved
+func (a generateCmd) generate(ctx context.Context, videoPath, outputPath string, opts thumber.ThumbOptions) (string, error) {
+	if a.Naming != "default" && a.Naming != "" && outputPath != "" {
+		return "", fmt.Errorf("cannot set an output path with --naming %s", a.Naming)
+	}
+	sealed := a.Checksum == "sha256" || (a.Sign != "none" && a.Sign != "")
+	switch a.Naming {
+	case "synology", "freedesktop":
+		if sealed {
+			return "", fmt.Errorf("cannot use --checksum or --sign with --naming %s", a.Naming)
+		}
+		if a.Naming == "synology" {
+			return a.generateSynology(ctx, videoPath, opts)
+		}
+		return writeFreedesktopThumbs(ctx, videoPath, []string{"normal", "large"}, opts)
+	}
+	if sealed && outputPath == "-" {
+		return "", fmt.Errorf("cannot use --checksum or --sign when writing to stdout")
+	}
+
+	if bundleFormat(outputPath) != "" {
+		path, err := a.generateBundle(ctx, videoPath, outputPath, opts)
+		if err != nil {
+			return "", err
+		}
+		return path, a.sealOutputs(ctx, []string{path})
+	}
+
+	targetSize, err := a.TargetSize.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("invalid target size: %w", err)
+	}
+
+	formats, err := sheetFormats(a.Format, outputPath)
+	if err != nil {
+		return "", err
+	}
+	if targetSize > 0 {
+		// only JPEG quality is searched, png is lossless and webp goes through ffmpeg
+		for _, format := range formats {
+			if format != "jpg" {
+				return "", fmt.Errorf("--target-size only applies to jpg sheets, not %s", format)
+			}
+		}
+	}
+
+	if outputPath == "-" && (a.HTML || a.Manifest || a.VTT || a.HoverClips) {
+		return "", fmt.Errorf("cannot write --html pages, --vtt, --manifest or --hover-clips when writing to stdout")
+	}
+	clipDuration, err := a.ClipDuration.Duration()
+	if err != nil {
+		return "", fmt.Errorf("invalid clip duration: %w", err)
+	}
+
+	if opts, err = a.withChapters(ctx, videoPath, opts); err != nil {
+		return "", err
+	}
+
+	started := time.Now()
+	sheets, err := thumber.GenerateSheets(ctx, videoPath, opts)
+	if err != nil {
+		if sheets, err = a.fallback(ctx, videoPath, err); err != nil {
+			return "", fmt.Errorf("failed to generate thumbnails: %w", err)
+		}
+	}
+	if outputPath == "-" && len(sheets) > 1 {
+		return "", fmt.Errorf("cannot write %d sheets to stdout, raise --max-tiles-per-sheet or set --output-path", len(sheets))
+	}
+	if outputPath == "-" && sheets[0].Window != (thumber.TimeRange{}) {
+		return "", fmt.Errorf("cannot write sheets named by range to stdout, set --output-path")
+	}
+	if outputPath == "" {
+		outputPath = defaultOutputPath(videoPath, "thumbs")
+	}
+
+	attrs, err := a.fileAttrs(videoPath)
+	if err != nil {
+		return "", err
+	}
+
+	// paths are the sheets, clips the hover clips of all tiles, outputs are everything written
+	var paths, clips, outputs []string
+	tileCount := 0
+	for i, sheet := range sheets {
+		path := outputPath
+		switch {
+		case sheet.Window != thumber.TimeRange{}:
+			path = windowPath(outputPath, sheet.Window)
+		case len(sheets) > 1:
+			path = pagePath(outputPath, i+1)
+		}
+		// the first format is what html pages, vtt and the manifest point to
+		for j, format := range formats {
+			formatPath, err := a.writeSheet(ctx, withExt(path, format), videoPath, sheet, format, targetSize, attrs)
+			if err != nil {
+				return "", err
+			}
+			if j == 0 {
+				paths = append(paths, formatPath)
+			}
+			outputs = append(outputs, formatPath)
+		}
+		path = paths[i]
+
+		if a.TilesDir != "" {
+			tilesDir := strings.ReplaceAll(a.TilesDir, "{name}", strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath)))
+			var tiles []string
+			if a.TilesFormat == "jpeg" {
+				tiles, err = thumber.SaveTiles(tilesDir, sheet.Tiles, opts, a.JPEGQuality, tileCount+1)
+			} else {
+				tiles, err = thumber.SaveRawTiles(ctx, videoPath, tilesDir, sheet.Tiles, opts, thumber.RawFormat(a.TilesFormat), tileCount+1)
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to save tiles: %w", err)
+			}
+			outputs = append(outputs, tiles...)
+		}
+
+		if a.HoverClips {
+			clipsDir := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".clips"
+			sheetClips, err := thumber.SaveClips(ctx, videoPath, clipsDir, sheet.Tiles, opts, thumber.ClipOptions{Duration: clipDuration}, tileCount+1)
+			if err != nil {
+				return "", fmt.Errorf("failed to save hover clips: %w", err)
+			}
+			clips = append(clips, sheetClips...)
+			outputs = append(outputs, sheetClips...)
+		}
+		tileCount += len(sheet.Tiles)
+
+		if a.HTML {
+			htmlPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".html"
+			if err := writeImageMap(htmlPath, path, videoPath, a.LinkTemplate, sheet); err != nil {
+				return "", fmt.Errorf("failed to write html: %w", err)
+			}
+			outputs = append(outputs, htmlPath)
+		}
+	}
+
+	if a.VTT {
+		vttPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".vtt"
+		if err := writeVTT(vttPath, videoPath, paths, sheets); err != nil {
+			return "", fmt.Errorf("failed to write vtt: %w", err)
+		}
+		outputs = append(outputs, vttPath)
+	}
+	if a.Manifest || a.HoverClips {
+		manifestPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".json"
+		if err := writeManifest(manifestPath, videoPath, paths, sheets, manifestExtras{Clips: clips, PHash: a.PHash, Features: a.Features}); err != nil {
+			return "", fmt.Errorf("failed to write manifest: %w", err)
+		}
+		outputs = append(outputs, manifestPath)
+	}
+	if err := a.sealOutputs(ctx, outputs); err != nil {
+		return "", err
+	}
+
+	frames := 0
+	for _, sheet := range sheets {
+		frames += len(sheet.Tiles)
+	}
+	slog.Info("generated contact sheet",
+		"stage", "total",
+		"duration_ms", time.Since(started).Milliseconds(),
+		"path", videoPath,
+		"output", paths[0],
+		"sheets", len(sheets),
+		"frames", frames,
+	)
+	return paths[0], nil
+}
+
+// limits constrain the ffmpeg processes and the frames they output
+func (a generateCmd) limits() (thumber.ProcessLimits, error) {
+	maxMemory, err := a.MaxMemory.Bytes()
+	if err != nil {
+		return thumber.ProcessLimits{}, fmt.Errorf("invalid max memory: %w", err)
+	}
+	maxOutput, err := a.MaxFrameOutput.Bytes()
+	if err != nil {
+		return thumber.ProcessLimits{}, fmt.Errorf("invalid max frame output: %w", err)
+	}
+	if a.MaxFramePixels < 0 {
+		return thumber.ProcessLimits{}, fmt.Errorf("max frame pixels can't be negative")
+	}
+	return thumber.ProcessLimits{
+		Threads:        a.ThreadsPerExtract,
+		MemoryBytes:    maxMemory,
+		Nice:           a.Nice,
+		MaxFramePixels: a.MaxFramePixels,
+		MaxOutputBytes: maxOutput,
+	}, nil
+}
+
+// fallback saves the embedded cover art in place of the sheet with --fallback cover, or returns err
+func (a generateCmd) fallback(ctx context.Context, videoPath string, err error) ([]thumber.Sheet, error) {
+	if a.Fallback != "cover" || errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	// the limits are validated along with the options
+	limits, _ := a.limits()
+	cover, coverErr := thumber.ReadCoverArt(ctx, videoPath, limits)
+	if coverErr != nil {
+		slog.Debug("failed to read cover art", "path", videoPath, "error", coverErr)
+		return nil, err
+	}
+	slog.Warn("failed to extract frames, using cover art instead", "path", videoPath, "error", err)
+	return []thumber.Sheet{{Image: cover}}, nil
+}
+
+// pagePath numbers the path of a sheet split over several pages, e.g. video.thumbs.002.jpg
+func pagePath(path string, page int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(path, ext), page, ext)
+}
+
+// windowPath names the sheet of a window by its range, e.g. movie.thumbs.00-10-00_00-20-00.jpg
+func windowPath(path string, window thumber.TimeRange) string {
+	ext := filepath.Ext(path)
+	format := func(t time.Duration) string { return strings.ReplaceAll(thumber.FormatDuration(t), ":", "-") }
+	return fmt.Sprintf("%s.%s_%s%s", strings.TrimSuffix(path, ext), format(window.Start), format(window.End), ext)
+}
+
+// sheetFormats lists the formats to encode each sheet in, picked from the output extension if none are given
+func sheetFormats(formats []string, outputPath string) ([]string, error) {
+	if len(formats) == 0 {
+		format := strings.ToLower(strings.TrimPrefix(filepath.Ext(outputPath), "."))
+		if format == "jpeg" || !isSheetFormat(format) {
+			format = "jpg"
+		}
+		return []string{format}, nil
+	}
+	if outputPath == "-" && len(formats) > 1 {
+		return nil, fmt.Errorf("cannot write %d formats to stdout", len(formats))
+	}
+	var out []string
+	for _, format := range formats {
+		format = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if format == "jpeg" {
+			format = "jpg"
+		}
+		if !isSheetFormat(format) {
+			return nil, fmt.Errorf("invalid format %q, expected one of jpg, png, webp", format)
+		}
+		if slices.Contains(out, format) {
+			continue
+		}
+		out = append(out, format)
+	}
+	return out, nil
+}
+
+func isSheetFormat(format string) bool {
+	switch format {
+	case "jpg", "png", "webp":
+		return true
+	}
+	return false
+}
+
+// withExt replaces the extension of path with the format's, keeping .jpeg for jpg. Stdout stays as is
+func withExt(path, format string) string {
+	if path == "-" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	if format == "jpg" && strings.EqualFold(ext, ".jpeg") {
+		return path
+	}
+	return strings.TrimSuffix(path, ext) + "." + format
+}
+
+// writeSheet encodes the sheet in the format, JPEG within the target size if set, and returns where it was saved
+func (a generateCmd) writeSheet(ctx context.Context, path, videoPath string, sheet thumber.Sheet, format string, targetSize int64, attrs fileAttrs) (_ string, err error) {
+	_, span := tracer.Start(ctx, "thumber.encode")
+	span.SetAttributes(attribute.String("format", format))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	f, err := createOutput(path, videoPath, "thumbs")
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for writing: %w", err)
+	}
+	defer f.Close()
+	f.SetAttrs(attrs)
+
+	quality := thumber.BoostDarkQuality(sheet, a.JPEGQuality, a.QualityDarkBoost)
+
+	switch {
+	case format == "png":
+		defer trace.StartRegion(ctx, "encode").End()
+		if err := png.Encode(f, sheet); err != nil {
+			return "", fmt.Errorf("failed to encode as png: %w", err)
+		}
+	case format == "webp":
+		out, err := thumber.EncodeWebP(ctx, sheet, quality)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode as webp: %w", err)
+		}
+		if _, err := f.Write(out); err != nil {
+			return "", fmt.Errorf("failed to write output: %w", err)
+		}
+	case targetSize == 0:
+		defer trace.StartRegion(ctx, "encode").End()
+		if err := jpeg.Encode(f, sheet, &jpeg.Options{Quality: quality}); err != nil {
+			return "", fmt.Errorf("failed to encode as jpeg: %w", err)
+		}
+	default:
+		out, quality, err := thumber.EncodeJPEGWithMaxSize(sheet, targetSize, quality)
+		if err != nil {
+			return "", err
+		}
+		slog.Debug("picked jpeg quality for target size", "quality", quality, "bytes", len(out))
+		if _, err := f.Write(out); err != nil {
+			return "", fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("failed to save output: %w", err)
+	}
+	return f.Path(), nil
+}
+
+// fileAttrs picks the permissions, owner and modification time for the output of videoPath
+func (a generateCmd) fileAttrs(videoPath string) (fileAttrs, error) {
+	attrs := defaultFileAttrs()
+	if a.Chmod != "" {
+		mode, err := parseFileMode(a.Chmod)
+		if err != nil {
+			return fileAttrs{}, fmt.Errorf("invalid chmod: %w", err)
+		}
+		attrs.mode = mode
+	}
+	if a.Chown != "" {
+		uid, gid, err := parseOwner(a.Chown)
+		if err != nil {
+			return fileAttrs{}, fmt.Errorf("invalid chown: %w", err)
+		}
+		attrs.uid, attrs.gid = uid, gid
+	}
+	if a.PreserveTimes {
+		info, err := os.Stat(videoPath)
+		if err != nil {
+			return fileAttrs{}, fmt.Errorf("failed to read video modification time: %w", err)
+		}
+		attrs.modTime = info.ModTime()
+	}
+	return attrs, nil
+}
+
+// readPathList reads newline or null separated paths from a file, or stdin if path is "-"
+func readPathList(path string, null bool) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := "\n"
+	if null {
+		sep = "\x00"
+	}
+	var paths []string
+	for _, p := range strings.Split(string(data), sep) {
+		if !null {
+			p = strings.TrimRight(p, "\r")
+		}
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
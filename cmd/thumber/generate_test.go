@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+func TestSheetFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		formats    []string
+		outputPath string
+		want       []string
+		err        string
+	}{
+		{name: "default", want: []string{"jpg"}},
+		{name: "from extension", outputPath: "sheet.PNG", want: []string{"png"}},
+		{name: "jpeg extension", outputPath: "sheet.jpeg", want: []string{"jpg"}},
+		{name: "unknown extension", outputPath: "sheet.thumb", want: []string{"jpg"}},
+		{name: "several", formats: []string{"jpeg", " webp", "jpg"}, outputPath: "sheet.jpg", want: []string{"jpg", "webp"}},
+		{name: "unknown format", formats: []string{"gif"}, err: `invalid format "gif"`},
+		{name: "several to stdout", formats: []string{"jpg", "webp"}, outputPath: "-", err: "cannot write 2 formats to stdout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sheetFormats(tt.formats, tt.outputPath)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithExt(t *testing.T) {
+	assert.Equal(t, "dir/video.thumbs.webp", withExt("dir/video.thumbs.jpg", "webp"))
+	assert.Equal(t, "video.thumbs.002.png", withExt("video.thumbs.002.jpg", "png"))
+	assert.Equal(t, "video.jpeg", withExt("video.jpeg", "jpg"))
+	assert.Equal(t, "-", withExt("-", "png"))
+}
+
+func TestWindowPath(t *testing.T) {
+	window := thumber.TimeRange{Start: 10 * time.Minute, End: 20 * time.Minute}
+	assert.Equal(t, "dir/video.thumbs.00-10-00_00-20-00.jpg", windowPath("dir/video.thumbs.jpg", window))
+}
+
+func TestWritePlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	j := batchJob{input: filepath.Join(dir, "broken.mp4"), cmd: generateCmd{JPEGQuality: 80}}
+
+	path, err := j.writePlaceholder(errors.New("moov atom not found"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "broken.thumbs.jpg"), path)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	cfg, err := jpeg.DecodeConfig(f)
+	require.NoError(t, err)
+	assert.Equal(t, placeholderWidth, cfg.Width)
+
+	sheet := filepath.Join(dir, "video.thumbs.jpg")
+	require.NoError(t, os.WriteFile(sheet, []byte("sheet"), 0o644))
+	j.input = filepath.Join(dir, "video.mp4")
+	path, err = j.writePlaceholder(errors.New("moov atom not found"))
+	require.NoError(t, err)
+	assert.Empty(t, path)
+	data, err := os.ReadFile(sheet)
+	require.NoError(t, err)
+	assert.Equal(t, "sheet", string(data), "a sheet of an earlier run is kept")
+
+	j.output = "-"
+	_, err = j.writePlaceholder(errors.New("moov atom not found"))
+	assert.Error(t, err, "stdout is reserved for sheets")
+}
+
+func TestGenerateBatchKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	var jobs []batchJob
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4", "e.mp4"} {
+		jobs = append(jobs, batchJob{input: filepath.Join(dir, name)})
+	}
+	summary := filepath.Join(dir, "summary.json")
+	err := generateBatch(context.Background(), jobs, batchOptions{summaryPath: summary, overlap: 3, workers: 2})
+	assert.ErrorContains(t, err, "failed to generate 5 of 5 contact sheets")
+
+	data, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	var results []batchResult
+	require.NoError(t, json.Unmarshal(data, &results))
+	require.Len(t, results, 5)
+	for i, r := range results {
+		assert.Equal(t, jobs[i].input, r.Input, "results are in the order of the jobs")
+		assert.Equal(t, statusFailed, r.Status)
+	}
+}
+
+func TestCheckDistinctOutputs(t *testing.T) {
+	jobs := []batchJob{{input: "a.mp4"}, {input: "b.mp4"}, {input: "c.mp4", output: "c.jpg"}}
+	assert.NoError(t, checkDistinctOutputs(jobs))
+
+	err := checkDistinctOutputs(append(jobs, batchJob{input: "d.mp4", output: "-"}, batchJob{input: "e.mp4", output: "-"}))
+	assert.ErrorContains(t, err, "d.mp4 and e.mp4 both write to stdout")
+	err = checkDistinctOutputs(append(jobs, batchJob{input: "d.mp4", output: "a.thumbs.jpg"}))
+	assert.ErrorContains(t, err, "a.mp4 and d.mp4 both write to")
+	err = checkDistinctOutputs([]batchJob{{input: "https://a.example.com/video.mp4"}, {input: "https://b.example.com/video.mp4"}})
+	assert.Error(t, err, "remote inputs are saved under their names")
+
+	dir := t.TempDir()
+	err = generateBatch(context.Background(), []batchJob{{input: filepath.Join(dir, "a.mp4"), output: "-"}, {input: filepath.Join(dir, "b.mp4"), output: "-"}}, batchOptions{overlap: 2})
+	assert.ErrorContains(t, err, "cannot overlap files")
+}
+
+func TestNameRemoteOutputs(t *testing.T) {
+	jobs := []batchJob{
+		{input: "video.mp4"},
+		{input: "https://a.example.com/video.mp4"},
+		{input: "https://b.example.com/video.mp4?token=x"},
+		{input: "s3://bucket/video.mp4", output: "-"},
+		{input: "https://c.example.com/other.mp4"},
+		{input: "https://d.example.com/clip.mp4"},
+		{input: "e.mp4", output: "clip.thumbs.jpg"},
+	}
+	nameRemoteOutputs(jobs)
+	var outputs []string
+	for _, j := range jobs {
+		outputs = append(outputs, j.output)
+	}
+	assert.Equal(t, []string{"", "video-2.thumbs.jpg", "video-3.thumbs.jpg", "-", "other.thumbs.jpg", "clip-2.thumbs.jpg", "clip.thumbs.jpg"}, outputs)
+	assert.NoError(t, checkDistinctOutputs(jobs[:3]))
+}
+
+func TestGenerateBatchSkipsWhenInterrupted(t *testing.T) {
+	in := &interrupt{}
+	soft := in.soften()
+	assert.True(t, in.softInterrupt())
+	assert.False(t, in.softInterrupt(), "only the first interrupt is soft")
+
+	dir := t.TempDir()
+	jobs := []batchJob{
+		{input: filepath.Join(dir, "a.mp4"), cmd: generateCmd{interrupt: soft}},
+		{input: filepath.Join(dir, "b.mp4"), cmd: generateCmd{interrupt: soft}},
+	}
+	summary := filepath.Join(dir, "summary.json")
+	err := generateBatch(context.Background(), jobs, batchOptions{summaryPath: summary, workers: 1})
+	assert.ErrorContains(t, err, "failed to generate 2 of 2 contact sheets")
+
+	data, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	var results []batchResult
+	require.NoError(t, json.Unmarshal(data, &results))
+	for _, r := range results {
+		assert.Equal(t, "interrupted", r.Error)
+	}
+}
+
+func TestProgressEvents(t *testing.T) {
+	var nilEvents *progressEvents
+	nilEvents.finish("video.mp4", "", nil, 0, 0)
+
+	var buf strings.Builder
+	events := newProgressEvents(&buf)
+	events.progress(thumber.Progress{Path: "video.mp4", Stage: "extract", Frame: 3, Frames: 20, Percent: 13.5333, ETA: 1500 * time.Millisecond})
+	events.finish("video.mp4", "video.thumbs.jpg", nil, 1, 2)
+	events.finish("other.mp4", "", errors.New("no video stream"), 2, 2)
+	assert.Equal(t, `{"event":"progress","path":"video.mp4","stage":"extract","frame":3,"frames":20,"percent":13.5,"eta_ms":1500}
+{"event":"done","path":"video.mp4","file":1,"files":2,"percent":100,"output":"video.thumbs.jpg"}
+{"event":"failed","path":"other.mp4","file":2,"files":2,"percent":0,"error":"no video stream"}
+`, buf.String())
+}
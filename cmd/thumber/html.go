@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+var imageMapTemplate = template.Must(template.New("map").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>body { margin: 0; background: #000; } img { display: block; max-width: none; }</style>
+</head>
+<body>
+<img src="{{.Image}}" alt="{{.Title}}" width="{{.Width}}" height="{{.Height}}" usemap="#tiles">
+<map name="tiles">
+{{- range .Areas}}
+<area shape="rect" coords="{{.Coords}}" href="{{.Href}}" title="{{.Title}}" alt="{{.Title}}">
+{{- end}}
+</map>
+</body>
+</html>
+`))
+
+type imageMapArea struct {
+	Coords string
+	Href   template.URL
+	Title  string
+}
+
+// linkTarget fills in the placeholders of a link template for a tile at t:
+// {path} and {uri} for the video, {seconds}, {ms} and {timestamp} for the position
+func linkTarget(tmpl, videoPath string, t time.Duration) (string, error) {
+	abs, err := filepath.Abs(videoPath)
+	if err != nil {
+		return "", err
+	}
+	uri, err := fileURI(abs)
+	if err != nil {
+		return "", err
+	}
+	return strings.NewReplacer(
+		"{path}", abs,
+		"{uri}", uri,
+		"{seconds}", strconv.Itoa(int(t.Seconds())),
+		"{ms}", strconv.FormatInt(t.Milliseconds(), 10),
+		"{timestamp}", thumber.FormatDuration(t.Round(time.Second)),
+	).Replace(tmpl), nil
+}
+
+// writeImageMap writes an HTML page showing the sheet at sheetPath, where clicking a tile follows linkTemplate
+func writeImageMap(htmlPath, sheetPath, videoPath, linkTemplate string, sheet thumber.Sheet) error {
+	src, err := filepath.Rel(filepath.Dir(htmlPath), sheetPath)
+	if err != nil {
+		return err
+	}
+
+	data := struct {
+		Title  string
+		Image  string
+		Width  int
+		Height int
+		Areas  []imageMapArea
+	}{
+		Title:  filepath.Base(videoPath),
+		Image:  filepath.ToSlash(src),
+		Width:  sheet.Bounds().Dx(),
+		Height: sheet.Bounds().Dy(),
+	}
+	for _, tile := range sheet.Tiles {
+		href, err := linkTarget(linkTemplate, videoPath, tile.Timestamp)
+		if err != nil {
+			return err
+		}
+		r := tile.Rect
+		data.Areas = append(data.Areas, imageMapArea{
+			Coords: fmt.Sprintf("%d,%d,%d,%d", r.Min.X, r.Min.Y, r.Max.X, r.Max.Y),
+			// templates may produce any scheme, e.g. file:// or an app deep link, which the user asked for
+			Href:  template.URL(href),
+			Title: thumber.FormatDuration(tile.Timestamp.Round(time.Second)),
+		})
+	}
+
+	f, err := createOutput(htmlPath, videoPath, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := imageMapTemplate.Execute(f, data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
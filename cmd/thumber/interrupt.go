@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/exp/slog"
+)
+
+// interrupt cancels the command on SIGINT or SIGTERM, so ffmpeg processes are killed and temporary files removed
+// as it unwinds. A command can ask for a softer first interrupt with soften, e.g. to save the frames it has,
+// and the next interrupt cancels it anyway. Once canceled, another interrupt quits right away.
+type interrupt struct {
+	cancel  context.CancelFunc
+	signals chan os.Signal
+
+	mu   sync.Mutex
+	soft chan struct{}
+}
+
+// handleInterrupts returns a context that's canceled on interrupt, and stops handling them when stop is called
+func handleInterrupts(ctx context.Context) (context.Context, *interrupt, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	in := &interrupt{cancel: cancel, signals: make(chan os.Signal, 1)}
+	signal.Notify(in.signals, os.Interrupt, syscall.SIGTERM)
+	go in.wait(ctx)
+	return ctx, in, func() {
+		signal.Stop(in.signals)
+		cancel()
+	}
+}
+
+func (in *interrupt) wait(ctx context.Context) {
+	for {
+		select {
+		case <-in.signals:
+		case <-ctx.Done():
+			return
+		}
+		if in.softInterrupt() {
+			slog.Warn("interrupted, finishing with what's done so far, interrupt again to quit")
+			continue
+		}
+		slog.Warn("interrupted, cleaning up, interrupt again to quit right away")
+		signal.Stop(in.signals)
+		in.cancel()
+		return
+	}
+}
+
+// softInterrupt closes the soft interrupt channel if there's one that isn't closed yet
+func (in *interrupt) softInterrupt() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.soft == nil {
+		return false
+	}
+	if isClosed(in.soft) {
+		return false
+	}
+	close(in.soft)
+	return true
+}
+
+// isClosed reports whether ch is closed, and is false for nil channels
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// soften makes the first interrupt close the returned channel instead of canceling the command
+func (in *interrupt) soften() <-chan struct{} {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.soft == nil {
+		in.soft = make(chan struct{})
+	}
+	return in.soft
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// job is a single entry of a jobs file.
+// Options are keyed by flag name, e.g. {"tile-width": 320, "columns": 4}, and override the flags given on the command line.
+type job struct {
+	Input   string                     `json:"input"`
+	Output  string                     `json:"output"`
+	Options map[string]json.RawMessage `json:"options"`
+}
+
+// readJobsFile reads jobs from a JSON array, or from CSV with input, output and flag name columns
+func readJobsFile(path string) ([]job, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readJobsCSV(f)
+	}
+
+	var jobs []job
+	if err := json.NewDecoder(f).Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse jobs: %w", err)
+	}
+	for i, j := range jobs {
+		if j.Input == "" {
+			return nil, fmt.Errorf("job %d has no input", i+1)
+		}
+	}
+	return jobs, nil
+}
+
+func readJobsCSV(r io.Reader) ([]job, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jobs: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, nil
+	}
+
+	header := records[0]
+	var jobs []job
+	for i, record := range records[1:] {
+		j := job{Options: map[string]json.RawMessage{}}
+		for col, value := range record {
+			switch name := strings.TrimSpace(header[col]); name {
+			case "input":
+				j.Input = value
+			case "output":
+				j.Output = value
+			default:
+				if value == "" {
+					continue
+				}
+				// values are passed as JSON strings, and converted to the flag's type in applyOverrides
+				raw, _ := json.Marshal(value)
+				j.Options[name] = raw
+			}
+		}
+		if j.Input == "" {
+			return nil, fmt.Errorf("job %d has no input", i+1)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// applyOverrides sets the fields of cmd, which must be a pointer to a command struct, by their flag names
+func applyOverrides(cmd any, overrides map[string]json.RawMessage) error {
+	v := reflect.ValueOf(cmd).Elem()
+	fields := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Tag.Get("arg") != "" {
+			continue
+		}
+		name := f.Tag.Get("name")
+		if name == "" {
+			name = flagName(f.Name)
+		}
+		fields[name] = v.Field(i)
+	}
+
+	for key, raw := range overrides {
+		field, ok := fields[strings.ReplaceAll(key, "_", "-")]
+		if !ok {
+			return fmt.Errorf("unknown option %q", key)
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw json.RawMessage) error {
+	if err := json.Unmarshal(raw, field.Addr().Interface()); err == nil {
+		return nil
+	}
+
+	// CSV values and loosely typed JSON arrive as strings or numbers, convert them to the field's type
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		// multiple values are separated with ; in CSV
+		parts := strings.Split(s, ";")
+		field.Set(reflect.ValueOf(parts).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// flagName converts a field name to its kebab-case flag name the same way kong does, e.g. TileWidth to tile-width
+func flagName(field string) string {
+	var sb strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
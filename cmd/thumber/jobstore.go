@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	// pure Go, so the store works in release builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// jobStore keeps jobs across restarts of the server. Progress isn't stored, only changes of status.
+type jobStore interface {
+	// save inserts or updates the job
+	save(j sheetJob) error
+	delete(id string) error
+	// all returns every job in the order they were created
+	all() ([]sheetJob, error)
+	Close() error
+}
+
+// sqliteStore is a jobStore in a SQLite database
+type sqliteStore struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id          TEXT PRIMARY KEY,
+	input       TEXT NOT NULL,
+	params      TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	error       TEXT NOT NULL DEFAULT '',
+	created_ms  INTEGER NOT NULL,
+	started_ms  INTEGER,
+	finished_ms INTEGER,
+	result_path TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS jobs_created ON jobs (created_ms);
+`
+
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open job store %s: %w", path, err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// sqliteDSN escapes the path, which may contain ? or #, into a file: URI. Writes from several runners
+// wait for each other instead of failing.
+func sqliteDSN(path string) string {
+	dsn := url.URL{Scheme: "file", OmitHost: true, Path: path}
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+func (s *sqliteStore) save(j sheetJob) error {
+	params, err := json.Marshal(j.Params)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO jobs (id, input, params, status, error, created_ms, started_ms, finished_ms, result_path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			error = excluded.error,
+			started_ms = excluded.started_ms,
+			finished_ms = excluded.finished_ms,
+			result_path = excluded.result_path`,
+		j.ID, j.Input, string(params), j.Status, j.Error,
+		j.Created.UnixMilli(), unixMilli(j.Started), unixMilli(j.Finished), j.ResultPath,
+	)
+	return err
+}
+
+func (s *sqliteStore) delete(id string) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqliteStore) all() ([]sheetJob, error) {
+	rows, err := s.db.Query(`
+		SELECT id, input, params, status, error, created_ms, started_ms, finished_ms, result_path
+		FROM jobs ORDER BY created_ms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []sheetJob
+	for rows.Next() {
+		var (
+			j                 sheetJob
+			params            string
+			created           int64
+			started, finished sql.NullInt64
+		)
+		if err := rows.Scan(&j.ID, &j.Input, &params, &j.Status, &j.Error, &created, &started, &finished, &j.ResultPath); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &j.Params); err != nil {
+			return nil, fmt.Errorf("invalid params of job %s: %w", j.ID, err)
+		}
+		j.Created = time.UnixMilli(created)
+		j.Started = fromUnixMilli(started)
+		j.Finished = fromUnixMilli(finished)
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func unixMilli(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+func fromUnixMilli(ms sql.NullInt64) *time.Time {
+	if !ms.Valid {
+		return nil
+	}
+	t := time.UnixMilli(ms.Int64)
+	return &t
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T) *sqliteStore {
+	path := filepath.Join(t.TempDir(), "jobs?#1.db")
+	store, err := openSQLiteStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	require.FileExists(t, path, "the path is escaped")
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	store := openTestStore(t)
+	created := time.UnixMilli(time.Now().UnixMilli())
+	finished := created.Add(time.Minute)
+	j := sheetJob{ID: "a", Input: "video.mp4", Params: map[string]string{"tiles": "12"}, Status: jobQueued, Created: created}
+	require.NoError(t, store.save(j))
+	j.Status, j.Finished, j.ResultPath = jobDone, &finished, "/tmp/a.jpg"
+	require.NoError(t, store.save(j))
+	require.NoError(t, store.save(sheetJob{ID: "b", Status: jobQueued, Created: created.Add(time.Second)}))
+
+	jobs, err := store.all()
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, j, jobs[0])
+	assert.Equal(t, "b", jobs[1].ID)
+
+	require.NoError(t, store.delete("a"))
+	jobs, err = store.all()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestJobQueueRestore(t *testing.T) {
+	store := openTestStore(t)
+	now := time.Now()
+	require.NoError(t, store.save(sheetJob{ID: "running", Status: jobRunning, Created: now, Started: &now}))
+	require.NoError(t, store.save(sheetJob{ID: "queued", Status: jobQueued, Created: now}))
+	require.NoError(t, store.save(sheetJob{ID: "done", Status: jobDone, Created: now, Finished: &now}))
+
+	q, err := newJobQueue(&server{}, t.TempDir(), 1, time.Hour, store)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"running", "queued", "done"}, keys(q.jobs))
+	assert.Equal(t, "running", <-q.pending)
+	running, _ := q.get("running")
+	assert.Equal(t, jobQueued, running.Status)
+	assert.Nil(t, running.Started)
+	queued, _ := q.get("queued")
+	assert.Equal(t, jobFailed, queued.Status, "the queue holds one job")
+
+	jobs, err := store.all()
+	require.NoError(t, err)
+	assert.Equal(t, jobFailed, jobs[1].Status)
+}
+
+func TestJobQueueListJobs(t *testing.T) {
+	q, err := newJobQueue(&server{}, t.TempDir(), 10, time.Hour, nil)
+	require.NoError(t, err)
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		q.jobs[id] = &sheetJob{ID: id, Status: jobQueued, Created: now.Add(time.Duration(i) * time.Second)}
+	}
+
+	list := func(target string) (int, []string) {
+		rec := httptest.NewRecorder()
+		q.handleJobs(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var jobs []sheetJob
+		json.Unmarshal(rec.Body.Bytes(), &jobs)
+		var ids []string
+		for _, j := range jobs {
+			ids = append(ids, j.ID)
+		}
+		return rec.Code, ids
+	}
+	code, ids := list("/jobs")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"c", "b", "a"}, ids)
+	_, ids = list("/jobs?limit=2")
+	assert.Equal(t, []string{"c", "b"}, ids)
+	code, _ = list("/jobs?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
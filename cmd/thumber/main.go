@@ -3,16 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
-	"image/jpeg"
-	"io"
-	"log"
+	"math"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
+	"go.opentelemetry.io/otel/codes"
 	"golang.org/x/exp/slog"
 
 	"github.com/abdusco/thumber/pkg/thumber"
@@ -20,11 +18,18 @@ import (
 )
 
 func main() {
-	var args cliArgs
+	// ffmpeg processes confined with --sandbox-ffmpeg are started through thumber itself on Linux
+	thumber.ExecConfined()
+
+	var args cli
 	cliCtx := kong.Parse(
 		&args,
 		kong.Name("thumber"),
-		kong.Vars{"version": version.Version.String()},
+		kong.Vars{
+			"version":              version.Version.String(),
+			"default_tile_width":   strconv.Itoa(thumber.DefaultTileWidth),
+			"default_tile_columns": strconv.Itoa(thumber.DefaultTileColumns),
+		},
 	)
 
 	logLevel := slog.LevelInfo
@@ -33,131 +38,177 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.HandlerOptions{Level: logLevel}.NewTextHandler(os.Stderr)))
 
-	if err := cliCtx.Run(); err != nil {
-		log.Fatal(err)
-	}
-}
-
-type cliArgs struct {
-	Version           kong.VersionFlag `help:"Show version and exit"`
-	VideoPath         string           `arg:"" help:"Path to video"`
-	OutputPath        string           `short:"o" help:"Output path to save JPEG, use - for stdout. Defaults to $filename.thumbs.jpg"`
-	From              Duration         `default:"10" help:"Starting point in seconds, 11h22m33s or mm:ss or hh:mm:ss format"`
-	To                Duration         `help:"Stopping point"`
-	TileWidth         int              `default:"540" help:"Tile width in px"`
-	TileHeight        int              `help:"Tile height in px, optional"`
-	Columns           int              `default:"3" help:"Columns of tile grid"`
-	IntervalSeconds   int              `default:"60" help:"Interval between tiles in seconds"`
-	JPEGQuality       int              `name:"quality" default:"80" help:"JPEG quality"`
-	Padding           int              `help:"Padding around tiles in px"`
-	OverlayTimestamps bool             `help:"Overlay timestamp on each tile"`
-	OverlayBackground string           `help:"Timestamp background color as RGB or RGBA hex color or \"transparent\" e.g. #FFF59D" default:"transparent"`
-	Debug             bool             `help:"Enable verbose logging"`
-}
-
-func (a cliArgs) Run() error {
-	from, err := a.From.Duration()
+	stopProfiling, err := startProfiling(args.Pprof, args.Trace)
 	if err != nil {
-		return fmt.Errorf("invalid from: %w", err)
+		presentError(os.Stderr, err, args.Debug)
+		os.Exit(1)
 	}
-
-	to, err := a.To.Duration()
+	ctx, shutdownTelemetry, err := setupTelemetry(context.Background())
 	if err != nil {
-		return fmt.Errorf("invalid to: %w", err)
+		slog.Warn("failed to set up telemetry", "error", err)
 	}
 
-	color, err := thumber.ParseColor(a.OverlayBackground)
+	ctx = thumber.WithSandbox(ctx, args.sandbox())
+	if args.SandboxFfmpeg && !args.NoNetworkFfmpeg && !thumber.ConfinesNetwork() {
+		slog.Warn("this kernel's Landlock can't deny network access, which needs Linux 6.7, so ffmpeg is only kept to local files and pipes as with --no-network-ffmpeg")
+	}
+	ctx, in, stopInterrupts := handleInterrupts(ctx)
+	ctx, span := tracer.Start(ctx, "thumber "+cliCtx.Command())
+	cliCtx.BindTo(ctx, (*context.Context)(nil))
+	cliCtx.Bind(in)
+	err = cliCtx.Run()
+	stopInterrupts()
 	if err != nil {
-		return fmt.Errorf("invalid overlay background color: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-
-	opts := thumber.ThumbOptions{
-		From:                from,
-		To:                  to,
-		TileColumns:         a.Columns,
-		Interval:            time.Second * time.Duration(a.IntervalSeconds),
-		TileWidth:           a.TileWidth,
-		TileHeight:          a.TileHeight,
-		Padding:             a.Padding,
-		OverlayTimestamps:   a.OverlayTimestamps,
-		TimestampBackground: color,
+	span.End()
+	if err := shutdownTelemetry(context.Background()); err != nil {
+		slog.Warn("failed to export telemetry", "error", err)
 	}
-	slog.Debug("parsed options", "options", opts)
-
-	img, err := thumber.Generate(context.Background(), a.VideoPath, opts)
+	stopProfiling()
 	if err != nil {
-		return fmt.Errorf("failed to generate thumbnails: %w", err)
+		presentError(os.Stderr, err, args.Debug)
+		os.Exit(1)
 	}
+}
 
-	f, err := a.OutputFile()
-	if err != nil {
-		return fmt.Errorf("failed to open file for writing: %w", err)
-	}
+type cli struct {
+	VersionFlag     kong.VersionFlag `name:"version" help:"Show version and exit"`
+	Debug           bool             `help:"Enable verbose logging"`
+	Pprof           string           `placeholder:"ADDR" help:"Serve pprof profiles on this address e.g. localhost:6060"`
+	Trace           string           `placeholder:"PATH" help:"Write a runtime trace to this file, view it with go tool trace"`
+	NoNetworkFfmpeg bool             `name:"no-network-ffmpeg" help:"Only let ffmpeg read local files and pipes, so inputs such as playlists can't make it fetch URLs"`
+	SandboxFfmpeg   bool             `name:"sandbox-ffmpeg" help:"Run ffmpeg under Landlock (Linux) or sandbox-exec (macOS) without network access and writes outside the temp directory. Before Linux 6.7, network access is denied with --no-network-ffmpeg instead"`
+	Generate        generateCmd      `cmd:"" default:"withargs" help:"Generate a contact sheet (default)"`
+	All             allCmd           `cmd:"" help:"Generate the poster, contact sheet, sprites with a VTT track and manifest in one pass"`
+	Around          aroundCmd        `cmd:"" help:"Generate a dense contact sheet of the moments around a timestamp"`
+	Scenes          scenesCmd        `cmd:"" help:"Export scene boundaries with a representative frame of each as JSON, CSV or EDL"`
+	Dataset         datasetCmd       `cmd:"" help:"Export frames of videos with a JSONL or CSV index for training data pipelines"`
+	Pick            pickCmd          `cmd:"" help:"Interactively pick frames for a contact sheet"`
+	Cover           coverCmd         `cmd:"" help:"Embed a frame as cover art into an MP4 or MKV video"`
+	Quicklook       quicklookCmd     `cmd:"" help:"Write a single small frame as PNG to stdout, for preview extensions"`
+	Check           checkCmd         `cmd:"" help:"Check a video for decode errors and report readable ranges"`
+	Dbus            dbusCmd          `cmd:"" name:"dbus" help:"Serve the freedesktop.org Thumbnailer1 D-Bus interface for file managers"`
+	Serve           serveCmd         `cmd:"" help:"Serve contact sheets of files under the given roots over HTTP"`
+	Bench           benchCmd         `cmd:"" help:"Time probing, extraction, composing and encoding with different settings"`
+	Doctor          doctorCmd        `cmd:"" help:"Diagnose problems with the environment"`
+	SelfUpdate      selfUpdateCmd    `cmd:"" help:"Update thumber to the latest release. Release builds check its minisign signature, other builds only its checksum, which doesn't protect against a tampered release"`
+	Version         versionCmd       `cmd:"" help:"Show version, use --full to include ffmpeg details"`
+}
 
-	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: a.JPEGQuality}); err != nil {
-		return fmt.Errorf("failed to encode as jpeg: %w", err)
+// sandbox confines ffmpeg as set by --no-network-ffmpeg and --sandbox-ffmpeg
+func (c cli) sandbox() thumber.Sandbox {
+	return thumber.Sandbox{
+		NoNetwork:    c.NoNetworkFfmpeg,
+		Confine:      c.SandboxFfmpeg,
+		WritableDirs: []string{os.TempDir()},
 	}
-	return nil
 }
 
-func (a cliArgs) OutputFile() (io.Writer, error) {
-	if a.OutputPath == "-" {
-		return os.Stdout, nil
-	}
+// Duration is a flag value that accepts Go durations (1h2m3s), clock times (mm:ss, hh:mm:ss)
+// with optional fractional seconds, and plain seconds (93.5).
+type Duration string
 
-	if a.OutputPath == "" {
-		dir := filepath.Dir(a.VideoPath)
-		base := strings.TrimSuffix(filepath.Base(a.VideoPath), filepath.Ext(a.VideoPath))
-		a.OutputPath = filepath.Join(dir, fmt.Sprintf("%s.thumbs.jpg", base))
-	}
+// defaultStart is where sheets start without --from, past the intros many videos have
+const defaultStart = 10 * time.Second
 
-	return os.Create(a.OutputPath)
+// defaultFrom is the default starting point of sheets, unless --from is given
+func defaultFrom(from Duration) time.Duration {
+	if from != "" {
+		return 0
+	}
+	return defaultStart
 }
 
-type Duration string
+const durationFormats = "use seconds (93.5), mm:ss (1:23.5), hh:mm:ss (01:02:03.250) or units (1m30s)"
 
 func (d Duration) Duration() (time.Duration, error) {
-	if d == "" {
+	s := strings.TrimSpace(string(d))
+	if s == "" {
 		return 0, nil
 	}
 
-	if d, err := time.ParseDuration(string(d)); err == nil {
-		return d, nil
+	invalid := func(reason string) error {
+		return fmt.Errorf("invalid duration %q: %s, %s", string(d), reason, durationFormats)
 	}
 
-	parts := reverse(strings.Split(strings.TrimSpace(string(d)), ":"))
-
-	var hour, minute, second int
-	var err error
-
-	if len(parts) > 2 {
-		hour, err = strconv.Atoi(parts[2])
+	if strings.ContainsAny(s, "hmsuµn") {
+		v, err := time.ParseDuration(s)
 		if err != nil {
-			return 0, fmt.Errorf("invalid duration: %q as hour", parts[0])
+			return 0, invalid("unknown unit or malformed number")
+		}
+		if v < 0 {
+			return 0, invalid("must not be negative")
 		}
+		return v, nil
 	}
 
-	if len(parts) > 1 {
-		minute, err = strconv.Atoi(parts[1])
-		if err != nil {
-			return 0, fmt.Errorf("invalid duration: %q as minute", parts[1])
-		}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, invalid("too many components")
 	}
 
-	if len(parts) > 0 {
-		second, err = strconv.Atoi(parts[0])
-		if err != nil {
-			return 0, fmt.Errorf("invalid duration: %q as second", parts[second])
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, invalid(fmt.Sprintf("%q is not a number of seconds", parts[len(parts)-1]))
+	}
+	if seconds < 0 {
+		return 0, invalid("must not be negative")
+	}
+	if len(parts) > 1 && seconds >= 60 {
+		return 0, invalid("seconds must be less than 60")
+	}
+
+	total := seconds
+	for i, unit := range []float64{60, 3600}[:len(parts)-1] {
+		part := parts[len(parts)-2-i]
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, invalid(fmt.Sprintf("%q is not a whole number", part))
+		}
+		// minutes are only bounded when hours come before them
+		if unit == 60 && len(parts) == 3 && n >= 60 {
+			return 0, invalid("minutes must be less than 60")
 		}
+		total += float64(n) * unit
 	}
+	return time.Duration(math.Round(total * float64(time.Second))), nil
+}
 
-	duration := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
-	return duration, nil
+type ByteSize string
+
+var byteSizeUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
 }
 
-func reverse[T any](s []T) []T {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+func (s ByteSize) Bytes() (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(string(s)))
+	if str == "" {
+		return 0, nil
+	}
+
+	scale := float64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			scale = u.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
 	}
-	return s
+	return int64(n * scale), nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "", want: 0},
+		{in: "10", want: 10 * time.Second},
+		{in: " 10 ", want: 10 * time.Second},
+		{in: "0", want: 0},
+		{in: "93.5", want: 93*time.Second + 500*time.Millisecond},
+		{in: "0.04", want: 40 * time.Millisecond},
+		{in: "1:23", want: 83 * time.Second},
+		{in: "1:23.5", want: 83*time.Second + 500*time.Millisecond},
+		{in: "90:00", want: 90 * time.Minute},
+		{in: "01:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{in: "01:02:03.250", want: time.Hour + 2*time.Minute + 3*time.Second + 250*time.Millisecond},
+		{in: "100:00:00", want: 100 * time.Hour},
+		{in: "5s", want: 5 * time.Second},
+		{in: "1h2m3.5s", want: time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{in: "250ms", want: 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Duration(tt.in).Duration()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDurationInvalid(t *testing.T) {
+	tests := []struct {
+		in      string
+		message string
+	}{
+		{in: "abc", message: "not a number of seconds"},
+		{in: "10x", message: "not a number of seconds"},
+		{in: "5 minutes", message: "unknown unit"},
+		{in: "-5", message: "must not be negative"},
+		{in: "-5s", message: "must not be negative"},
+		{in: "1:2:3:4", message: "too many components"},
+		{in: "1:60", message: "seconds must be less than 60"},
+		{in: "1:60:00", message: "minutes must be less than 60"},
+		{in: "1.5:00", message: "not a whole number"},
+		{in: "a:30", message: "not a whole number"},
+		{in: "1:", message: "not a number of seconds"},
+		{in: "nan", message: "unknown unit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := Duration(tt.in).Duration()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Contains(t, err.Error(), "hh:mm:ss")
+		})
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{in: "", want: 0},
+		{in: "500", want: 500},
+		{in: "500B", want: 500},
+		{in: "500KB", want: 500 << 10},
+		{in: "500k", want: 500 << 10},
+		{in: "2MB", want: 2 << 20},
+		{in: "1.5M", want: 3 << 19},
+		{in: " 2 GB ", want: 2 << 30},
+		{in: "1g", want: 1 << 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ByteSize(tt.in).Bytes()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestByteSizeInvalid(t *testing.T) {
+	for _, in := range []string{"MB", "two MB", "0", "-1MB", "2TB", "1.5.2K"} {
+		t.Run(in, func(t *testing.T) {
+			_, err := ByteSize(in).Bytes()
+			assert.EqualError(t, err, "invalid size: \""+in+"\"")
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type manifest struct {
+	Video  string          `json:"video"`
+	Sheets []manifestSheet `json:"sheets"`
+}
+
+type manifestSheet struct {
+	Path   string         `json:"path"`
+	Width  int            `json:"width"`
+	Height int            `json:"height"`
+	Tiles  []manifestTile `json:"tiles"`
+}
+
+type manifestTile struct {
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	TimestampMs int64  `json:"timestamp_ms"`
+	Timecode    string `json:"timecode,omitempty"`
+	Clip        string `json:"clip,omitempty"`
+	// PHash is the perceptual hash of the frame as 16 hex digits
+	PHash    string    `json:"phash,omitempty"`
+	Features []float64 `json:"features,omitempty"`
+}
+
+// manifestExtras are optional details of each tile
+type manifestExtras struct {
+	// Clips are the hover clips of the tiles in order over all sheets
+	Clips    []string
+	PHash    bool
+	Features bool
+}
+
+// featureSize is the width and height of the grayscale thumbnail used as the feature vector of a tile
+const featureSize = 8
+
+// writeManifest describes the sheets saved at paths, so other tools can find the tile for a timestamp.
+// Sheet and clip paths are relative to the manifest.
+func writeManifest(path, videoPath string, paths []string, sheets []thumber.Sheet, extras manifestExtras) error {
+	// relative paths keep the manifest valid when the outputs are moved together
+	rel := func(p string) (string, error) {
+		r, err := filepath.Rel(filepath.Dir(path), p)
+		return filepath.ToSlash(r), err
+	}
+	m := manifest{Video: videoPath}
+	tileIndex := 0
+	for i, sheet := range sheets {
+		sheetPath, err := rel(paths[i])
+		if err != nil {
+			return err
+		}
+		s := manifestSheet{
+			Path:   sheetPath,
+			Width:  sheet.Bounds().Dx(),
+			Height: sheet.Bounds().Dy(),
+		}
+		for _, t := range sheet.Tiles {
+			tile := manifestTile{
+				X:           t.Rect.Min.X,
+				Y:           t.Rect.Min.Y,
+				Width:       t.Rect.Dx(),
+				Height:      t.Rect.Dy(),
+				TimestampMs: t.Timestamp.Milliseconds(),
+				Timecode:    t.Frame.Timecode,
+			}
+			if tileIndex < len(extras.Clips) {
+				if tile.Clip, err = rel(extras.Clips[tileIndex]); err != nil {
+					return err
+				}
+			}
+			// hashed before timestamps are overlaid, so tiles of the same frame match regardless of options
+			if extras.PHash {
+				tile.PHash = fmt.Sprintf("%016x", thumber.PerceptualHash(t.Frame))
+			}
+			if extras.Features {
+				tile.Features = thumber.FeatureVector(t.Frame, featureSize)
+			}
+			tileIndex++
+			s.Tiles = append(s.Tiles, tile)
+		}
+		m.Sheets = append(m.Sheets, s)
+	}
+
+	f, err := createOutput(path, videoPath, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return f.Commit()
+}
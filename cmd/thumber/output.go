@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputFile is written to a temporary file next to its destination, and only moved into place on Commit,
+// so failed or interrupted runs don't leave truncated images behind.
+type outputFile struct {
+	*os.File
+	path      string
+	attrs     fileAttrs
+	committed bool
+}
+
+// createOutput opens the output file, defaulting to $filename.$suffix.jpg next to the video.
+// "-" writes to stdout.
+func createOutput(outputPath, videoPath, suffix string) (*outputFile, error) {
+	if outputPath == "-" {
+		return &outputFile{File: os.Stdout}, nil
+	}
+
+	if outputPath == "" {
+		outputPath = defaultOutputPath(videoPath, suffix)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{File: f, path: outputPath, attrs: defaultFileAttrs()}, nil
+}
+
+func defaultOutputPath(videoPath, suffix string) string {
+	dir := filepath.Dir(videoPath)
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	return filepath.Join(dir, fmt.Sprintf("%s.%s.jpg", base, suffix))
+}
+
+// Path returns where the output ends up, or "-" for stdout
+func (f *outputFile) Path() string {
+	if f.path == "" {
+		return "-"
+	}
+	return f.path
+}
+
+func (f *outputFile) Commit() error {
+	if f.path == "" {
+		return nil
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if err := f.attrs.apply(f.File.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.path); err != nil {
+		return err
+	}
+	f.committed = true
+	return nil
+}
+
+// SetAttrs sets the permissions, owner and modification time applied to the output on Commit
+func (f *outputFile) SetAttrs(attrs fileAttrs) {
+	f.attrs = attrs
+}
+
+// Close discards the output unless it was committed
+func (f *outputFile) Close() error {
+	if f.path == "" || f.committed {
+		return nil
+	}
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+type pickCmd struct {
+	VideoPath         string   `arg:"" help:"Path to video"`
+	OutputPath        string   `short:"o" help:"Output path to save JPEG, use - for stdout. Defaults to $filename.picked.jpg"`
+	Candidates        int      `default:"20" help:"Number of candidate frames spread over the video"`
+	TileWidth         int      `default:"${default_tile_width}" help:"Tile width in px"`
+	Columns           int      `default:"3" help:"Columns of tile grid"`
+	Padding           int      `help:"Padding around tiles in px"`
+	JPEGQuality       int      `name:"quality" default:"80" help:"JPEG quality"`
+	SeekStep          Duration `default:"5s" help:"How far to seek when reseeking a candidate"`
+	Preview           string   `default:"ansi" enum:"ansi,kitty" help:"How to preview frames, one of ansi (colored blocks, works everywhere) or kitty (graphics protocol)"`
+	PreviewWidth      int      `default:"80" help:"Preview width in terminal columns for ansi previews"`
+	OverlayTimestamps bool     `help:"Overlay timestamp on each tile"`
+}
+
+func (c pickCmd) Run() error {
+	ctx := context.Background()
+
+	step, err := c.SeekStep.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid seek step: %w", err)
+	}
+
+	opts := thumber.ThumbOptions{
+		TileCount:           c.Candidates,
+		TileColumns:         c.Columns,
+		TileWidth:           c.TileWidth,
+		Padding:             c.Padding,
+		OverlayTimestamps:   c.OverlayTimestamps,
+		TimestampBackground: color.Transparent,
+	}
+
+	fmt.Fprintf(os.Stderr, "extracting %d candidates...\n", c.Candidates)
+	candidates, err := thumber.MakeThumbnails(ctx, c.VideoPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to extract candidates: %w", err)
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	var picked []thumber.Thumbnail
+loop:
+	for i := 0; i < len(candidates); {
+		candidate := candidates[i]
+		if c.Preview == "kitty" {
+			if err := printKittyPreview(os.Stderr, candidate); err != nil {
+				return err
+			}
+		} else {
+			printANSIPreview(os.Stderr, candidate, c.PreviewWidth)
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s, picked %d. (a)ccept, (r)eject, (+/-) seek %s, (d)one, (q)uit [a]: ",
+			i+1, len(candidates), candidate.Timestamp.Round(time.Second), len(picked), step)
+
+		if !in.Scan() {
+			break
+		}
+		switch strings.TrimSpace(in.Text()) {
+		case "", "a":
+			picked = append(picked, candidate)
+			i++
+		case "r":
+			i++
+		case "+":
+			candidates[i] = c.reseek(ctx, candidate, candidate.RequestedTimestamp+step, opts)
+		case "-":
+			candidates[i] = c.reseek(ctx, candidate, candidate.RequestedTimestamp-step, opts)
+		case "d":
+			break loop
+		case "q":
+			return fmt.Errorf("aborted")
+		default:
+			fmt.Fprintln(os.Stderr, "unknown command")
+		}
+	}
+
+	if len(picked) == 0 {
+		return fmt.Errorf("no frames picked")
+	}
+
+	img, err := thumber.MakeContactSheet(picked, opts)
+	if err != nil {
+		return fmt.Errorf("failed to make contact sheet: %w", err)
+	}
+
+	f, err := createOutput(c.OutputPath, c.VideoPath, "picked")
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing: %w", err)
+	}
+	defer f.Close()
+
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: c.JPEGQuality}); err != nil {
+		return fmt.Errorf("failed to encode as jpeg: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to save output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "composed %d frames\n", len(picked))
+	return nil
+}
+
+// reseek extracts a frame at t, keeping the current candidate if that fails
+func (c pickCmd) reseek(ctx context.Context, current thumber.Thumbnail, t time.Duration, opts thumber.ThumbOptions) thumber.Thumbnail {
+	if t < 0 {
+		t = 0
+	}
+	th, err := thumber.ExtractFrame(ctx, c.VideoPath, t, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to seek: %s\n", err)
+		return current
+	}
+	return th
+}
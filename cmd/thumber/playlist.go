@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// maxPlaylists and maxPlaylistSize bound what checkPlaylist reads, counting nested playlists
+const (
+	maxPlaylists    = 32
+	maxPlaylistSize = 4 << 20
+)
+
+var (
+	// hlsURIAttr is the URI of tags like EXT-X-KEY, EXT-X-MAP and EXT-X-MEDIA
+	hlsURIAttr  = regexp.MustCompile(`URI="([^"]*)"`)
+	dashBaseURL = regexp.MustCompile(`<BaseURL[^>]*>([^<]*)</BaseURL>`)
+	// dashURLAttr are the attributes of segments, their templates and linked periods
+	dashURLAttr = regexp.MustCompile(`\b(?:media|initialization|sourceURL|index|href)="([^"]*)"`)
+)
+
+// inputProtocols are the ffmpeg protocols an input needs: local files, or the scheme of a URL, but not both,
+// so a local playlist can't reach the network and a remote one can't read local files
+func inputProtocols(input string) []string {
+	protocols := []string{"pipe", "crypto", "data"}
+	switch u, err := url.Parse(input); {
+	case err != nil || !thumber.IsRemote(input):
+		return append(protocols, "file")
+	case strings.EqualFold(u.Scheme, "http"):
+		return append(protocols, "http", "tcp")
+	default:
+		return append(protocols, "https", "tls", "tcp")
+	}
+}
+
+// checkPlaylist reads an HLS or DASH playlist and those it references, and refuses it if a segment, key or
+// nested playlist is outside of the roots and allowed schemes. ffmpeg follows them on its own, so checking
+// the input isn't enough.
+func (s *server) checkPlaylist(ctx context.Context, input string) error {
+	top, err := playlistURL(input)
+	if err != nil {
+		return err
+	}
+	pending := []*url.URL{top}
+	seen := map[string]bool{top.String(): true}
+	for len(pending) > 0 {
+		u := pending[0]
+		pending = pending[1:]
+		data, err := readPlaylist(ctx, u)
+		if err != nil {
+			return fmt.Errorf("failed to read playlist %s: %w", u.Redacted(), err)
+		}
+		refs, err := playlistRefs(u, data)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if err := s.checkRef(ref); err != nil {
+				return err
+			}
+			if !thumber.IsAdaptive(ref.String()) || seen[ref.String()] {
+				continue
+			}
+			if len(seen) == maxPlaylists {
+				return fmt.Errorf("%w: more than %d nested playlists", errForbidden, maxPlaylists)
+			}
+			seen[ref.String()] = true
+			pending = append(pending, ref)
+		}
+	}
+	return nil
+}
+
+// playlistURL is the URL references of the input resolve against, file:// for local paths
+func playlistURL(input string) (*url.URL, error) {
+	if thumber.IsRemote(input) {
+		return url.Parse(input)
+	}
+	abs, err := filepath.Abs(input)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}, nil
+}
+
+func readPlaylist(ctx context.Context, u *url.URL) ([]byte, error) {
+	var r io.Reader
+	switch strings.ToLower(u.Scheme) {
+	case "file":
+		f, err := os.Open(filepath.FromSlash(u.Path))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status %s", res.Status)
+		}
+		r = res.Body
+	default:
+		return nil, fmt.Errorf("%w: playlists over %s", errForbidden, u.Scheme)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxPlaylistSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPlaylistSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxPlaylistSize)
+	}
+	return data, nil
+}
+
+// playlistRefs resolves what an HLS or DASH playlist references. Those of DASH are resolved against
+// every BaseURL too, as they may apply to them.
+func playlistRefs(playlist *url.URL, data []byte) ([]*url.URL, error) {
+	var raw []string
+	bases := []*url.URL{playlist}
+	if strings.Contains(string(data), "<MPD") {
+		for _, m := range dashBaseURL.FindAllSubmatch(data, -1) {
+			ref, err := parseRef(string(m[1]))
+			if err != nil {
+				return nil, err
+			}
+			for _, base := range bases {
+				if len(bases) == maxPlaylists {
+					return nil, fmt.Errorf("%w: too many base URLs", errForbidden)
+				}
+				bases = append(bases, base.ResolveReference(ref))
+			}
+		}
+		for _, m := range dashURLAttr.FindAllSubmatch(data, -1) {
+			raw = append(raw, string(m[1]))
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "":
+			case strings.HasPrefix(line, "#"):
+				for _, m := range hlsURIAttr.FindAllStringSubmatch(line, -1) {
+					raw = append(raw, m[1])
+				}
+			default:
+				raw = append(raw, line)
+			}
+		}
+	}
+
+	// base URLs are checked like references, as they could point outside on their own
+	refs := append([]*url.URL(nil), bases...)
+	for _, r := range raw {
+		ref, err := parseRef(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, base := range bases {
+			refs = append(refs, base.ResolveReference(ref))
+		}
+	}
+	return refs, nil
+}
+
+func parseRef(raw string) (*url.URL, error) {
+	ref, err := url.Parse(strings.TrimSpace(html.UnescapeString(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid playlist reference %q", errForbidden, raw)
+	}
+	return ref, nil
+}
+
+// checkRef checks a reference of a playlist like the input itself. data: URIs are self-contained.
+func (s *server) checkRef(ref *url.URL) error {
+	switch scheme := strings.ToLower(ref.Scheme); scheme {
+	case "data":
+		return nil
+	case "file":
+		if len(s.roots) == 0 {
+			return fmt.Errorf("%w: no roots", errForbidden)
+		}
+		return checkLocal(filepath.FromSlash(ref.Path), s.roots)
+	default:
+		if !slices.Contains(s.schemes, scheme) {
+			return fmt.Errorf("%w: playlist references scheme %s", errForbidden, ref.Scheme)
+		}
+		return nil
+	}
+}
+
+// checkLocal checks that p is within one of the roots. Referenced files may not exist,
+// e.g. segment templates, so the symlinks of the longest existing part of p are resolved.
+func checkLocal(p string, roots []string) error {
+	_, err := resolveLocal(p, roots)
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	dir, rest := filepath.Clean(p), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			if withinRoots(filepath.Join(resolved, rest), roots) {
+				return nil
+			}
+			return fmt.Errorf("%w: playlist references a file outside of roots", errForbidden)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
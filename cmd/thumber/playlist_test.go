@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCheckPlaylist(t *testing.T) {
+	root, err := resolveRoot(t.TempDir())
+	require.NoError(t, err)
+	outside, err := resolveRoot(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	write := func(name, content string) string {
+		p := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		return p
+	}
+	write("video/seg0.ts", "")
+	write("video/index.m3u8", "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:4,\nseg0.ts\n#EXTINF:4,\nseg1.ts\n")
+	write("master.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nvideo/index.m3u8\n")
+	write("nested/bad.m3u8", "#EXTM3U\n#EXTINF:4,\n../../"+filepath.Base(outside)+"/secret.ts\n")
+
+	s := &server{roots: []string{root}, schemes: []string{"https"}}
+	for name, content := range map[string]string{
+		"master.m3u8":       "",
+		"remote.m3u8":       "#EXTM3U\n#EXTINF:4,\nhttps://cdn.example.com/seg0.ts\n",
+		"data.m3u8":         "#EXTM3U\n#EXTINF:4,\ndata:video/mp2t;base64,AAAA\n",
+		"template.mpd":      `<MPD><Period><AdaptationSet><SegmentTemplate media="seg-$Number$.m4s" initialization="init.mp4"/></AdaptationSet></Period></MPD>`,
+		"relative-base.mpd": `<MPD><BaseURL>video/</BaseURL><Period><SegmentList><SegmentURL media="seg0.ts"/></SegmentList></Period></MPD>`,
+	} {
+		p := filepath.Join(root, name)
+		if content != "" {
+			p = write(name, content)
+		}
+		assert.NoError(t, s.checkPlaylist(context.Background(), p), name)
+	}
+
+	for name, content := range map[string]string{
+		"absolute.m3u8": "#EXTM3U\n#EXTINF:4,\n" + filepath.Join(outside, "secret.ts") + "\n",
+		"file-url.m3u8": "#EXTM3U\n#EXTINF:4,\nfile:///etc/passwd\n",
+		"symlink.m3u8":  "#EXTM3U\n#EXTINF:4,\nescape/secret.ts\n",
+		"key.m3u8":      "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"http://169.254.169.254/key\"\n#EXTINF:4,\nvideo/seg0.ts\n",
+		"nested.m3u8":   "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nnested/bad.m3u8\n",
+		"base-url.mpd":  `<MPD><BaseURL>../` + filepath.Base(outside) + `/</BaseURL><Period><SegmentList><SegmentURL media="secret.ts"/></SegmentList></Period></MPD>`,
+		"concat.m3u8":   "#EXTM3U\n#EXTINF:4,\nconcat:a.ts|b.ts\n",
+		"escaped.mpd":   `<MPD><Period><SegmentList><SegmentURL media="file:///etc/passwd?a&amp;b"/></SegmentList></Period></MPD>`,
+	} {
+		err := s.checkPlaylist(context.Background(), write(name, content))
+		assert.ErrorIs(t, err, errForbidden, name)
+	}
+}
+
+func TestServerCheckRemotePlaylist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXTINF:4,\nseg0.ts\n"))
+		case "/local.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXTINF:4,\nfile:///etc/passwd\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := &server{schemes: []string{"http"}}
+	assert.NoError(t, s.checkPlaylist(context.Background(), srv.URL+"/ok.m3u8"))
+	assert.ErrorIs(t, s.checkPlaylist(context.Background(), srv.URL+"/local.m3u8"), errForbidden)
+	assert.Error(t, s.checkPlaylist(context.Background(), srv.URL+"/missing.m3u8"))
+}
+
+func TestInputProtocols(t *testing.T) {
+	assert.Equal(t, []string{"pipe", "crypto", "data", "file"}, inputProtocols("/videos/index.m3u8"))
+	assert.Equal(t, []string{"pipe", "crypto", "data", "http", "tcp"}, inputProtocols("http://example.com/index.m3u8"))
+	assert.Equal(t, []string{"pipe", "crypto", "data", "https", "tls", "tcp"}, inputProtocols("HTTPS://example.com/index.m3u8"))
+}
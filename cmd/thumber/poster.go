@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// posterMaxDimension is larger than any video, so poster frames without a size aren't scaled
+const posterMaxDimension = 1 << 15
+
+// extractPosterFrame extracts a single full size frame at the starting point of opts,
+// using the first frame instead for videos shorter than that
+func extractPosterFrame(ctx context.Context, videoPath string, opts thumber.ThumbOptions) (thumber.Thumbnail, error) {
+	frameOpts := thumber.ThumbOptions{
+		TileColumns: 1,
+		TileWidth:   opts.TileWidth,
+		TileHeight:  opts.TileHeight,
+		Crop:        opts.Crop,
+		BlurRegions: opts.BlurRegions,
+		Limits:      opts.Limits,
+	}
+	if opts.TileWidth == 0 && opts.TileHeight == 0 {
+		// keeps the frame at source resolution, rather than the default tile width
+		frameOpts.MaxTileDimension = opts.MaxTileDimension
+		if frameOpts.MaxTileDimension == 0 {
+			frameOpts.MaxTileDimension = posterMaxDimension
+		}
+	}
+	at := opts.From
+	if at == 0 {
+		at = opts.DefaultFrom
+	}
+	frame, err := thumber.ExtractFrame(ctx, videoPath, at, frameOpts)
+	if err != nil && at > 0 && ctx.Err() == nil {
+		slog.Debug("failed to extract poster frame, retrying with the first frame", "error", err)
+		frame, err = thumber.ExtractFrame(ctx, videoPath, 0, frameOpts)
+	}
+	return frame, err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// printANSIPreview draws img with half-block characters in 24-bit color,
+// each character cell showing two vertically stacked pixels.
+func printANSIPreview(w io.Writer, img image.Image, columns int) {
+	b := img.Bounds()
+	rows := columns * b.Dy() / b.Dx() / 2
+	if rows < 1 {
+		rows = 1
+	}
+	small := imaging.Resize(img, columns, rows*2, imaging.Box)
+
+	var sb strings.Builder
+	for y := 0; y < rows*2; y += 2 {
+		for x := 0; x < columns; x++ {
+			top := small.NRGBAAt(x, y)
+			bottom := small.NRGBAAt(x, y+1)
+			fmt.Fprintf(&sb, "\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		sb.WriteString("\033[0m\n")
+	}
+	io.WriteString(w, sb.String())
+}
+
+// printKittyPreview draws img using the kitty terminal graphics protocol
+func printKittyPreview(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode preview: %w", err)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	// payload must be sent in chunks of at most 4096 bytes
+	const chunkSize = 4096
+	for i := 0; i < len(data); i += chunkSize {
+		end := i + chunkSize
+		more := 1
+		if end >= len(data) {
+			end = len(data)
+			more = 0
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\033_Gf=100,a=T,m=%d;%s\033\\", more, data[i:end])
+		} else {
+			fmt.Fprintf(w, "\033_Gm=%d;%s\033\\", more, data[i:end])
+		}
+	}
+	fmt.Fprintln(w)
+	return nil
+}
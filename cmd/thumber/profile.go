@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime/trace"
+
+	"golang.org/x/exp/slog"
+)
+
+// startProfiling serves pprof profiles on pprofAddr and writes a runtime trace to tracePath, if they're set.
+// The returned function stops tracing, and must be called before exiting.
+func startProfiling(pprofAddr, tracePath string) (func(), error) {
+	if pprofAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go func() {
+			slog.Info("serving pprof", "url", fmt.Sprintf("http://%s/debug/pprof/", pprofAddr))
+			if err := http.ListenAndServe(pprofAddr, mux); err != nil {
+				slog.Error("failed to serve pprof", "error", err)
+			}
+		}()
+	}
+
+	if tracePath == "" {
+		return func() {}, nil
+	}
+	f, err := os.Create(tracePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start trace: %w", err)
+	}
+	return func() {
+		trace.Stop()
+		if err := f.Close(); err != nil {
+			slog.Error("failed to save trace", "error", err)
+		}
+	}, nil
+}
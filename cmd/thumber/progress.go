@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// progressEvents writes progress as newline-delimited JSON for GUI wrappers, from all files of a batch.
+// A nil *progressEvents writes nothing.
+type progressEvents struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// progressEvent is a line of --progress-format json
+type progressEvent struct {
+	// Event is progress while a file is worked on, then done or failed
+	Event string `json:"event"`
+	Path  string `json:"path"`
+	// File is the position of the file in a batch from 1, out of Files
+	File  int    `json:"file,omitempty"`
+	Files int    `json:"files,omitempty"`
+	Stage string `json:"stage,omitempty"`
+	// Frame is how many frames are extracted, out of Frames
+	Frame   int     `json:"frame,omitempty"`
+	Frames  int     `json:"frames,omitempty"`
+	Percent float64 `json:"percent"`
+	ETAMs   int64   `json:"eta_ms,omitempty"`
+	Output  string  `json:"output,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+func newProgressEvents(w io.Writer) *progressEvents {
+	return &progressEvents{enc: json.NewEncoder(w)}
+}
+
+func (e *progressEvents) write(ev progressEvent) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// a wrapper that stopped reading shouldn't fail the generation
+	_ = e.enc.Encode(ev)
+}
+
+// progress reports the stages and frames of thumber.ThumbOptions.Progress
+func (e *progressEvents) progress(p thumber.Progress) {
+	e.write(progressEvent{
+		Event:   "progress",
+		Path:    p.Path,
+		Stage:   p.Stage,
+		Frame:   p.Frame,
+		Frames:  p.Frames,
+		Percent: roundPercent(p.Percent),
+		ETAMs:   p.ETA.Milliseconds(),
+	})
+}
+
+// finish reports the outcome of the file at position file of files in a batch, which are zero outside of batches
+func (e *progressEvents) finish(path, output string, err error, file, files int) {
+	ev := progressEvent{Event: "done", Path: path, File: file, Files: files, Percent: 100, Output: output}
+	if err != nil {
+		ev = progressEvent{Event: "failed", Path: path, File: file, Files: files, Error: err.Error()}
+	}
+	e.write(ev)
+}
+
+// start reports that the file at position file of files in a batch is next
+func (e *progressEvents) start(path string, file, files int) {
+	e.write(progressEvent{Event: "progress", Path: path, File: file, Files: files, Stage: "start"})
+}
+
+func roundPercent(p float64) float64 {
+	return float64(int(p*10+0.5)) / 10
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// statuses of a sheet job
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// jobParams are the request parameters that set the options of a job
+var jobParams = []string{"tiles", "columns", "width"}
+
+// errQueueFull is returned when more jobs are waiting than the queue holds
+var errQueueFull = errors.New("job queue is full")
+
+// sheetJob is a contact sheet generated in the background, for videos that take longer than an HTTP request may
+type sheetJob struct {
+	ID    string `json:"id"`
+	Input string `json:"input"`
+	// Params are the request parameters the job was created with, e.g. tiles
+	Params   map[string]string `json:"params,omitempty"`
+	Status   string            `json:"status"`
+	Progress *jobProgress      `json:"progress,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Created  time.Time         `json:"created"`
+	Started  *time.Time        `json:"started,omitempty"`
+	Finished *time.Time        `json:"finished,omitempty"`
+	// ResultPath is where the sheet is saved once the job is done
+	ResultPath string `json:"-"`
+}
+
+type jobProgress struct {
+	Stage   string  `json:"stage"`
+	Frame   int     `json:"frame,omitempty"`
+	Frames  int     `json:"frames,omitempty"`
+	Percent float64 `json:"percent"`
+	ETAMs   int64   `json:"eta_ms,omitempty"`
+}
+
+func (j sheetJob) finished() bool {
+	return j.Status == jobDone || j.Status == jobFailed
+}
+
+// jobQueue runs sheet jobs on a fixed number of runners, and keeps finished jobs for the retention period
+type jobQueue struct {
+	server    *server
+	dir       string
+	retention time.Duration
+	pending   chan string
+	// store is nil if jobs are only kept in memory
+	store jobStore
+
+	mu   sync.Mutex
+	jobs map[string]*sheetJob
+	// changed are closed when their job is updated, to wake up those watching it
+	changed map[string]chan struct{}
+}
+
+// newJobQueue saves results into dir, and holds up to size jobs waiting for a runner.
+// Jobs of the store, if any, are restored.
+func newJobQueue(s *server, dir string, size int, retention time.Duration, store jobStore) (*jobQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &jobQueue{
+		server:    s,
+		dir:       dir,
+		retention: retention,
+		pending:   make(chan string, size),
+		store:     store,
+		jobs:      map[string]*sheetJob{},
+		changed:   map[string]chan struct{}{},
+	}
+	if store != nil {
+		if err := q.restore(); err != nil {
+			return nil, fmt.Errorf("failed to restore jobs: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// restore loads the jobs of the store, and queues again those that hadn't finished when the server stopped
+func (q *jobQueue) restore() error {
+	jobs, err := q.store.all()
+	if err != nil {
+		return err
+	}
+	requeued := 0
+	for _, j := range jobs {
+		j := j
+		q.jobs[j.ID] = &j
+		if j.finished() {
+			continue
+		}
+		j.Status, j.Started = jobQueued, nil
+		select {
+		case q.pending <- j.ID:
+			requeued++
+		default:
+			finished := time.Now()
+			j.Status, j.Error, j.Finished = jobFailed, "queue was full when the server restarted", &finished
+		}
+		q.persist(j)
+	}
+	q.prune(time.Now())
+	if requeued > 0 {
+		slog.Info("restored unfinished jobs", "count", requeued)
+	}
+	return nil
+}
+
+// persist saves the job in the store, if there's one. Failing to do so doesn't fail the job.
+func (q *jobQueue) persist(j sheetJob) {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.save(j); err != nil {
+		slog.Error("failed to save job", "id", j.ID, "error", err)
+	}
+}
+
+// run starts n runners that work through the queue until ctx is done
+func (q *jobQueue) run(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-q.pending:
+					q.runJob(ctx, id)
+				}
+			}
+		}()
+	}
+}
+
+// submit queues a job for the input, which is checked already
+func (q *jobQueue) submit(input string, params map[string]string) (sheetJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return sheetJob{}, err
+	}
+	j := &sheetJob{ID: id, Input: input, Params: params, Status: jobQueued, Created: time.Now()}
+	// saved before a runner can pick it up, so its later states aren't overwritten
+	q.persist(*j)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(j.Created)
+	select {
+	case q.pending <- id:
+	default:
+		q.forget(id)
+		return sheetJob{}, errQueueFull
+	}
+	q.jobs[id] = j
+	return *j, nil
+}
+
+// forget removes the job from the store
+func (q *jobQueue) forget(id string) {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.delete(id); err != nil {
+		slog.Error("failed to delete job", "id", id, "error", err)
+	}
+}
+
+// recent returns copies of the latest jobs, newest first
+func (q *jobQueue) recent(limit int) []sheetJob {
+	q.mu.Lock()
+	jobs := make([]sheetJob, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, *j)
+	}
+	q.mu.Unlock()
+	slices.SortFunc(jobs, func(a, b sheetJob) bool {
+		return a.Created.After(b.Created)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// counts returns how many jobs there are of each status
+func (q *jobQueue) counts() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	counts := map[string]int{jobQueued: 0, jobRunning: 0, jobDone: 0, jobFailed: 0}
+	for _, j := range q.jobs {
+		counts[j.Status]++
+	}
+	return counts
+}
+
+// get returns a copy of the job
+func (q *jobQueue) get(id string) (sheetJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return sheetJob{}, false
+	}
+	return *j, true
+}
+
+// update changes the job under the lock, and wakes up those watching it
+func (q *jobQueue) update(id string, f func(j *sheetJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return
+	}
+	f(j)
+	if ch, ok := q.changed[id]; ok {
+		close(ch)
+		delete(q.changed, id)
+	}
+}
+
+// watch returns a copy of the job, and a channel that's closed when it's next updated
+func (q *jobQueue) watch(id string) (sheetJob, <-chan struct{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return sheetJob{}, nil, false
+	}
+	ch, ok := q.changed[id]
+	if !ok {
+		ch = make(chan struct{})
+		q.changed[id] = ch
+	}
+	return *j, ch, true
+}
+
+// prune forgets jobs that finished more than the retention period ago, and removes their results
+func (q *jobQueue) prune(now time.Time) {
+	for id, j := range q.jobs {
+		if j.finished() && now.Sub(*j.Finished) > q.retention {
+			if j.ResultPath != "" {
+				_ = os.Remove(j.ResultPath)
+			}
+			delete(q.jobs, id)
+			delete(q.changed, id)
+			q.forget(id)
+		}
+	}
+}
+
+func (q *jobQueue) runJob(ctx context.Context, id string) {
+	j, ok := q.get(id)
+	if !ok {
+		return
+	}
+	started := time.Now()
+	q.update(id, func(j *sheetJob) {
+		j.Status = jobRunning
+		j.Started = &started
+	})
+	q.persistByID(id)
+
+	resultPath, err := q.generate(ctx, j)
+	if err != nil && ctx.Err() != nil {
+		// the server is stopping, and the job runs again when it's restored
+		return
+	}
+	finished := time.Now()
+	q.update(id, func(j *sheetJob) {
+		j.Finished = &finished
+		if err != nil {
+			j.Status = jobFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = jobDone
+		j.ResultPath = resultPath
+		j.Progress = &jobProgress{Stage: "done", Percent: 100}
+	})
+	q.persistByID(id)
+	if err != nil {
+		slog.Error("job failed", "id", id, "path", j.Input, "error", err)
+		return
+	}
+	slog.Info("job done", "id", id, "path", j.Input, "elapsed", finished.Sub(started))
+}
+
+func (q *jobQueue) persistByID(id string) {
+	if j, ok := q.get(id); ok {
+		q.persist(j)
+	}
+}
+
+// generate makes the sheet of the job and saves it in the results directory
+func (q *jobQueue) generate(ctx context.Context, j sheetJob) (string, error) {
+	// checked again, as restored jobs were queued with the roots and schemes of the server then
+	input, err := q.server.input(j.Input)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	for k, v := range j.Params {
+		params.Set(k, v)
+	}
+	opts, err := q.server.options(params)
+	if err != nil {
+		return "", err
+	}
+	opts.Progress = func(p thumber.Progress) {
+		q.update(j.ID, func(j *sheetJob) {
+			j.Progress = &jobProgress{Stage: p.Stage, Frame: p.Frame, Frames: p.Frames, Percent: p.Percent, ETAMs: p.ETA.Milliseconds()}
+		})
+	}
+	sheet, err := q.server.generate(ctx, input, opts)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(q.dir, j.ID+".jpg")
+	if err := writeJPEG(path, "", sheet.Image, q.server.cmd.JPEGQuality); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleJobs creates jobs on POST /jobs, lists them on GET /jobs, and reports them on GET /jobs/{id},
+// GET /jobs/{id}/result and GET /jobs/{id}/events
+func (q *jobQueue) handleJobs(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodPost:
+			q.createJob(w, r)
+		case http.MethodGet:
+			q.listJobs(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, sub, _ := strings.Cut(rest, "/")
+	j, ok := q.get(id)
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	switch sub {
+	case "":
+		writeJSON(w, http.StatusOK, j)
+	case "result":
+		q.writeResult(w, r, j)
+	case "events":
+		q.streamEvents(w, r, j.ID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// defaultJobsLimit is how many jobs GET /jobs lists without a limit parameter
+const defaultJobsLimit = 50
+
+// listJobs responds with the latest jobs, newest first
+func (q *jobQueue) listJobs(w http.ResponseWriter, r *http.Request) {
+	limit := defaultJobsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, q.recent(limit))
+}
+
+// maxJobRequestSize bounds the JSON body of POST /jobs
+const maxJobRequestSize = 64 << 10
+
+// createJob queues a job for the path parameter, with the same parameters as GET /sheet
+func (q *jobQueue) createJob(w http.ResponseWriter, r *http.Request) {
+	form, err := jobForm(w, r)
+	if errors.Is(err, errNotJSON) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input, err := q.server.input(form.Get("path"))
+	if err != nil {
+		writeInputError(w, err)
+		return
+	}
+	// options are checked now, so invalid ones fail the request rather than the job
+	if _, err := q.server.options(form); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := map[string]string{}
+	for _, name := range jobParams {
+		if v := form.Get(name); v != "" {
+			params[name] = v
+		}
+	}
+
+	j, err := q.submit(input, params)
+	if errors.Is(err, errQueueFull) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create job", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("job queued", "id", j.ID, "path", input, "remote", r.RemoteAddr)
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// errNotJSON is returned for job requests that aren't JSON
+var errNotJSON = errors.New("content type must be application/json")
+
+// jobForm reads the parameters of POST /jobs from a JSON object of strings and numbers. Browsers can't send
+// JSON to another origin without a preflight, so other pages can't submit jobs to a server on localhost.
+func jobForm(w http.ResponseWriter, r *http.Request) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil, errNotJSON
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestSize))
+	dec.UseNumber()
+	var body map[string]any
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	form := url.Values{}
+	for k, v := range body {
+		switch v := v.(type) {
+		case string:
+			form.Set(k, v)
+		case json.Number:
+			form.Set(k, v.String())
+		default:
+			return nil, fmt.Errorf("invalid %s, must be a string or number", k)
+		}
+	}
+	return form, nil
+}
+
+// writeResult responds with the sheet of a finished job, or a conflict if it isn't done
+func (q *jobQueue) writeResult(w http.ResponseWriter, r *http.Request, j sheetJob) {
+	switch j.Status {
+	case jobDone:
+		w.Header().Set("Content-Type", "image/jpeg")
+		http.ServeFile(w, r, j.ResultPath)
+	case jobFailed:
+		http.Error(w, fmt.Sprintf("job failed: %s", j.Error), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("job is %s", j.Status), http.StatusConflict)
+	}
+}
+
+// streamEvents sends the job as server-sent events as it changes, a progress event for each update and a done
+// or failed event when it finishes. Updates that come faster than the client reads are coalesced.
+func (q *jobQueue) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		j, changed, ok := q.watch(id)
+		if !ok {
+			// pruned while watched
+			return
+		}
+		event := "progress"
+		if j.finished() {
+			event = j.Status
+		}
+		data, err := json.Marshal(j)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return
+		}
+		flusher.Flush()
+		if j.finished() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
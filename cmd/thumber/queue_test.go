@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobQueueHandleJobs(t *testing.T) {
+	root, err := resolveRoot(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "video.mp4"), nil, 0o644))
+	s := &server{cmd: serveCmd{Tiles: 20, Columns: 4, TileWidth: 320, MaxTiles: 100, MaxColumns: 20, MaxWidth: 1920}, roots: []string{root}}
+	q, err := newJobQueue(s, t.TempDir(), 1, time.Hour, nil)
+	require.NoError(t, err)
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		q.handleJobs(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		q.handleJobs(rec, r)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnsupportedMediaType, do(http.MethodPost, "/jobs?path=video.mp4").Code, "form posts can come from any page")
+	assert.Equal(t, http.StatusForbidden, post(`{"path": "/etc/passwd"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"path": "video.mp4", "tiles": "x"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"path": ["video.mp4"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`path=video.mp4`).Code)
+
+	rec := post(`{"path": "video.mp4", "tiles": 12}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var created sheetJob
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "/jobs/"+created.ID, rec.Header().Get("Location"))
+	assert.Equal(t, jobQueued, created.Status)
+	assert.Equal(t, map[string]string{"tiles": "12"}, created.Params)
+
+	assert.Equal(t, http.StatusServiceUnavailable, post(`{"path": "video.mp4"}`).Code, "the queue holds one job")
+
+	rec = do(http.MethodGet, "/jobs/"+created.ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"queued"`)
+	rec = do(http.MethodGet, "/jobs/"+created.ID+"/result")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "job is queued"))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/jobs/missing").Code)
+}
+
+func TestJobQueuePrune(t *testing.T) {
+	q, err := newJobQueue(&server{}, t.TempDir(), 10, time.Hour, nil)
+	require.NoError(t, err)
+	result := filepath.Join(q.dir, "old.jpg")
+	require.NoError(t, os.WriteFile(result, nil, 0o644))
+
+	now := time.Now()
+	old, recent := now.Add(-2*time.Hour), now.Add(-time.Minute)
+	q.jobs["old"] = &sheetJob{ID: "old", Status: jobDone, Finished: &old, ResultPath: result}
+	q.jobs["recent"] = &sheetJob{ID: "recent", Status: jobFailed, Finished: &recent}
+	q.jobs["queued"] = &sheetJob{ID: "queued", Status: jobQueued, Created: old}
+	q.prune(now)
+
+	assert.ElementsMatch(t, []string{"recent", "queued"}, keys(q.jobs))
+	assert.NoFileExists(t, result)
+}
+
+func TestJobQueueStreamEvents(t *testing.T) {
+	q, err := newJobQueue(&server{}, t.TempDir(), 10, time.Hour, nil)
+	require.NoError(t, err)
+	j, err := q.submit("video.mp4", nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.handleJobs(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/events", nil))
+	}()
+
+	// each update waits for the stream to catch up, so none of them are coalesced
+	waitWatched := func() {
+		require.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.changed[j.ID] != nil
+		}, time.Second, time.Millisecond)
+	}
+	waitWatched()
+	q.update(j.ID, func(j *sheetJob) {
+		j.Status = jobRunning
+		j.Progress = &jobProgress{Stage: "extract", Frame: 1, Frames: 2, Percent: 45}
+	})
+	waitWatched()
+	q.update(j.ID, func(j *sheetJob) {
+		now := time.Now()
+		j.Status = jobDone
+		j.Finished = &now
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't end when the job finished")
+	}
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	var events []string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+	}
+	assert.Equal(t, []string{"progress", "progress", "done"}, events)
+	assert.Contains(t, rec.Body.String(), `"progress":{"stage":"extract","frame":1,"frames":2,"percent":45}`)
+}
+
+func keys[V any](m map[string]V) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func TestJobQueueGenerateChecksInput(t *testing.T) {
+	s := &server{cmd: serveCmd{Tiles: 20, Columns: 4, TileWidth: 320, MaxTiles: 100, MaxColumns: 20, MaxWidth: 1920}, roots: []string{t.TempDir()}}
+	q, err := newJobQueue(s, t.TempDir(), 10, time.Hour, nil)
+	require.NoError(t, err)
+	_, err = q.generate(context.Background(), sheetJob{ID: "restored", Input: "/etc/passwd"})
+	assert.ErrorIs(t, err, errForbidden, "jobs restored from another configuration are checked again")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// quicklookCmd renders a single frame for preview extensions, which run it under tight budgets.
+// It either writes a complete PNG to stdout or nothing at all, and exits non-zero on failure.
+type quicklookCmd struct {
+	VideoPath string   `arg:"" help:"Path to video"`
+	At        Duration `default:"10" help:"Timestamp of the frame, videos shorter than this use their first frame"`
+	Size      int      `default:"512" help:"Maximum width and height in px"`
+	Timeout   Duration `default:"5s" help:"Give up after this long"`
+	MaxSize   ByteSize `default:"1MB" placeholder:"SIZE" help:"Maximum PNG size, the frame is downscaled until it fits"`
+}
+
+// quicklookMinSize is the smallest the frame is downscaled to when fitting --max-size
+const quicklookMinSize = 64
+
+func (c quicklookCmd) Run() error {
+	at, err := c.At.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	timeout, err := c.Timeout.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	maxBytes, err := c.MaxSize.Bytes()
+	if err != nil {
+		return fmt.Errorf("invalid max size: %w", err)
+	}
+	if c.Size < quicklookMinSize {
+		return fmt.Errorf("size must be at least %d", quicklookMinSize)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	frame, err := extractPosterFrame(ctx, c.VideoPath, thumber.ThumbOptions{
+		From:   at,
+		Limits: thumber.ProcessLimits{Threads: 2},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %s", ctx.Err(), err)
+		}
+		return fmt.Errorf("failed to extract frame: %w", err)
+	}
+
+	out, err := encodePNGWithMaxSize(frame.Image, c.Size, maxBytes)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// encodePNGWithMaxSize fits img within size x size and keeps halving it until it encodes within maxBytes
+func encodePNGWithMaxSize(img image.Image, size int, maxBytes int64) ([]byte, error) {
+	for ; size >= quicklookMinSize; size /= 2 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, imaging.Fit(img, size, size, imaging.Lanczos)); err != nil {
+			return nil, fmt.Errorf("failed to encode as png: %w", err)
+		}
+		if maxBytes == 0 || int64(buf.Len()) <= maxBytes {
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, errors.New("cannot fit frame within max size")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// scenesCmd exports scene boundaries with a representative frame of each scene
+type scenesCmd struct {
+	VideoPath   string  `arg:"" help:"Path to video"`
+	Format      string  `default:"json" enum:"json,csv,edl" help:"Format of the scene list, one of json, csv, edl (CMX 3600 for editors)"`
+	OutputPath  string  `short:"o" help:"Output path of the scene list, use - for stdout. Defaults to $filename.scenes.$format"`
+	Threshold   float64 `default:"0.4" help:"Scene change score from 0 to 1 that counts as a cut, lower finds more scenes"`
+	ThumbsDir   string  `placeholder:"DIR" help:"Directory for the representative frame of each scene. Defaults to $filename.scenes next to the video"`
+	TileWidth   int     `default:"320" help:"Width of the representative frames in px"`
+	JPEGQuality int     `name:"quality" default:"80" help:"JPEG quality"`
+	Concurrency int     `default:"4" help:"How many frames to extract in parallel"`
+}
+
+type sceneEntry struct {
+	Index     int     `json:"index"`
+	StartMs   int64   `json:"start_ms"`
+	EndMs     int64   `json:"end_ms"`
+	Score     float64 `json:"score"`
+	Thumbnail string  `json:"thumbnail"`
+}
+
+func (c scenesCmd) Run(ctx context.Context) error {
+	base := strings.TrimSuffix(c.VideoPath, filepath.Ext(c.VideoPath))
+	outputPath := c.OutputPath
+	if outputPath == "" {
+		outputPath = base + ".scenes." + c.Format
+	}
+	thumbsDir := c.ThumbsDir
+	if thumbsDir == "" {
+		thumbsDir = base + ".scenes"
+	}
+
+	scenes, err := thumber.DetectScenes(ctx, c.VideoPath, c.Threshold, thumber.ThumbOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to detect scenes: %w", err)
+	}
+	var fps float64
+	if c.Format == "edl" {
+		// checked before extracting any frames
+		if len(scenes) > maxEDLEvents {
+			return errTooManyEvents(len(scenes))
+		}
+		if fps, err = thumber.ReadFrameRate(ctx, c.VideoPath); err != nil {
+			return fmt.Errorf("failed to read frame rate: %w", err)
+		}
+	}
+
+	middles := make([]time.Duration, len(scenes))
+	for i, s := range scenes {
+		middles[i] = s.Middle()
+	}
+	frames, err := thumber.ExtractThumbnails(ctx, c.VideoPath, middles, thumber.ThumbOptions{
+		TileWidth:   c.TileWidth,
+		Concurrency: c.Concurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extract scene frames: %w", err)
+	}
+	if err := os.MkdirAll(thumbsDir, 0o755); err != nil {
+		return err
+	}
+
+	// thumbnails are linked relative to the list, so they can be moved together
+	listDir := filepath.Dir(outputPath)
+	if outputPath == "-" {
+		listDir = "."
+	}
+	entries := make([]sceneEntry, len(scenes))
+	for i, s := range scenes {
+		path := filepath.Join(thumbsDir, fmt.Sprintf("scene-%04d.jpg", i+1))
+		if err := writeJPEG(path, c.VideoPath, frames[i].Image, c.JPEGQuality); err != nil {
+			return fmt.Errorf("failed to write scene frame: %w", err)
+		}
+		rel, err := filepath.Rel(listDir, path)
+		if err != nil {
+			rel = path
+		}
+		entries[i] = sceneEntry{
+			Index:     i + 1,
+			StartMs:   s.Start.Milliseconds(),
+			EndMs:     s.End.Milliseconds(),
+			Score:     math.Round(s.Score*1000) / 1000,
+			Thumbnail: filepath.ToSlash(rel),
+		}
+	}
+
+	f, err := createOutput(outputPath, c.VideoPath, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeSceneList(f, c.Format, c.VideoPath, entries, fps); err != nil {
+		return fmt.Errorf("failed to write scene list: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return err
+	}
+	slog.Info("exported scenes", "path", c.VideoPath, "scenes", len(scenes), "output", f.Path(), "thumbnails", thumbsDir)
+	return nil
+}
+
+func writeSceneList(w io.Writer, format, videoPath string, scenes []sceneEntry, fps float64) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"index", "start_ms", "end_ms", "score", "thumbnail"})
+		for _, s := range scenes {
+			_ = cw.Write([]string{
+				strconv.Itoa(s.Index),
+				strconv.FormatInt(s.StartMs, 10),
+				strconv.FormatInt(s.EndMs, 10),
+				strconv.FormatFloat(s.Score, 'f', -1, 64),
+				s.Thumbnail,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "edl":
+		return writeEDL(w, videoPath, scenes, fps)
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Video  string       `json:"video"`
+			Scenes []sceneEntry `json:"scenes"`
+		}{videoPath, scenes})
+	}
+}
+
+// maxEDLEvents is the most events a CMX 3600 edit decision list can number in its 3 digit column
+const maxEDLEvents = 999
+
+func errTooManyEvents(n int) error {
+	return fmt.Errorf("%d scenes don't fit into the %d events of an edl, use --format json or csv, or a higher --threshold", n, maxEDLEvents)
+}
+
+// writeEDL writes a CMX 3600 edit decision list with an event for each scene, in non drop frame timecode.
+// Events are numbered in order, and follow each other on the record timeline from 00:00:00:00.
+// Representative frames are noted in comments, which editors ignore.
+func writeEDL(w io.Writer, videoPath string, scenes []sceneEntry, fps float64) error {
+	if len(scenes) > maxEDLEvents {
+		return errTooManyEvents(len(scenes))
+	}
+	name := filepath.Base(videoPath)
+	if _, err := fmt.Fprintf(w, "TITLE: %s\nFCM: NON-DROP FRAME\n\n", strings.TrimSuffix(name, filepath.Ext(name))); err != nil {
+		return err
+	}
+	var record int64
+	for i, s := range scenes {
+		// counted in frames, so rounding doesn't leave gaps between events
+		in := edlFrames(time.Duration(s.StartMs)*time.Millisecond, fps)
+		out := edlFrames(time.Duration(s.EndMs)*time.Millisecond, fps)
+		recordOut := record + out - in
+		_, err := fmt.Fprintf(w, "%03d  AX       V     C        %s %s %s %s\n* FROM CLIP NAME: %s\n* THUMBNAIL: %s\n\n",
+			i+1, frameTimecode(in, fps), frameTimecode(out, fps), frameTimecode(record, fps), frameTimecode(recordOut, fps), name, s.Thumbnail)
+		if err != nil {
+			return err
+		}
+		record = recordOut
+	}
+	return nil
+}
+
+// edlTimecode formats t as hh:mm:ss:ff, counting frames at the nominal rate as non drop frame timecode does
+func edlTimecode(t time.Duration, fps float64) string {
+	return frameTimecode(edlFrames(t, fps), fps)
+}
+
+// edlFrames is the number of the frame at t
+func edlFrames(t time.Duration, fps float64) int64 {
+	return int64(math.Round(t.Seconds() * fps))
+}
+
+// frameTimecode formats a frame number as hh:mm:ss:ff at the nominal rate of fps
+func frameTimecode(frames int64, fps float64) string {
+	nominal := int64(math.Round(fps))
+	if nominal < 1 {
+		nominal = 25
+	}
+	ff := frames % nominal
+	secs := frames / nominal
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", secs/3600, secs/60%60, secs%60, ff)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEDLTimecode(t *testing.T) {
+	assert.Equal(t, "00:00:00:00", edlTimecode(0, 25))
+	assert.Equal(t, "00:01:02:12", edlTimecode(62*time.Second+480*time.Millisecond, 25))
+	assert.Equal(t, "01:00:00:00", edlTimecode(time.Hour, 24))
+}
+
+func TestWriteSceneList(t *testing.T) {
+	scenes := []sceneEntry{
+		{Index: 1, StartMs: 0, EndMs: 4000, Thumbnail: "video.scenes/scene-0001.jpg"},
+		{Index: 2, StartMs: 4000, EndMs: 10000, Score: 0.62, Thumbnail: "video.scenes/scene-0002.jpg"},
+	}
+
+	var csv strings.Builder
+	require.NoError(t, writeSceneList(&csv, "csv", "video.mp4", scenes, 0))
+	assert.Equal(t, "index,start_ms,end_ms,score,thumbnail\n1,0,4000,0,video.scenes/scene-0001.jpg\n2,4000,10000,0.62,video.scenes/scene-0002.jpg\n", csv.String())
+
+	var edl strings.Builder
+	require.NoError(t, writeSceneList(&edl, "edl", "dir/video.mp4", scenes, 25))
+	assert.Contains(t, edl.String(), "TITLE: video\nFCM: NON-DROP FRAME\n")
+	assert.Contains(t, edl.String(), "002  AX       V     C        00:00:04:00 00:00:10:00 00:00:04:00 00:00:10:00\n* FROM CLIP NAME: video.mp4\n")
+
+	// events follow each other on the record timeline, whatever the source timecodes
+	edl.Reset()
+	later := []sceneEntry{{Index: 3, StartMs: 20000, EndMs: 22000}, {Index: 5, StartMs: 30000, EndMs: 31500}}
+	require.NoError(t, writeSceneList(&edl, "edl", "video.mp4", later, 25))
+	assert.Contains(t, edl.String(), "001  AX       V     C        00:00:20:00 00:00:22:00 00:00:00:00 00:00:02:00\n")
+	assert.Contains(t, edl.String(), "002  AX       V     C        00:00:30:00 00:00:31:13 00:00:02:00 00:00:03:13\n")
+
+	assert.Error(t, writeSceneList(io.Discard, "edl", "video.mp4", make([]sceneEntry, maxEDLEvents+1), 25))
+}
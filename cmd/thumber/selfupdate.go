@@ -0,0 +1,318 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+	"golang.org/x/mod/semver"
+
+	"github.com/abdusco/thumber/version"
+)
+
+const releasesURL = "https://api.github.com/repos/abdusco/thumber/releases/latest"
+
+// updateKey is the minisign public key releases are signed with, the base64 line of its .pub file. Release builds set
+// it with -ldflags "-X main.updateKey=RWQ...". Without it, archives are only checked against the checksum published
+// next to them, which doesn't help if the release itself is tampered with.
+var updateKey string
+
+type selfUpdateCmd struct {
+	Force  bool `help:"Install the latest release even if it's not newer than this build"`
+	DryRun bool `help:"Only check for a newer release"`
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r githubRelease) assetURL(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+func (c selfUpdateCmd) Run() error {
+	ctx := context.Background()
+
+	var release githubRelease
+	body, err := httpGet(ctx, releasesURL)
+	if err != nil {
+		return fmt.Errorf("failed to check latest release: %w", err)
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return fmt.Errorf("failed to parse release: %w", err)
+	}
+
+	if !c.Force {
+		newer, err := isNewerRelease(release.TagName, version.Version.Tag)
+		if err != nil {
+			return fmt.Errorf("%w, use --force to update anyway", err)
+		}
+		if !newer {
+			fmt.Printf("already up to date with %s\n", release.TagName)
+			return nil
+		}
+	}
+	if c.DryRun {
+		fmt.Printf("%s is available\n", release.TagName)
+		return nil
+	}
+
+	archiveName := fmt.Sprintf("thumber-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		archiveName = fmt.Sprintf("thumber-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	}
+	archiveURL, ok := release.assetURL(archiveName)
+	if !ok {
+		return fmt.Errorf("release %s has no build for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	checksumURL, ok := release.assetURL(archiveName + ".sha256")
+	if !ok {
+		return fmt.Errorf("release %s has no checksum for %s, refusing to update", release.TagName, archiveName)
+	}
+	signatureURL, ok := release.assetURL(archiveName + ".minisig")
+	if !ok && updateKey != "" {
+		return fmt.Errorf("release %s has no signature for %s, refusing to update", release.TagName, archiveName)
+	}
+
+	slog.Debug("downloading release", "tag", release.TagName, "url", archiveURL)
+	archive, err := httpGet(ctx, archiveURL)
+	if err != nil {
+		return fmt.Errorf("failed to download release: %w", err)
+	}
+	checksum, err := httpGet(ctx, checksumURL)
+	if err != nil {
+		return fmt.Errorf("failed to download checksum: %w", err)
+	}
+	if err := verifySHA256(archive, string(checksum)); err != nil {
+		return err
+	}
+	if updateKey == "" {
+		slog.Warn("this build has no key to verify releases with, only checking the checksum")
+	} else {
+		signature, err := httpGet(ctx, signatureURL)
+		if err != nil {
+			return fmt.Errorf("failed to download signature: %w", err)
+		}
+		if err := verifyMinisign(archive, string(signature), updateKey); err != nil {
+			return fmt.Errorf("invalid signature of %s: %w", archiveName, err)
+		}
+	}
+
+	binary, err := extractBinary(archiveName, archive)
+	if err != nil {
+		return fmt.Errorf("failed to extract binary: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate running binary: %w", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("failed to locate running binary: %w", err)
+	}
+	if err := replaceBinary(exe, binary); err != nil {
+		return fmt.Errorf("failed to replace binary: %w", err)
+	}
+
+	fmt.Printf("updated to %s\n", release.TagName)
+	return nil
+}
+
+// isNewerRelease compares the semantic versions of the release and of this build, with or without a v prefix
+func isNewerRelease(tag, current string) (bool, error) {
+	tag, current = canonicalVersion(tag), canonicalVersion(current)
+	if !semver.IsValid(current) {
+		return false, fmt.Errorf("cannot tell the version of this build")
+	}
+	if !semver.IsValid(tag) {
+		return false, fmt.Errorf("invalid release version %q", tag)
+	}
+	return semver.Compare(tag, current) > 0, nil
+}
+
+func canonicalVersion(v string) string {
+	if v != "" && !strings.HasPrefix(v, "v") {
+		return "v" + v
+	}
+	return v
+}
+
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, url)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// verifySHA256 checks data against a checksum file in sha256sum format
+func verifySHA256(data []byte, checksumFile string) error {
+	fields := strings.Fields(checksumFile)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file")
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, fields[0]) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", fields[0], got)
+	}
+	return nil
+}
+
+// verifyMinisign checks data against a minisign signature file made with the secret key of publicKey,
+// and the trusted comment of the signature against its global signature
+func verifyMinisign(data []byte, signatureFile, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || string(key[:2]) != "Ed" {
+		return fmt.Errorf("invalid public key")
+	}
+	lines := strings.Split(strings.ReplaceAll(signatureFile, "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return fmt.Errorf("malformed signature file")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("malformed signature")
+	}
+	if !bytes.Equal(sig[2:10], key[2:10]) {
+		return fmt.Errorf("signed with key %X, not %X", sig[2:10], key[2:10])
+	}
+
+	pub := ed25519.PublicKey(key[10:])
+	message := data
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		// prehashed, the default of minisign since 0.10
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(pub, message, sig[10:]) {
+		return fmt.Errorf("signature doesn't match")
+	}
+
+	comment, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("malformed trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(pub, append(slices.Clone(sig[10:]), comment...), global) {
+		return fmt.Errorf("trusted comment doesn't match")
+	}
+	return nil
+}
+
+func extractBinary(archiveName string, archive []byte) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if filepath.Base(f.Name) == "thumber.exe" {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("thumber.exe not found in archive")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("thumber not found in archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag == tar.TypeReg && filepath.Base(h.Name) == "thumber" {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// replaceBinary writes the new binary next to the old one and renames it into place,
+// so a failed update never leaves a half-written executable behind
+func replaceBinary(path string, binary []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumber-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	// windows can't overwrite a running executable, but it can rename it
+	old := path + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Rename(old, path)
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		_ = os.Remove(old)
+	}
+	return nil
+}
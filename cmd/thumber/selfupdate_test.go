@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/exp/slices"
+)
+
+func TestIsNewerRelease(t *testing.T) {
+	for _, tc := range []struct {
+		tag, current string
+		want         bool
+	}{
+		{tag: "v1.2.0", current: "v1.2.0", want: false},
+		{tag: "v1.2.0", current: "v1.1.9", want: true},
+		{tag: "1.10.0", current: "v1.9.0", want: true},
+		{tag: "v1.2.0", current: "v1.3.0-rc.1", want: false},
+		{tag: "v1.3.0", current: "v1.3.0-rc.1", want: true},
+	} {
+		got, err := isNewerRelease(tc.tag, tc.current)
+		if assert.NoError(t, err, tc) {
+			assert.Equal(t, tc.want, got, tc)
+		}
+	}
+
+	_, err := isNewerRelease("v1.2.0", "")
+	assert.ErrorContains(t, err, "cannot tell the version")
+	_, err = isNewerRelease("nightly", "v1.2.0")
+	assert.ErrorContains(t, err, "invalid release version")
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+	archive := []byte("thumber-linux-amd64.tar.gz")
+
+	// sign lays out a signature file like minisign -S
+	sign := func(alg string, id []byte, data []byte, comment string) string {
+		message := data
+		if alg == "ED" {
+			sum := blake2b.Sum512(data)
+			message = sum[:]
+		}
+		sig := ed25519.Sign(priv, message)
+		global := ed25519.Sign(priv, append(slices.Clone(sig), comment...))
+		return "untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte(alg), id...), sig...)) + "\n" +
+			"trusted comment: " + comment + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n"
+	}
+
+	assert.NoError(t, verifyMinisign(archive, sign("ED", keyID, archive, "timestamp:1700000000"), publicKey))
+	assert.NoError(t, verifyMinisign(archive, sign("Ed", keyID, archive, "timestamp:1700000000"), publicKey), "legacy signatures")
+
+	assert.EqualError(t, verifyMinisign([]byte("tampered"), sign("ED", keyID, archive, "c"), publicKey), "signature doesn't match")
+	assert.ErrorContains(t, verifyMinisign(archive, sign("ED", []byte{8, 7, 6, 5, 4, 3, 2, 1}, archive, "c"), publicKey), "signed with key 0807060504030201")
+	forged := strings.Replace(sign("ED", keyID, archive, "timestamp:1"), "timestamp:1", "timestamp:2", 1)
+	assert.EqualError(t, verifyMinisign(archive, forged, publicKey), "trusted comment doesn't match")
+	assert.EqualError(t, verifyMinisign(archive, "untrusted comment: nothing", publicKey), "malformed signature file")
+	assert.EqualError(t, verifyMinisign(archive, sign("ED", keyID, archive, "c"), "RWQ"), "invalid public key")
+}
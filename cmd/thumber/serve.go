@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// serveCmd serves contact sheets over HTTP. Inputs are confined to the roots and URL schemes it's given,
+// so the server can't be used to read arbitrary files.
+type serveCmd struct {
+	Addr         string   `default:"localhost:8080" help:"Address to listen on"`
+	Root         []string `placeholder:"DIR" help:"Directories the path parameter may point into, checked after resolving symlinks. Relative paths are relative to the first. Repeat for several"`
+	AllowScheme  []string `placeholder:"SCHEME" help:"URL schemes the path parameter may use, of http, https and s3. Remote inputs are refused without it. Repeat for several"`
+	Tiles        int      `default:"20" help:"Number of tiles, unless the request sets tiles"`
+	Columns      int      `default:"4" help:"Columns of tile grid, unless the request sets columns"`
+	TileWidth    int      `default:"320" help:"Tile width in px, unless the request sets width"`
+	MaxTiles     int      `default:"100" help:"Largest tiles a request may set"`
+	MaxColumns   int      `default:"20" help:"Largest columns a request may set"`
+	MaxWidth     int      `default:"1920" help:"Largest width in px a request may set"`
+	JPEGQuality  int      `name:"quality" default:"80" help:"JPEG quality"`
+	Concurrency  int      `default:"4" help:"How many frames to extract in parallel for each request"`
+	Workers      int      `default:"8" help:"How many ffmpeg extraction processes all requests and jobs share"`
+	Jobs         int      `default:"2" help:"How many jobs of POST /jobs run at once"`
+	QueueSize    int      `default:"100" help:"How many jobs may wait for a runner before POST /jobs is refused"`
+	JobRetention Duration `default:"24h" help:"How long finished jobs and their results are kept"`
+	DB           string   `name:"db" placeholder:"PATH" help:"Keep jobs in this SQLite database, so queued jobs survive restarts. Results are kept with them only with --cache-dir"`
+	MaxUpload    ByteSize `default:"2GB" placeholder:"SIZE" help:"Largest video that can be uploaded to POST /sheet"`
+	CacheDir     string   `placeholder:"DIR" help:"Keep sheets here for requests with response=url, which get a signed URL to fetch them from instead of the image"`
+	MaxCacheSize ByteSize `default:"1GB" placeholder:"SIZE" help:"Remove the oldest cached sheets once they take more than this, 0 for no limit. Sheets are removed anyway once their URLs expire"`
+	SigningKey   string   `env:"THUMBER_SIGNING_KEY" help:"Key that signs URLs of cached sheets, so they stay valid across restarts. Defaults to a random key"`
+	URLExpiry    Duration `name:"url-expiry" default:"1h" help:"How long signed URLs of cached sheets are valid"`
+	AdminToken   string   `env:"THUMBER_ADMIN_TOKEN" help:"Require this token for /ui and /metrics, as the password of basic auth or a bearer token. Both are open without it"`
+	// unlike generate, decoding is limited by default, as inputs come from clients
+	MaxFramePixels int64    `default:"36000000" placeholder:"PIXELS" help:"Refuse to decode frames larger than this many pixels, 0 for no limit"`
+	MaxFrameOutput ByteSize `default:"64MB" placeholder:"SIZE" help:"Stop ffmpeg if it writes more than this for a single frame, 0 for no limit"`
+}
+
+// errForbidden is returned for inputs outside of the roots and allowed schemes
+var errForbidden = errors.New("input is not allowed")
+
+// remoteSchemes are the URL schemes of inputs that can be allowed
+var remoteSchemes = []string{"http", "https", "s3"}
+
+type server struct {
+	cmd serveCmd
+	// roots are the resolved --root directories
+	roots     []string
+	schemes   []string
+	maxUpload int64
+	limits    thumber.ProcessLimits
+	workers   *thumber.Workers
+	// cache is nil without --cache-dir
+	cache   *sheetCache
+	jobs    *jobQueue
+	metrics serverMetrics
+}
+
+func (c serveCmd) Run(ctx context.Context) error {
+	s := &server{cmd: c, workers: thumber.NewWorkers(c.Workers)}
+	s.metrics.started = time.Now()
+	for _, root := range c.Root {
+		resolved, err := resolveRoot(root)
+		if err != nil {
+			return fmt.Errorf("invalid root %s: %w", root, err)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	for _, scheme := range c.AllowScheme {
+		scheme = strings.ToLower(scheme)
+		if !slices.Contains(remoteSchemes, scheme) {
+			return fmt.Errorf("invalid scheme %q, must be one of %s", scheme, strings.Join(remoteSchemes, ", "))
+		}
+		s.schemes = append(s.schemes, scheme)
+	}
+	if len(s.roots) == 0 && len(s.schemes) == 0 {
+		slog.Warn("no --root or --allow-scheme given, only uploads will be accepted")
+	}
+	maxUpload, err := c.MaxUpload.Bytes()
+	if err != nil {
+		return fmt.Errorf("invalid max upload: %w", err)
+	}
+	s.maxUpload = maxUpload
+	maxOutput, err := c.MaxFrameOutput.Bytes()
+	if err != nil {
+		return fmt.Errorf("invalid max frame output: %w", err)
+	}
+	if c.MaxFramePixels < 0 {
+		return fmt.Errorf("max frame pixels can't be negative")
+	}
+	s.limits = thumber.ProcessLimits{MaxFramePixels: c.MaxFramePixels, MaxOutputBytes: maxOutput}
+	if c.CacheDir != "" {
+		maxCache, err := c.MaxCacheSize.Bytes()
+		if err != nil {
+			return fmt.Errorf("invalid max cache size: %w", err)
+		}
+		if s.cache, err = newSheetCache(c.CacheDir, c.SigningKey, c.URLExpiry, maxCache); err != nil {
+			return err
+		}
+	}
+
+	// results of jobs are kept with the cache, or for as long as the server runs without one
+	jobsDir := filepath.Join(c.CacheDir, "jobs")
+	if c.CacheDir == "" {
+		ws, err := thumber.NewWorkspace("", 0)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		jobsDir = ws.Dir()
+	}
+	retention, err := c.JobRetention.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid job retention: %w", err)
+	}
+	if c.Jobs < 1 || c.QueueSize < 1 {
+		return fmt.Errorf("jobs and queue size must be positive")
+	}
+	var store jobStore
+	if c.DB != "" {
+		sqlite, err := openSQLiteStore(c.DB)
+		if err != nil {
+			return err
+		}
+		defer sqlite.Close()
+		store = sqlite
+	}
+	if s.jobs, err = newJobQueue(s, jobsDir, c.QueueSize, retention, store); err != nil {
+		return err
+	}
+	s.jobs.run(ctx, c.Jobs)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sheet", s.handleSheet)
+	mux.HandleFunc("/jobs", s.jobs.handleJobs)
+	mux.HandleFunc("/jobs/", s.jobs.handleJobs)
+	if s.cache != nil {
+		mux.HandleFunc("/cache/", s.cache.handleCache)
+	}
+	mux.Handle("/metrics", requireToken(c.AdminToken, http.HandlerFunc(s.handleMetrics)))
+	mux.Handle("/ui/", requireToken(c.AdminToken, handleUI()))
+	mux.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	srv := &http.Server{
+		Addr:              c.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// requests run with the command context, which carries the ffmpeg sandbox
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go thumber.WatchOrphans(ctx, time.Minute)
+
+	slog.Info("serving contact sheets", "addr", c.Addr, "ui", "http://"+c.Addr+"/ui/", "roots", s.roots, "schemes", s.schemes)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func resolveRoot(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// handleSheet makes the contact sheet of the path parameter on GET, or of an uploaded video on POST
+func (s *server) handleSheet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getSheet(w, r)
+	case http.MethodPost:
+		s.postSheet(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSheet responds with the contact sheet of the path parameter
+func (s *server) getSheet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	input, err := s.input(query.Get("path"))
+	if err != nil {
+		writeInputError(w, err)
+		return
+	}
+	opts, err := s.options(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("generating contact sheet", "path", input, "remote", r.RemoteAddr)
+	sheet, err := s.generate(r.Context(), input, opts)
+	if errors.Is(err, errForbidden) {
+		writeInputError(w, err)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to generate contact sheet", "path", input, "error", err)
+		s.metrics.failed.Add(1)
+		http.Error(w, "failed to generate contact sheet", http.StatusInternalServerError)
+		return
+	}
+	s.respond(w, r, sheet)
+}
+
+// respond writes the sheet as JPEG, or with response=url caches it and writes a signed URL to it as JSON
+func (s *server) respond(w http.ResponseWriter, r *http.Request, sheet thumber.Sheet) {
+	s.metrics.sheets.Add(1)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet.Image, &jpeg.Options{Quality: s.cmd.JPEGQuality}); err != nil {
+		http.Error(w, "failed to encode contact sheet", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("response") != "url" {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+	if s.cache == nil {
+		http.Error(w, "response=url needs the server to run with --cache-dir", http.StatusBadRequest)
+		return
+	}
+	name, err := s.cache.store(buf.Bytes())
+	if err != nil {
+		slog.Error("failed to cache contact sheet", "error", err)
+		http.Error(w, "failed to cache contact sheet", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"url": s.cache.signedURL(name, time.Now())})
+}
+
+// writeInputError responds to a path parameter that input refused
+func writeInputError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// input checks the path parameter against the allowed schemes and roots, and returns what to generate the sheet of
+func (s *server) input(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	// anything with a scheme is refused unless allowed, including ffmpeg protocols like concat: and file:.
+	// Single letters are Windows drives.
+	if u, err := url.Parse(p); err == nil && len(u.Scheme) > 1 {
+		if !slices.Contains(s.schemes, strings.ToLower(u.Scheme)) {
+			return "", fmt.Errorf("%w: scheme %s", errForbidden, u.Scheme)
+		}
+		return p, nil
+	}
+	if len(s.roots) == 0 {
+		return "", fmt.Errorf("%w: no roots", errForbidden)
+	}
+	return resolveLocal(p, s.roots)
+}
+
+// resolveLocal resolves the symlinks of p and checks that it's within one of the roots, which are resolved too.
+// Relative paths are relative to the first root.
+func resolveLocal(p string, roots []string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(roots[0], p)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	if !withinRoots(resolved, roots) {
+		return "", fmt.Errorf("%w: outside of roots", errForbidden)
+	}
+	return resolved, nil
+}
+
+// withinRoots reports whether the resolved path p is one of the roots or inside one
+func withinRoots(p string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// options are the flags, overridden by the tiles, columns and width parameters up to their maximums
+func (s *server) options(query url.Values) (thumber.ThumbOptions, error) {
+	opts := thumber.ThumbOptions{
+		TileCount:         s.cmd.Tiles,
+		TileColumns:       s.cmd.Columns,
+		TileWidth:         s.cmd.TileWidth,
+		OverlayTimestamps: true,
+		Concurrency:       s.cmd.Concurrency,
+		Workers:           s.workers,
+		Limits:            s.limits,
+	}
+	for _, param := range []struct {
+		name  string
+		field *int
+		max   int
+	}{
+		{"tiles", &opts.TileCount, s.cmd.MaxTiles},
+		{"columns", &opts.TileColumns, s.cmd.MaxColumns},
+		{"width", &opts.TileWidth, s.cmd.MaxWidth},
+	} {
+		v := query.Get(param.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s %q", param.name, v)
+		}
+		if n > param.max {
+			return opts, fmt.Errorf("%s %d is larger than the maximum of %d", param.name, n, param.max)
+		}
+		*param.field = n
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid options: %w", err)
+	}
+	return opts, nil
+}
+
+// generate makes the sheet of a local file, an adaptive stream, or a remote input that ffmpeg reads with range
+// requests, or downloads a remote input first if its server doesn't support them.
+// ffmpeg may only use the protocols of the input, and playlists are checked like the input.
+func (s *server) generate(ctx context.Context, input string, opts thumber.ThumbOptions) (thumber.Sheet, error) {
+	ctx = thumber.WithProtocols(ctx, inputProtocols(input)...)
+	if thumber.IsAdaptive(input) {
+		if err := s.checkPlaylist(ctx, input); err != nil {
+			return thumber.Sheet{}, err
+		}
+	}
+	if !thumber.IsRemote(input) || thumber.IsAdaptive(input) {
+		return thumber.GenerateSheet(ctx, input, opts)
+	}
+	if thumber.CanStream(ctx, input) {
+		src, err := thumber.RemoteURL(input)
+		if err != nil {
+			return thumber.Sheet{}, err
+		}
+		return thumber.GenerateSheet(ctx, src, opts)
+	}
+	ws, err := thumber.NewWorkspace("", 0)
+	if err != nil {
+		return thumber.Sheet{}, err
+	}
+	defer ws.Close()
+	videoPath, err := thumber.Download(ctx, ws, input, thumber.DownloadOptions{})
+	if err != nil {
+		return thumber.Sheet{}, err
+	}
+	return thumber.GenerateSheet(ctx, videoPath, opts)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+func TestServerInput(t *testing.T) {
+	root, err := resolveRoot(t.TempDir())
+	require.NoError(t, err)
+	outside, err := resolveRoot(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "video.mp4"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.mp4"), nil, 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.mp4"), filepath.Join(root, "link.mp4")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "video.mp4"), filepath.Join(outside, "inside.mp4")))
+
+	s := &server{roots: []string{root}, schemes: []string{"https"}}
+	for _, tc := range []struct {
+		path string
+		want string
+		err  error
+	}{
+		{path: "video.mp4", want: filepath.Join(root, "video.mp4")},
+		{path: filepath.Join(root, "video.mp4"), want: filepath.Join(root, "video.mp4")},
+		{path: filepath.Join(outside, "inside.mp4"), want: filepath.Join(root, "video.mp4")},
+		{path: "https://example.com/video.mp4", want: "https://example.com/video.mp4"},
+		{path: "../" + filepath.Base(outside) + "/secret.mp4", err: errForbidden},
+		{path: filepath.Join(outside, "secret.mp4"), err: errForbidden},
+		{path: "link.mp4", err: errForbidden},
+		{path: "http://example.com/video.mp4", err: errForbidden},
+		{path: "file://" + filepath.Join(root, "video.mp4"), err: errForbidden},
+		{path: "concat:" + filepath.Join(outside, "secret.mp4"), err: errForbidden},
+		{path: "missing.mp4", err: os.ErrNotExist},
+	} {
+		got, err := s.input(tc.path)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.path)
+			continue
+		}
+		if assert.NoError(t, err, tc.path) {
+			assert.Equal(t, tc.want, got, tc.path)
+		}
+	}
+
+	_, err = (&server{}).input(filepath.Join(root, "video.mp4"))
+	assert.ErrorIs(t, err, errForbidden, "local paths are refused without roots")
+}
+
+func TestServerHandleSheet(t *testing.T) {
+	s := &server{cmd: serveCmd{Tiles: 20, Columns: 4, TileWidth: 320, MaxTiles: 100, MaxColumns: 20, MaxWidth: 1920}}
+	for path, status := range map[string]int{
+		"/sheet":                          http.StatusBadRequest,
+		"/sheet?path=/etc/passwd":         http.StatusForbidden,
+		"/sheet?path=https://example.com": http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleSheet(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, rec.Code, path)
+	}
+
+	s.roots = []string{t.TempDir()}
+	rec := httptest.NewRecorder()
+	s.handleSheet(rec, httptest.NewRequest(http.MethodGet, "/sheet?path=missing.mp4", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServerOptions(t *testing.T) {
+	s := &server{
+		cmd:    serveCmd{Tiles: 20, Columns: 4, TileWidth: 320, MaxTiles: 100, MaxColumns: 20, MaxWidth: 1920},
+		limits: thumber.ProcessLimits{MaxFramePixels: 1000},
+	}
+	opts, err := s.options(url.Values{"tiles": {"100"}, "width": {"640"}})
+	require.NoError(t, err)
+	assert.Equal(t, 100, opts.TileCount)
+	assert.Equal(t, 640, opts.TileWidth)
+	assert.Equal(t, int64(1000), opts.Limits.MaxFramePixels)
+
+	for _, query := range []url.Values{{"tiles": {"101"}}, {"columns": {"21"}}, {"width": {"60000"}}} {
+		_, err := s.options(query)
+		assert.ErrorContains(t, err, "larger than the maximum", query.Encode())
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// batchState records completed jobs so an interrupted batch can resume.
+// A job is only skipped if its inputs and options are unchanged and its output still matches the recorded hash.
+type batchState struct {
+	path string
+	mu   sync.Mutex
+	Jobs map[string]stateEntry `json:"jobs"`
+}
+
+type stateEntry struct {
+	Input        string    `json:"input"`
+	Output       string    `json:"output"`
+	OutputSHA256 string    `json:"output_sha256"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+func loadBatchState(path string) (*batchState, error) {
+	s := &batchState{path: path, Jobs: map[string]stateEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if s.Jobs == nil {
+		s.Jobs = map[string]stateEntry{}
+	}
+	return s, nil
+}
+
+// jobKey identifies a job by its input file, its modification time and size, and the options it was run with.
+// Remote inputs are identified by their url.
+func jobKey(j batchJob) (string, error) {
+	input := j.input
+	var size int64
+	var modTime time.Time
+	// remote inputs are only known by their url, checking them would mean downloading them
+	if !thumber.IsRemote(j.input) {
+		stat, err := os.Stat(j.input)
+		if err != nil {
+			return "", err
+		}
+		if input, err = filepath.Abs(j.input); err != nil {
+			return "", err
+		}
+		size, modTime = stat.Size(), stat.ModTime().UTC()
+	}
+	key, err := json.Marshal(struct {
+		Input   string
+		Size    int64
+		ModTime time.Time
+		Output  string
+		Cmd     generateCmd
+	}{input, size, modTime, j.output, j.cmd})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Completed reports whether the job already ran, and its output is intact
+func (s *batchState) Completed(key string) (stateEntry, bool) {
+	s.mu.Lock()
+	e, ok := s.Jobs[key]
+	s.mu.Unlock()
+	if !ok {
+		return stateEntry{}, false
+	}
+	sum, err := fileSHA256(e.Output)
+	if err != nil || sum != e.OutputSHA256 {
+		return stateEntry{}, false
+	}
+	return e, true
+}
+
+// Record marks the job as completed and saves the state file
+func (s *batchState) Record(key, input, output string) error {
+	sum, err := fileSHA256(output)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Jobs[key] = stateEntry{Input: input, Output: output, OutputSHA256: sum, CompletedAt: time.Now()}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	statusOK      = "ok"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+	statusTimeout = "timeout"
+)
+
+type batchResult struct {
+	Input      string    `json:"input"`
+	Output     string    `json:"output,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func printSummaryTable(w io.Writer, results []batchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tTIME\tINPUT\tOUTPUT / ERROR")
+	ok := 0
+	for _, r := range results {
+		detail := r.Output
+		if r.Status == statusFailed || r.Status == statusTimeout {
+			detail = firstLine(r.Error)
+		} else {
+			ok++
+		}
+		took := (time.Duration(r.DurationMs) * time.Millisecond).Round(100 * time.Millisecond)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Status, took, r.Input, detail)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d succeeded, %d failed\n", ok, len(results)-ok)
+}
+
+// writeSummary writes results as CSV if path ends with .csv, and JSON otherwise
+func writeSummary(path string, results []batchResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		w := csv.NewWriter(f)
+		w.Write([]string{"input", "output", "status", "started_at", "duration_ms", "error"})
+		for _, r := range results {
+			w.Write([]string{r.Input, r.Output, r.Status, r.StartedAt.Format(time.RFC3339), strconv.FormatInt(r.DurationMs, 10), r.Error})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
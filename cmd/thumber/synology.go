@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// synologyThumbs are the sidecar files Synology Photos and Video Station look for, with the length of their longest side
+var synologyThumbs = []struct {
+	name string
+	size int
+}{
+	{"SYNOPHOTO_THUMB_XL.jpg", 1280},
+	{"SYNOPHOTO_THUMB_L.jpg", 800},
+	{"SYNOPHOTO_THUMB_B.jpg", 640},
+	{"SYNOPHOTO_THUMB_M.jpg", 320},
+	{"SYNOPHOTO_THUMB_SM.jpg", 240},
+	{"SYNOPHOTO_THUMB_S.jpg", 120},
+	{"SYNOVIDEO_VIDEO_SCREENSHOT.jpg", 1280},
+}
+
+// synologyDir returns the @eaDir directory where the indexer looks for thumbnails of videoPath
+func synologyDir(videoPath string) string {
+	return filepath.Join(filepath.Dir(videoPath), "@eaDir", filepath.Base(videoPath))
+}
+
+// generateSynology writes a poster frame at the starting point in every size the Synology indexer expects,
+// and returns the directory it wrote them to
+func (a generateCmd) generateSynology(ctx context.Context, videoPath string, opts thumber.ThumbOptions) (string, error) {
+	attrs, err := a.fileAttrs(videoPath)
+	if err != nil {
+		return "", err
+	}
+
+	// extract at the source size, and downscale for each thumbnail
+	opts.TileWidth, opts.TileHeight = 0, 0
+	frame, err := extractPosterFrame(ctx, videoPath, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract poster frame: %w", err)
+	}
+
+	dir := synologyDir(videoPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for _, t := range synologyThumbs {
+		f, err := createOutput(filepath.Join(dir, t.name), videoPath, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to open file for writing: %w", err)
+		}
+		f.SetAttrs(attrs)
+
+		img := imaging.Fit(frame.Image, t.size, t.size, imaging.Lanczos)
+		if err := jpeg.Encode(f, img, &jpeg.Options{Quality: a.JPEGQuality}); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to encode %s: %w", t.name, err)
+		}
+		if err := f.Commit(); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to save %s: %w", t.name, err)
+		}
+	}
+	return dir, nil
+}
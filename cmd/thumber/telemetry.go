@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/abdusco/thumber/version"
+)
+
+var tracer = otel.Tracer("github.com/abdusco/thumber/cmd/thumber")
+
+// setupTelemetry exports spans over OTLP/HTTP when an OTLP endpoint is configured with the standard
+// OTEL_EXPORTER_OTLP_* variables. Runs started by a traced parent can join its trace by setting TRACEPARENT.
+// The returned function flushes pending spans.
+func setupTelemetry(ctx context.Context) (context.Context, func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return ctx, noop, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return ctx, noop, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "thumber"),
+		attribute.String("service.version", version.Version.String()),
+	))
+	if err != nil {
+		return ctx, noop, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	})
+	return ctx, provider.Shutdown, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/subtle"
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// handleUI serves the admin page at /ui/, which works through the same endpoints as any other client
+func handleUI() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui/", http.FileServer(http.FS(files)))
+}
+
+// requireToken lets requests through to h only with the token, either as the password of basic auth, so
+// browsers ask for it, or as a bearer token. An empty token lets every request through.
+func requireToken(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, _ = r.BasicAuth()
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="thumber"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serverMetrics counts the sheets made while handling requests, jobs are counted from the queue
+type serverMetrics struct {
+	started time.Time
+	sheets  atomic.Int64
+	failed  atomic.Int64
+}
+
+type metricsReport struct {
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	// Sheets and SheetsFailed are of GET and POST /sheet
+	Sheets       int64 `json:"sheets"`
+	SheetsFailed int64 `json:"sheets_failed"`
+	// Jobs counts the jobs kept by the queue by status
+	Jobs      map[string]int `json:"jobs"`
+	QueueSize int            `json:"queue_size"`
+	// WorkersBusy is how many of the shared ffmpeg processes are running
+	WorkersBusy int `json:"workers_busy"`
+	Workers     int `json:"workers"`
+}
+
+// handleMetrics responds with a snapshot of the server's counters as JSON
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, metricsReport{
+		UptimeSeconds: int64(time.Since(s.metrics.started).Seconds()),
+		Sheets:        s.metrics.sheets.Load(),
+		SheetsFailed:  s.metrics.failed.Load(),
+		Jobs:          s.jobs.counts(),
+		QueueSize:     cap(s.jobs.pending),
+		WorkersBusy:   s.workers.Busy(),
+		Workers:       s.workers.Size(),
+	})
+}
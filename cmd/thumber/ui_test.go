@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+func TestHandleUI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleUI().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>thumber</title>")
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	do := func(token string, set func(r *http.Request)) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		set(r)
+		requireToken(token, ok).ServeHTTP(rec, r)
+		return rec
+	}
+	none := func(r *http.Request) {}
+	assert.Equal(t, http.StatusOK, do("", none).Code)
+
+	rec := do("secret", none)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Basic realm="thumber"`, rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusOK, do("secret", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }).Code)
+	assert.Equal(t, http.StatusOK, do("secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("secret", func(r *http.Request) { r.SetBasicAuth("secret", "wrong") }).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }).Code)
+}
+
+func TestHandleMetrics(t *testing.T) {
+	s := &server{workers: thumber.NewWorkers(3)}
+	s.metrics.started = time.Now().Add(-time.Minute)
+	s.metrics.sheets.Add(2)
+	var err error
+	s.jobs, err = newJobQueue(s, t.TempDir(), 10, time.Hour, nil)
+	require.NoError(t, err)
+	_, err = s.jobs.submit("video.mp4", nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	s.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var m metricsReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+	assert.Equal(t, int64(60), m.UptimeSeconds)
+	assert.Equal(t, int64(2), m.Sheets)
+	assert.Equal(t, map[string]int{jobQueued: 1, jobRunning: 0, jobDone: 0, jobFailed: 0}, m.Jobs)
+	assert.Equal(t, 10, m.QueueSize)
+	assert.Equal(t, 3, m.Workers)
+}
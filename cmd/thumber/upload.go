@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// uploadField is the form field of the video uploaded to POST /sheet
+const uploadField = "video"
+
+// errUnsupportedUpload is returned for uploads that aren't videos
+var errUnsupportedUpload = errors.New("upload must be a video")
+
+// postSheet responds with the contact sheet of a video uploaded as multipart/form-data,
+// for clients that don't share a filesystem with the server
+func (s *server) postSheet(w http.ResponseWriter, r *http.Request) {
+	opts, err := s.options(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws, err := thumber.NewWorkspace("", s.maxUpload)
+	if err != nil {
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	defer ws.Close()
+
+	// the form around the video can't take much more than the video itself
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload+1<<20)
+	videoPath, err := receiveUpload(r, ws)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, thumber.ErrWorkspaceFull) || errors.As(err, &maxBytesErr):
+		http.Error(w, fmt.Sprintf("upload is larger than %s", thumber.FormatSize(s.maxUpload, thumber.Locale{})), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, errUnsupportedUpload):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("generating contact sheet of upload", "path", videoPath, "remote", r.RemoteAddr)
+	sheet, err := thumber.GenerateSheet(thumber.WithProtocols(r.Context(), inputProtocols(videoPath)...), videoPath, opts)
+	if err != nil {
+		slog.Error("failed to generate contact sheet", "path", videoPath, "error", err)
+		s.metrics.failed.Add(1)
+		http.Error(w, "failed to generate contact sheet", http.StatusUnprocessableEntity)
+		return
+	}
+	s.respond(w, r, sheet)
+}
+
+// receiveUpload streams the video field of the form into the workspace and returns its path
+func receiveUpload(r *http.Request, ws *thumber.Workspace) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return "", fmt.Errorf("request must be multipart/form-data with the video in the %s field", uploadField)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return "", fmt.Errorf("no %s field", uploadField)
+		}
+		if err != nil {
+			return "", err
+		}
+		if part.FormName() != uploadField {
+			continue
+		}
+		if !isVideoType(part.Header.Get("Content-Type")) {
+			return "", fmt.Errorf("%w, not %s", errUnsupportedUpload, part.Header.Get("Content-Type"))
+		}
+		name := part.FileName()
+		if name == "" {
+			name = "upload"
+		}
+		return ws.CopyFrom(name, &sniffReader{r: part})
+	}
+}
+
+// isVideoType reports whether an upload of the declared content type may be a video.
+// Clients that don't know the type send application/octet-stream, which ffprobe sorts out.
+func isVideoType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	return strings.HasPrefix(mediaType, "video/") || mediaType == "application/octet-stream"
+}
+
+// sniffReader fails reads of content that's recognizably not a video, e.g. HTML or an image,
+// before the rest of it is stored
+type sniffReader struct {
+	r       io.Reader
+	sniffed bool
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if !s.sniffed && n > 0 {
+		s.sniffed = true
+		detected := http.DetectContentType(p[:n])
+		if strings.HasPrefix(detected, "text/") || strings.HasPrefix(detected, "image/") {
+			return 0, fmt.Errorf("%w, looks like %s", errUnsupportedUpload, detected)
+		}
+	}
+	return n, err
+}
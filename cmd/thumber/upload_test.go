@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// uploadRequest is a POST /sheet request with content in the video field, declared as contentType
+func uploadRequest(t *testing.T, contentType string, content []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="video"; filename="clip.mp4"`)
+	header.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/sheet", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// mp4Header is the start of an MP4 file
+var mp4Header = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+
+func TestReceiveUpload(t *testing.T) {
+	ws, err := thumber.NewWorkspace(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	path, err := receiveUpload(uploadRequest(t, "video/mp4", mp4Header), ws)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, mp4Header, data)
+
+	_, err = receiveUpload(uploadRequest(t, "image/png", mp4Header), ws)
+	assert.ErrorIs(t, err, errUnsupportedUpload, "declared type must be a video")
+	_, err = receiveUpload(uploadRequest(t, "application/octet-stream", []byte("<html><body>hi</body></html>")), ws)
+	assert.ErrorIs(t, err, errUnsupportedUpload, "content must not look like something else")
+}
+
+func TestPostSheet(t *testing.T) {
+	s := &server{cmd: serveCmd{Tiles: 20, Columns: 4, TileWidth: 320, MaxTiles: 100, MaxColumns: 20, MaxWidth: 1920}, maxUpload: 16}
+
+	rec := httptest.NewRecorder()
+	s.handleSheet(rec, uploadRequest(t, "video/mp4", mp4Header))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	s.maxUpload = 1 << 20
+	rec = httptest.NewRecorder()
+	s.handleSheet(rec, uploadRequest(t, "text/plain", mp4Header))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.handleSheet(rec, httptest.NewRequest(http.MethodPost, "/sheet", bytes.NewReader(mp4Header)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "uploads must be multipart")
+}
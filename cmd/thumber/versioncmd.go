@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+	"github.com/abdusco/thumber/version"
+)
+
+type versionCmd struct {
+	Full bool `help:"Also show ffmpeg and ffprobe versions, hardware acceleration methods and encoders"`
+}
+
+func (c versionCmd) Run() error {
+	fmt.Println(version.Version.String())
+	if !c.Full {
+		return nil
+	}
+
+	fmt.Printf("go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	env, err := thumber.ProbeEnvironment(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ffmpeg: %s (%s)\n", env.FfmpegVersion, env.FfmpegPath)
+	fmt.Printf("ffprobe: %s (%s)\n", env.FfprobeVersion, env.FfprobePath)
+	fmt.Printf("hwaccels: %s\n", joinOrNone(env.HWAccels))
+	fmt.Printf("encoders: %s\n", joinOrNone(env.Encoders))
+	return nil
+}
+
+func joinOrNone(s []string) string {
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, ", ")
+}
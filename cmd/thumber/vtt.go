@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abdusco/thumber/pkg/thumber"
+)
+
+// parseSpriteGrid parses a CxR grid such as 10x10
+func parseSpriteGrid(s string) (columns, rows int, err error) {
+	c, r, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		columns, err = strconv.Atoi(c)
+		if err == nil {
+			rows, err = strconv.Atoi(r)
+		}
+	}
+	if !ok || err != nil || columns < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid sprite grid %q, must be COLUMNSxROWS e.g. 10x10", s)
+	}
+	return columns, rows, nil
+}
+
+// writeVTT writes a WebVTT thumbnail track, where each cue points to its tile in the sprite saved at paths[i]
+// using a media fragment, as players that support thumbnail tracks expect
+func writeVTT(path, videoPath string, paths []string, sheets []thumber.Sheet) error {
+	type cue struct {
+		start time.Duration
+		image string
+		rect  string
+	}
+	var cues []cue
+	for i, sheet := range sheets {
+		src, err := filepath.Rel(filepath.Dir(path), paths[i])
+		if err != nil {
+			return err
+		}
+		for _, t := range sheet.Tiles {
+			r := t.Rect
+			cues = append(cues, cue{
+				start: t.Timestamp,
+				image: filepath.ToSlash(src),
+				rect:  fmt.Sprintf("%d,%d,%d,%d", r.Min.X, r.Min.Y, r.Dx(), r.Dy()),
+			})
+		}
+	}
+	if len(cues) == 0 {
+		return fmt.Errorf("no tiles to write")
+	}
+
+	f, err := createOutput(path, videoPath, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprint(w, "WEBVTT\n")
+	for i, c := range cues {
+		// tiles cover the video from its start until the next tile, the last one for as long as the one before it
+		start, end := c.start, c.start
+		if i == 0 {
+			start = 0
+		}
+		switch {
+		case i+1 < len(cues):
+			end = cues[i+1].start
+		case i > 0:
+			end = c.start + (c.start - cues[i-1].start)
+		default:
+			end = c.start + time.Second
+		}
+		fmt.Fprintf(w, "\n%s --> %s\n%s#xywh=%s\n", formatVTTTime(start), formatVTTTime(end), c.image, c.rect)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+func formatVTTTime(t time.Duration) string {
+	ms := t.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,135 @@
+package thumber
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
+)
+
+// Annotation is a short label for the point in the video at At, e.g. "goal" or "scene 12".
+// It's rendered as a caption under the tile that covers At.
+type Annotation struct {
+	At   time.Duration
+	Text string
+}
+
+func (a Annotation) Validate() error {
+	if a.At < 0 {
+		return fmt.Errorf("annotation %q has a negative timestamp", a.Text)
+	}
+	if a.Text == "" {
+		return fmt.Errorf("annotation at %s has no text", FormatDuration(a.At))
+	}
+	return nil
+}
+
+// captionPadding is the space above and below caption text, and the minimum space on its sides
+const captionPadding = 4
+
+// annotate sets the captions of thumbs, which must be in timestamp order.
+// A tile covers the span from its timestamp up to the next tile, and the last tile covers the rest of the video.
+// Annotations for the same tile are joined with commas.
+func annotate(thumbs []Thumbnail, annotations []Annotation) {
+	if len(thumbs) == 0 {
+		return
+	}
+	sorted := slices.Clone(annotations)
+	slices.SortStableFunc(sorted, func(a, b Annotation) bool {
+		return a.At < b.At
+	})
+	for _, a := range sorted {
+		i := len(thumbs) - 1
+		for i >= 0 && thumbs[i].Timestamp > a.At {
+			i--
+		}
+		if i < 0 {
+			slog.Warn("annotation is before the first tile, skipping", "at", a.At, "text", a.Text)
+			continue
+		}
+		if thumbs[i].Caption != "" {
+			thumbs[i].Caption += ", "
+		}
+		thumbs[i].Caption += a.Text
+	}
+}
+
+func (l LayoutOptions) captionRenderer() timestampRenderer {
+	return defaultRenderer{
+		Font:            fonts.RobotoMonoMedium,
+		Fallbacks:       l.FallbackFonts,
+		FontSizePt:      12,
+		BackgroundColor: color.Transparent,
+		ForegroundColor: l.Theme.orDefault().Text,
+	}
+}
+
+func (l LayoutOptions) labelRenderer() timestampRenderer {
+	return defaultRenderer{
+		Font:            fonts.RobotoMonoMedium,
+		Fallbacks:       l.FallbackFonts,
+		FontSizePt:      14,
+		BackgroundColor: color.Transparent,
+		ForegroundColor: l.Theme.orDefault().Text,
+	}
+}
+
+// captionHeight is the height of the strip reserved under every tile for captions, or 0 without captions
+func (l LayoutOptions) captionHeight() (int, error) {
+	if !l.Captions {
+		return 0, nil
+	}
+	sample, err := l.captionRenderer().Render("Ag")
+	if err != nil {
+		return 0, err
+	}
+	return sample.Bounds().Dy() + 2*captionPadding, nil
+}
+
+// renderCaption renders text to fit into width, cutting it short with an ellipsis when it's too long
+func renderCaption(r timestampRenderer, text string, width int) (image.Image, error) {
+	runes := []rune(text)
+	suffix := ""
+	for {
+		img, err := r.Render(string(runes) + suffix)
+		if err != nil {
+			return nil, err
+		}
+		if img.Bounds().Dx() <= width-2*captionPadding || len(runes) <= 1 {
+			return img, nil
+		}
+		runes = runes[:len(runes)-1]
+		suffix = "..."
+	}
+}
+
+// drawCaption draws the caption centered in the strip of the given size at pt
+func drawCaption(canvas *image.NRGBA, r timestampRenderer, text string, pt image.Point, width, height int) (*image.NRGBA, error) {
+	textImg, err := renderCaption(r, text, width)
+	if err != nil {
+		return canvas, err
+	}
+	x := pt.X + (width-textImg.Bounds().Dx())/2
+	y := pt.Y + (height-textImg.Bounds().Dy())/2
+	return imaging.Overlay(canvas, textImg, image.Pt(x, y), 1), nil
+}
+
+// drawLabel draws the label aligned as set and vertically centered in its rectangle
+func drawLabel(canvas *image.NRGBA, r timestampRenderer, l Label, align TextAlign) (*image.NRGBA, error) {
+	textImg, err := renderCaption(r, l.Text, l.Rect.Dx())
+	if err != nil {
+		return canvas, err
+	}
+	x := l.Rect.Min.X
+	if align.alignRight(l.Text) {
+		x = l.Rect.Max.X - textImg.Bounds().Dx()
+	}
+	y := l.Rect.Min.Y + (l.Rect.Dy()-textImg.Bounds().Dy())/2
+	return imaging.Overlay(canvas, textImg, image.Pt(x, y), 1), nil
+}
@@ -0,0 +1,54 @@
+package thumber
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotate(t *testing.T) {
+	thumbs := []Thumbnail{
+		{Timestamp: 10 * time.Second},
+		{Timestamp: 20 * time.Second},
+		{Timestamp: 30 * time.Second},
+	}
+	annotate(thumbs, []Annotation{
+		{At: 45 * time.Second, Text: "credits"},
+		{At: 25 * time.Second, Text: "goal"},
+		{At: 20 * time.Second, Text: "kickoff"},
+		{At: 5 * time.Second, Text: "too early"},
+	})
+
+	assert.Equal(t, "", thumbs[0].Caption)
+	assert.Equal(t, "kickoff, goal", thumbs[1].Caption)
+	assert.Equal(t, "credits", thumbs[2].Caption)
+}
+
+func TestContactSheetCaptions(t *testing.T) {
+	tile := Thumbnail{Image: image.NewRGBA(image.Rect(0, 0, 160, 90))}
+	thumbs := []Thumbnail{tile, tile, tile}
+	thumbs[1].Caption = "a label much too long to fit under a tile that is only 160 px wide"
+
+	layout := LayoutOptions{Columns: 2, Padding: 5, Captions: true}
+	captionHeight, err := layout.captionHeight()
+	require.NoError(t, err)
+	require.Positive(t, captionHeight)
+
+	sheet, err := ComposeSheet(thumbs, layout)
+	require.NoError(t, err)
+	assert.Equal(t, 2*(90+captionHeight)+3*5, sheet.Bounds().Dy(), "every row reserves room for captions")
+	assert.Equal(t, image.Rect(5, 90+captionHeight+10, 165, 2*90+captionHeight+10), sheet.Tiles[2].Rect)
+
+	plain, err := ComposeSheet(thumbs, LayoutOptions{Columns: 2, Padding: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 2*90+3*5, plain.Bounds().Dy(), "no room is reserved without annotations")
+}
+
+func TestAnnotationValidate(t *testing.T) {
+	assert.NoError(t, Annotation{At: time.Minute, Text: "goal"}.Validate())
+	assert.Error(t, Annotation{At: time.Minute}.Validate())
+	assert.Error(t, Annotation{At: -time.Second, Text: "goal"}.Validate())
+}
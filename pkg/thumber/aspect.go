@@ -0,0 +1,58 @@
+package thumber
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AspectRatio is the width to height ratio of tiles e.g. 16:9.
+// The zero value keeps the aspect ratio of the source.
+type AspectRatio struct {
+	W, H int
+}
+
+// ParseAspectRatio parses W:H, or "source" for the zero value
+func ParseAspectRatio(s string) (AspectRatio, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "source" {
+		return AspectRatio{}, nil
+	}
+	w, h, ok := strings.Cut(s, ":")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width < 1 || height < 1 {
+		return AspectRatio{}, fmt.Errorf("invalid aspect ratio %q, must be W:H e.g. 16:9, or source", s)
+	}
+	return AspectRatio{W: width, H: height}, nil
+}
+
+func (a AspectRatio) IsSource() bool {
+	return a.W == 0 || a.H == 0
+}
+
+func (a AspectRatio) String() string {
+	if a.IsSource() {
+		return "source"
+	}
+	return fmt.Sprintf("%d:%d", a.W, a.H)
+}
+
+// withTileAspect derives the missing tile dimension from the aspect ratio, and covers tiles with frames unless a fit is set
+func (o ThumbOptions) withTileAspect() ThumbOptions {
+	a := o.TileAspect
+	if a.IsSource() {
+		return o
+	}
+	switch {
+	case o.TileWidth > 0 && o.TileHeight == 0:
+		o.TileHeight = roundToMultiple(int(math.Round(float64(o.TileWidth*a.H)/float64(a.W))), o.dimensionMultiple())
+	case o.TileHeight > 0 && o.TileWidth == 0:
+		o.TileWidth = roundToMultiple(int(math.Round(float64(o.TileHeight*a.W)/float64(a.H))), o.dimensionMultiple())
+	}
+	if o.Fit == "" {
+		o.Fit = FitCover
+	}
+	return o
+}
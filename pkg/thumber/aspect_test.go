@@ -0,0 +1,51 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAspectRatio(t *testing.T) {
+	a, err := ParseAspectRatio("16:9")
+	require.NoError(t, err)
+	assert.Equal(t, AspectRatio{W: 16, H: 9}, a)
+
+	a, err = ParseAspectRatio("source")
+	require.NoError(t, err)
+	assert.True(t, a.IsSource())
+
+	for _, s := range []string{"16", "16:0", "a:b", "-4:3"} {
+		_, err := ParseAspectRatio(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestWithTileAspect(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   ThumbOptions
+		width  int
+		height int
+		fit    Fit
+	}{
+		{name: "square from default width", opts: ThumbOptions{TileAspect: AspectRatio{1, 1}}, width: 540, height: 540, fit: FitCover},
+		{name: "width from height", opts: ThumbOptions{TileHeight: 300, TileAspect: AspectRatio{4, 3}}, width: 400, height: 300, fit: FitCover},
+		{name: "explicit fit", opts: ThumbOptions{TileWidth: 320, TileAspect: AspectRatio{16, 9}, Fit: FitContain}, width: 320, height: 180, fit: FitContain},
+		{name: "even", opts: ThumbOptions{TileWidth: 100, TileAspect: AspectRatio{4, 3}, DimensionMultiple: 2}, width: 100, height: 76, fit: FitCover},
+		{name: "source", opts: ThumbOptions{TileWidth: 320}, width: 320, height: 0, fit: FitStretch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts.withDefaults()
+			assert.Equal(t, tt.width, opts.TileWidth)
+			assert.Equal(t, tt.height, opts.TileHeight)
+			assert.Equal(t, tt.fit, opts.Fit)
+			assert.NoError(t, tt.opts.Validate())
+		})
+	}
+
+	err := ThumbOptions{TileWidth: 100, TileHeight: 100, TileAspect: AspectRatio{1, 1}}.Validate()
+	assert.ErrorContains(t, err, "cannot be set together with both tile width and height")
+}
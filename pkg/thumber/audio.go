@@ -0,0 +1,181 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AudioProfile is the loudness of the audio over a span of the video
+type AudioProfile struct {
+	Span TimeRange
+	// Silences are ranges quieter than silenceThreshold for at least silenceMinDuration.
+	// Videos without audio are silent throughout.
+	Silences []TimeRange
+	// Loudness is the momentary loudness over time
+	Loudness []LoudnessSample
+	// Integrated is the loudness of the whole span in LUFS
+	Integrated float64
+	NoAudio    bool
+}
+
+type LoudnessSample struct {
+	At   time.Duration
+	LUFS float64
+}
+
+const (
+	silenceThreshold   = "-50dB"
+	silenceMinDuration = "1"
+	// audioStripHeight is the height of the timeline strip drawn under sheets
+	audioStripHeight = 48
+	// quietestLUFS is drawn as an empty bar in the timeline strip
+	quietestLUFS = -60
+)
+
+// ReadAudioProfile measures silences with ffmpeg's silencedetect filter and loudness with its ebur128 filter,
+// over the span of the video
+func ReadAudioProfile(ctx context.Context, videoPath string, span TimeRange, opts ThumbOptions) (_ AudioProfile, err error) {
+	ctx, stage := startStage(ctx, "audio", attribute.Int64("thumber.span_ms", (span.End-span.Start).Milliseconds()))
+	defer func() { stage.End(err) }()
+
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args,
+		"-ss", fmt.Sprintf("%dms", span.Start.Milliseconds()),
+		"-t", fmt.Sprintf("%dms", (span.End-span.Start).Milliseconds()),
+		"-i", videoPath,
+		"-vn",
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%s,ebur128=framelog=info", silenceThreshold, silenceMinDuration),
+	)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "null", "-")
+	cmd := command(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		if strings.Contains(stderr.String(), "does not contain any stream") {
+			return AudioProfile{Span: span, Silences: []TimeRange{span}, NoAudio: true}, nil
+		}
+		return AudioProfile{}, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	return parseAudioProfile(stderr.String(), span), nil
+}
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start:\s*(-?[0-9.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end:\s*(-?[0-9.]+)`)
+	loudnessPattern     = regexp.MustCompile(`t:\s*([0-9.]+)\s+TARGET:.*?\sM:\s*(-?[0-9.]+)`)
+	integratedPattern   = regexp.MustCompile(`^\s*I:\s*(-?[0-9.]+) LUFS`)
+)
+
+// parseAudioProfile reads the log of silencedetect and ebur128, whose timestamps are relative to the start of span
+func parseAudioProfile(log string, span TimeRange) AudioProfile {
+	p := AudioProfile{Span: span}
+	seconds := func(s string) time.Duration {
+		d, _ := parseSeconds(s)
+		return span.Start + d
+	}
+	silenceStart := time.Duration(-1)
+	for _, line := range strings.Split(log, "\n") {
+		switch {
+		case silenceStartPattern.MatchString(line):
+			silenceStart = seconds(silenceStartPattern.FindStringSubmatch(line)[1])
+			if silenceStart < span.Start {
+				silenceStart = span.Start
+			}
+		case silenceEndPattern.MatchString(line) && silenceStart >= 0:
+			p.Silences = append(p.Silences, TimeRange{Start: silenceStart, End: seconds(silenceEndPattern.FindStringSubmatch(line)[1])})
+			silenceStart = -1
+		case loudnessPattern.MatchString(line):
+			m := loudnessPattern.FindStringSubmatch(line)
+			if lufs, err := strconv.ParseFloat(m[2], 64); err == nil {
+				p.Loudness = append(p.Loudness, LoudnessSample{At: seconds(m[1]), LUFS: lufs})
+			}
+		case integratedPattern.MatchString(line):
+			// the summary comes last, after the frame lines
+			p.Integrated, _ = strconv.ParseFloat(integratedPattern.FindStringSubmatch(line)[1], 64)
+		}
+	}
+	if silenceStart >= 0 {
+		// silent up to the end
+		p.Silences = append(p.Silences, TimeRange{Start: silenceStart, End: span.End})
+	}
+	return p
+}
+
+// within returns the part of the profile inside span
+func (p AudioProfile) within(span TimeRange) AudioProfile {
+	out := AudioProfile{Span: span, Integrated: p.Integrated, NoAudio: p.NoAudio}
+	for _, s := range p.Silences {
+		if s.End > span.Start && s.Start < span.End {
+			out.Silences = append(out.Silences, s)
+		}
+	}
+	for _, l := range p.Loudness {
+		if span.Contains(l.At) {
+			out.Loudness = append(out.Loudness, l)
+		}
+	}
+	return out
+}
+
+// drawAudioStrip draws the loudness over the span of the profile as bars, silences in red,
+// and a tick at each tile timestamp
+func drawAudioStrip(canvas *image.NRGBA, rect image.Rectangle, p AudioProfile, ticks []time.Duration) {
+	draw.Draw(canvas, rect, &image.Uniform{C: color.NRGBA{R: 40, G: 40, B: 40, A: 255}}, image.Point{}, draw.Src)
+	length := p.Span.End - p.Span.Start
+	if length <= 0 || rect.Dx() <= 0 {
+		return
+	}
+	at := func(x int) time.Duration {
+		return p.Span.Start + time.Duration(float64(length)*float64(x)/float64(rect.Dx()))
+	}
+	xOf := func(t time.Duration) int {
+		return rect.Min.X + int(float64(t-p.Span.Start)/float64(length)*float64(rect.Dx()))
+	}
+
+	silence := &image.Uniform{C: color.NRGBA{R: 200, G: 40, B: 40, A: 255}}
+	for _, s := range p.Silences {
+		r := image.Rect(xOf(s.Start), rect.Min.Y, xOf(s.End), rect.Max.Y).Intersect(rect)
+		draw.Draw(canvas, r, silence, image.Point{}, draw.Src)
+	}
+
+	bar := &image.Uniform{C: color.NRGBA{R: 180, G: 180, B: 180, A: 255}}
+	i := 0
+	for x := 0; x < rect.Dx(); x++ {
+		end := at(x + 1)
+		loudest, found := float64(quietestLUFS), false
+		for ; i < len(p.Loudness) && p.Loudness[i].At < end; i++ {
+			if l := p.Loudness[i].LUFS; l > loudest {
+				loudest, found = l, true
+			}
+		}
+		if !found {
+			continue
+		}
+		level := (loudest - quietestLUFS) / -quietestLUFS
+		if level > 1 {
+			level = 1
+		}
+		h := int(level * float64(rect.Dy()))
+		draw.Draw(canvas, image.Rect(rect.Min.X+x, rect.Max.Y-h, rect.Min.X+x+1, rect.Max.Y), bar, image.Point{}, draw.Src)
+	}
+
+	tick := &image.Uniform{C: color.White}
+	for _, t := range ticks {
+		x := xOf(t)
+		draw.Draw(canvas, image.Rect(x, rect.Min.Y, x+1, rect.Min.Y+6).Intersect(rect), tick, image.Point{}, draw.Src)
+	}
+}
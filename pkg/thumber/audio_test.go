@@ -0,0 +1,69 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAudioProfile(t *testing.T) {
+	log := `[silencedetect @ 0x55] silence_start: 2.5
+[Parsed_ebur128_1 @ 0x56] t: 0.4       TARGET:-23 LUFS    M: -27.4 S:-120.7     I: -27.4 LUFS       LRA:   0.0 LU
+[Parsed_ebur128_1 @ 0x56] t: 0.5       TARGET:-23 LUFS    M: -18.0 S:-120.7     I: -22.1 LUFS       LRA:   0.0 LU
+[silencedetect @ 0x55] silence_end: 4 | silence_duration: 1.5
+[silencedetect @ 0x55] silence_start: 9
+[Parsed_ebur128_1 @ 0x56] Summary:
+
+  Integrated loudness:
+    I:         -19.5 LUFS
+    Threshold: -29.8 LUFS`
+
+	p := parseAudioProfile(log, TimeRange{Start: 10 * time.Second, End: 20 * time.Second})
+	assert.Equal(t, []TimeRange{
+		{Start: 12500 * time.Millisecond, End: 14 * time.Second},
+		{Start: 19 * time.Second, End: 20 * time.Second},
+	}, p.Silences)
+	assert.Equal(t, []LoudnessSample{
+		{At: 10400 * time.Millisecond, LUFS: -27.4},
+		{At: 10500 * time.Millisecond, LUFS: -18},
+	}, p.Loudness)
+	assert.Equal(t, -19.5, p.Integrated)
+}
+
+func TestAudioProfileWithin(t *testing.T) {
+	p := AudioProfile{
+		Span:     TimeRange{Start: 0, End: time.Minute},
+		Silences: []TimeRange{{Start: 5 * time.Second, End: 15 * time.Second}, {Start: 40 * time.Second, End: 50 * time.Second}},
+		Loudness: []LoudnessSample{{At: time.Second}, {At: 20 * time.Second}},
+	}
+	page := p.within(TimeRange{Start: 10 * time.Second, End: 30 * time.Second})
+	assert.Equal(t, []TimeRange{{Start: 5 * time.Second, End: 15 * time.Second}}, page.Silences)
+	assert.Equal(t, []LoudnessSample{{At: 20 * time.Second}}, page.Loudness)
+}
+
+func TestPlanAudioTimeline(t *testing.T) {
+	tiles := []LayoutTile{{Size: image.Pt(100, 50)}, {Size: image.Pt(100, 50)}}
+	plan, err := LayoutOptions{Columns: 2, Padding: 10, Audio: &AudioProfile{}}.Plan(tiles)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(10, 70, 220, 70+audioStripHeight), plan.Timeline)
+	assert.Equal(t, image.Pt(230, 70+audioStripHeight+10), plan.Size)
+}
+
+func TestDrawAudioStrip(t *testing.T) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, 100, audioStripHeight))
+	p := AudioProfile{
+		Span:     TimeRange{Start: 0, End: 100 * time.Second},
+		Silences: []TimeRange{{Start: 50 * time.Second, End: 100 * time.Second}},
+		Loudness: []LoudnessSample{{At: 10 * time.Second, LUFS: 0}},
+	}
+	drawAudioStrip(canvas, canvas.Bounds(), p, []time.Duration{30 * time.Second})
+
+	assert.Equal(t, color.NRGBA{R: 200, G: 40, B: 40, A: 255}, canvas.At(75, 40), "silence")
+	assert.Equal(t, color.NRGBA{R: 180, G: 180, B: 180, A: 255}, canvas.At(10, 10), "loudest bar fills the strip")
+	assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, canvas.At(30, 0), "tile tick")
+	assert.Equal(t, color.NRGBA{R: 40, G: 40, B: 40, A: 255}, canvas.At(20, 20), "background")
+}
@@ -0,0 +1,189 @@
+package thumber
+
+import (
+	"golang.org/x/text/unicode/bidi"
+)
+
+// TextAlign controls how labels such as the header and chapter titles are aligned
+type TextAlign string
+
+const (
+	// TextAlignAuto aligns right to left text to the right, and everything else to the left
+	TextAlignAuto  TextAlign = "auto"
+	TextAlignLeft  TextAlign = "left"
+	TextAlignRight TextAlign = "right"
+)
+
+// alignRight reports whether text is aligned to the right
+func (a TextAlign) alignRight(text string) bool {
+	switch a {
+	case TextAlignRight:
+		return true
+	case TextAlignLeft:
+		return false
+	default:
+		return isRTL(text)
+	}
+}
+
+// bidiDirection is the resolved direction of a character, or neutral for whitespace and punctuation
+type bidiDirection int
+
+const (
+	bidiNeutral bidiDirection = iota
+	bidiLTR
+	bidiRTL
+	bidiNumber
+)
+
+func classify(r rune) bidiDirection {
+	p, _ := bidi.LookupRune(r)
+	switch p.Class() {
+	case bidi.L:
+		return bidiLTR
+	case bidi.R, bidi.AL:
+		return bidiRTL
+	case bidi.EN, bidi.AN:
+		return bidiNumber
+	}
+	return bidiNeutral
+}
+
+// isRTL reports whether the first letter of text with a direction is right to left, e.g. Hebrew or Arabic
+func isRTL(text string) bool {
+	for _, r := range text {
+		switch classify(r) {
+		case bidiLTR:
+			return false
+		case bidiRTL:
+			return true
+		}
+	}
+	return false
+}
+
+// bidiMirrors are the characters drawn mirrored in right to left runs
+var bidiMirrors = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<', '«': '»', '»': '«'}
+
+// visualOrder reorders text from the order it's written in to the order it's drawn in from left to right,
+// so right to left scripts mixed with numbers and left to right text read correctly.
+// It implements the parts of the Unicode bidirectional algorithm that matter for a single line of plain text:
+// explicit embeddings and isolates are ignored.
+func visualOrder(text string) string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return text
+	}
+	base := 0
+	if isRTL(text) {
+		base = 1
+	}
+
+	// numbers take the direction of the letters before them, neutrals that of the letters around them
+	dirs := make([]bidiDirection, len(runes))
+	hasRTL := false
+	last := bidiLTR
+	if base == 1 {
+		last = bidiRTL
+	}
+	for i, r := range runes {
+		d := classify(r)
+		switch d {
+		case bidiLTR, bidiRTL:
+			last = d
+			hasRTL = hasRTL || d == bidiRTL
+		case bidiNumber:
+			if last != bidiRTL {
+				d = bidiLTR
+			}
+		}
+		dirs[i] = d
+	}
+	if !hasRTL {
+		return text
+	}
+
+	levels := make([]int, len(runes))
+	for i := 0; i < len(runes); {
+		switch dirs[i] {
+		case bidiLTR:
+			levels[i] = base + base%2
+			i++
+			continue
+		case bidiRTL:
+			levels[i] = 1
+			i++
+			continue
+		case bidiNumber:
+			levels[i] = 2
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && dirs[end] == bidiNeutral {
+			end++
+		}
+		before, after := directionAt(dirs, i-1, base), directionAt(dirs, end, base)
+		level := base
+		if before == after {
+			level = 0
+			if before == bidiRTL {
+				level = 1
+			} else if base == 1 {
+				level = 2
+			}
+		}
+		for j := i; j < end; j++ {
+			levels[j] = level
+		}
+		i = end
+	}
+
+	// reverse every run at or above each odd level, from the highest level down
+	highest := 0
+	for _, l := range levels {
+		if l > highest {
+			highest = l
+		}
+	}
+	for level := highest; level >= 1; level-- {
+		for i := 0; i < len(runes); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			end := i
+			for end < len(runes) && levels[end] >= level {
+				end++
+			}
+			for a, b := i, end-1; a < b; a, b = a+1, b-1 {
+				runes[a], runes[b] = runes[b], runes[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = end
+		}
+	}
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			if m, ok := bidiMirrors[r]; ok {
+				runes[i] = m
+			}
+		}
+	}
+	return string(runes)
+}
+
+// directionAt is the direction neutrals next to i lean towards, numbers count as right to left.
+// Outside text it's the base direction.
+func directionAt(dirs []bidiDirection, i, base int) bidiDirection {
+	if i < 0 || i >= len(dirs) {
+		if base == 1 {
+			return bidiRTL
+		}
+		return bidiLTR
+	}
+	if dirs[i] == bidiNumber {
+		return bidiRTL
+	}
+	return dirs[i]
+}
@@ -0,0 +1,36 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "left to right", text: "movie (2019).mkv", want: "movie (2019).mkv"},
+		{name: "hebrew", text: "שלום", want: "םולש"},
+		{name: "hebrew in english", text: "clip שלום עולם.mp4", want: "clip םלוע םולש.mp4"},
+		{name: "numbers in hebrew", text: "פרק 12", want: "12 קרפ"},
+		{name: "english in hebrew", text: "סרט HD חדש", want: "שדח HD טרס"},
+		{name: "mirrored brackets", text: "סרט (חדש)", want: "(שדח) טרס"},
+		{name: "arabic", text: "فيلم 2", want: "2 مليف"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, visualOrder(tt.text))
+		})
+	}
+}
+
+func TestTextAlign(t *testing.T) {
+	assert.False(t, TextAlignAuto.alignRight("movie.mkv"))
+	assert.True(t, TextAlignAuto.alignRight("2019 סרט"), "the first letter decides, not the first character")
+	assert.True(t, TextAlignRight.alignRight("movie.mkv"))
+	assert.False(t, TextAlignLeft.alignRight("סרט"))
+	assert.False(t, TextAlign("").alignRight("movie.mkv"))
+}
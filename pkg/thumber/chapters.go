@@ -0,0 +1,35 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReadChapters reads the chapters of the video in order, e.g. to group tiles with ChapterLayout
+func ReadChapters(ctx context.Context, videoPath string) ([]Chapter, error) {
+	out, err := runProbe(ctx,
+		"-show_chapters",
+		videoPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseChapters(out)
+}
+
+func parseChapters(out []byte) ([]Chapter, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapters: %w", err)
+	}
+
+	chapters := make([]Chapter, 0, len(p.Chapters))
+	for _, c := range p.Chapters {
+		start, err := parseSeconds(c.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter start: %w", err)
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: c.Tags.Title})
+	}
+	return chapters, nil
+}
@@ -0,0 +1,131 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/exp/slog"
+)
+
+// ClipOptions controls the short animated clips made for tiles, e.g. for galleries that play them on hover
+type ClipOptions struct {
+	// Duration of each clip, centered on the tile's timestamp. Defaults to 1s
+	Duration time.Duration
+	// FPS is the frame rate of the clips. Defaults to 10
+	FPS int
+	// Quality is the WebP quality from 0 to 100. Defaults to 60
+	Quality int
+}
+
+func (c ClipOptions) withDefaults() ClipOptions {
+	if c.Duration == 0 {
+		c.Duration = time.Second
+	}
+	if c.FPS == 0 {
+		c.FPS = 10
+	}
+	if c.Quality == 0 {
+		c.Quality = 60
+	}
+	return c
+}
+
+func (c ClipOptions) Validate() error {
+	c = c.withDefaults()
+	if c.Duration < 0 {
+		return fmt.Errorf("clip duration cannot be negative")
+	}
+	if c.FPS < 0 {
+		return fmt.Errorf("clip frame rate cannot be negative")
+	}
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("clip quality must be between 0 and 100")
+	}
+	return nil
+}
+
+// SaveClips writes an animated WebP clip around the timestamp of each tile into dir
+// as clip-0001.webp, clip-0002.webp and so on numbered from first, and returns their paths.
+// Clips are scaled, cropped and blurred like the tiles.
+func SaveClips(ctx context.Context, videoPath, dir string, tiles []TilePlacement, opts ThumbOptions, clip ClipOptions, first int) ([]string, error) {
+	opts = opts.withDefaults()
+	if err := clip.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid clip options: %w", err)
+	}
+	clip = clip.withDefaults()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	p := pool.New().
+		WithContext(ctx).
+		WithMaxGoroutines(opts.concurrency()).
+		WithCancelOnError().
+		WithFirstError()
+
+	paths := make([]string, len(tiles))
+	filter := fmt.Sprintf("%s,fps=%d", videoFilter(opts), clip.FPS)
+	for i, tile := range tiles {
+		i, tile := i, tile
+		paths[i] = filepath.Join(dir, fmt.Sprintf("clip-%04d.webp", first+i))
+		p.Go(func(ctx context.Context) (err error) {
+			defer RecoverPanic(&err)
+			ctx = withFrameIndex(withVideoPath(ctx, videoPath), i)
+			slog.Debug("extracting clip", "current", i+1, "total", len(tiles))
+			data, err := extractClipWebP(ctx, videoPath, tile.Timestamp, filter, opts, clip)
+			if err != nil {
+				slog.Error("failed to extract clip", "timestamp", tile.Timestamp, "error", err)
+				return err
+			}
+			return os.WriteFile(paths[i], data, 0o644)
+		})
+	}
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// extractClipWebP returns a looping animated WebP of the clip centered on timestamp
+func extractClipWebP(ctx context.Context, filename string, timestamp time.Duration, filter string, opts ThumbOptions, clip ClipOptions) (_ []byte, err error) {
+	ctx, stage := startStage(ctx, "clip", attribute.Int64("thumber.timestamp_ms", timestamp.Milliseconds()))
+	defer func() { stage.End(err) }()
+
+	start := timestamp - clip.Duration/2
+	if start < 0 {
+		start = 0
+	}
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args,
+		"-ss", fmt.Sprintf("%dms", start.Milliseconds()),
+		"-t", fmt.Sprintf("%dms", clip.Duration.Milliseconds()),
+		"-i", filename,
+		"-vf", filter,
+		"-an",
+		"-c:v", "libwebp",
+		"-quality", fmt.Sprint(clip.Quality),
+		"-loop", "0",
+	)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "webp", "pipe:1")
+	cmd := command(ctx, "ffmpeg", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	if stdout.Len() == 0 {
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: fmt.Errorf("no frames at %s", timestamp)}
+	}
+	return stdout.Bytes(), nil
+}
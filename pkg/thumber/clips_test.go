@@ -0,0 +1,19 @@
+package thumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClipOptions(t *testing.T) {
+	c := ClipOptions{}.withDefaults()
+	assert.Equal(t, time.Second, c.Duration)
+	assert.Equal(t, 10, c.FPS)
+	assert.Equal(t, 60, c.Quality)
+
+	assert.NoError(t, ClipOptions{Duration: 2 * time.Second, FPS: 15}.Validate())
+	assert.ErrorContains(t, ClipOptions{Duration: -time.Second}.Validate(), "duration cannot be negative")
+	assert.ErrorContains(t, ClipOptions{Quality: 101}.Validate(), "quality must be between 0 and 100")
+}
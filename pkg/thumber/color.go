@@ -0,0 +1,131 @@
+package thumber
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses a CSS-like color. It accepts hex colors with or without a leading hash
+// (#RGB, #RGBA, #RRGGBB, #RRGGBBAA), named CSS colors such as "navy", "transparent",
+// and the functional rgb(...) and rgba(...) notations.
+func ParseColor(s string) (color.Color, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty color string")
+	}
+
+	lower := strings.ToLower(s)
+	if lower == "transparent" {
+		return color.Transparent, nil
+	}
+	if c, ok := colornames.Map[lower]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(lower, "rgb(") || strings.HasPrefix(lower, "rgba(") {
+		return parseRGBFunc(lower)
+	}
+	return parseHexColor(s)
+}
+
+func parseHexColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	switch len(hex) {
+	case 3, 4:
+		// shorthand: every digit is doubled, #FA0 is #FFAA00
+		var expanded strings.Builder
+		for _, r := range hex {
+			expanded.WriteRune(r)
+			expanded.WriteRune(r)
+		}
+		hex = expanded.String()
+	case 6, 8:
+	default:
+		return nil, fmt.Errorf("invalid hex color %q: expected 3, 4, 6 or 8 hex digits", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color %q: %w", s, err)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// parseRGBFunc parses rgb(...) and rgba(...) with either comma or space separated channels,
+// e.g. "rgba(255, 0, 0, 0.5)" or "rgb(255 0 0 / 50%)"
+func parseRGBFunc(s string) (color.Color, error) {
+	open := strings.IndexByte(s, '(')
+	if !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid color %q: missing closing parenthesis", s)
+	}
+	body := s[open+1 : len(s)-1]
+
+	var parts []string
+	if strings.Contains(body, ",") {
+		for _, p := range strings.Split(body, ",") {
+			parts = append(parts, strings.TrimSpace(p))
+		}
+	} else {
+		body = strings.Replace(body, "/", " ", 1)
+		parts = strings.Fields(body)
+	}
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, fmt.Errorf("invalid color %q: expected 3 or 4 components, got %d", s, len(parts))
+	}
+
+	var channels [4]uint8
+	channels[3] = 0xff
+	for i, p := range parts {
+		var (
+			v   float64
+			err error
+		)
+		if i == 3 {
+			v, err = parseAlpha(p)
+		} else {
+			v, err = parseChannel(p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid color %q: %w", s, err)
+		}
+		channels[i] = uint8(math.Round(v))
+	}
+	return color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: channels[3]}, nil
+}
+
+// parseChannel parses a color channel given as 0-255 or as a percentage, and returns it in 0-255
+func parseChannel(s string) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := parseRange(p, 0, 100)
+		return v * 255 / 100, err
+	}
+	return parseRange(s, 0, 255)
+}
+
+// parseAlpha parses an alpha value given as 0-1 or as a percentage, and returns it in 0-255
+func parseAlpha(s string) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := parseRange(p, 0, 100)
+		return v * 255 / 100, err
+	}
+	v, err := parseRange(s, 0, 1)
+	return v * 255, err
+}
+
+func parseRange(s string, lo, hi float64) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("%s is out of range [%g, %g]", s, lo, hi)
+	}
+	return v, nil
+}
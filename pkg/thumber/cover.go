@@ -0,0 +1,173 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// coverMuxers maps the extensions that support attached pictures to ffmpeg muxers
+var coverMuxers = map[string]string{
+	".mp4": "mp4",
+	".m4v": "mp4",
+	".mov": "mov",
+	".mkv": "matroska",
+}
+
+// CoverFormat returns the ffmpeg muxer used to embed cover art into a file with the given path,
+// or an error if its container doesn't support attached pictures
+func CoverFormat(path string) (string, error) {
+	format, ok := coverMuxers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", fmt.Errorf("cannot embed cover art into %q, only mp4, m4v, mov and mkv files are supported", filepath.Base(path))
+	}
+	return format, nil
+}
+
+// EmbedCover remuxes the video into outputPath with cover as its attached picture, without re-encoding.
+// Existing cover art is replaced, all other streams and metadata are kept.
+// The container is picked from the extension of videoPath, so outputPath can be a temporary file.
+func EmbedCover(ctx context.Context, videoPath, outputPath string, cover image.Image, quality int) error {
+	if err := checkFfmpegInstalled(); err != nil {
+		return err
+	}
+	format, err := CoverFormat(videoPath)
+	if err != nil {
+		return err
+	}
+
+	coverFile, err := os.CreateTemp("", "thumber-cover-*.jpg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(coverFile.Name())
+	err = jpeg.Encode(coverFile, cover, &jpeg.Options{Quality: quality})
+	if closeErr := coverFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode cover: %w", err)
+	}
+
+	videoStreams, err := countVideoStreams(ctx, videoPath)
+	if err != nil {
+		return fmt.Errorf("failed to read video streams: %w", err)
+	}
+	coverStream := "v:" + strconv.Itoa(videoStreams)
+	ctx = allowWrites(ctx, filepath.Dir(outputPath))
+
+	cmd := command(
+		ctx,
+		"ffmpeg",
+		"-hide_banner",
+		"-y",
+		"-i", videoPath,
+		"-i", coverFile.Name(),
+		// keep everything but the existing cover art, V only matches video that isn't an attached picture
+		"-map", "0",
+		"-map", "-0:v",
+		"-map", "0:V",
+		"-map", "1",
+		"-map_metadata", "0",
+		"-c", "copy",
+		"-disposition:"+coverStream, "attached_pic",
+		"-metadata:s:"+coverStream, "filename=cover.jpg",
+		"-metadata:s:"+coverStream, "mimetype=image/jpeg",
+		"-f", format,
+		outputPath,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	return nil
+}
+
+// countVideoStreams counts the video streams that aren't attached pictures
+func countVideoStreams(ctx context.Context, videoPath string) (int, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "V",
+		"-show_entries", "stream=index",
+		videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	p, err := decodeProbe(out)
+	if err != nil {
+		return 0, err
+	}
+	return len(p.Streams), nil
+}
+
+// ReadCoverArt decodes the first attached picture of the file, e.g. the cover art of an audio-only container.
+// It returns ErrNoCoverArt if the file has none, and a LimitError if it exceeds the limits.
+func ReadCoverArt(ctx context.Context, videoPath string, limits ProcessLimits) (image.Image, error) {
+	if err := checkFfmpegInstalled(); err != nil {
+		return nil, err
+	}
+	out, err := runProbe(ctx,
+		"-select_streams", "v",
+		"-show_entries", "stream=index:stream_disposition=attached_pic",
+		videoPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read streams: %w", err)
+	}
+	streams, err := parseAttachedPics(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, ErrNoCoverArt
+	}
+
+	// re-encoded as PNG, covers can be in formats Go can't decode, e.g. WebP
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args,
+		"-i", videoPath,
+		"-map", "0:"+strconv.Itoa(streams[0]),
+		"-frames:v", "1",
+		"-c:v", "png",
+		"-f", "image2", "pipe:1",
+	)
+	cmd := command(ctx, "ffmpeg", args...)
+	stdout := limits.outputBuffer()
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		if err := stdout.err(); err != nil {
+			return nil, err
+		}
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	img, err := limits.decodeImage(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cover art: %w", err)
+	}
+	return img, nil
+}
+
+// parseAttachedPics picks the indexes of the streams with the attached_pic disposition
+func parseAttachedPics(out []byte) ([]int, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return nil, err
+	}
+	var streams []int
+	for _, s := range p.Streams {
+		if s.Disposition["attached_pic"] == 1 {
+			streams = append(streams, s.Index)
+		}
+	}
+	return streams, nil
+}
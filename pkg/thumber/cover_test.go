@@ -0,0 +1,28 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttachedPics(t *testing.T) {
+	streams, err := parseAttachedPics([]byte(`{"streams": [
+		{"index": 0, "disposition": {"default": 1, "attached_pic": 0}},
+		{"index": 2, "disposition": {"default": 0, "attached_pic": 1}}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, streams)
+
+	streams, err = parseAttachedPics([]byte(`{"streams": [{"index": 0, "disposition": {"attached_pic": 0}}]}`))
+	require.NoError(t, err)
+	assert.Empty(t, streams)
+
+	streams, err = parseAttachedPics([]byte(`{}`))
+	require.NoError(t, err)
+	assert.Empty(t, streams, "files without video streams")
+
+	_, err = parseAttachedPics([]byte("0,1\n"))
+	assert.Error(t, err)
+}
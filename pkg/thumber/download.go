@@ -0,0 +1,177 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IsRemote reports whether the input is an http, https or s3 URL, which is downloaded before extraction
+func IsRemote(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "s3://")
+}
+
+// RemoteName is the file name of a remote input, e.g. movie.mp4 for https://example.com/videos/movie.mp4?token=x
+func RemoteName(input string) string {
+	u, err := url.Parse(input)
+	if err != nil {
+		return "video"
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || name == "" {
+		return "video"
+	}
+	return name
+}
+
+// RemoteURL is where a remote input is read from. s3://bucket/key is read anonymously,
+// so only public objects can be read, from AWS_ENDPOINT_URL for S3 compatible storage, or the region in AWS_REGION.
+func RemoteURL(input string) (string, error) {
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	if !strings.EqualFold(u.Scheme, "s3") {
+		return input, nil
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid s3 url %q, must be s3://bucket/key", input)
+	}
+	key := strings.TrimPrefix(u.EscapedPath(), "/")
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), u.Host, key), nil
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.Host, region, key), nil
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", u.Host, key), nil
+}
+
+// CanStream reports whether ffmpeg can read the remote input directly instead of downloading it first,
+// which is when its server supports range requests, so ffmpeg only fetches the parts it seeks to,
+// and the sandbox of ctx lets ffmpeg use the network
+func CanStream(ctx context.Context, input string) bool {
+	if s := sandboxFrom(ctx); s.NoNetwork || s.Confine {
+		return false
+	}
+	src, err := RemoteURL(input)
+	if err != nil {
+		return false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusPartialContent
+}
+
+// DownloadOptions control how remote inputs are downloaded
+type DownloadOptions struct {
+	// MaxBytesPerSecond caps the download rate, 0 is unlimited
+	MaxBytesPerSecond int64
+	// Progress is called every few seconds and when the download finishes, total is -1 if the size is unknown
+	Progress func(read, total int64)
+}
+
+// progressInterval is how often DownloadOptions.Progress is called
+const progressInterval = 5 * time.Second
+
+// Download saves a remote input to the workspace under its own name, for servers that ffmpeg can't seek on,
+// see CanStream, and returns its path. The file gets the modification time of the remote file if the server reports it.
+func Download(ctx context.Context, w *Workspace, input string, opts DownloadOptions) (string, error) {
+	src, err := RemoteURL(input)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", input, resp.Status)
+	}
+
+	// a directory per download keeps the name, which ends up in headers and templates
+	dir, err := os.MkdirTemp(w.Dir(), "download-*")
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, RemoteName(input))
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	r := &throttledReader{ctx: ctx, r: resp.Body, rate: opts.MaxBytesPerSecond, start: time.Now(), total: resp.ContentLength, progress: opts.Progress}
+	if err := w.copy(f, r); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", input, err)
+	}
+	if opts.Progress != nil {
+		opts.Progress(r.read, resp.ContentLength)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		_ = os.Chtimes(p, modified, modified)
+	}
+	return p, nil
+}
+
+// throttledReader reads no faster than rate bytes per second on average, and reports progress
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	rate     int64
+	start    time.Time
+	read     int64
+	total    int64
+	progress func(read, total int64)
+	reported time.Time
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// small reads keep the pauses short, so the rate is even rather than bursts
+	if chunk := t.rate/10 + 1; t.rate > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+	if t.rate > 0 {
+		due := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+		if wait := due - time.Since(t.start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-t.ctx.Done():
+				return n, t.ctx.Err()
+			}
+		}
+	}
+	if t.progress != nil && time.Since(t.reported) >= progressInterval {
+		if !t.reported.IsZero() {
+			t.progress(t.read, t.total)
+		}
+		t.reported = time.Now()
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRemote(t *testing.T) {
+	assert.True(t, IsRemote("https://example.com/movie.mp4"))
+	assert.True(t, IsRemote("HTTP://example.com/movie.mp4"))
+	assert.True(t, IsRemote("s3://bucket/videos/movie.mp4"))
+	assert.False(t, IsRemote("movie.mp4"))
+	assert.False(t, IsRemote("/videos/http://movie.mp4"))
+}
+
+func TestRemoteName(t *testing.T) {
+	assert.Equal(t, "movie night.mp4", RemoteName("https://example.com/videos/movie%20night.mp4?token=x"))
+	assert.Equal(t, "movie.mp4", RemoteName("s3://bucket/videos/movie.mp4"))
+	assert.Equal(t, "video", RemoteName("https://example.com/"))
+}
+
+func TestRemoteURL(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_REGION", "")
+	u, err := RemoteURL("s3://bucket/videos/movie%20night.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/videos/movie%20night.mp4", u)
+
+	t.Setenv("AWS_REGION", "eu-west-1")
+	u, _ = RemoteURL("s3://bucket/movie.mp4")
+	assert.Equal(t, "https://bucket.s3.eu-west-1.amazonaws.com/movie.mp4", u)
+
+	t.Setenv("AWS_ENDPOINT_URL", "http://minio.local:9000/")
+	u, _ = RemoteURL("s3://bucket/movie.mp4")
+	assert.Equal(t, "http://minio.local:9000/bucket/movie.mp4", u, "path style for s3 compatible storage")
+
+	u, _ = RemoteURL("https://example.com/movie.mp4")
+	assert.Equal(t, "https://example.com/movie.mp4", u)
+}
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("video"), 40_000)
+	modified := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.mp4" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "movie.mp4", modified, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	ws, err := NewWorkspace(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	var read, total int64
+	started := time.Now()
+	path, err := Download(context.Background(), ws, srv.URL+"/videos/movie.mp4", DownloadOptions{
+		MaxBytesPerSecond: 1_000_000,
+		Progress:          func(r, t int64) { read, total = r, t },
+	})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(started), 150*time.Millisecond, "200 KB at 1 MB/s")
+	assert.Equal(t, "movie.mp4", filepath.Base(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int64(len(content)), read)
+	assert.Equal(t, int64(len(content)), total)
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, stat.ModTime().Equal(modified), "modification time of the remote file")
+
+	_, err = Download(context.Background(), ws, srv.URL+"/missing.mp4", DownloadOptions{})
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestCanStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-ranges.mp4" {
+			w.Write([]byte("video"))
+			return
+		}
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader([]byte("video")))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	assert.True(t, CanStream(ctx, srv.URL+"/movie.mp4"))
+	assert.False(t, CanStream(ctx, srv.URL+"/no-ranges.mp4"))
+	assert.False(t, CanStream(WithSandbox(ctx, Sandbox{NoNetwork: true}), srv.URL+"/movie.mp4"), "ffmpeg can't use the network")
+}
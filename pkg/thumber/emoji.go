@@ -0,0 +1,44 @@
+package thumber
+
+import (
+	"strings"
+
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+)
+
+// hasGlyph reports whether f has a glyph for ch. freetype only reads the Basic Multilingual Plane of a font,
+// and would look up the characters above it, like most emoji, as another one.
+func hasGlyph(f *truetype.Font, ch rune) bool {
+	return ch <= 0xFFFF && f.Index(ch) != 0
+}
+
+// isEmoji reports whether r is in one of the blocks of symbols and pictographs emoji come from
+func isEmoji(r rune) bool {
+	return (r >= 0x2190 && r <= 0x21FF) || (r >= 0x2300 && r <= 0x23FF) || (r >= 0x25A0 && r <= 0x27BF) ||
+		(r >= 0x2B00 && r <= 0x2BFF) || (r >= 0x1F000 && r <= 0x1FAFF)
+}
+
+// isEmojiModifier reports whether r is an invisible character that picks the presentation of emoji
+// or changes their skin tone. Monochrome fonts draw the base emoji, so they're dropped.
+func isEmojiModifier(r rune) bool {
+	return r == '\uFE0E' || r == '\uFE0F' || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// stripEmojiModifiers drops the emoji modifiers from text, which would otherwise be drawn as missing glyphs,
+// and the zero width joiners of emoji sequences, whose emoji are drawn one by one. Joiners elsewhere,
+// e.g. between the letters of scripts that use them to pick a form, are kept.
+func stripEmojiModifiers(text string) string {
+	if strings.IndexFunc(text, func(r rune) bool { return r == '\u200D' || isEmojiModifier(r) }) < 0 {
+		return text
+	}
+	var b strings.Builder
+	var prev rune
+	for _, r := range text {
+		if isEmojiModifier(r) || (r == '\u200D' && isEmoji(prev)) {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
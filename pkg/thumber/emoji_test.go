@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
+)
+
+func TestStripEmojiModifiers(t *testing.T) {
+	assert.Equal(t, "goal ⚽", stripEmojiModifiers("goal ⚽️"))
+	assert.Equal(t, "👍 nice", stripEmojiModifiers("👍🏽 nice"), "skin tones are dropped")
+	assert.Equal(t, "👨👩👧", stripEmojiModifiers("👨‍👩‍👧"), "joined emoji are drawn one by one")
+	assert.Equal(t, "plain", stripEmojiModifiers("plain"))
+	assert.Equal(t, "क्\u200Dष", stripEmojiModifiers("क्\u200Dष"), "joiners outside of emoji sequences are kept")
+}
+
+func TestBundledEmoji(t *testing.T) {
+	r := defaultRenderer{Font: fonts.RobotoMonoMedium, FontSizePt: 12, BackgroundColor: color.Transparent, ForegroundColor: color.White}
+
+	segments := r.segments("ok ☀😀")
+	require.Len(t, segments, 2)
+	assert.Equal(t, fontSegment{font: fonts.RobotoMonoMedium, text: "ok "}, segments[0])
+	assert.Equal(t, fontSegment{font: fonts.Emoji, text: "☀" + string(fonts.EmojiRune('😀'))}, segments[1])
+
+	img, err := r.Render("😀")
+	require.NoError(t, err)
+	var inked bool
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+				inked = true
+			}
+		}
+	}
+	assert.True(t, inked, "the emoji is drawn without system fonts")
+}
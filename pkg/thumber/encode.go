@@ -0,0 +1,144 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"runtime/trace"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/exp/slog"
+)
+
+// MinTargetQuality is the lowest JPEG quality EncodeJPEGWithMaxSize tries, below it tiles are too blocky to be useful
+const MinTargetQuality = 10
+
+// EncodeJPEGWithMaxSize encodes img as JPEG using the highest quality from MinTargetQuality up to maxQuality
+// that keeps the output within maxBytes.
+// It returns an error if the image doesn't fit even at MinTargetQuality.
+func EncodeJPEGWithMaxSize(img image.Image, maxBytes int64, maxQuality int) ([]byte, int, error) {
+	defer trace.StartRegion(context.Background(), "encode").End()
+
+	encode := func(quality int) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode as jpeg: %w", err)
+		}
+		slog.Debug("encoded jpeg", "quality", quality, "bytes", buf.Len())
+		return buf.Bytes(), nil
+	}
+
+	best, err := encode(maxQuality)
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(best)) <= maxBytes {
+		return best, maxQuality, nil
+	}
+
+	if maxQuality <= MinTargetQuality {
+		return nil, 0, fmt.Errorf("image takes %d bytes at quality %d, more than the target of %d", len(best), maxQuality, maxBytes)
+	}
+	best, err = encode(MinTargetQuality)
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(best)) > maxBytes {
+		return nil, 0, fmt.Errorf("image takes %d bytes even at quality %d, more than the target of %d", len(best), MinTargetQuality, maxBytes)
+	}
+
+	// binary search for the highest quality that fits
+	bestQuality := MinTargetQuality
+	lo, hi := MinTargetQuality+1, maxQuality-1
+	for lo <= hi {
+		q := (lo + hi) / 2
+		out, err := encode(q)
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(out)) <= maxBytes {
+			best, bestQuality = out, q
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+
+	return best, bestQuality, nil
+}
+
+// EncodeWebP encodes img as lossy WebP at quality from 0 to 100 with ffmpeg, as Go has no WebP encoder
+func EncodeWebP(ctx context.Context, img image.Image, quality int) ([]byte, error) {
+	defer trace.StartRegion(ctx, "encode").End()
+
+	// PNG is lossless and fast to decode, so the frame reaches ffmpeg unchanged
+	var in bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&in, img); err != nil {
+		return nil, fmt.Errorf("failed to encode as png: %w", err)
+	}
+	cmd := command(ctx, "ffmpeg",
+		"-hide_banner",
+		"-f", "png_pipe", "-i", "pipe:0",
+		"-c:v", "libwebp",
+		"-quality", strconv.Itoa(quality),
+		"-f", "webp", "pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = &in
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+const (
+	// darkLuma is the average luma from 0 to 255 below which a tile counts as dark
+	darkLuma = 48
+	// darkTileShare is the share of dark tiles from which the quality of a sheet is boosted
+	darkTileShare = 0.25
+)
+
+// BoostDarkQuality raises quality by boost, up to 100, when at least a quarter of the tiles on the sheet are dark.
+// Grain in dark scenes turns to blocks at qualities that suit well lit ones.
+func BoostDarkQuality(sheet Sheet, quality, boost int) int {
+	if boost <= 0 || len(sheet.Tiles) == 0 {
+		return quality
+	}
+	dark := 0
+	for _, t := range sheet.Tiles {
+		if averageLuma(t.Frame.Image) < darkLuma {
+			dark++
+		}
+	}
+	if float64(dark)/float64(len(sheet.Tiles)) < darkTileShare {
+		return quality
+	}
+	quality += boost
+	if quality > 100 {
+		quality = 100
+	}
+	slog.Debug("boosted quality for dark tiles", "dark_tiles", dark, "tiles", len(sheet.Tiles), "quality", quality)
+	return quality
+}
+
+// averageLuma is the mean brightness of img from 0 to 255, measured on a small copy
+func averageLuma(img image.Image) float64 {
+	if img == nil {
+		return 0
+	}
+	const size = 16
+	gray := imaging.Grayscale(imaging.Resize(img, size, size, imaging.Box))
+	var sum float64
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			sum += float64(gray.Pix[y*gray.Stride+x*4])
+		}
+	}
+	return sum / (size * size)
+}
@@ -0,0 +1,63 @@
+package thumber
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoostDarkQuality(t *testing.T) {
+	tile := func(c color.Color) TilePlacement {
+		return TilePlacement{Frame: Thumbnail{Image: imaging.New(32, 18, c)}}
+	}
+	dark, bright := color.Gray{Y: 20}, color.Gray{Y: 160}
+	sheet := func(tiles ...TilePlacement) Sheet {
+		return Sheet{Image: image.NewRGBA(image.Rect(0, 0, 1, 1)), Tiles: tiles}
+	}
+
+	assert.Equal(t, 80, BoostDarkQuality(sheet(tile(bright), tile(bright), tile(bright), tile(bright)), 80, 10))
+	assert.Equal(t, 90, BoostDarkQuality(sheet(tile(dark), tile(bright), tile(bright), tile(bright)), 80, 10))
+	assert.Equal(t, 100, BoostDarkQuality(sheet(tile(dark)), 95, 10), "quality is capped")
+	assert.Equal(t, 80, BoostDarkQuality(sheet(tile(dark)), 80, 0), "no boost by default")
+}
+
+func TestEncodeJPEGWithMaxSize(t *testing.T) {
+	// noise, so every step of quality changes the size
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	rng.Read(img.Pix)
+	size := func(quality int) int64 {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}))
+		return int64(buf.Len())
+	}
+
+	out, quality, err := EncodeJPEGWithMaxSize(img, size(80), 80)
+	require.NoError(t, err)
+	assert.Equal(t, 80, quality, "fits at the highest quality")
+	assert.Equal(t, size(80), int64(len(out)))
+
+	target := size(50)
+	out, quality, err = EncodeJPEGWithMaxSize(img, target, 80)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, int64(len(out)), target)
+	assert.GreaterOrEqual(t, quality, 50)
+	assert.Less(t, quality, 80)
+
+	out, quality, err = EncodeJPEGWithMaxSize(img, size(MinTargetQuality), 80)
+	require.NoError(t, err)
+	assert.Equal(t, MinTargetQuality, quality, "fits only at the floor")
+	assert.Equal(t, size(MinTargetQuality), int64(len(out)))
+
+	_, _, err = EncodeJPEGWithMaxSize(img, size(MinTargetQuality)-1, 80)
+	assert.ErrorContains(t, err, "even at quality 10")
+	_, _, err = EncodeJPEGWithMaxSize(img, 100, MinTargetQuality)
+	assert.ErrorContains(t, err, "more than the target of 100")
+}
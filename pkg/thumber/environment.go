@@ -0,0 +1,104 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Environment describes the ffmpeg installation thumber runs against
+type Environment struct {
+	FfmpegPath     string
+	FfmpegVersion  string
+	FfprobePath    string
+	FfprobeVersion string
+	HWAccels       []string
+	// Encoders lists the available image encoders relevant to thumber
+	Encoders []string
+}
+
+var relevantEncoders = []string{"mjpeg", "png", "ppm", "libwebp", "libwebp_anim", "gif", "tiff"}
+
+func ProbeEnvironment(ctx context.Context) (Environment, error) {
+	var env Environment
+	var err error
+
+	if env.FfmpegPath, err = exec.LookPath("ffmpeg"); err != nil {
+		return env, ErrFfmpegNotFound
+	}
+	if env.FfprobePath, err = exec.LookPath("ffprobe"); err != nil {
+		return env, ErrFfprobeNotFound
+	}
+
+	if env.FfmpegVersion, err = readToolVersion(ctx, "ffmpeg"); err != nil {
+		return env, err
+	}
+	if env.FfprobeVersion, err = readToolVersion(ctx, "ffprobe"); err != nil {
+		return env, err
+	}
+
+	out, err := runTool(ctx, "ffmpeg", "-hide_banner", "-hwaccels")
+	if err != nil {
+		return env, err
+	}
+	env.HWAccels = parseHWAccels(out)
+
+	out, err = runTool(ctx, "ffmpeg", "-hide_banner", "-encoders")
+	if err != nil {
+		return env, err
+	}
+	env.Encoders = parseEncoders(out, relevantEncoders)
+
+	return env, nil
+}
+
+func runTool(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := command(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return "", &CommandError{Command: name, Stderr: string(out), Err: err}
+	}
+	return string(out), nil
+}
+
+// readToolVersion returns the version from the first line of -version output, e.g. "ffmpeg version 6.0 Copyright..."
+func readToolVersion(ctx context.Context, name string) (string, error) {
+	out, err := runTool(ctx, name, "-version")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[1] != "version" {
+		return "", fmt.Errorf("failed to parse %s version", name)
+	}
+	return fields[2], nil
+}
+
+func parseHWAccels(out string) []string {
+	var accels []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		accels = append(accels, line)
+	}
+	return accels
+}
+
+// parseEncoders picks the names in wanted from -encoders output, where each line looks like " V....D mjpeg   MJPEG (Motion JPEG)"
+func parseEncoders(out string, wanted []string) []string {
+	var encoders []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields[0]) != 6 {
+			continue
+		}
+		if slices.Contains(wanted, fields[1]) {
+			encoders = append(encoders, fields[1])
+		}
+	}
+	return encoders
+}
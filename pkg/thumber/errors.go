@@ -0,0 +1,59 @@
+package thumber
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	ErrFfmpegNotFound  = errors.New("ffmpeg not installed or not in PATH")
+	ErrFfprobeNotFound = errors.New("ffprobe not installed or not in PATH")
+	// ErrTesseractNotFound is returned when reading timecodes is requested without tesseract installed
+	ErrTesseractNotFound = errors.New("tesseract not installed or not in PATH")
+	// ErrNoCoverArt is returned by ReadCoverArt for files without an attached picture
+	ErrNoCoverArt = errors.New("no embedded cover art")
+)
+
+// CommandError is returned when ffmpeg or ffprobe fails.
+// The message only includes the last line of stderr, the full output is in Stderr.
+type CommandError struct {
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	if line := lastLine(e.Stderr); line != "" {
+		return fmt.Sprintf("failed to run %s: %s: %s", e.Command, e.Err, line)
+	}
+	return fmt.Sprintf("failed to run %s: %s", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned when extracting a frame panics, e.g. while decoding garbage bytes,
+// so a bad frame fails like any other instead of taking down the process
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// RecoverPanic turns a panic into a PanicError assigned to err. It must be deferred directly.
+func RecoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
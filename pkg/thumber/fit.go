@@ -0,0 +1,80 @@
+package thumber
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Fit controls how frames are scaled when both tile width and height are set
+type Fit string
+
+const (
+	// FitStretch scales frames to the tile size ignoring the aspect ratio
+	FitStretch Fit = "stretch"
+	// FitContain scales frames to fit inside the tile and pads the rest
+	FitContain Fit = "contain"
+	// FitCover scales frames to fill the tile and crops the overflow
+	FitCover Fit = "cover"
+)
+
+func ParseFit(s string) (Fit, error) {
+	switch f := Fit(s); f {
+	case FitStretch, FitContain, FitCover:
+		return f, nil
+	case "":
+		return FitStretch, nil
+	}
+	return "", fmt.Errorf("invalid fit mode %q, must be one of stretch, contain, cover", s)
+}
+
+const ambientBlur = "boxblur=lr='min(w,h)/8':lp=2:cr='min(cw,ch)/8':cp=2"
+
+func scaleFilter(opts ThumbOptions) string {
+	width, height := opts.TileWidth, opts.TileHeight
+	m := opts.dimensionMultiple()
+	switch {
+	case width == 0 && height == 0:
+		if opts.MaxTileDimension > 0 {
+			// fit into a square of the max dimension, without upscaling smaller frames
+			return fmt.Sprintf("scale='min(%[1]d,iw)':'min(%[1]d,ih)':force_original_aspect_ratio=decrease:force_divisible_by=%[2]d", opts.MaxTileDimension, m)
+		}
+		if m > 1 {
+			return fmt.Sprintf("scale=trunc(iw/%[1]d)*%[1]d:trunc(ih/%[1]d)*%[1]d", m)
+		}
+		return "scale=iw:ih"
+	case width == 0:
+		// a negative size of -m follows the aspect ratio, rounded to a multiple of m
+		return fmt.Sprintf("scale=-%d:%d", m, height)
+	case height == 0:
+		return fmt.Sprintf("scale=%d:-%d", width, m)
+	}
+
+	switch opts.Fit {
+	case FitContain:
+		if opts.PadBlur {
+			return fmt.Sprintf(
+				"split[fitfg][fitbg];[fitbg]scale=%[1]d:%[2]d:force_original_aspect_ratio=increase,crop=%[1]d:%[2]d,%[3]s[fitbgout];"+
+					"[fitfg]scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease[fitfgout];"+
+					"[fitbgout][fitfgout]overlay=(W-w)/2:(H-h)/2",
+				width, height, ambientBlur,
+			)
+		}
+		return fmt.Sprintf(
+			"scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2:color=%[3]s",
+			width, height, ffmpegColor(opts.PadColor),
+		)
+	case FitCover:
+		return fmt.Sprintf("scale=%[1]d:%[2]d:force_original_aspect_ratio=increase,crop=%[1]d:%[2]d", width, height)
+	default:
+		return fmt.Sprintf("scale=%d:%d", width, height)
+	}
+}
+
+// ffmpegColor formats c in ffmpeg's 0xRRGGBBAA color syntax, defaulting to black
+func ffmpegColor(c color.Color) string {
+	if c == nil {
+		c = color.Black
+	}
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("0x%02x%02x%02x%02x", rgba.R, rgba.G, rgba.B, rgba.A)
+}
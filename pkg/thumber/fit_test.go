@@ -0,0 +1,73 @@
+package thumber
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Fit
+		err  string
+	}{
+		{in: "", want: FitStretch},
+		{in: "stretch", want: FitStretch},
+		{in: "contain", want: FitContain},
+		{in: "cover", want: FitCover},
+		{in: "fill", err: `invalid fit mode "fill", must be one of stretch, contain, cover`},
+		{in: "Cover", err: `invalid fit mode "Cover", must be one of stretch, contain, cover`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFit(tt.in)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScaleFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ThumbOptions
+		want string
+	}{
+		{name: "width only", opts: ThumbOptions{TileWidth: 320, Fit: FitCover}, want: "scale=320:-1"},
+		{name: "height only", opts: ThumbOptions{TileHeight: 180, DimensionMultiple: 2}, want: "scale=-2:180"},
+		{name: "stretch", opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitStretch}, want: "scale=320:180"},
+		{name: "stretch by default", opts: ThumbOptions{TileWidth: 320, TileHeight: 180}, want: "scale=320:180"},
+		{
+			name: "contain",
+			opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitContain},
+			want: "scale=320:180:force_original_aspect_ratio=decrease,pad=320:180:(ow-iw)/2:(oh-ih)/2:color=0x000000ff",
+		},
+		{
+			name: "contain with pad color",
+			opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitContain, PadColor: color.RGBA{R: 0xff, G: 0x80, A: 0xff}},
+			want: "scale=320:180:force_original_aspect_ratio=decrease,pad=320:180:(ow-iw)/2:(oh-ih)/2:color=0xff8000ff",
+		},
+		{
+			name: "contain with blurred padding",
+			opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitContain, PadBlur: true},
+			want: "split[fitfg][fitbg];[fitbg]scale=320:180:force_original_aspect_ratio=increase,crop=320:180," + ambientBlur + "[fitbgout];" +
+				"[fitfg]scale=320:180:force_original_aspect_ratio=decrease[fitfgout];[fitbgout][fitfgout]overlay=(W-w)/2:(H-h)/2",
+		},
+		{
+			name: "cover",
+			opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitCover},
+			want: "scale=320:180:force_original_aspect_ratio=increase,crop=320:180",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scaleFilter(tt.opts))
+		})
+	}
+}
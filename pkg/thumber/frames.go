@@ -0,0 +1,55 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+	"golang.org/x/exp/slog"
+)
+
+// FrameFunc receives a frame encoded in ThumbOptions.Intermediate along with its presentation time
+type FrameFunc func(ts time.Duration, r io.Reader) error
+
+// ExtractFrames extracts the same frames as MakeThumbnails, but hands them to fn as encoded bytes straight from ffmpeg
+// without decoding them, which is much cheaper when the frames only need to be saved.
+// Since frames aren't decoded, timestamps aren't overlaid.
+// fn is never called concurrently, but frames arrive in the order they're extracted rather than by timestamp.
+// Extraction stops at the first error fn returns.
+func ExtractFrames(ctx context.Context, videoPath string, opts ThumbOptions, fn FrameFunc) error {
+	opts = opts.withDefaults()
+	timestamps, err := PlanTimestamps(ctx, videoPath, opts)
+	if err != nil {
+		return err
+	}
+
+	p := pool.New().
+		WithContext(ctx).
+		WithMaxGoroutines(opts.concurrency()).
+		WithCancelOnError().
+		WithFirstError()
+
+	var mu sync.Mutex
+	filter := videoFilter(opts)
+	for i, t := range timestamps {
+		i, t := i, t
+		p.Go(func(ctx context.Context) (err error) {
+			defer RecoverPanic(&err)
+			ctx = withFrameIndex(withVideoPath(ctx, videoPath), i)
+			slog.Debug("extracting frame", "current", i+1, "total", len(timestamps))
+			data, actual, err := extractFrame(ctx, videoPath, t, filter, opts)
+			if err != nil {
+				slog.Error("failed to extract frame", "timestamp", t, "error", err)
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(actual, bytes.NewReader(data))
+		})
+	}
+	return p.Wait()
+}
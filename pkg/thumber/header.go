@@ -0,0 +1,190 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerLineHeight is the height of each line of the metadata header in px
+const headerLineHeight = 24
+
+// HeaderInfo is what the metadata header above the tiles shows about the video
+type HeaderInfo struct {
+	Name     string
+	Size     int64
+	Duration time.Duration
+	// Bitrate is the overall bitrate in bits per second
+	Bitrate    int64
+	VideoCodec string
+	Width      int
+	Height     int
+	FrameRate  float64
+	// AudioTracks and SubtitleTracks are in the order of the streams in the file
+	AudioTracks    []Track
+	SubtitleTracks []Track
+}
+
+// Track is an audio or subtitle stream of the video
+type Track struct {
+	Codec string
+	// Channels is the channel layout of audio tracks, e.g. 5.1
+	Channels string
+	// Language is the ISO 639-2 code of the track, e.g. eng, and empty if it isn't tagged
+	Language string
+}
+
+// ReadHeaderInfo probes the video for the metadata header
+func ReadHeaderInfo(ctx context.Context, videoPath string) (HeaderInfo, error) {
+	out, err := runProbe(ctx,
+		"-show_entries", "format=size,duration,bit_rate"+
+			":stream=codec_type,codec_name,width,height,avg_frame_rate,channels,channel_layout"+
+			":stream_tags=language:stream_disposition=attached_pic",
+		videoPath,
+	)
+	if err != nil {
+		return HeaderInfo{}, err
+	}
+	info, err := parseHeaderInfo(out)
+	if err != nil {
+		return HeaderInfo{}, err
+	}
+	info.Name = filepath.Base(videoPath)
+	return info, nil
+}
+
+// parseHeaderInfo reads the format, the first video stream that isn't cover art, and the audio and subtitle streams
+// of the probe, skipping values ffprobe doesn't report
+func parseHeaderInfo(out []byte) (HeaderInfo, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return HeaderInfo{}, err
+	}
+	var info HeaderInfo
+	info.Size, _ = strconv.ParseInt(p.Format.Size, 10, 64)
+	info.Bitrate, _ = strconv.ParseInt(p.Format.BitRate, 10, 64)
+	info.Duration, _ = parseSeconds(p.Format.Duration)
+	video := false
+	for _, s := range p.Streams {
+		switch s.CodecType {
+		case "video":
+			if video || s.Disposition["attached_pic"] == 1 {
+				continue
+			}
+			video = true
+			info.VideoCodec = s.CodecName
+			info.Width = s.Width
+			info.Height = s.Height
+			info.FrameRate, _ = parseFrameRate(s.AvgFrameRate)
+		case "audio":
+			info.AudioTracks = append(info.AudioTracks, Track{Codec: s.CodecName, Channels: channelLayout(s), Language: trackLanguage(s)})
+		case "subtitle":
+			info.SubtitleTracks = append(info.SubtitleTracks, Track{Codec: s.CodecName, Language: trackLanguage(s)})
+		}
+	}
+	return info, nil
+}
+
+// channelLayout names the channels of an audio stream like 5.1 or 2.0, from the layout ffprobe reports or the channel count
+func channelLayout(s probeStream) string {
+	layout, _, _ := strings.Cut(s.ChannelLayout, "(")
+	switch layout {
+	case "mono":
+		return "1.0"
+	case "stereo":
+		return "2.0"
+	case "":
+	default:
+		return layout
+	}
+	switch s.Channels {
+	case 0:
+		return ""
+	case 1:
+		return "1.0"
+	case 2:
+		return "2.0"
+	case 6:
+		return "5.1"
+	case 8:
+		return "7.1"
+	}
+	return fmt.Sprintf("%dch", s.Channels)
+}
+
+// trackLanguage is the language tag of the stream, where und means there is none
+func trackLanguage(s probeStream) string {
+	if lang := s.Tags["language"]; lang != "und" {
+		return lang
+	}
+	return ""
+}
+
+// Lines are the lines of the header in the locale: the file name, the file and the video details, then the audio and
+// subtitle tracks. Details that weren't probed are left out, but there are always four lines so the header keeps its height.
+func (h HeaderInfo) Lines(l Locale) []string {
+	l = l.orDefault()
+	var file, video []string
+	if h.Size > 0 {
+		file = append(file, fmt.Sprintf("%s: %s", l.Size, FormatSize(h.Size, l)))
+	}
+	if h.Duration > 0 {
+		file = append(file, fmt.Sprintf("%s: %s", l.Duration, FormatDuration(h.Duration)))
+	}
+	if h.Bitrate > 0 {
+		file = append(file, fmt.Sprintf("%s: %s", l.Bitrate, FormatBitrate(h.Bitrate, l)))
+	}
+	if h.VideoCodec != "" {
+		video = append(video, fmt.Sprintf("%s: %s", l.Video, strings.ToUpper(h.VideoCodec)))
+	}
+	if h.Width > 0 && h.Height > 0 {
+		video = append(video, fmt.Sprintf("%s: %dx%d", l.Resolution, h.Width, h.Height))
+	}
+	if h.FrameRate > 0 {
+		// up to 3 decimals, e.g. 25 or 23.976
+		video = append(video, l.formatFloat(math.Round(h.FrameRate*1000)/1000, -1)+" fps")
+	}
+	var tracks []string
+	if len(h.AudioTracks) > 0 {
+		var audio []string
+		for _, t := range h.AudioTracks {
+			audio = append(audio, joinNonEmpty(strings.ToUpper(t.Codec), t.Channels, t.Language))
+		}
+		tracks = append(tracks, fmt.Sprintf("%s: %s", l.Audio, strings.Join(audio, ", ")))
+	}
+	if len(h.SubtitleTracks) > 0 {
+		var subs []string
+		for _, t := range h.SubtitleTracks {
+			// the language is what tells subtitles apart, the codec only stands in for untagged ones
+			if t.Language != "" {
+				subs = append(subs, t.Language)
+			} else {
+				subs = append(subs, strings.ToUpper(t.Codec))
+			}
+		}
+		tracks = append(tracks, fmt.Sprintf("%s: %s", l.Subtitles, strings.Join(subs, ", ")))
+	}
+	return []string{h.Name, strings.Join(file, " | "), strings.Join(video, " | "), strings.Join(tracks, "; ")}
+}
+
+func joinNonEmpty(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// headerHeight is the height of the header above the tiles, or 0 without one
+func (l LayoutOptions) headerHeight() int {
+	if len(l.Header) == 0 {
+		return 0
+	}
+	return l.Padding + len(l.Header)*headerLineHeight
+}
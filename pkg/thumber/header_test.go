@@ -0,0 +1,70 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeaderInfo(t *testing.T) {
+	out := `{
+		"streams": [
+			{"codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "disposition": {"attached_pic": 1}},
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "24000/1001", "disposition": {"attached_pic": 0}},
+			{"codec_type": "audio", "codec_name": "ac3", "channels": 6, "channel_layout": "5.1(side)", "tags": {"language": "eng"}},
+			{"codec_type": "audio", "codec_name": "aac", "channels": 2, "tags": {"language": "und"}},
+			{"codec_type": "subtitle", "codec_name": "subrip", "tags": {"language": "ger"}}
+		],
+		"format": {"duration": "5025.042000", "size": "1503238554"}
+	}`
+	info, err := parseHeaderInfo([]byte(out))
+	require.NoError(t, err)
+	assert.Equal(t, "h264", info.VideoCodec)
+	assert.Equal(t, 1920, info.Width)
+	assert.Equal(t, 1080, info.Height)
+	assert.InDelta(t, 23.976, info.FrameRate, 0.001)
+	assert.Equal(t, 5025042*time.Millisecond, info.Duration)
+	assert.Equal(t, int64(1503238554), info.Size)
+	assert.Zero(t, info.Bitrate)
+	assert.Equal(t, []Track{{Codec: "ac3", Channels: "5.1", Language: "eng"}, {Codec: "aac", Channels: "2.0"}}, info.AudioTracks)
+	assert.Equal(t, []Track{{Codec: "subrip", Language: "ger"}}, info.SubtitleTracks)
+}
+
+func TestHeaderLines(t *testing.T) {
+	info := HeaderInfo{
+		Name: "movie.mkv", Size: 1503238554, Duration: 5025 * time.Second, Bitrate: 2_393_000, VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 24000.0 / 1001,
+		AudioTracks:    []Track{{Codec: "ac3", Channels: "5.1", Language: "eng"}, {Codec: "aac", Channels: "2.0", Language: "jpn"}},
+		SubtitleTracks: []Track{{Codec: "subrip", Language: "eng"}, {Codec: "ass", Language: "ger"}, {Codec: "hdmv_pgs_subtitle"}},
+	}
+	assert.Equal(t, []string{
+		"movie.mkv",
+		"Size: 1.4 GB | Duration: 01:23:45 | Bitrate: 2.4 Mb/s",
+		"Video: H264 | Resolution: 1920x1080 | 23.976 fps",
+		"Audio: AC3 5.1 eng, AAC 2.0 jpn; Subs: eng, ger, HDMV_PGS_SUBTITLE",
+	}, info.Lines(Locale{}))
+
+	de, _ := ParseLocale("de")
+	assert.Equal(t, []string{
+		"movie.mkv",
+		"Größe: 1,4 GB | Dauer: 01:23:45 | Bitrate: 2,4 Mb/s",
+		"Video: H264 | Auflösung: 1920x1080 | 23,976 fps",
+		"Audio: AC3 5.1 eng, AAC 2.0 jpn; Untertitel: eng, ger, HDMV_PGS_SUBTITLE",
+	}, info.Lines(de))
+
+	assert.Len(t, HeaderInfo{Name: "audio.m4a"}.Lines(Locale{}), 4, "missing details keep the header height")
+}
+
+func TestComposeSheetWithHeader(t *testing.T) {
+	tiles := []Thumbnail{{Image: imaging.New(100, 50, color.White)}, {Image: imaging.New(100, 50, color.White)}}
+	sheet, err := ComposeSheet(tiles, LayoutOptions{Columns: 2, Padding: 10, Header: []string{"movie.mkv", "Size: 1.4 GB"}})
+	require.NoError(t, err)
+
+	headerHeight := 10 + 2*headerLineHeight
+	assert.Equal(t, image.Rect(0, 0, 230, 70+headerHeight), sheet.Bounds())
+	assert.Equal(t, image.Rect(120, 10+headerHeight, 220, 60+headerHeight), sheet.Tiles[1].Rect)
+}
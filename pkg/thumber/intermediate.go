@@ -0,0 +1,85 @@
+package thumber
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Intermediate is the codec ffmpeg encodes extracted frames in before they're decoded and composed
+type Intermediate string
+
+const (
+	// IntermediateJPEG is fast and small, but the final encode of the sheet compresses the frames a second time
+	IntermediateJPEG Intermediate = "jpeg"
+	// IntermediatePNG is lossless, and slower to encode and decode
+	IntermediatePNG Intermediate = "png"
+	// IntermediatePPM is lossless and uncompressed, the cheapest to encode and decode but the largest to pipe
+	IntermediatePPM Intermediate = "ppm"
+)
+
+// codecArgs are the ffmpeg output arguments that encode frames in the intermediate codec
+func (i Intermediate) codecArgs() []string {
+	switch i {
+	case IntermediatePNG:
+		return []string{"-c:v", "png"}
+	case IntermediatePPM:
+		return []string{"-c:v", "ppm", "-pix_fmt", "rgb24"}
+	default:
+		return []string{"-q:v", "1"}
+	}
+}
+
+func init() {
+	image.RegisterFormat("ppm", "P6", decodePPM, decodePPMConfig)
+}
+
+// decodePPM decodes binary PPM images with 8 bits per channel, as written by ffmpeg's ppm encoder
+func decodePPM(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	cfg, err := readPPMHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	row := make([]byte, cfg.Width*3)
+	for y := 0; y < cfg.Height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, fmt.Errorf("failed to read ppm pixels: %w", err)
+		}
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < cfg.Width; x++ {
+			pix[x*4], pix[x*4+1], pix[x*4+2], pix[x*4+3] = row[x*3], row[x*3+1], row[x*3+2], 0xff
+		}
+	}
+	return img, nil
+}
+
+func decodePPMConfig(r io.Reader) (image.Config, error) {
+	return readPPMHeader(bufio.NewReader(r))
+}
+
+// readPPMHeader reads the magic, size and maxval of a PPM image, leaving br at the first pixel
+func readPPMHeader(br *bufio.Reader) (image.Config, error) {
+	var magic string
+	var width, height, maxval int
+	// Fscan skips the whitespace between fields, the single whitespace byte before the pixels is read after
+	if _, err := fmt.Fscan(br, &magic, &width, &height, &maxval); err != nil {
+		return image.Config{}, fmt.Errorf("failed to read ppm header: %w", err)
+	}
+	if magic != "P6" {
+		return image.Config{}, fmt.Errorf("unsupported ppm format %q", magic)
+	}
+	if maxval != 255 {
+		return image.Config{}, fmt.Errorf("unsupported ppm maxval %d, expected 255", maxval)
+	}
+	if width <= 0 || height <= 0 {
+		return image.Config{}, fmt.Errorf("invalid ppm size %dx%d", width, height)
+	}
+	if _, err := br.ReadByte(); err != nil {
+		return image.Config{}, fmt.Errorf("failed to read ppm header: %w", err)
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, nil
+}
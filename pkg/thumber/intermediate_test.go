@@ -0,0 +1,28 @@
+package thumber
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePPM(t *testing.T) {
+	data := append([]byte("P6\n2 1\n255\n"), 0xff, 0x00, 0x00, 0x00, 0x80, 0x0a)
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "ppm", format)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), img.Bounds())
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.At(0, 0))
+	assert.Equal(t, color.RGBA{G: 0x80, B: 0x0a, A: 0xff}, img.At(1, 0), "a pixel byte that looks like whitespace isn't skipped")
+
+	_, _, err = image.Decode(bytes.NewReader(data[:len(data)-1]))
+	assert.Error(t, err, "truncated pixels")
+
+	_, err = decodePPM(bytes.NewReader([]byte("P6\n2 1\n65535\n")))
+	assert.ErrorContains(t, err, "unsupported ppm maxval 65535")
+}
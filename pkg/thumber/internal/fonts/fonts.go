@@ -18,3 +18,31 @@ var RobotoMonoMedium *truetype.Font = func() *truetype.Font {
 	}
 	return f
 }()
+
+//go:embed DejaVuSans-Emoji.ttf
+var emojiBytes []byte
+
+// Emoji has the monochrome symbols and emoji of DejaVu Sans: arrows, geometric shapes, dingbats and emoticons.
+// freetype only reads the Basic Multilingual Plane of a font, so the emoji from U+1F300 to U+1FAFF are moved
+// to the Private Use Area from U+E000 on, see EmojiRune.
+var Emoji *truetype.Font = func() *truetype.Font {
+	f, err := freetype.ParseFont(emojiBytes)
+	if err != nil {
+		panic(fmt.Errorf("failed to parse font: %w", err))
+	}
+	return f
+}()
+
+// emojiStart and emojiEnd are the emoji moved to the Private Use Area of Emoji
+const (
+	emojiStart = 0x1F300
+	emojiEnd   = 0x1FAFF
+)
+
+// EmojiRune is the code point of r in Emoji
+func EmojiRune(r rune) rune {
+	if r >= emojiStart && r <= emojiEnd {
+		return 0xE000 + r - emojiStart
+	}
+	return r
+}
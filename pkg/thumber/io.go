@@ -0,0 +1,45 @@
+package thumber
+
+import (
+	"context"
+	"sync"
+)
+
+// IOMode controls how seeks into the same source are scheduled
+type IOMode string
+
+const (
+	// IOParallel extracts Concurrency frames of a file at once, which suits local disks
+	IOParallel IOMode = "parallel"
+	// IOSequential extracts frames of the same file one at a time, as parallel seeks on SMB and NFS shares
+	// are slower than sequential ones. Different files are still read in parallel.
+	IOSequential IOMode = "sequential"
+	// IOMount extracts one frame at a time from all files on the same mount, for shares that are slow
+	// even when different files are read at once
+	IOMount IOMode = "mount"
+)
+
+// sourceLocks serialize reads per source key, shared by every generation in the process
+var sourceLocks sync.Map
+
+// lockSource waits until no other frame is read from the same source as videoPath, or until ctx is done.
+// The returned function releases the source, and is a no-op in parallel mode.
+func lockSource(ctx context.Context, videoPath string, mode IOMode) (func(), error) {
+	var key string
+	switch mode {
+	case IOSequential:
+		key = fileKey(videoPath)
+	case IOMount:
+		key = mountKey(videoPath)
+	default:
+		return func() {}, nil
+	}
+	v, _ := sourceLocks.LoadOrStore(key, make(chan struct{}, 1))
+	lock := v.(chan struct{})
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
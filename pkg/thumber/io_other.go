@@ -0,0 +1,17 @@
+//go:build !unix
+
+package thumber
+
+import "path/filepath"
+
+// fileKey identifies the file by its absolute path
+func fileKey(path string) string {
+	abs, _ := filepath.Abs(path)
+	return "file:" + filepath.Clean(abs)
+}
+
+// mountKey identifies the volume of the file, e.g. a drive letter or a UNC share
+func mountKey(path string) string {
+	abs, _ := filepath.Abs(path)
+	return "mount:" + filepath.VolumeName(abs)
+}
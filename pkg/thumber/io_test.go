@@ -0,0 +1,44 @@
+package thumber
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockSource(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.mp4"), filepath.Join(dir, "b.mp4")
+	require.NoError(t, os.WriteFile(a, nil, 0o644))
+	require.NoError(t, os.WriteFile(b, nil, 0o644))
+	ctx := context.Background()
+	busy := func(path string, mode IOMode) bool {
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		unlock, err := lockSource(timeout, path, mode)
+		if err != nil {
+			return true
+		}
+		unlock()
+		return false
+	}
+
+	unlock, err := lockSource(ctx, a, IOSequential)
+	require.NoError(t, err)
+	assert.True(t, busy(a, IOSequential), "frames of the same file are read one at a time")
+	assert.True(t, busy(filepath.Join(dir, ".", "a.mp4"), IOSequential), "another path to the same file")
+	assert.False(t, busy(b, IOSequential), "other files are read in parallel")
+	assert.False(t, busy(a, IOParallel))
+	unlock()
+	assert.False(t, busy(a, IOSequential))
+
+	unlock, err = lockSource(ctx, a, IOMount)
+	require.NoError(t, err)
+	assert.True(t, busy(b, IOMount), "files on the same mount are read one at a time")
+	unlock()
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package thumber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileKey identifies the file by device and inode, so links and different paths to it share a key
+func fileKey(path string) string {
+	if st, ok := stat(path); ok {
+		return fmt.Sprintf("file:%d:%d", st.Dev, st.Ino)
+	}
+	abs, _ := filepath.Abs(path)
+	return "file:" + abs
+}
+
+// mountKey identifies the mounted filesystem the file is on
+func mountKey(path string) string {
+	if st, ok := stat(path); ok {
+		return fmt.Sprintf("mount:%d", st.Dev)
+	}
+	abs, _ := filepath.Abs(path)
+	return "mount:" + filepath.VolumeName(abs)
+}
+
+func stat(path string) (*syscall.Stat_t, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return st, ok
+}
@@ -0,0 +1,42 @@
+package thumber
+
+import (
+	"math/rand"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// rand is the source of random choices like jitter, seeded from Seed so runs can be reproduced
+func (o ThumbOptions) rand() *rand.Rand {
+	seed := time.Now().UnixNano()
+	if o.Seed != nil {
+		seed = *o.Seed
+	} else {
+		slog.Debug("picked random seed", "seed", seed)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// jitterWindow limits the jitter to half the interval, so tiles keep their order and don't swap places
+func jitterWindow(jitter, interval time.Duration) time.Duration {
+	if jitter > interval/2 {
+		return interval / 2
+	}
+	return jitter
+}
+
+// jitter moves t by a random offset within ±window, keeping it within [start, end)
+func jitter(rng *rand.Rand, t, window, start, end time.Duration) time.Duration {
+	if window <= 0 {
+		return t
+	}
+	t += time.Duration(rng.Int63n(int64(2*window)+1)) - window
+	if t < start {
+		t = start
+	}
+	if end > start && t >= end {
+		t = end - time.Millisecond
+	}
+	return t
+}
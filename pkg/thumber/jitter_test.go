@@ -0,0 +1,41 @@
+package thumber
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterWindow(t *testing.T) {
+	assert.Equal(t, 5*time.Second, jitterWindow(5*time.Second, time.Minute))
+	assert.Equal(t, 4*time.Second, jitterWindow(5*time.Second, 8*time.Second), "at most half the interval")
+}
+
+func TestJitter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	moved := false
+	for i := 0; i < 100; i++ {
+		got := jitter(rng, time.Minute, 5*time.Second, 0, time.Hour)
+		assert.InDelta(t, float64(time.Minute), float64(got), float64(5*time.Second))
+		moved = moved || got != time.Minute
+	}
+	assert.True(t, moved)
+
+	for i := 0; i < 100; i++ {
+		got := jitter(rng, 10*time.Second, 5*time.Second, 10*time.Second, 12*time.Second)
+		assert.GreaterOrEqual(t, got, 10*time.Second, "not before the starting point")
+		assert.Less(t, got, 12*time.Second, "not past the end")
+	}
+	assert.Equal(t, time.Minute, jitter(rng, time.Minute, 0, 0, time.Hour))
+}
+
+func TestSeed(t *testing.T) {
+	seed := int64(42)
+	opts := ThumbOptions{Seed: &seed}
+	a, b := opts.rand(), opts.rand()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, jitter(a, time.Minute, 5*time.Second, 0, time.Hour), jitter(b, time.Minute, 5*time.Second, 0, time.Hour))
+	}
+}
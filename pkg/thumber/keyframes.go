@@ -0,0 +1,94 @@
+package thumber
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// readKeyframes lists the presentation times of the keyframes in the first video stream, in order.
+// Only keyframes are decoded, so this is much faster than reading every frame.
+func readKeyframes(ctx context.Context, videoPath string) ([]time.Duration, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "v:0",
+		"-skip_frame", "nokey",
+		"-show_frames",
+		"-show_entries", "frame=best_effort_timestamp_time",
+		videoPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyframes(out)
+}
+
+// parseKeyframes reads the timestamps of the frames, skipping frames ffprobe doesn't report one for
+func parseKeyframes(out []byte) ([]time.Duration, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return nil, err
+	}
+	var keyframes []time.Duration
+	for _, f := range p.Frames {
+		t, err := parseSeconds(f.BestEffortTimestampTime)
+		if err != nil {
+			continue
+		}
+		keyframes = append(keyframes, t)
+	}
+	sort.Slice(keyframes, func(i, j int) bool { return keyframes[i] < keyframes[j] })
+	return keyframes, nil
+}
+
+// snapToKeyframes moves each timestamp to the nearest keyframe within window of it, which seeks land on without
+// decoding any frames before it. A keyframe is used by one tile at most, so tiles keep their even spacing where keyframes
+// are sparse, and tiles without a free keyframe nearby stay where they are.
+func snapToKeyframes(keyframes, timestamps []time.Duration, window time.Duration) []time.Duration {
+	snapped := make([]time.Duration, len(timestamps))
+	used := map[int]bool{}
+	for j, t := range timestamps {
+		snapped[j] = t
+		i := sort.Search(len(keyframes), func(i int) bool { return keyframes[i] >= t })
+		best := -1
+		for _, k := range []int{i - 1, i} {
+			if k < 0 || k >= len(keyframes) || used[k] || absDuration(keyframes[k]-t) > window {
+				continue
+			}
+			if best < 0 || absDuration(keyframes[k]-t) < absDuration(keyframes[best]-t) {
+				best = k
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			snapped[j] = keyframes[best]
+		}
+	}
+	return snapped
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// alignToKeyframes moves each timestamp to the last keyframe at or before it, or the first keyframe if there's none.
+// Like in snapToKeyframes, a keyframe is used by one tile at most, so tiles between the same keyframes don't show
+// the same frame, and those whose keyframe is taken stay where they are.
+func alignToKeyframes(keyframes, timestamps []time.Duration) []time.Duration {
+	aligned := make([]time.Duration, len(timestamps))
+	used := map[int]bool{}
+	for j, t := range timestamps {
+		aligned[j] = t
+		i := sort.Search(len(keyframes), func(i int) bool { return keyframes[i] > t }) - 1
+		if i < 0 {
+			i = 0
+		}
+		if !used[i] {
+			used[i] = true
+			aligned[j] = keyframes[i]
+		}
+	}
+	return aligned
+}
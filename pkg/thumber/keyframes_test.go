@@ -0,0 +1,45 @@
+package thumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	out := `{"frames": [
+		{"best_effort_timestamp_time": "0.000000"},
+		{"best_effort_timestamp_time": "4.004000"},
+		{},
+		{"best_effort_timestamp_time": "2.002000"}
+	]}`
+	keyframes, err := parseKeyframes([]byte(out))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{0, 2002 * time.Millisecond, 4004 * time.Millisecond}, keyframes)
+}
+
+func TestAlignToKeyframes(t *testing.T) {
+	s := time.Second
+	keyframes := []time.Duration{s, 5 * s, 10 * s}
+
+	assert.Equal(t, []time.Duration{s, 5 * s, 10 * s}, alignToKeyframes(keyframes, []time.Duration{0, 5 * s, time.Minute}))
+	assert.Equal(t, []time.Duration{s, 5 * s}, alignToKeyframes(keyframes, []time.Duration{4 * s, 6 * s}))
+
+	// tiles between the same keyframes don't collapse onto the same frame
+	assert.Equal(t, []time.Duration{s, 2 * s, 5 * s, 7 * s}, alignToKeyframes(keyframes, []time.Duration{0, 2 * s, 6 * s, 7 * s}))
+}
+
+func TestSnapToKeyframes(t *testing.T) {
+	s := time.Second
+	keyframes := []time.Duration{0, 9 * s, 11 * s, 12 * s, 31 * s, 48 * s}
+	timestamps := []time.Duration{0, 10 * s, 20 * s, 30 * s, 40 * s}
+
+	// 10s is as close to 9s as to 11s and takes the earlier one, while 20s and 40s have none within the window and stay
+	assert.Equal(t, []time.Duration{0, 9 * s, 20 * s, 31 * s, 40 * s}, snapToKeyframes(keyframes, timestamps, 2500*time.Millisecond))
+
+	// a keyframe is used once, so close tiles don't collapse onto the same frame
+	assert.Equal(t, []time.Duration{9 * s, 11 * s}, snapToKeyframes([]time.Duration{9 * s, 11 * s}, []time.Duration{10 * s, 10500 * time.Millisecond}, 2*s))
+	assert.Equal(t, []time.Duration{9 * s, 10500 * time.Millisecond}, snapToKeyframes([]time.Duration{9 * s}, []time.Duration{10 * s, 10500 * time.Millisecond}, 2*s))
+}
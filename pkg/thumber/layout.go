@@ -0,0 +1,419 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+	"github.com/disintegration/imaging"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
+)
+
+// LayoutOptions controls how tiles are laid out and decorated on a sheet, independently of how frames are extracted
+type LayoutOptions struct {
+	Columns int
+	// Padding is the space around and between tiles in px
+	Padding             int
+	OverlayTimestamps   bool
+	TimestampBackground color.Color
+	// TimestampOffset is subtracted from overlaid timestamps, e.g. to show offsets from the start of an excerpt
+	TimestampOffset time.Duration
+	// Captions reserves a strip under every tile for Thumbnail.Caption
+	Captions  bool
+	QRMarkers bool
+	// MotionHeatmap borders tiles in the color of Thumbnail.Motion
+	MotionHeatmap bool
+	// Audio is drawn as a timeline strip under the tiles, if set
+	Audio *AudioProfile
+	// Header are lines of text drawn above the tiles, e.g. from HeaderInfo.Lines
+	Header []string
+	// Locale is the language of text drawn by layouts, e.g. titles of untitled chapters
+	Locale Locale
+	// FallbackFonts draw characters of captions and labels the bundled font has no glyph for
+	FallbackFonts []*truetype.Font
+	// TextAlign aligns labels, e.g. the header and chapter titles
+	TextAlign TextAlign
+	// Theme colors the canvas, tile borders, text and timestamp badges
+	Theme Theme
+	// Template draws bands of text and images around the tiles, if set
+	Template *Template
+	// TemplateData is what the bands of Template refer to, the page and tiles are filled in for each sheet
+	TemplateData TemplateData
+	// MaxCanvasDimension limits the width and height of the sheet, defaults to the limit of JPEG
+	MaxCanvasDimension int
+	OnOversize         Oversize
+	// Layout places the tiles, defaults to GridLayout
+	Layout Layout
+	// DimensionMultiple rounds the sheet size up and scaled tile sizes down to a multiple of this many px
+	DimensionMultiple int
+}
+
+// LayoutOptions returns the layout part of the options
+func (o ThumbOptions) LayoutOptions() LayoutOptions {
+	l := LayoutOptions{
+		Columns:             o.TileColumns,
+		Padding:             o.Padding,
+		OverlayTimestamps:   o.OverlayTimestamps,
+		TimestampBackground: o.TimestampBackground,
+		Captions:            len(o.Annotations) > 0,
+		QRMarkers:           o.QRMarkers,
+		MotionHeatmap:       o.MotionHeatmap,
+		MaxCanvasDimension:  o.MaxCanvasDimension,
+		OnOversize:          o.OnOversize,
+		Layout:              o.Layout,
+		DimensionMultiple:   o.DimensionMultiple,
+		Locale:              o.Locale,
+		FallbackFonts:       o.FallbackFonts,
+		TextAlign:           o.TextAlign,
+		Template:            o.Template,
+		Theme:               o.Theme,
+	}
+	if o.TimestampOrigin == TimestampRelative {
+		l.TimestampOffset = o.From
+	}
+	return l
+}
+
+func (l LayoutOptions) layout() Layout {
+	if l.Layout == nil {
+		return GridLayout{}
+	}
+	return l.Layout
+}
+
+// maxCanvasDimension is the limit of the sheet size, rounded down so the sheet stays within it after rounding up
+func (l LayoutOptions) maxCanvasDimension() int {
+	limit := maxJPEGDimension
+	if l.MaxCanvasDimension != 0 {
+		limit = l.MaxCanvasDimension
+	}
+	return limit / l.dimensionMultiple() * l.dimensionMultiple()
+}
+
+func (l LayoutOptions) dimensionMultiple() int {
+	if l.DimensionMultiple > 1 {
+		return l.DimensionMultiple
+	}
+	return 1
+}
+
+func (l LayoutOptions) timestampRenderer() timestampRenderer {
+	theme := l.Theme.orDefault()
+	background := l.TimestampBackground
+	if background == nil {
+		background = theme.Badge
+	}
+	return defaultRenderer{
+		Font:            fonts.RobotoMonoMedium,
+		FontSizePt:      12,
+		BackgroundColor: background,
+		ForegroundColor: theme.BadgeText,
+	}
+}
+
+// displayTimestamp is the timestamp shown on the tile of a frame at ts
+func (l LayoutOptions) displayTimestamp(ts time.Duration) time.Duration {
+	if ts < l.TimestampOffset {
+		// frames moved before the starting point, e.g. by keyframe alignment
+		return 0
+	}
+	return ts - l.TimestampOffset
+}
+
+func (l LayoutOptions) validate(tiles []Thumbnail) error {
+	if len(tiles) == 0 {
+		return fmt.Errorf("no thumbnails to lay out")
+	}
+	if l.Padding < 0 {
+		return fmt.Errorf("padding cannot be negative")
+	}
+	switch l.OnOversize {
+	case "", OversizeError, OversizeScale:
+	default:
+		return fmt.Errorf("invalid oversize policy %q, must be one of error, scale", l.OnOversize)
+	}
+	for i, t := range tiles {
+		if t.Image == nil || t.Bounds().Empty() {
+			return fmt.Errorf("tile %d has no image", i+1)
+		}
+	}
+	return nil
+}
+
+// ComposeSheet lays out tiles in a grid and decorates them as set in layout.
+// Tiles can come from anywhere, e.g. a decoder of the caller, and are all drawn at the size of the first one.
+// The tiles are not modified.
+func ComposeSheet(tiles []Thumbnail, layout LayoutOptions) (Sheet, error) {
+	if err := layout.validate(tiles); err != nil {
+		return Sheet{}, err
+	}
+	return composeSheet(context.Background(), tiles, layout)
+}
+
+func composeSheet(ctx context.Context, thumbs []Thumbnail, opts LayoutOptions) (_ Sheet, err error) {
+	_, stage := startStage(ctx, "compose", attribute.Int("thumber.tiles", len(thumbs)))
+	defer func() { stage.End(err) }()
+
+	// tiles are scaled and overlaid below, which shouldn't leak into the slice of the caller
+	thumbs = slices.Clone(thumbs)
+	tiles := make([]LayoutTile, len(thumbs))
+	for i, t := range thumbs {
+		tiles[i] = LayoutTile{Size: t.Bounds().Size(), Timestamp: t.Timestamp}
+	}
+	plan, err := opts.Plan(tiles)
+	if err != nil {
+		return Sheet{}, err
+	}
+	if plan.Scale < 1 {
+		slog.Warn("contact sheet exceeds size limit, scaling tiles down", "limit", opts.maxCanvasDimension(), "scale", plan.Scale)
+	}
+	captionHeight := plan.CaptionHeight
+
+	theme := opts.Theme.orDefault()
+	background := theme.Background
+	if opts.Template != nil && opts.Template.background != nil {
+		background = opts.Template.background
+	}
+	canvas := imaging.New(plan.Size.X, plan.Size.Y, background)
+
+	renderer := opts.timestampRenderer()
+	captionRenderer := opts.captionRenderer()
+
+	var placements []TilePlacement
+	for i, img := range thumbs {
+		rect := plan.Tiles[i]
+		if img.Bounds().Size() != rect.Size() {
+			img.resize(rect.Dx(), rect.Dy())
+		}
+
+		frame := img
+		if opts.OverlayTimestamps {
+			// the tile is placed without it, so later tiles keep their slots
+			if err := img.overlayTimestamp(renderer, opts.displayTimestamp(img.Timestamp)); err != nil {
+				slog.Warn("failed to overlay timestamp text", "timestamp", img.Timestamp, "error", err)
+			}
+		}
+		// drawn before the marker, which must keep its quiet zone
+		if opts.MotionHeatmap {
+			img.overlayMotion()
+		}
+		if opts.QRMarkers {
+			if err := img.overlayMarker(); err != nil {
+				slog.Warn("failed to overlay qr marker", "timestamp", img.Timestamp, "error", err)
+			}
+		}
+		canvas = imaging.Paste(canvas, img, rect.Min)
+		// without padding, borders would cover the neighbouring tiles
+		if opts.Padding > 0 {
+			theme.drawBorder(canvas, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y+captionHeight))
+		}
+		if captionHeight > 0 && img.Caption != "" {
+			canvas, err = drawCaption(canvas, captionRenderer, img.Caption, image.Pt(rect.Min.X, rect.Max.Y), rect.Dx(), captionHeight)
+			if err != nil {
+				slog.Error("failed to draw caption", "timestamp", img.Timestamp, "caption", img.Caption, "error", err)
+			}
+		}
+		placements = append(placements, TilePlacement{
+			Rect:      rect,
+			Timestamp: img.Timestamp,
+			Frame:     frame,
+		})
+	}
+
+	if opts.Audio != nil {
+		ticks := make([]time.Duration, len(thumbs))
+		for i, t := range thumbs {
+			ticks[i] = t.Timestamp
+		}
+		drawAudioStrip(canvas, plan.Timeline, *opts.Audio, ticks)
+	}
+
+	labelRenderer := opts.labelRenderer()
+	for _, l := range plan.Labels {
+		canvas, err = drawLabel(canvas, labelRenderer, l, opts.TextAlign)
+		if err != nil {
+			slog.Error("failed to draw label", "label", l.Text, "error", err)
+		}
+	}
+
+	if opts.Template != nil {
+		data := opts.TemplateData
+		data.Tiles = len(thumbs)
+		data.First, data.Last = thumbs[0].Timestamp, thumbs[len(thumbs)-1].Timestamp
+		if data.Page == 0 {
+			data.Page, data.Pages = 1, 1
+		}
+		if canvas, err = opts.Template.drawBands(canvas, plan.Bands, opts, data); err != nil {
+			return Sheet{}, fmt.Errorf("failed to draw template bands: %w", err)
+		}
+	}
+	return Sheet{Image: canvas, Tiles: placements}, nil
+}
+
+// SheetPlan is the geometry of a sheet, computed before anything is rendered
+type SheetPlan struct {
+	// Rows and Columns count the distinct positions of tiles, which for grids are the rows and columns of the grid
+	Rows, Columns int
+	Size          image.Point
+	// Tiles are where the frames go, without their caption strips
+	Tiles  []image.Rectangle
+	Labels []Label
+	// CaptionHeight is the height of the strip under each tile reserved for captions
+	CaptionHeight int
+	// Scale is how much frames are scaled down to fit MaxCanvasDimension, 1 if they fit as they are
+	Scale float64
+	// Timeline is where the audio timeline strip goes, empty without LayoutOptions.Audio
+	Timeline image.Rectangle
+	// Bands are where the bands of LayoutOptions.Template go in order, without the grid
+	Bands []image.Rectangle
+}
+
+// Plan arranges tiles of the given sizes and timestamps, and applies the canvas size limit.
+// It returns an error when the sheet would be too large and OnOversize doesn't allow scaling,
+// so callers can check dimensions before extracting any frames.
+func (l LayoutOptions) Plan(tiles []LayoutTile) (SheetPlan, error) {
+	if len(tiles) == 0 {
+		return SheetPlan{}, fmt.Errorf("no tiles to lay out")
+	}
+	captionHeight, err := l.captionHeight()
+	if err != nil {
+		return SheetPlan{}, fmt.Errorf("failed to measure captions: %w", err)
+	}
+	tiles = slices.Clone(tiles)
+	for i := range tiles {
+		tiles[i].CaptionHeight = captionHeight
+	}
+
+	layout := l.layout()
+	arr, err := layout.Arrange(tiles, l)
+	if err != nil {
+		return SheetPlan{}, err
+	}
+	if len(arr.Cells) != len(tiles) {
+		return SheetPlan{}, fmt.Errorf("layout placed %d of %d tiles", len(arr.Cells), len(tiles))
+	}
+
+	// the timeline strip goes under the tiles, followed by padding like the tiles
+	timelineHeight := 0
+	if l.Audio != nil {
+		timelineHeight = audioStripHeight + l.Padding
+	}
+
+	headerHeight := l.headerHeight()
+
+	// bands of the template go above the header and under the timeline
+	var above, below int
+	if l.Template != nil {
+		if above, below, err = l.Template.heights(l); err != nil {
+			return SheetPlan{}, fmt.Errorf("failed to measure template bands: %w", err)
+		}
+	}
+	extraHeight := timelineHeight + headerHeight + above + below
+
+	scale := 1.0
+	limit := l.maxCanvasDimension()
+	if w, h := arr.Size.X, arr.Size.Y+extraHeight; w > limit || h > limit {
+		ok := false
+		if l.OnOversize == OversizeScale {
+			arr, scale, ok = scaleToFit(layout, tiles, l, limit, extraHeight)
+		}
+		if !ok {
+			return SheetPlan{}, fmt.Errorf("contact sheet would be %dx%d px, exceeding the limit of %d px: use fewer tiles, smaller tiles or scale on oversize", w, h, limit)
+		}
+	}
+
+	if headerHeight > 0 {
+		arr = withHeader(arr, l.Header, l.Padding, headerHeight)
+	}
+	if above > 0 {
+		arr = shiftDown(arr, above)
+	}
+
+	var timeline image.Rectangle
+	if timelineHeight > 0 {
+		timeline = image.Rect(l.Padding, arr.Size.Y, arr.Size.X-l.Padding, arr.Size.Y+audioStripHeight)
+		arr.Size.Y += timelineHeight
+	}
+
+	var bands []image.Rectangle
+	if l.Template != nil {
+		bands = l.Template.place(l, arr.Size.X, arr.Size.Y)
+		arr.Size.Y += below
+	}
+
+	// the margin added by rounding up is filled with the background
+	m := l.dimensionMultiple()
+	arr.Size = image.Pt((arr.Size.X+m-1)/m*m, (arr.Size.Y+m-1)/m*m)
+
+	plan := SheetPlan{Size: arr.Size, Labels: arr.Labels, CaptionHeight: captionHeight, Scale: scale, Timeline: timeline, Bands: bands}
+	xs, ys := map[int]bool{}, map[int]bool{}
+	for _, c := range arr.Cells {
+		plan.Tiles = append(plan.Tiles, image.Rect(c.Min.X, c.Min.Y, c.Max.X, c.Max.Y-captionHeight))
+		xs[c.Min.X], ys[c.Min.Y] = true, true
+	}
+	plan.Rows, plan.Columns = len(ys), len(xs)
+	return plan, nil
+}
+
+// withHeader moves the arrangement down by height, and puts the header lines above it as labels
+func withHeader(arr Arrangement, lines []string, padding, height int) Arrangement {
+	arr = shiftDown(arr, height)
+	var labels []Label
+	for i, line := range lines {
+		top := padding + i*headerLineHeight
+		labels = append(labels, Label{Rect: image.Rect(padding, top, arr.Size.X-padding, top+headerLineHeight), Text: line})
+	}
+	arr.Labels = append(labels, arr.Labels...)
+	return arr
+}
+
+// shiftDown moves the cells and labels of the arrangement down by height, leaving the space above empty
+func shiftDown(arr Arrangement, height int) Arrangement {
+	offset := image.Pt(0, height)
+	cells := make([]image.Rectangle, len(arr.Cells))
+	for i, c := range arr.Cells {
+		cells[i] = c.Add(offset)
+	}
+	labels := make([]Label, len(arr.Labels))
+	for i, label := range arr.Labels {
+		labels[i] = Label{Rect: label.Rect.Add(offset), Text: label.Text}
+	}
+	return Arrangement{Size: arr.Size.Add(offset), Cells: cells, Labels: labels}
+}
+
+// scaleToFit finds the largest scale of the frames, at which the arrangement and extraHeight under it fit within limit.
+// Padding and captions keep their size, so the scale is searched for instead of computed.
+func scaleToFit(layout Layout, tiles []LayoutTile, opts LayoutOptions, limit, extraHeight int) (_ Arrangement, scale float64, ok bool) {
+	var best Arrangement
+	lo, hi := 0.0, 1.0
+	scaled := make([]LayoutTile, len(tiles))
+	for i := 0; i < 32; i++ {
+		mid := (lo + hi) / 2
+		tooSmall := false
+		for j, t := range tiles {
+			m := opts.dimensionMultiple()
+			t.Size = image.Pt(int(float64(t.Size.X)*mid)/m*m, int(float64(t.Size.Y)*mid)/m*m)
+			tooSmall = tooSmall || t.Size.X < 1 || t.Size.Y < 1
+			scaled[j] = t
+		}
+		if tooSmall {
+			lo = mid
+			continue
+		}
+		arr, err := layout.Arrange(scaled, opts)
+		if err == nil && arr.Size.X <= limit && arr.Size.Y+extraHeight <= limit {
+			best, scale, ok = arr, mid, true
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return best, scale, ok
+}
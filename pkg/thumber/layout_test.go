@@ -0,0 +1,73 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeSheet(t *testing.T) {
+	var tiles []Thumbnail
+	for i := 0; i < 5; i++ {
+		tiles = append(tiles, Thumbnail{Image: imaging.New(100, 50, color.White), Timestamp: time.Duration(i) * time.Minute})
+	}
+
+	sheet, err := ComposeSheet(tiles, LayoutOptions{Columns: 2, Padding: 10, OverlayTimestamps: true})
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 2*100+3*10, 3*50+4*10), sheet.Bounds())
+	require.Len(t, sheet.Tiles, 5)
+	assert.Equal(t, image.Rect(120, 70, 220, 120), sheet.Tiles[3].Rect)
+	assert.Equal(t, 3*time.Minute, sheet.Tiles[3].Timestamp)
+	assert.Same(t, tiles[0].Image, sheet.Tiles[0].Frame.Image, "placements keep the frame before overlays")
+
+	_, err = ComposeSheet(tiles, LayoutOptions{Columns: 2, MaxCanvasDimension: 100, OnOversize: OversizeScale})
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), tiles[0].Bounds(), "tiles of the caller aren't scaled")
+}
+
+func TestComposeSheetOversize(t *testing.T) {
+	var tiles []Thumbnail
+	for i := 0; i < 8; i++ {
+		tiles = append(tiles, Thumbnail{Image: imaging.New(400, 225, color.White), Timestamp: time.Duration(i) * time.Minute})
+	}
+	layout := LayoutOptions{Columns: 4, Padding: 10, MaxCanvasDimension: 1000}
+
+	_, err := ComposeSheet(tiles, layout)
+	assert.ErrorContains(t, err, "exceeding the limit of 1000 px")
+
+	layout.OnOversize = OversizeScale
+	sheet, err := ComposeSheet(tiles, layout)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, sheet.Bounds().Dx(), 1000)
+	assert.LessOrEqual(t, sheet.Bounds().Dy(), 1000)
+	for _, tile := range sheet.Tiles {
+		assert.Less(t, tile.Rect.Dx(), 400)
+		assert.InDelta(t, 400.0/225, float64(tile.Rect.Dx())/float64(tile.Rect.Dy()), 0.02, "aspect ratio is kept")
+		assert.True(t, tile.Rect.In(sheet.Bounds()))
+	}
+}
+
+func TestComposeSheetInvalid(t *testing.T) {
+	tile := Thumbnail{Image: imaging.New(100, 50, color.White)}
+	tests := []struct {
+		name   string
+		tiles  []Thumbnail
+		layout LayoutOptions
+	}{
+		{name: "no tiles", layout: LayoutOptions{Columns: 1}},
+		{name: "no columns", tiles: []Thumbnail{tile}},
+		{name: "negative padding", tiles: []Thumbnail{tile}, layout: LayoutOptions{Columns: 1, Padding: -1}},
+		{name: "missing image", tiles: []Thumbnail{tile, {}}, layout: LayoutOptions{Columns: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ComposeSheet(tt.tiles, tt.layout)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package thumber
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// LayoutTile is what a Layout knows about a tile
+type LayoutTile struct {
+	// Size is the size of the frame
+	Size image.Point
+	// CaptionHeight is the height of the strip under the frame reserved for its caption, which is never scaled
+	CaptionHeight int
+	Timestamp     time.Duration
+}
+
+// cell is the size of the frame along with its caption strip
+func (t LayoutTile) cell() image.Point {
+	return image.Pt(t.Size.X, t.Size.Y+t.CaptionHeight)
+}
+
+// Arrangement is where a Layout puts the tiles on the sheet
+type Arrangement struct {
+	Size image.Point
+	// Cells are the rectangles of the tiles in order, including their caption strips.
+	// Frames are scaled to fit their cell if they differ in size.
+	Cells []image.Rectangle
+	// Labels are headings drawn on the sheet, e.g. chapter titles
+	Labels []Label
+}
+
+// Label is a line of text drawn left aligned and vertically centered in Rect
+type Label struct {
+	Rect image.Rectangle
+	Text string
+}
+
+// Layout decides the size of the sheet and where each tile goes.
+// Frames are drawn, decorated and scaled by the compositor, so new sheet styles only need to implement Arrange.
+type Layout interface {
+	Arrange(tiles []LayoutTile, opts LayoutOptions) (Arrangement, error)
+}
+
+// GridLayout puts tiles of the size of the first one in rows of LayoutOptions.Columns tiles. It's the default layout.
+type GridLayout struct{}
+
+func (GridLayout) Arrange(tiles []LayoutTile, opts LayoutOptions) (Arrangement, error) {
+	if opts.Columns < 1 {
+		return Arrangement{}, fmt.Errorf("tile columns must be positive")
+	}
+	cells, size := gridCells(len(tiles), opts.Columns, tiles[0].cell(), opts.Padding, 0)
+	return Arrangement{Size: size, Cells: cells}, nil
+}
+
+// gridCells lays out n cells in rows of columns starting at top, and returns them along with the size they cover
+func gridCells(n, columns int, cell image.Point, padding, top int) ([]image.Rectangle, image.Point) {
+	rows := (n + columns - 1) / columns
+	cells := make([]image.Rectangle, 0, n)
+	for i := 0; i < n; i++ {
+		row, col := i/columns, i%columns
+		x := padding + col*(cell.X+padding)
+		y := top + padding + row*(cell.Y+padding)
+		cells = append(cells, image.Rect(x, y, x+cell.X, y+cell.Y))
+	}
+	size := image.Pt(
+		cell.X*columns+(columns+1)*padding,
+		top+cell.Y*rows+(rows+1)*padding,
+	)
+	return cells, size
+}
+
+// StripLayout puts all tiles in a single row, like a film strip. Columns are ignored.
+type StripLayout struct{}
+
+func (StripLayout) Arrange(tiles []LayoutTile, opts LayoutOptions) (Arrangement, error) {
+	cells, size := gridCells(len(tiles), len(tiles), tiles[0].cell(), opts.Padding, 0)
+	return Arrangement{Size: size, Cells: cells}, nil
+}
+
+// MasonryLayout keeps the aspect ratio of every tile in LayoutOptions.Columns columns as wide as the first tile,
+// putting each tile in the shortest column. It suits tiles of mixed orientation, e.g. from several sources.
+type MasonryLayout struct{}
+
+func (MasonryLayout) Arrange(tiles []LayoutTile, opts LayoutOptions) (Arrangement, error) {
+	if opts.Columns < 1 {
+		return Arrangement{}, fmt.Errorf("tile columns must be positive")
+	}
+	width := tiles[0].Size.X
+	heights := make([]int, opts.Columns)
+	for i := range heights {
+		heights[i] = opts.Padding
+	}
+	cells := make([]image.Rectangle, 0, len(tiles))
+	for _, t := range tiles {
+		if t.Size.X < 1 || t.Size.Y < 1 {
+			return Arrangement{}, fmt.Errorf("tile size must be positive")
+		}
+		col := 0
+		for i, h := range heights {
+			if h < heights[col] {
+				col = i
+			}
+		}
+		h := int(math.Round(float64(t.Size.Y)*float64(width)/float64(t.Size.X))) + t.CaptionHeight
+		x := opts.Padding + col*(width+opts.Padding)
+		cells = append(cells, image.Rect(x, heights[col], x+width, heights[col]+h))
+		heights[col] += h + opts.Padding
+	}
+	height := 0
+	for _, h := range heights {
+		if h > height {
+			height = h
+		}
+	}
+	return Arrangement{
+		Size:  image.Pt(width*opts.Columns+(opts.Columns+1)*opts.Padding, height),
+		Cells: cells,
+	}, nil
+}
+
+// Chapter is a titled section of a video starting at Start
+type Chapter struct {
+	Start time.Duration
+	Title string
+}
+
+// chapterLabelHeight is the height of the title band above each chapter in px
+const chapterLabelHeight = 28
+
+// ChapterLayout starts a new grid under a title band for each chapter, grouping tiles by the chapter they're in.
+// Tiles before the first chapter are put in an untitled group.
+type ChapterLayout struct {
+	Chapters []Chapter
+}
+
+func (l ChapterLayout) Arrange(tiles []LayoutTile, opts LayoutOptions) (Arrangement, error) {
+	if opts.Columns < 1 {
+		return Arrangement{}, fmt.Errorf("tile columns must be positive")
+	}
+	chapters := slices.Clone(l.Chapters)
+	slices.SortStableFunc(chapters, func(a, b Chapter) bool {
+		return a.Start < b.Start
+	})
+	chapterOf := func(t time.Duration) int {
+		i := len(chapters) - 1
+		for i >= 0 && chapters[i].Start > t {
+			i--
+		}
+		return i
+	}
+
+	cell := tiles[0].cell()
+	var arr Arrangement
+	top := 0
+	for start := 0; start < len(tiles); {
+		chapter := chapterOf(tiles[start].Timestamp)
+		end := start + 1
+		for end < len(tiles) && chapterOf(tiles[end].Timestamp) == chapter {
+			end++
+		}
+		if chapter >= 0 {
+			title := chapters[chapter].Title
+			if title == "" {
+				title = opts.Locale.chapterTitle(chapter + 1)
+			}
+			arr.Labels = append(arr.Labels, Label{
+				Rect: image.Rect(opts.Padding, top+opts.Padding, opts.Padding+opts.Columns*cell.X+(opts.Columns-1)*opts.Padding, top+opts.Padding+chapterLabelHeight),
+				Text: title,
+			})
+			top += opts.Padding + chapterLabelHeight
+		}
+		cells, size := gridCells(end-start, opts.Columns, cell, opts.Padding, top)
+		arr.Cells = append(arr.Cells, cells...)
+		// the padding below the group is the padding above the next one
+		top = size.Y - opts.Padding
+		arr.Size = image.Pt(size.X, size.Y)
+		start = end
+	}
+	return arr, nil
+}
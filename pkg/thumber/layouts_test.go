@@ -0,0 +1,103 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func layoutTiles(size image.Point, timestamps ...time.Duration) []LayoutTile {
+	var tiles []LayoutTile
+	for _, t := range timestamps {
+		tiles = append(tiles, LayoutTile{Size: size, Timestamp: t})
+	}
+	return tiles
+}
+
+func TestGridLayout(t *testing.T) {
+	tiles := layoutTiles(image.Pt(100, 50), 0, time.Second, 2*time.Second)
+	tiles[0].CaptionHeight = 10
+
+	arr, err := GridLayout{}.Arrange(tiles, LayoutOptions{Columns: 2, Padding: 5})
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(215, 135), arr.Size)
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(5, 5, 105, 65),
+		image.Rect(110, 5, 210, 65),
+		image.Rect(5, 70, 105, 130),
+	}, arr.Cells)
+
+	_, err = GridLayout{}.Arrange(tiles, LayoutOptions{})
+	assert.Error(t, err)
+}
+
+func TestStripLayout(t *testing.T) {
+	arr, err := StripLayout{}.Arrange(layoutTiles(image.Pt(100, 50), 0, time.Second, 2*time.Second), LayoutOptions{Columns: 2})
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(300, 50), arr.Size)
+	assert.Equal(t, image.Rect(200, 0, 300, 50), arr.Cells[2])
+}
+
+func TestMasonryLayout(t *testing.T) {
+	tiles := []LayoutTile{
+		{Size: image.Pt(100, 50)},
+		{Size: image.Pt(50, 100)},
+		{Size: image.Pt(100, 100)},
+	}
+	arr, err := MasonryLayout{}.Arrange(tiles, LayoutOptions{Columns: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(0, 0, 100, 50),
+		image.Rect(100, 0, 200, 200),
+		image.Rect(0, 50, 100, 150),
+	}, arr.Cells)
+	assert.Equal(t, image.Pt(200, 200), arr.Size)
+}
+
+func TestChapterLayout(t *testing.T) {
+	layout := ChapterLayout{Chapters: []Chapter{
+		{Start: 20 * time.Second},
+		{Start: 10 * time.Second, Title: "Intro"},
+	}}
+	tiles := layoutTiles(image.Pt(100, 50), 0, 10*time.Second, 15*time.Second, 17*time.Second, 25*time.Second)
+
+	arr, err := layout.Arrange(tiles, LayoutOptions{Columns: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []Label{
+		{Rect: image.Rect(0, 50, 200, 50+chapterLabelHeight), Text: "Intro"},
+		{Rect: image.Rect(0, 150+chapterLabelHeight, 200, 150+2*chapterLabelHeight), Text: "Chapter 2"},
+	}, arr.Labels)
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(0, 0, 100, 50),
+		image.Rect(0, 50+chapterLabelHeight, 100, 100+chapterLabelHeight),
+		image.Rect(100, 50+chapterLabelHeight, 200, 100+chapterLabelHeight),
+		image.Rect(0, 100+chapterLabelHeight, 100, 150+chapterLabelHeight),
+		image.Rect(0, 150+2*chapterLabelHeight, 100, 200+2*chapterLabelHeight),
+	}, arr.Cells)
+	assert.Equal(t, image.Pt(200, 200+2*chapterLabelHeight), arr.Size)
+}
+
+func TestComposeSheetWithLayout(t *testing.T) {
+	tiles := []Thumbnail{
+		{Image: imaging.New(100, 50, color.White)},
+		{Image: imaging.New(50, 100, color.White)},
+	}
+	sheet, err := ComposeSheet(tiles, LayoutOptions{Columns: 2, Layout: MasonryLayout{}})
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(100, 0, 200, 200), sheet.Tiles[1].Rect)
+	assert.Equal(t, image.Rect(0, 0, 100, 200), sheet.Tiles[1].Frame.Bounds(), "frames are scaled to their cell")
+}
+
+func TestParseChapters(t *testing.T) {
+	chapters, err := parseChapters([]byte(`{"chapters": [
+		{"id": 0, "start_time": "0.000000", "tags": {"title": "Opening"}},
+		{"id": 1, "start_time": "93.500000", "tags": {}}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Chapter{{Start: 0, Title: "Opening"}, {Start: 93500 * time.Millisecond}}, chapters)
+}
@@ -0,0 +1,115 @@
+package thumber
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+)
+
+// ProcessLimits constrain the ffmpeg processes spawned for extraction,
+// so thumbnailing can share a host with latency sensitive services.
+type ProcessLimits struct {
+	// Threads caps decoding, filtering and encoding threads of each ffmpeg process
+	Threads int
+	// MemoryBytes caps the address space of each ffmpeg process, only supported on Linux.
+	// The limit is set right after the process starts, so whatever ffmpeg allocates before that isn't refused,
+	// only what it allocates later. That's little more than its libraries, but it's not a hard guarantee.
+	MemoryBytes int64
+	// Nice lowers the scheduling priority of ffmpeg processes, only supported on Unix
+	Nice int
+	// MaxFramePixels caps the width times height of frames decoded by ffmpeg and of the images it outputs,
+	// so a hostile input can't exhaust memory before anything is scaled down
+	MaxFramePixels int64
+	// MaxOutputBytes caps how much ffmpeg may write for a single frame
+	MaxOutputBytes int64
+}
+
+// LimitError is returned when a frame exceeds MaxFramePixels or MaxOutputBytes
+type LimitError struct {
+	// Limit is the unit of the exceeded limit, pixels or bytes
+	Limit string
+	// Size is the size that exceeded Max. For bytes it's how much ffmpeg had written when it was stopped.
+	Size, Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("frame of %d %s exceeds the limit of %d %s", e.Size, e.Limit, e.Max, e.Limit)
+}
+
+func (l ProcessLimits) inputArgs() []string {
+	var args []string
+	if l.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(l.Threads))
+	}
+	if l.MaxFramePixels > 0 {
+		// the decoder refuses larger frames instead of allocating them
+		args = append(args, "-max_pixels", strconv.FormatInt(l.MaxFramePixels, 10))
+	}
+	return args
+}
+
+func (l ProcessLimits) outputArgs() []string {
+	if l.Threads <= 0 {
+		return nil
+	}
+	return []string{"-threads", strconv.Itoa(l.Threads), "-filter_threads", strconv.Itoa(l.Threads)}
+}
+
+// runLimited runs cmd and applies the limits as soon as it starts. Go can't set resource limits of a child
+// before it executes, so there's a short window where they don't apply yet, see ProcessLimits.MemoryBytes.
+func runLimited(cmd *exec.Cmd, limits ProcessLimits) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := applyProcessLimits(cmd.Process.Pid, limits); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+// outputBuffer collects the output of ffmpeg up to MaxOutputBytes. Writes past it fail, which closes the pipe
+// and stops ffmpeg.
+type outputBuffer struct {
+	bytes.Buffer
+	max      int64
+	exceeded int64
+}
+
+func (l ProcessLimits) outputBuffer() *outputBuffer {
+	return &outputBuffer{max: l.MaxOutputBytes}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	if size := int64(b.Len() + len(p)); b.max > 0 && size > b.max {
+		b.exceeded = size
+		return 0, b.err()
+	}
+	return b.Buffer.Write(p)
+}
+
+// err is a LimitError if the output exceeded the limit, which takes precedence over the error of the process it caused
+func (b *outputBuffer) err() error {
+	if b.exceeded == 0 {
+		return nil
+	}
+	return &LimitError{Limit: "bytes", Size: b.exceeded, Max: b.max}
+}
+
+// decodeImage decodes an image output by ffmpeg, checking its dimensions against MaxFramePixels before allocating it
+func (l ProcessLimits) decodeImage(data []byte) (image.Image, error) {
+	if l.MaxFramePixels > 0 {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > l.MaxFramePixels {
+			return nil, &LimitError{Limit: "pixels", Size: pixels, Max: l.MaxFramePixels}
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
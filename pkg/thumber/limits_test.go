@@ -0,0 +1,45 @@
+package thumber
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	b := ProcessLimits{MaxOutputBytes: 8}.outputBuffer()
+	_, err := b.Write([]byte("12345"))
+	require.NoError(t, err)
+	assert.NoError(t, b.err())
+
+	_, err = b.Write([]byte("67890"))
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, LimitError{Limit: "bytes", Size: 10, Max: 8}, *limitErr)
+	assert.Equal(t, "12345", b.String(), "writes past the limit are dropped")
+	assert.Error(t, b.err())
+}
+
+func TestDecodeImageLimit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30))))
+
+	img, err := ProcessLimits{MaxFramePixels: 1200}.decodeImage(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 40, img.Bounds().Dx())
+
+	_, err = ProcessLimits{MaxFramePixels: 1199}.decodeImage(buf.Bytes())
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, "frame of 1200 pixels exceeds the limit of 1199 pixels", err.Error())
+}
+
+func TestLimitsInputArgs(t *testing.T) {
+	assert.Empty(t, ProcessLimits{}.inputArgs())
+	assert.Equal(t, []string{"-threads", "2", "-max_pixels", "1000"}, ProcessLimits{Threads: 2, MaxFramePixels: 1000}.inputArgs())
+}
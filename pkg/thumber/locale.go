@@ -0,0 +1,109 @@
+package thumber
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale is the language of text drawn on sheets, such as the labels of the metadata header
+type Locale struct {
+	// Tag is the language code, e.g. de
+	Tag string
+	// Decimal separates the fraction of numbers, e.g. "," in German
+	Decimal    string
+	Duration   string
+	Size       string
+	Resolution string
+	Video      string
+	Bitrate    string
+	Audio      string
+	Subtitles  string
+	// Chapter titles chapters without one, with %d for the chapter number
+	Chapter string
+}
+
+var locales = map[string]Locale{
+	"en": {Tag: "en", Decimal: ".", Duration: "Duration", Size: "Size", Resolution: "Resolution", Video: "Video", Bitrate: "Bitrate", Audio: "Audio", Subtitles: "Subs", Chapter: "Chapter %d"},
+	"de": {Tag: "de", Decimal: ",", Duration: "Dauer", Size: "Größe", Resolution: "Auflösung", Video: "Video", Bitrate: "Bitrate", Audio: "Audio", Subtitles: "Untertitel", Chapter: "Kapitel %d"},
+	"es": {Tag: "es", Decimal: ",", Duration: "Duración", Size: "Tamaño", Resolution: "Resolución", Video: "Vídeo", Bitrate: "Tasa de bits", Audio: "Audio", Subtitles: "Subtítulos", Chapter: "Capítulo %d"},
+	"fr": {Tag: "fr", Decimal: ",", Duration: "Durée", Size: "Taille", Resolution: "Résolution", Video: "Vidéo", Bitrate: "Débit", Audio: "Audio", Subtitles: "Sous-titres", Chapter: "Chapitre %d"},
+	"it": {Tag: "it", Decimal: ",", Duration: "Durata", Size: "Dimensione", Resolution: "Risoluzione", Video: "Video", Bitrate: "Bitrate", Audio: "Audio", Subtitles: "Sottotitoli", Chapter: "Capitolo %d"},
+	"nl": {Tag: "nl", Decimal: ",", Duration: "Duur", Size: "Grootte", Resolution: "Resolutie", Video: "Video", Bitrate: "Bitrate", Audio: "Audio", Subtitles: "Ondertitels", Chapter: "Hoofdstuk %d"},
+	"pt": {Tag: "pt", Decimal: ",", Duration: "Duração", Size: "Tamanho", Resolution: "Resolução", Video: "Vídeo", Bitrate: "Taxa de bits", Audio: "Áudio", Subtitles: "Legendas", Chapter: "Capítulo %d"},
+	"tr": {Tag: "tr", Decimal: ",", Duration: "Süre", Size: "Boyut", Resolution: "Çözünürlük", Video: "Video", Bitrate: "Bit hızı", Audio: "Ses", Subtitles: "Altyazı", Chapter: "Bölüm %d"},
+}
+
+// ParseLocale picks the locale of a language tag like de, de-DE or de_DE.UTF-8.
+// An empty tag is English.
+func ParseLocale(tag string) (Locale, error) {
+	lang := strings.ToLower(tag)
+	if i := strings.IndexAny(lang, "-_."); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" || lang == "c" || lang == "posix" {
+		lang = "en"
+	}
+	l, ok := locales[lang]
+	if !ok {
+		var tags []string
+		for t := range locales {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		return Locale{}, fmt.Errorf("unsupported locale %q, must be one of %s", tag, strings.Join(tags, ", "))
+	}
+	return l, nil
+}
+
+// orDefault is the locale, or English if it's unset
+func (l Locale) orDefault() Locale {
+	if l.Tag == "" {
+		return locales["en"]
+	}
+	return l
+}
+
+// formatFloat formats f with prec decimals, or as few as needed if prec is -1, and the decimal separator of the locale
+func (l Locale) formatFloat(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	return strings.Replace(s, ".", l.orDefault().Decimal, 1)
+}
+
+// chapterTitle is the title of the nth chapter, counted from 1, for chapters without one
+func (l Locale) chapterTitle(n int) string {
+	return fmt.Sprintf(l.orDefault().Chapter, n)
+}
+
+// FormatSize formats a size in bytes in binary units with one decimal and the decimal separator of the locale,
+// e.g. 1.4 GB, like the metadata header. The zero Locale is English.
+func FormatSize(bytes int64, l Locale) string {
+	units := []string{"KB", "MB", "GB", "TB"}
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	size := float64(bytes) / 1024
+	unit := units[0]
+	for _, u := range units[1:] {
+		if size < 1024 {
+			break
+		}
+		size /= 1024
+		unit = u
+	}
+	return l.formatFloat(size, 1) + " " + unit
+}
+
+// FormatBitrate formats a bitrate in bits per second with one decimal and the decimal separator of the locale,
+// e.g. 4.5 Mb/s, like the metadata header. The zero Locale is English.
+func FormatBitrate(bps int64, l Locale) string {
+	switch {
+	case bps >= 1_000_000:
+		return l.formatFloat(float64(bps)/1_000_000, 1) + " Mb/s"
+	case bps >= 1_000:
+		return l.formatFloat(float64(bps)/1_000, 1) + " kb/s"
+	default:
+		return fmt.Sprintf("%d b/s", bps)
+	}
+}
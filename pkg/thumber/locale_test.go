@@ -0,0 +1,37 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocale(t *testing.T) {
+	for _, tag := range []string{"de", "de-DE", "de_AT.UTF-8", "DE"} {
+		l, err := ParseLocale(tag)
+		require.NoError(t, err, tag)
+		assert.Equal(t, "de", l.Tag, tag)
+	}
+	l, err := ParseLocale("")
+	require.NoError(t, err)
+	assert.Equal(t, "en", l.Tag)
+
+	_, err = ParseLocale("xx")
+	assert.ErrorContains(t, err, `unsupported locale "xx"`)
+}
+
+func TestFormatSize(t *testing.T) {
+	de, _ := ParseLocale("de")
+	assert.Equal(t, "512 B", FormatSize(512, Locale{}))
+	assert.Equal(t, "1.5 KB", FormatSize(1536, Locale{}))
+	assert.Equal(t, "1.4 GB", FormatSize(1503238554, Locale{}))
+	assert.Equal(t, "1,4 GB", FormatSize(1503238554, de))
+}
+
+func TestFormatBitrate(t *testing.T) {
+	fr, _ := ParseLocale("fr")
+	assert.Equal(t, "800 b/s", FormatBitrate(800, Locale{}))
+	assert.Equal(t, "128.0 kb/s", FormatBitrate(128_000, Locale{}))
+	assert.Equal(t, "4,5 Mb/s", FormatBitrate(4_500_000, fr))
+}
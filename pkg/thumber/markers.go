@@ -0,0 +1,35 @@
+package thumber
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/skip2/go-qrcode"
+)
+
+// markerModuleSize is the size of each QR module in px, big enough to survive typical JPEG re-encoding.
+// Markers of tiles too small for it are drawn with smaller modules.
+const markerModuleSize = 3
+
+// markerContent is the text encoded in the QR marker of a frame, e.g. thumber:pts_ms=83500
+func markerContent(t Thumbnail) string {
+	return fmt.Sprintf("thumber:pts_ms=%d", t.Timestamp.Milliseconds())
+}
+
+// overlayMarker draws a QR code encoding the presentation time of the frame in the top left corner of the tile.
+// Its quiet zone is drawn too, so it can be scanned against any background.
+func (t *Thumbnail) overlayMarker() error {
+	q, err := qrcode.New(markerContent(*t), qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	for size := markerModuleSize; size > 0; size-- {
+		marker := q.Image(-size)
+		if marker.Bounds().Dx() <= t.Image.Bounds().Dx() && marker.Bounds().Dy() <= t.Image.Bounds().Dy() {
+			t.Image = imaging.Paste(t.Image, marker, image.Pt(0, 0))
+			return nil
+		}
+	}
+	return fmt.Errorf("tile is too small for a qr code")
+}
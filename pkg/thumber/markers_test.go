@@ -0,0 +1,32 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayMarker(t *testing.T) {
+	th := Thumbnail{Image: imaging.New(320, 180, color.Black), Timestamp: 83500 * time.Millisecond}
+	assert.Equal(t, "thumber:pts_ms=83500", markerContent(th))
+
+	require.NoError(t, th.overlayMarker())
+	assert.Equal(t, image.Rect(0, 0, 320, 180), th.Bounds(), "the tile keeps its size")
+	r, g, b, _ := th.At(0, 0).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b}, "the quiet zone is drawn")
+	r, g, b, _ = th.At(319, 179).RGBA()
+	assert.Equal(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, b}, "the rest of the tile is untouched")
+
+	small := Thumbnail{Image: imaging.New(80, 70, color.Black)}
+	require.NoError(t, small.overlayMarker(), "smaller modules are used")
+	r, g, b, _ = small.At(0, 0).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+
+	tiny := Thumbnail{Image: imaging.New(20, 20, color.Black)}
+	assert.Error(t, tiny.overlayMarker())
+}
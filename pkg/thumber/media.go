@@ -0,0 +1,91 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// MediaInfo is what's probed about a video. It's probed once and shared by every stage of generation,
+// and callers that already know it can set ThumbOptions.Media to skip probing entirely.
+type MediaInfo struct {
+	Duration time.Duration
+	// Keyframes are presentation times of keyframes in order, read only when tiles are aligned or snapped to keyframes
+	Keyframes []time.Duration
+	// Size is the size of the frames, read only when pixel regions are checked against it
+	Size image.Point
+}
+
+// ProbeMedia reads the duration of the video, and its keyframes if withKeyframes is set
+func ProbeMedia(ctx context.Context, videoPath string, withKeyframes bool) (MediaInfo, error) {
+	var info MediaInfo
+	var err error
+	info.Duration, err = readDuration(ctx, videoPath)
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("failed to read video duration: %w", err)
+	}
+	if withKeyframes {
+		info.Keyframes, err = readKeyframes(ctx, videoPath)
+		if err != nil {
+			return MediaInfo{}, fmt.Errorf("failed to read keyframes: %w", err)
+		}
+	}
+	return info, nil
+}
+
+func (o ThumbOptions) needsKeyframes() bool {
+	return o.AlignKeyframes || o.SnapKeyframes
+}
+
+// needsSize reports whether regions are given in pixels, which checkRegions checks against the frame size
+func (o ThumbOptions) needsSize() bool {
+	if o.Crop != nil && !o.Crop.Relative {
+		return true
+	}
+	for _, r := range o.BlurRegions {
+		if !r.Relative {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRegions refuses blur regions and crops outside of the frame, which ffmpeg would fail on for every tile
+func (o ThumbOptions) checkRegions(frame image.Point) error {
+	for i, r := range o.BlurRegions {
+		if !r.fits(frame) {
+			return fmt.Errorf("blur region %d extends beyond the %dx%d frame", i+1, frame.X, frame.Y)
+		}
+	}
+	if o.Crop != nil && !o.Crop.fits(frame) {
+		return fmt.Errorf("crop extends beyond the %dx%d frame", frame.X, frame.Y)
+	}
+	return nil
+}
+
+// media returns the MediaInfo set in opts, probing whatever's missing
+func (o ThumbOptions) media(ctx context.Context, videoPath string) (MediaInfo, error) {
+	var info MediaInfo
+	var err error
+	if o.Media == nil {
+		if info, err = ProbeMedia(ctx, videoPath, o.needsKeyframes()); err != nil {
+			return MediaInfo{}, err
+		}
+	} else {
+		info = *o.Media
+		if o.needsKeyframes() && info.Keyframes == nil {
+			info.Keyframes, err = readKeyframes(ctx, videoPath)
+			if err != nil {
+				return MediaInfo{}, fmt.Errorf("failed to read keyframes: %w", err)
+			}
+		}
+	}
+	if o.needsSize() && info.Size == (image.Point{}) {
+		info.Size, err = readVideoSize(ctx, videoPath)
+		if err != nil {
+			return MediaInfo{}, fmt.Errorf("failed to read video size: %w", err)
+		}
+	}
+	return info, nil
+}
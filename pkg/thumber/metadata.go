@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metadataSample is a value printed by ffmpeg's metadata filter for a frame
+type metadataSample struct {
+	At    time.Duration
+	Value float64
+}
+
+var metadataPTSPattern = regexp.MustCompile(`pts_time:\s*(-?[0-9.]+)`)
+
+// parseMetadataLog reads the values of key printed by the metadata filter, each after a line with the pts of its frame.
+// Timestamps are relative to the seek point, so offset is added to them.
+func parseMetadataLog(log, key string, offset time.Duration) []metadataSample {
+	prefix := key + "="
+	var samples []metadataSample
+	at, ok := time.Duration(0), false
+	for _, line := range strings.Split(log, "\n") {
+		if m := metadataPTSPattern.FindStringSubmatch(line); m != nil {
+			pts, err := parseSeconds(m[1])
+			at, ok = offset+pts, err == nil
+			continue
+		}
+		i := strings.Index(line, prefix)
+		if i < 0 || !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(line[i+len(prefix):]), 64); err == nil {
+			samples = append(samples, metadataSample{At: at, Value: v})
+		}
+		ok = false
+	}
+	return samples
+}
@@ -0,0 +1,113 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// motionFilter measures motion in a low resolution pass as the average luma difference between consecutive frames
+const motionFilter = "fps=4,scale=64:-2,format=gray,tblend=all_mode=difference,signalstats,metadata=print:key=lavfi.signalstats.YAVG"
+
+// motionBorderWidth is the width of the border drawn around tiles for the motion heatmap
+const motionBorderWidth = 6
+
+// measureMotion sets the motion of thumbs, which must be in timestamp order,
+// from one analysis pass over the span they cover
+func measureMotion(ctx context.Context, videoPath string, thumbs []Thumbnail, opts ThumbOptions) (err error) {
+	if len(thumbs) == 0 {
+		return nil
+	}
+	ctx, stage := startStage(ctx, "motion", attribute.Int("thumber.tiles", len(thumbs)))
+	defer func() { stage.End(err) }()
+
+	from := thumbs[0].Timestamp
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args, "-ss", fmt.Sprintf("%dms", from.Milliseconds()))
+	if opts.To > from {
+		args = append(args, "-t", fmt.Sprintf("%dms", (opts.To-from).Milliseconds()))
+	}
+	args = append(args, "-i", videoPath, "-an", "-vf", motionFilter)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "null", "-")
+	cmd := command(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		return &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	scoreMotion(thumbs, parseMetadataLog(stderr.String(), "lavfi.signalstats.YAVG", from))
+	return nil
+}
+
+// scoreMotion sets the motion of each tile to the average difference over the span it covers,
+// up to the next tile, relative to the tile with the most motion
+func scoreMotion(thumbs []Thumbnail, samples []metadataSample) {
+	var most float64
+	for i := range thumbs {
+		var sum float64
+		n := 0
+		for _, s := range samples {
+			if s.At < thumbs[i].Timestamp || (i+1 < len(thumbs) && s.At >= thumbs[i+1].Timestamp) {
+				continue
+			}
+			sum += s.Value
+			n++
+		}
+		thumbs[i].Motion = 0
+		if n > 0 {
+			thumbs[i].Motion = sum / float64(n)
+		}
+		if thumbs[i].Motion > most {
+			most = thumbs[i].Motion
+		}
+	}
+	if most == 0 {
+		return
+	}
+	for i := range thumbs {
+		thumbs[i].Motion /= most
+	}
+}
+
+// motionColor goes from blue for still tiles to red for the tiles with the most motion
+func motionColor(level float64) color.NRGBA {
+	if level < 0 {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+	return color.NRGBA{
+		R: uint8(255 * level),
+		G: uint8(64 * (1 - level)),
+		B: uint8(255 * (1 - level)),
+		A: 255,
+	}
+}
+
+// overlayMotion draws a border in the color of the motion level of the tile
+func (t *Thumbnail) overlayMotion() {
+	b := t.Image.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), t.Image, b.Min, draw.Src)
+
+	border := &image.Uniform{C: motionColor(t.Motion)}
+	w := motionBorderWidth
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, b.Dx(), w),
+		image.Rect(0, b.Dy()-w, b.Dx(), b.Dy()),
+		image.Rect(0, 0, w, b.Dy()),
+		image.Rect(b.Dx()-w, 0, b.Dx(), b.Dy()),
+	} {
+		draw.Draw(img, r.Intersect(img.Bounds()), border, image.Point{}, draw.Src)
+	}
+	t.Image = img
+}
@@ -0,0 +1,45 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadataLog(t *testing.T) {
+	log := `[Parsed_metadata_5 @ 0x55] frame:0    pts:0       pts_time:0
+[Parsed_metadata_5 @ 0x55] lavfi.signalstats.YAVG=1.5
+[Parsed_metadata_5 @ 0x55] frame:1    pts:1       pts_time:0.25
+[Parsed_metadata_5 @ 0x55] lavfi.signalstats.YAVG=12
+frame=    2 fps=0.0 q=-0.0 size=N/A time=00:00:00.50 bitrate=N/A speed=  10x`
+
+	samples := parseMetadataLog(log, "lavfi.signalstats.YAVG", 10*time.Second)
+	require.Len(t, samples, 2)
+	assert.Equal(t, metadataSample{At: 10 * time.Second, Value: 1.5}, samples[0])
+	assert.Equal(t, metadataSample{At: 10*time.Second + 250*time.Millisecond, Value: 12}, samples[1])
+}
+
+func TestScoreMotion(t *testing.T) {
+	thumbs := []Thumbnail{{Timestamp: 0}, {Timestamp: 10 * time.Second}, {Timestamp: 20 * time.Second}}
+	samples := []metadataSample{
+		{At: time.Second, Value: 2},
+		{At: 5 * time.Second, Value: 4},
+		{At: 12 * time.Second, Value: 12},
+	}
+	scoreMotion(thumbs, samples)
+	assert.InDelta(t, 0.25, thumbs[0].Motion, 1e-9)
+	assert.InDelta(t, 1, thumbs[1].Motion, 1e-9)
+	assert.Zero(t, thumbs[2].Motion, "no samples after the last tile")
+}
+
+func TestOverlayMotion(t *testing.T) {
+	th := Thumbnail{Image: image.NewRGBA(image.Rect(0, 0, 40, 30)), Motion: 1}
+	th.overlayMotion()
+	assert.Equal(t, color.NRGBA{R: 255, A: 255}, th.Image.At(0, 0))
+	assert.Equal(t, color.NRGBA{R: 255, A: 255}, th.Image.At(39, 29))
+	assert.Equal(t, color.NRGBA{}, th.Image.At(20, 15), "inside is untouched")
+}
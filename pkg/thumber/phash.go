@@ -0,0 +1,105 @@
+package thumber
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// PerceptualHash is a 64 bit DCT hash of an image. Similar images have hashes a small Hamming distance apart,
+// even after scaling or re-encoding, so it can be used to find duplicate videos.
+func PerceptualHash(img image.Image) uint64 {
+	const size, low = 32, 8
+	gray := imaging.Grayscale(imaging.Resize(img, size, size, imaging.Box))
+	pixels := make([][]float64, size)
+	for y := range pixels {
+		pixels[y] = make([]float64, size)
+		for x := range pixels[y] {
+			pixels[y][x] = float64(gray.Pix[y*gray.Stride+x*4])
+		}
+	}
+	dct := dct2D(pixels)
+
+	// the lowest frequencies carry the structure of the image, except the first which is its average brightness.
+	// It's left out, and the next horizontal frequency fills its bit.
+	coeffs := make([]float64, 0, low*low)
+	for y := 0; y < low; y++ {
+		for x := 0; x < low; x++ {
+			if x == 0 && y == 0 {
+				continue
+			}
+			coeffs = append(coeffs, dct[y][x])
+		}
+	}
+	coeffs = append(coeffs, dct[0][low])
+	sorted := append([]float64(nil), coeffs...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+// HashDistance is the number of bits that differ between two perceptual hashes, from 0 for the same image to 64
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FeatureVector is the image scaled down to size x size in grayscale, row by row, with values from 0 to 1.
+// It's a crude embedding for visual search.
+func FeatureVector(img image.Image, size int) []float64 {
+	gray := imaging.Grayscale(imaging.Resize(img, size, size, imaging.Box))
+	v := make([]float64, 0, size*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v = append(v, math.Round(float64(gray.Pix[y*gray.Stride+x*4])/255*1000)/1000)
+		}
+	}
+	return v
+}
+
+// dct2D is the type II discrete cosine transform of a square matrix
+func dct2D(m [][]float64) [][]float64 {
+	n := len(m)
+	cos := make([][]float64, n)
+	for k := range cos {
+		cos[k] = make([]float64, n)
+		for i := range cos[k] {
+			cos[k][i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+	transform := func(in [][]float64) [][]float64 {
+		out := make([][]float64, n)
+		for y := range in {
+			out[y] = make([]float64, n)
+			for k := 0; k < n; k++ {
+				var sum float64
+				for i, v := range in[y] {
+					sum += v * cos[k][i]
+				}
+				out[y][k] = sum
+			}
+		}
+		return out
+	}
+	transpose := func(in [][]float64) [][]float64 {
+		out := make([][]float64, n)
+		for y := range out {
+			out[y] = make([]float64, n)
+			for x := range out[y] {
+				out[y][x] = in[x][y]
+			}
+		}
+		return out
+	}
+	// rows, then columns
+	return transpose(transform(transpose(transform(m))))
+}
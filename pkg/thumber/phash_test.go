@@ -0,0 +1,52 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// pattern is a test image with structure at several scales, like a frame rather than a flat gradient
+func pattern(w, h int, flip bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			if flip {
+				fx = 1 - fx
+			}
+			v := 128 + 60*math.Sin(fx*7) + 50*math.Cos(fy*5+fx*3)
+			if fx > 0.6 && fy < 0.4 {
+				v = 250
+			}
+			img.Set(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	a := PerceptualHash(pattern(320, 180, false))
+	scaled := PerceptualHash(imaging.Resize(pattern(320, 180, false), 160, 90, imaging.Lanczos))
+	other := PerceptualHash(pattern(320, 180, true))
+
+	assert.LessOrEqual(t, HashDistance(a, scaled), 4, "scaling keeps the hash close")
+	assert.Greater(t, HashDistance(a, other), 16, "different images are far apart")
+
+	dim := PerceptualHash(imaging.AdjustBrightness(pattern(320, 180, false), -10))
+	assert.LessOrEqual(t, HashDistance(a, dim), 2, "the average brightness isn't hashed")
+	assert.Zero(t, a>>63, "the first bit is the first horizontal frequency, not the average brightness, which always set it")
+}
+
+func TestFeatureVector(t *testing.T) {
+	v := FeatureVector(pattern(320, 180, false), 4)
+	assert.Len(t, v, 16)
+	for _, x := range v {
+		assert.True(t, x >= 0 && x <= 1)
+	}
+	assert.Less(t, v[4], v[7], "brightness increases to the right")
+}
@@ -0,0 +1,100 @@
+package thumber
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
+)
+
+// placeholderBackground is the grey of placeholder images
+var placeholderBackground = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+// placeholderMargin is the space around the text of placeholder images in px
+const placeholderMargin = 24
+
+// Placeholder renders a grey image of the given size that says the file is unavailable, with its name and the error,
+// so galleries have something to show for files that failed instead of a broken image.
+// Text that doesn't fit is cut short.
+func Placeholder(width, height int, name, message string) (image.Image, error) {
+	if width <= 2*placeholderMargin || height <= 2*placeholderMargin {
+		return nil, fmt.Errorf("placeholder size %dx%d is too small", width, height)
+	}
+	title := defaultRenderer{Font: fonts.RobotoMonoMedium, FontSizePt: 24, BackgroundColor: color.Transparent, ForegroundColor: color.White}
+	body := defaultRenderer{Font: fonts.RobotoMonoMedium, FontSizePt: 12, BackgroundColor: color.Transparent, ForegroundColor: color.White}
+
+	textWidth := width - 2*placeholderMargin
+	type line struct {
+		r    timestampRenderer
+		text string
+	}
+	lines := []line{{title, "Unavailable"}, {body, name}, {body, ""}}
+	wrapped, err := wrapText(body, message, textWidth)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range wrapped {
+		lines = append(lines, line{body, l})
+	}
+
+	canvas := imaging.New(width, height, placeholderBackground)
+	y := placeholderMargin
+	for _, l := range lines {
+		img, err := renderCaption(l.r, l.text, textWidth+2*captionPadding)
+		if err != nil {
+			return nil, err
+		}
+		if y+img.Bounds().Dy() > height-placeholderMargin {
+			break
+		}
+		canvas = imaging.Overlay(canvas, img, image.Pt(placeholderMargin, y), 1)
+		y += img.Bounds().Dy()
+	}
+	return canvas, nil
+}
+
+// wrapText splits text into lines of whole words that fit into width when rendered, and words longer
+// than a line, like paths and URLs, across lines. The font is monospaced, so the width of a line follows from its length.
+func wrapText(r timestampRenderer, text string, width int) ([]string, error) {
+	sample, err := r.Render("0000000000")
+	if err != nil {
+		return nil, err
+	}
+	perLine := width * 10 / sample.Bounds().Dx()
+	if perLine < 1 {
+		perLine = 1
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var current string
+		for _, word := range strings.Fields(paragraph) {
+			for runes := []rune(word); len(runes) > perLine; runes = runes[perLine:] {
+				if current != "" {
+					lines = append(lines, current)
+					current = ""
+				}
+				lines = append(lines, string(runes[:perLine]))
+				word = string(runes[perLine:])
+			}
+			if word == "" {
+				continue
+			}
+			switch {
+			case current == "":
+				current = word
+			case len([]rune(current))+1+len([]rune(word)) <= perLine:
+				current += " " + word
+			default:
+				lines = append(lines, current)
+				current = word
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines, nil
+}
@@ -0,0 +1,35 @@
+package thumber
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceholder(t *testing.T) {
+	img, err := Placeholder(480, 270, "broken.mp4", "failed to read video duration: moov atom not found")
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 480, 270), img.Bounds())
+	r, g, b, _ := img.At(479, 269).RGBA()
+	assert.Equal(t, []uint32{0x8080, 0x8080, 0x8080}, []uint32{r, g, b}, "background is grey")
+
+	_, err = Placeholder(40, 40, "broken.mp4", "error")
+	assert.Error(t, err)
+}
+
+func TestWrapText(t *testing.T) {
+	r := LayoutOptions{}.captionRenderer()
+	sample, err := r.Render("0000000000")
+	require.NoError(t, err)
+	width := sample.Bounds().Dx() * 12 / 10
+
+	lines, err := wrapText(r, "failed to run ffmpeg: exit status 1\nmoov atom not found", width)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"failed to", "run ffmpeg:", "exit status", "1", "moov atom", "not found"}, lines)
+
+	lines, err = wrapText(r, "open /videos/2024/holiday.mp4: no such file", width)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"open", "/videos/202", "4/holiday.m", "p4: no such", "file"}, lines)
+}
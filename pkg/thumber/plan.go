@@ -0,0 +1,126 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+)
+
+// PlanSheets works out the geometry of the sheets GenerateSheets would make, probing the video but extracting no frames
+func PlanSheets(ctx context.Context, videoPath string, opts ThumbOptions) ([]SheetPlan, error) {
+	opts = opts.withDefaults()
+	timestamps, err := PlanTimestamps(ctx, videoPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	source, err := readVideoSize(ctx, videoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read video size: %w", err)
+	}
+	size := opts.tileSize(source)
+
+	layout := opts.LayoutOptions()
+	if opts.AudioTimeline {
+		// only the size of the strip matters
+		layout.Audio = &AudioProfile{}
+	}
+	if opts.Header {
+		// the header always has the same number of lines
+		layout.Header = HeaderInfo{}.Lines(opts.Locale)
+	}
+	var plans []SheetPlan
+	for _, p := range opts.paginate(timestamps) {
+		var tiles []LayoutTile
+		for _, t := range timestamps[p.start:p.end] {
+			tiles = append(tiles, LayoutTile{Size: size, Timestamp: t})
+		}
+		plan, err := layout.Plan(tiles)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %d: %w", len(plans)+1, err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// tileSize is the size of the frames ffmpeg extracts from a video of the given size with these options
+func (o ThumbOptions) tileSize(source image.Point) image.Point {
+	frame := source
+	if o.Crop != nil {
+		frame = o.Crop.size(source)
+	}
+
+	// same as the scale filter, where ffmpeg rounds derived sizes to the nearest multiple and boxed sizes down
+	m := o.dimensionMultiple()
+	derived := func(n, num, den int) int {
+		return int(math.Round(float64(n)*float64(num)/float64(den)/float64(m))) * m
+	}
+	var size image.Point
+	switch {
+	case o.TileWidth > 0 && o.TileHeight > 0:
+		size = image.Pt(o.TileWidth, o.TileHeight)
+	case o.TileWidth > 0:
+		size = image.Pt(o.TileWidth, derived(o.TileWidth, frame.Y, frame.X))
+	case o.TileHeight > 0:
+		size = image.Pt(derived(o.TileHeight, frame.X, frame.Y), o.TileHeight)
+	case o.MaxTileDimension > 0:
+		// same as ffmpeg's force_original_aspect_ratio=decrease into a box of the max dimension
+		boxW, boxH := frame.X, frame.Y
+		if boxW > o.MaxTileDimension {
+			boxW = o.MaxTileDimension
+		}
+		if boxH > o.MaxTileDimension {
+			boxH = o.MaxTileDimension
+		}
+		size = image.Pt(int(math.Round(float64(boxH)*float64(frame.X)/float64(frame.Y))), int(math.Round(float64(boxW)*float64(frame.Y)/float64(frame.X))))
+		if size.X > boxW {
+			size.X = boxW
+		}
+		if size.Y > boxH {
+			size.Y = boxH
+		}
+		size = image.Pt(size.X/m*m, size.Y/m*m)
+	default:
+		size = image.Pt(frame.X/m*m, frame.Y/m*m)
+	}
+	if o.DetailRow {
+		size.Y += o.detailHeight()
+	}
+	return size
+}
+
+// size is the size of the region in a frame of the given size
+func (r Region) size(frame image.Point) image.Point {
+	if r.Relative {
+		return image.Pt(int(r.W*float64(frame.X)), int(r.H*float64(frame.Y)))
+	}
+	return image.Pt(int(r.W), int(r.H))
+}
+
+func readVideoSize(ctx context.Context, videoPath string) (image.Point, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		videoPath,
+	)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return parseVideoSize(out)
+}
+
+func parseVideoSize(out []byte) (image.Point, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return image.Point{}, err
+	}
+	stream, err := p.firstStream()
+	if err != nil {
+		return image.Point{}, err
+	}
+	if stream.Width < 1 || stream.Height < 1 {
+		return image.Point{}, fmt.Errorf("unexpected video size %dx%d", stream.Width, stream.Height)
+	}
+	return image.Pt(stream.Width, stream.Height), nil
+}
@@ -0,0 +1,115 @@
+package thumber
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTileSize(t *testing.T) {
+	source := image.Pt(1920, 1080)
+	tests := []struct {
+		name string
+		opts ThumbOptions
+		want image.Point
+	}{
+		{name: "width", opts: ThumbOptions{TileWidth: 540}, want: image.Pt(540, 304)},
+		{name: "height", opts: ThumbOptions{TileHeight: 270}, want: image.Pt(480, 270)},
+		{name: "both", opts: ThumbOptions{TileWidth: 300, TileHeight: 300, Fit: FitContain}, want: image.Pt(300, 300)},
+		{name: "source", opts: ThumbOptions{}, want: source},
+		{name: "max dimension", opts: ThumbOptions{MaxTileDimension: 640}, want: image.Pt(640, 360)},
+		{name: "max dimension above source", opts: ThumbOptions{MaxTileDimension: 4000}, want: source},
+		{name: "crop", opts: ThumbOptions{TileWidth: 400, Crop: &Region{W: 0.5, H: 1, Relative: true}}, want: image.Pt(400, 450)},
+		{name: "detail row", opts: ThumbOptions{TileWidth: 540, DetailRow: true}, want: image.Pt(540, 304+270)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.tileSize(source))
+		})
+	}
+}
+
+func TestParseVideoSize(t *testing.T) {
+	size, err := parseVideoSize([]byte(`{"programs": [], "streams": [{"width": 1920, "height": 1080}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(1920, 1080), size)
+
+	_, err = parseVideoSize([]byte(`{"streams": []}`))
+	assert.ErrorContains(t, err, "no video stream")
+
+	_, err = parseVideoSize([]byte(""))
+	assert.Error(t, err)
+}
+
+func TestTileSizeMultiple(t *testing.T) {
+	source := image.Pt(1920, 1080)
+	tests := []struct {
+		name string
+		opts ThumbOptions
+		want image.Point
+	}{
+		{name: "width", opts: ThumbOptions{TileWidth: 500, DimensionMultiple: 2}, want: image.Pt(500, 282)},
+		{name: "height", opts: ThumbOptions{TileHeight: 275, DimensionMultiple: 2}, want: image.Pt(490, 276)},
+		{name: "multiple of 16", opts: ThumbOptions{TileWidth: 500, DimensionMultiple: 16}, want: image.Pt(496, 272)},
+		{name: "max dimension", opts: ThumbOptions{MaxTileDimension: 501, DimensionMultiple: 2}, want: image.Pt(500, 282)},
+		{name: "source", opts: ThumbOptions{MaxTileDimension: 4000, DimensionMultiple: 7}, want: image.Pt(1918, 1078)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.withDefaults().tileSize(source))
+		})
+	}
+}
+
+func TestLayoutPlanMultiple(t *testing.T) {
+	tiles := []LayoutTile{{Size: image.Pt(101, 51)}, {Size: image.Pt(101, 51)}}
+
+	plan, err := LayoutOptions{Columns: 2, Padding: 3, DimensionMultiple: 2}.Plan(tiles)
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(212, 58), plan.Size)
+
+	plan, err = LayoutOptions{Columns: 1, MaxCanvasDimension: 81, OnOversize: OversizeScale, DimensionMultiple: 4}.Plan(tiles)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, plan.Size.Y, 80)
+	for _, r := range plan.Tiles {
+		assert.Zero(t, r.Dx()%4)
+		assert.Zero(t, r.Dy()%4)
+	}
+}
+
+func TestTileSizePortrait(t *testing.T) {
+	source := image.Pt(1080, 1920)
+	assert.Equal(t, image.Pt(360, 640), ThumbOptions{MaxTileDimension: 640}.tileSize(source))
+	assert.Equal(t, image.Pt(304, 540), ThumbOptions{TileHeight: 540}.tileSize(source))
+}
+
+func TestLayoutPlan(t *testing.T) {
+	tiles := make([]LayoutTile, 5)
+	for i := range tiles {
+		tiles[i] = LayoutTile{Size: image.Pt(100, 50), Timestamp: time.Duration(i) * time.Minute}
+	}
+
+	plan, err := LayoutOptions{Columns: 2, Padding: 10}.Plan(tiles)
+	require.NoError(t, err)
+	assert.Equal(t, 3, plan.Rows)
+	assert.Equal(t, 2, plan.Columns)
+	assert.Equal(t, image.Pt(230, 190), plan.Size)
+	assert.Equal(t, image.Rect(120, 70, 220, 120), plan.Tiles[3])
+	assert.Equal(t, 1.0, plan.Scale)
+
+	_, err = LayoutOptions{Columns: 2, MaxCanvasDimension: 150}.Plan(tiles)
+	assert.ErrorContains(t, err, "exceeding the limit of 150 px")
+
+	plan, err = LayoutOptions{Columns: 2, MaxCanvasDimension: 150, OnOversize: OversizeScale}.Plan(tiles)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, plan.Size.Y, 150)
+	assert.Less(t, plan.Scale, 1.0)
+
+	plan, err = LayoutOptions{Columns: 2, Captions: true}.Plan(tiles)
+	require.NoError(t, err)
+	assert.Positive(t, plan.CaptionHeight)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), plan.Tiles[0], "tiles exclude caption strips")
+}
@@ -0,0 +1,50 @@
+package thumber
+
+import "fmt"
+
+// Preset is a named combination of tile count, columns and tile width.
+// The tile count follows the duration of the video, up to the tiles of the shipped template of the same name,
+// whose columns and tile width it takes.
+type Preset string
+
+const (
+	PresetQuick    Preset = "quick"
+	PresetStandard Preset = "standard"
+	PresetDense    Preset = "dense"
+)
+
+// presetMinTiles are the fewest tiles of each preset, for short videos
+var presetMinTiles = map[Preset]int{
+	PresetQuick:    3,
+	PresetStandard: 8,
+	PresetDense:    24,
+}
+
+func ParsePreset(s string) (Preset, error) {
+	switch Preset(s) {
+	case PresetQuick, PresetStandard, PresetDense:
+		return Preset(s), nil
+	}
+	return "", fmt.Errorf("invalid preset %q, must be one of quick, standard, dense", s)
+}
+
+// Apply fills in the columns and tile width unless they're already set, and bounds the tile count picked from
+// the duration unless the tiles are given some other way
+func (p Preset) Apply(opts ThumbOptions) (ThumbOptions, error) {
+	if _, err := ParsePreset(string(p)); err != nil {
+		return opts, err
+	}
+	t, err := LoadTemplate(string(p))
+	if err != nil {
+		return opts, fmt.Errorf("failed to load template of preset %s: %w", p, err)
+	}
+	auto := opts.TileCount == 0 && opts.Interval == 0 && len(opts.AtFrames) == 0
+	opts = t.applyGrid(opts)
+	if auto {
+		opts.TileCount = 0
+		if opts.MinTiles == 0 && opts.MaxTiles == 0 {
+			opts.MinTiles, opts.MaxTiles = presetMinTiles[p], t.Tiles
+		}
+	}
+	return opts, nil
+}
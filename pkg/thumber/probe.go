@@ -0,0 +1,201 @@
+package thumber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func runFfprobe(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := command(ctx, "ffprobe", append([]string{"-v", "error"}, args...)...)
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, &CommandError{Command: "ffprobe", Stderr: string(exitErr.Stderr), Err: err}
+		}
+		return nil, &CommandError{Command: "ffprobe", Err: err}
+	}
+	return out, nil
+}
+
+// probeResult is what ffprobe prints with -of json, limited to the entries thumber asks for.
+// ffprobe prints times, rates, sizes and bitrates as strings, and leaves out values it can't report.
+type probeResult struct {
+	Format   probeFormat    `json:"format"`
+	Streams  []probeStream  `json:"streams"`
+	Packets  []probePacket  `json:"packets"`
+	Frames   []probeFrame   `json:"frames"`
+	Chapters []probeChapter `json:"chapters"`
+}
+
+type probeFormat struct {
+	Duration string `json:"duration"`
+	Size     string `json:"size"`
+	BitRate  string `json:"bit_rate"`
+}
+
+type probeStream struct {
+	Index         int               `json:"index"`
+	CodecType     string            `json:"codec_type"`
+	CodecName     string            `json:"codec_name"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	AvgFrameRate  string            `json:"avg_frame_rate"`
+	Duration      string            `json:"duration"`
+	Channels      int               `json:"channels"`
+	ChannelLayout string            `json:"channel_layout"`
+	Disposition   map[string]int    `json:"disposition"`
+	Tags          map[string]string `json:"tags"`
+}
+
+type probePacket struct {
+	PTSTime      string `json:"pts_time"`
+	DurationTime string `json:"duration_time"`
+}
+
+type probeFrame struct {
+	BestEffortTimestampTime string `json:"best_effort_timestamp_time"`
+}
+
+type probeChapter struct {
+	StartTime string `json:"start_time"`
+	Tags      struct {
+		Title string `json:"title"`
+	} `json:"tags"`
+}
+
+// runProbe runs ffprobe with JSON output, args select the entries and end with the input
+func runProbe(ctx context.Context, args ...string) ([]byte, error) {
+	return runFfprobe(ctx, append([]string{"-of", "json"}, args...)...)
+}
+
+func decodeProbe(out []byte) (probeResult, error) {
+	var p probeResult
+	if err := json.Unmarshal(out, &p); err != nil {
+		return probeResult{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return p, nil
+}
+
+// firstStream is the first stream ffprobe selected, e.g. with -select_streams v:0
+func (p probeResult) firstStream() (probeStream, error) {
+	if len(p.Streams) == 0 {
+		return probeStream{}, fmt.Errorf("no video stream")
+	}
+	return p.Streams[0], nil
+}
+
+// readDuration reads the container duration, falling back to the video stream duration,
+// and then to scanning packet timestamps for files that report neither, e.g. fragmented MP4s or transport streams.
+func readDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+	probes := []struct {
+		name  string
+		probe func(ctx context.Context, videoPath string) (time.Duration, error)
+	}{
+		{"format", readFormatDuration},
+		{"stream", readStreamDuration},
+		{"packets", readPacketsDuration},
+	}
+
+	var errs []error
+	for _, p := range probes {
+		d, err := p.probe(ctx, videoPath)
+		if err == nil && d > 0 {
+			return d, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no duration reported")
+		}
+		slog.Debug("failed to read duration", "source", p.name, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+	}
+
+	return 0, errors.Join(errs...)
+}
+
+func readFormatDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+	out, err := runProbe(ctx,
+		"-show_entries", "format=duration",
+		videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	p, err := decodeProbe(out)
+	if err != nil {
+		return 0, err
+	}
+	return parseSeconds(p.Format.Duration)
+}
+
+func readStreamDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "v:0",
+		"-show_entries", "stream=duration",
+		videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	p, err := decodeProbe(out)
+	if err != nil {
+		return 0, err
+	}
+	stream, err := p.firstStream()
+	if err != nil {
+		return 0, err
+	}
+	return parseSeconds(stream.Duration)
+}
+
+// readPacketsDuration reads every video packet's timestamp without decoding, which is slow but works as a last resort
+func readPacketsDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,duration_time",
+		videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return parsePacketsDuration(out)
+}
+
+func parsePacketsDuration(out []byte) (time.Duration, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return 0, err
+	}
+	var end time.Duration
+	for _, packet := range p.Packets {
+		pts, err := parseSeconds(packet.PTSTime)
+		if err != nil {
+			continue
+		}
+		// packet duration is optional
+		d, _ := parseSeconds(packet.DurationTime)
+		if pts+d > end {
+			end = pts + d
+		}
+	}
+	return end, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse seconds: %w", err)
+	}
+	// round, since e.g. 2.002 is slightly less than that as a float
+	return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
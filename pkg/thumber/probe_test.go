@@ -0,0 +1,24 @@
+package thumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePacketsDuration(t *testing.T) {
+	out := `{"packets": [
+		{"pts_time": "0.000000", "duration_time": "0.040000"},
+		{"pts_time": "9.960000", "duration_time": "0.040000"},
+		{"pts_time": "4.000000"},
+		{"duration_time": "0.040000"}
+	]}`
+	d, err := parsePacketsDuration([]byte(out))
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, d)
+
+	_, err = parsePacketsDuration([]byte("0.000000,0.040000\n"))
+	assert.Error(t, err)
+}
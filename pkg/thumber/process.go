@@ -0,0 +1,70 @@
+package thumber
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// parentEnv marks the processes thumber spawns with its pid, which their children inherit,
+// so processes left behind by a thumber process that died can be found
+const parentEnv = "THUMBER_PARENT_PID"
+
+// processWaitDelay is how long a canceled process has to exit, and how long its output is read after it exits,
+// before its pipes are closed so that grandchildren holding them can't block Wait
+const processWaitDelay = 5 * time.Second
+
+// command is exec.CommandContext for the tools thumber runs.
+// The process gets its own process group, so canceling ctx kills it along with any processes it spawned,
+// and it's sandboxed as set by WithSandbox.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	sandbox := sandboxFrom(ctx)
+	cmd := exec.CommandContext(ctx, name, sandbox.args(name, args)...)
+	cmd.Env = append(os.Environ(), parentEnv+"="+strconv.Itoa(os.Getpid()))
+	cmd.WaitDelay = processWaitDelay
+	superviseProcess(cmd)
+	if sandbox.Confine {
+		confine(cmd, sandbox.WritableDirs)
+	}
+	return cmd
+}
+
+// WatchOrphans kills processes spawned by thumber processes that are no longer running, e.g. ffmpeg processes
+// of a crashed server, at every interval until ctx is done. It's meant for long running modes,
+// where orphans would otherwise pile up across restarts.
+func WatchOrphans(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if n := killOrphans(); n > 0 {
+			slog.Warn("killed orphaned processes", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// orphanedBy is the pid of the thumber process that spawned a process with the environment env,
+// and whether that thumber process is gone
+func orphanedBy(env []string) (int, bool) {
+	for _, kv := range env {
+		v, ok := strings.CutPrefix(kv, parentEnv+"=")
+		if !ok {
+			continue
+		}
+		pid, err := strconv.Atoi(v)
+		if err != nil || pid <= 0 {
+			return 0, false
+		}
+		return pid, pid != os.Getpid() && !processAlive(pid)
+	}
+	return 0, false
+}
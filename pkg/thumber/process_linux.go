@@ -0,0 +1,82 @@
+package thumber
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/exp/slog"
+	"golang.org/x/sys/unix"
+)
+
+func applyProcessLimits(pid int, limits ProcessLimits) error {
+	if limits.MemoryBytes > 0 {
+		rlimit := unix.Rlimit{Cur: uint64(limits.MemoryBytes), Max: uint64(limits.MemoryBytes)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &rlimit, nil); err != nil {
+			return fmt.Errorf("failed to limit ffmpeg memory: %w", err)
+		}
+	}
+	if limits.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, limits.Nice); err != nil {
+			return fmt.Errorf("failed to set ffmpeg priority: %w", err)
+		}
+	}
+	return nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists but belongs to someone else
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// superviseProcess puts cmd in its own process group that's killed on cancel, and has the kernel kill it
+// if thumber dies without canceling it
+func superviseProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+}
+
+func killProcessGroup(pid int) error {
+	// a negative pid signals the whole group
+	err := syscall.Kill(-pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// killOrphans kills the processes whose environment says they were spawned by a thumber process that's gone,
+// and returns how many
+func killOrphans() int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	killed := 0
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// unreadable for processes of other users, and empty for zombies
+		environ, err := os.ReadFile(filepath.Join("/proc", e.Name(), "environ"))
+		if err != nil {
+			continue
+		}
+		parent, orphaned := orphanedBy(strings.Split(string(environ), "\x00"))
+		if !orphaned {
+			continue
+		}
+		slog.Debug("killing orphaned process", "pid", pid, "parent", parent)
+		if err := syscall.Kill(pid, syscall.SIGKILL); err == nil {
+			killed++
+		}
+	}
+	return killed
+}
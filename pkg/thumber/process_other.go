@@ -0,0 +1,29 @@
+//go:build !unix
+
+package thumber
+
+import (
+	"os/exec"
+
+	"golang.org/x/exp/slog"
+)
+
+func applyProcessLimits(pid int, limits ProcessLimits) error {
+	if limits.MemoryBytes > 0 || limits.Nice != 0 {
+		slog.Warn("memory and priority limits are not supported on this platform, ignoring")
+	}
+	return nil
+}
+
+// processAlive can't tell on this platform, so workspaces are only cleaned up by age
+func processAlive(pid int) bool {
+	return true
+}
+
+// superviseProcess leaves cmd as is, canceling it kills the process but not the processes it spawned
+func superviseProcess(cmd *exec.Cmd) {}
+
+// killOrphans can't find processes by their environment on this platform
+func killOrphans() int {
+	return 0
+}
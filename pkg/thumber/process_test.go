@@ -0,0 +1,74 @@
+//go:build linux
+
+package thumber
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exited reports whether pid is gone or a zombie waiting to be reaped by its new parent
+func exited(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return fields[0] == "Z"
+}
+
+func TestCommandCancelKillsGrandchildren(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := command(ctx, "sh", "-c", "sleep 30 & echo $!; wait")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	line := make([]byte, 32)
+	n, err := stdout.Read(line)
+	require.NoError(t, err)
+	grandchild, err := strconv.Atoi(strings.TrimSpace(string(line[:n])))
+	require.NoError(t, err)
+
+	cancel()
+	assert.Error(t, cmd.Wait())
+	assert.Eventually(t, func() bool { return exited(grandchild) }, time.Second, 10*time.Millisecond)
+}
+
+func TestKillOrphans(t *testing.T) {
+	gone := exec.Command("true")
+	require.NoError(t, gone.Run())
+
+	cmd := exec.Command("sleep", "30")
+	cmd.Env = []string{parentEnv + "=" + strconv.Itoa(gone.Process.Pid)}
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	ours := command(context.Background(), "sleep", "30")
+	require.NoError(t, ours.Start())
+	t.Cleanup(func() { _ = ours.Process.Kill() })
+
+	assert.Equal(t, 1, killOrphans())
+	assert.Error(t, cmd.Wait(), "the orphan is killed")
+	assert.False(t, exited(ours.Process.Pid), "processes of running thumber processes are left alone")
+}
+
+func TestApplyProcessLimits(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	require.NoError(t, applyProcessLimits(cmd.Process.Pid, ProcessLimits{MemoryBytes: 512 << 20}))
+	limits, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/limits")
+	require.NoError(t, err)
+	assert.Regexp(t, `Max address space\s+536870912\s+536870912`, string(limits))
+}
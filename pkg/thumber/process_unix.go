@@ -0,0 +1,51 @@
+//go:build unix && !linux
+
+package thumber
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/exp/slog"
+)
+
+func applyProcessLimits(pid int, limits ProcessLimits) error {
+	if limits.MemoryBytes > 0 {
+		slog.Warn("memory limits are only supported on linux, ignoring")
+	}
+	if limits.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, limits.Nice); err != nil {
+			return fmt.Errorf("failed to set ffmpeg priority: %w", err)
+		}
+	}
+	return nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists but belongs to someone else
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// superviseProcess puts cmd in its own process group that's killed on cancel
+func superviseProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+}
+
+func killProcessGroup(pid int) error {
+	// a negative pid signals the whole group
+	err := syscall.Kill(-pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// killOrphans can't read the environment of other processes on this platform
+func killOrphans() int {
+	return 0
+}
@@ -0,0 +1,79 @@
+package thumber
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Progress is an update on the generation of a file, reported to ThumbOptions.Progress
+type Progress struct {
+	Path string
+	// Stage is probe, extract or compose
+	Stage string
+	// Frame is how many frames are extracted so far, out of Frames. Both are zero outside of extraction
+	Frame, Frames int
+	// Percent is how much of the file is done. Extraction takes most of the time, so it goes up to extractPercent
+	Percent float64
+	// ETA is the time left until extraction is done at its pace so far, zero outside of extraction
+	ETA time.Duration
+}
+
+// interrupted reports whether the interrupt channel is closed
+func interrupted(interrupt <-chan struct{}) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// extractPercent is how much of a file is done once its frames are extracted
+const extractPercent = 90
+
+// reportProgress reports a stage without frames
+func (o ThumbOptions) reportProgress(ctx context.Context, stage string, percent float64) {
+	if o.Progress == nil {
+		return
+	}
+	o.Progress(Progress{Path: stageFieldsFrom(ctx).path, Stage: stage, Percent: percent})
+}
+
+// frameProgress counts extracted frames, which finish out of order and concurrently
+type frameProgress struct {
+	report  func(Progress)
+	path    string
+	frames  int
+	started time.Time
+
+	mu   sync.Mutex
+	done int
+}
+
+// newFrameProgress tracks the extraction of frames, and is nil if progress isn't reported
+func (o ThumbOptions) newFrameProgress(path string, frames int) *frameProgress {
+	if o.Progress == nil {
+		return nil
+	}
+	return &frameProgress{report: o.Progress, path: path, frames: frames, started: time.Now()}
+}
+
+// frameDone reports another extracted frame. Reports are serialized, so they arrive in order.
+func (p *frameProgress) frameDone() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	elapsed := time.Since(p.started)
+	p.report(Progress{
+		Path:    p.path,
+		Stage:   "extract",
+		Frame:   p.done,
+		Frames:  p.frames,
+		Percent: extractPercent * float64(p.done) / float64(p.frames),
+		ETA:     elapsed / time.Duration(p.done) * time.Duration(p.frames-p.done),
+	})
+}
@@ -0,0 +1,25 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameProgress(t *testing.T) {
+	assert.Nil(t, ThumbOptions{}.newFrameProgress("video.mp4", 4), "no tracking without a callback")
+	var nilProgress *frameProgress
+	nilProgress.frameDone()
+
+	var reports []Progress
+	p := ThumbOptions{Progress: func(p Progress) { reports = append(reports, p) }}.newFrameProgress("video.mp4", 4)
+	p.frameDone()
+	p.frameDone()
+	require.Len(t, reports, 2)
+	assert.Equal(t, "extract", reports[1].Stage)
+	assert.Equal(t, "video.mp4", reports[1].Path)
+	assert.Equal(t, 2, reports[1].Frame)
+	assert.Equal(t, 4, reports[1].Frames)
+	assert.Equal(t, 45.0, reports[1].Percent)
+}
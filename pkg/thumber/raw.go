@@ -0,0 +1,147 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/exp/slog"
+)
+
+// RawFormat is a high bit depth format for tiles, encoded by ffmpeg straight from the decoded frame
+// so that none of the precision is lost to the 8-bit images sheets are composed of
+type RawFormat string
+
+const (
+	// RawPNG16 is a 16-bit per channel RGB PNG
+	RawPNG16 RawFormat = "png16"
+	// RawTIFF is a 16-bit per channel RGB TIFF
+	RawTIFF RawFormat = "tiff"
+	// RawEXR is a 32-bit float OpenEXR, which needs ffmpeg 5.0 or newer
+	RawEXR RawFormat = "exr"
+)
+
+func (f RawFormat) Validate() error {
+	switch f {
+	case RawPNG16, RawTIFF, RawEXR:
+		return nil
+	}
+	return fmt.Errorf("invalid raw format %q, must be one of png16, tiff, exr", f)
+}
+
+// Ext is the file extension of the format, with the dot
+func (f RawFormat) Ext() string {
+	if f == RawPNG16 {
+		return ".png"
+	}
+	return "." + string(f)
+}
+
+// codecArgs are the ffmpeg output arguments that encode a single frame in the format
+func (f RawFormat) codecArgs() []string {
+	switch f {
+	case RawTIFF:
+		return []string{"-c:v", "tiff", "-pix_fmt", "rgb48le"}
+	case RawEXR:
+		return []string{"-c:v", "exr", "-pix_fmt", "gbrpf32le"}
+	default:
+		return []string{"-c:v", "png", "-pix_fmt", "rgb48be"}
+	}
+}
+
+// rawFilter crops and blurs frames like the tiles, but keeps them at the resolution of the video
+func rawFilter(opts ThumbOptions) string {
+	var filters []string
+	for i, r := range opts.BlurRegions {
+		filters = append(filters, r.blurFilter(i))
+	}
+	if opts.Crop != nil {
+		filters = append(filters, opts.Crop.cropFilter())
+	}
+	return strings.Join(filters, ",")
+}
+
+// SaveRawTiles writes the frame of each tile in a high bit depth format into dir as tile-0001.png, tile-0002.png and so on
+// numbered from first, and returns their paths.
+// Frames are extracted again at their timestamps and encoded by ffmpeg at the resolution of the video,
+// so they're cropped and blurred like the tiles but timestamps and markers aren't overlaid.
+func SaveRawTiles(ctx context.Context, videoPath, dir string, tiles []TilePlacement, opts ThumbOptions, format RawFormat, first int) ([]string, error) {
+	opts = opts.withDefaults()
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	p := pool.New().
+		WithContext(ctx).
+		WithMaxGoroutines(opts.concurrency()).
+		WithCancelOnError().
+		WithFirstError()
+
+	paths := make([]string, len(tiles))
+	filter := rawFilter(opts)
+	for i, tile := range tiles {
+		i, tile := i, tile
+		paths[i] = filepath.Join(dir, fmt.Sprintf("tile-%04d%s", first+i, format.Ext()))
+		p.Go(func(ctx context.Context) (err error) {
+			defer RecoverPanic(&err)
+			ctx = withFrameIndex(withVideoPath(ctx, videoPath), i)
+			slog.Debug("extracting raw frame", "current", i+1, "total", len(tiles))
+			data, err := extractRawFrame(ctx, videoPath, tile.Timestamp, filter, opts, format)
+			if err != nil {
+				slog.Error("failed to extract raw frame", "timestamp", tile.Timestamp, "error", err)
+				return err
+			}
+			return os.WriteFile(paths[i], data, 0o644)
+		})
+	}
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// extractRawFrame returns the frame at timestamp encoded in format
+func extractRawFrame(ctx context.Context, filename string, timestamp time.Duration, filter string, opts ThumbOptions, format RawFormat) (_ []byte, err error) {
+	ctx, stage := startStage(ctx, "raw", attribute.Int64("thumber.timestamp_ms", timestamp.Milliseconds()), attribute.String("thumber.format", string(format)))
+	defer func() { stage.End(err) }()
+
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args,
+		"-ss", fmt.Sprintf("%dms", timestamp.Milliseconds()),
+		"-i", filename,
+	)
+	if filter != "" {
+		args = append(args, "-vf", filter)
+	}
+	args = append(args, "-an", "-frames:v", "1")
+	args = append(args, format.codecArgs()...)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "image2pipe", "pipe:1")
+	cmd := command(ctx, "ffmpeg", args...)
+
+	stdout := limits.outputBuffer()
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		if err := stdout.err(); err != nil {
+			return nil, err
+		}
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	if stdout.Len() == 0 {
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: fmt.Errorf("no frames at %s", timestamp)}
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,24 @@
+package thumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawFormat(t *testing.T) {
+	assert.NoError(t, RawEXR.Validate())
+	assert.ErrorContains(t, RawFormat("dpx").Validate(), "invalid raw format")
+
+	assert.Equal(t, ".png", RawPNG16.Ext())
+	assert.Equal(t, ".tiff", RawTIFF.Ext())
+	assert.Contains(t, RawPNG16.codecArgs(), "rgb48be")
+	assert.Contains(t, RawEXR.codecArgs(), "gbrpf32le")
+}
+
+func TestRawFilter(t *testing.T) {
+	assert.Equal(t, "", rawFilter(ThumbOptions{}), "frames are kept as decoded")
+
+	crop := Region{X: 10, Y: 10, W: 50, H: 50, Relative: true}
+	assert.Equal(t, crop.cropFilter(), rawFilter(ThumbOptions{Crop: &crop, TileWidth: 300}), "frames aren't scaled")
+}
@@ -0,0 +1,146 @@
+package thumber
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Region is a rectangle in source frame coordinates.
+// If Relative is set, components are fractions of the frame size instead of pixels.
+// If Centered is set, X and Y are ignored and the region is centered in the frame.
+type Region struct {
+	X, Y, W, H float64
+	Relative   bool
+	Centered   bool
+}
+
+// ParseRegion parses a region in x,y,w,h or center:WxH format,
+// e.g. 100,200,320,80 or 10%,80%,30%,15% or center:50%x50%
+func ParseRegion(s string) (Region, error) {
+	s = strings.TrimSpace(s)
+	if size, ok := strings.CutPrefix(s, "center:"); ok {
+		w, h, ok := strings.Cut(size, "x")
+		if !ok {
+			return Region{}, fmt.Errorf("invalid region %q: expected center:WxH", s)
+		}
+		r, err := parseRegionParts(s, []string{"0", "0", w, h})
+		if err != nil {
+			return Region{}, err
+		}
+		r.Centered = true
+		return r, r.Validate()
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Region{}, fmt.Errorf("invalid region %q: expected x,y,w,h", s)
+	}
+
+	r, err := parseRegionParts(s, parts)
+	if err != nil {
+		return Region{}, err
+	}
+	return r, r.Validate()
+}
+
+func parseRegionParts(s string, parts []string) (Region, error) {
+	var values [4]float64
+	var percents, zeros int
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if strings.HasSuffix(p, "%") {
+			p = strings.TrimSuffix(p, "%")
+			percents++
+		}
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return Region{}, fmt.Errorf("invalid region %q: %q is not a number", s, parts[i])
+		}
+		values[i] = v
+		if v == 0 {
+			zeros++
+		}
+	}
+
+	r := Region{X: values[0], Y: values[1], W: values[2], H: values[3]}
+	switch {
+	case percents == 0:
+	case percents+zeros == len(parts):
+		// a bare 0 is the same in both units
+		r.Relative = true
+		r.X, r.Y, r.W, r.H = r.X/100, r.Y/100, r.W/100, r.H/100
+	default:
+		return Region{}, fmt.Errorf("invalid region %q: cannot mix pixels and percentages", s)
+	}
+
+	return r, nil
+}
+
+func (r Region) Validate() error {
+	if !r.Centered && (r.X < 0 || r.Y < 0) {
+		return fmt.Errorf("region position cannot be negative")
+	}
+	if r.W <= 0 || r.H <= 0 {
+		return fmt.Errorf("region size must be positive")
+	}
+	if r.Relative && r.Centered && (r.W > 1 || r.H > 1) {
+		return fmt.Errorf("region is larger than the frame")
+	}
+	if r.Relative && !r.Centered && (r.X+r.W > 1 || r.Y+r.H > 1) {
+		return fmt.Errorf("region extends beyond the frame")
+	}
+	return nil
+}
+
+// fits reports whether the region lies within a frame of the given size.
+// Relative regions always do, as Validate checks them against the frame.
+func (r Region) fits(frame image.Point) bool {
+	if r.Relative {
+		return true
+	}
+	if r.Centered {
+		return r.W <= float64(frame.X) && r.H <= float64(frame.Y)
+	}
+	return r.X+r.W <= float64(frame.X) && r.Y+r.H <= float64(frame.Y)
+}
+
+// cropArgs returns w:h:x:y arguments for ffmpeg's crop filter
+func (r Region) cropArgs() string {
+	if r.Centered {
+		// crop centers the region when x and y are omitted
+		if r.Relative {
+			return fmt.Sprintf("iw*%g:ih*%g", r.W, r.H)
+		}
+		return fmt.Sprintf("%d:%d", int(r.W), int(r.H))
+	}
+	if r.Relative {
+		return fmt.Sprintf("iw*%g:ih*%g:iw*%g:ih*%g", r.W, r.H, r.X, r.Y)
+	}
+	return fmt.Sprintf("%d:%d:%d:%d", int(r.W), int(r.H), int(r.X), int(r.Y))
+}
+
+// overlayArgs returns x:y arguments for ffmpeg's overlay filter to put a cropped region back in place
+func (r Region) overlayArgs() string {
+	if r.Centered {
+		return "(W-w)/2:(H-h)/2"
+	}
+	if r.Relative {
+		return fmt.Sprintf("W*%g:H*%g", r.X, r.Y)
+	}
+	return fmt.Sprintf("%d:%d", int(r.X), int(r.Y))
+}
+
+func (r Region) cropFilter() string {
+	return "crop=" + r.cropArgs()
+}
+
+// blurFilter returns an ffmpeg filter chain that blurs the region in place.
+// id is used to keep stream labels unique when multiple regions are chained.
+func (r Region) blurFilter(id int) string {
+	return fmt.Sprintf(
+		"split[blur%[1]dmain][blur%[1]dsrc];[blur%[1]dsrc]crop=%[2]s,boxblur=lr='min(w,h)/4':lp=2:cr='min(cw,ch)/4':cp=2[blur%[1]dout];[blur%[1]dmain][blur%[1]dout]overlay=%[3]s",
+		id, r.cropArgs(), r.overlayArgs(),
+	)
+}
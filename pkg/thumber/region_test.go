@@ -0,0 +1,104 @@
+package thumber
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Region
+		wantErr bool
+	}{
+		{
+			name:  "pixels",
+			input: "100,200,320,80",
+			want:  Region{X: 100, Y: 200, W: 320, H: 80},
+		},
+		{
+			name:  "percentages",
+			input: "10%, 80%, 30%, 15%",
+			want:  Region{X: 0.1, Y: 0.8, W: 0.3, H: 0.15, Relative: true},
+		},
+		{
+			name:  "zero offsets with percentages",
+			input: "0,0,50%,50%",
+			want:  Region{W: 0.5, H: 0.5, Relative: true},
+		},
+		{
+			name:  "centered",
+			input: "center:640x360",
+			want:  Region{W: 640, H: 360, Centered: true},
+		},
+		{
+			name:  "centered percentages",
+			input: "center:50%x25%",
+			want:  Region{W: 0.5, H: 0.25, Relative: true, Centered: true},
+		},
+		{
+			name:    "mixed units",
+			input:   "10%,80,30%,15%",
+			wantErr: true,
+		},
+		{
+			name:    "missing components",
+			input:   "10,20,30",
+			wantErr: true,
+		},
+		{
+			name:    "out of frame",
+			input:   "80%,0%,30%,10%",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRegion(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want.X, r.X, 1e-9)
+			assert.InDelta(t, tt.want.Y, r.Y, 1e-9)
+			assert.InDelta(t, tt.want.W, r.W, 1e-9)
+			assert.InDelta(t, tt.want.H, r.H, 1e-9)
+			assert.Equal(t, tt.want.Relative, r.Relative)
+			assert.Equal(t, tt.want.Centered, r.Centered)
+		})
+	}
+}
+
+func TestCheckRegions(t *testing.T) {
+	frame := image.Pt(1920, 1080)
+	tests := []struct {
+		name string
+		opts ThumbOptions
+		err  string
+	}{
+		{name: "inside", opts: ThumbOptions{BlurRegions: []Region{{X: 1600, Y: 1000, W: 320, H: 80}}, Crop: &Region{W: 1920, H: 1080, Centered: true}}},
+		{name: "relative", opts: ThumbOptions{BlurRegions: []Region{{X: 0.9, Y: 0.9, W: 0.1, H: 0.1, Relative: true}}}},
+		{
+			name: "blur region past the edge",
+			opts: ThumbOptions{BlurRegions: []Region{{W: 10, H: 10}, {X: 1700, Y: 1000, W: 320, H: 80}}},
+			err:  "blur region 2 extends beyond the 1920x1080 frame",
+		},
+		{name: "crop larger than the frame", opts: ThumbOptions{Crop: &Region{W: 3840, H: 2160, Centered: true}}, err: "crop extends beyond the 1920x1080 frame"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.checkRegions(frame)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	assert.True(t, ThumbOptions{BlurRegions: []Region{{W: 10, H: 10}}}.needsSize())
+	assert.False(t, ThumbOptions{Crop: &Region{W: 0.5, H: 0.5, Relative: true, Centered: true}}.needsSize())
+}
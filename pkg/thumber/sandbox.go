@@ -0,0 +1,125 @@
+package thumber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// Sandbox reduces what the ffmpeg and ffprobe processes thumber runs can do, for servers processing untrusted files.
+// It applies to the processes run with a context from WithSandbox.
+type Sandbox struct {
+	// NoNetwork restricts ffmpeg and ffprobe to local files and pipes with a protocol whitelist,
+	// so inputs such as playlists can't make them fetch URLs
+	NoNetwork bool
+	// Protocols restricts ffmpeg and ffprobe to these protocols with a whitelist, e.g. to those of the input
+	// on a server, so a playlist can't reference anything else. NoNetwork takes precedence.
+	Protocols []string
+	// Confine runs processes under Landlock on Linux or sandbox-exec on macOS, which denies network access
+	// and writes outside of WritableDirs. Starting them fails on other platforms, and on Linux kernels without Landlock.
+	// Landlock only denies network access from Linux 6.7, see ConfinesNetwork, and before that the NoNetwork
+	// whitelist applies instead. Reading isn't restricted, so a playlist can still make ffmpeg read any local file
+	// the process can. On Linux, programs must call ExecConfined at the start of main.
+	Confine bool
+	// WritableDirs are where confined processes may write besides their pipes. Files ffmpeg writes itself,
+	// e.g. with EmbedCover, are allowed as needed.
+	WritableDirs []string
+}
+
+// localProtocols are the protocols ffmpeg and ffprobe may use with NoNetwork
+const localProtocols = "file,pipe,crypto,data"
+
+// confineEnv marks a thumber process started to confine a command, with the writable directories as a path list
+const confineEnv = "THUMBER_CONFINE"
+
+type sandboxKey struct{}
+
+// WithSandbox confines the processes run with the returned context
+func WithSandbox(ctx context.Context, s Sandbox) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, s)
+}
+
+func sandboxFrom(ctx context.Context) Sandbox {
+	s, _ := ctx.Value(sandboxKey{}).(Sandbox)
+	return s
+}
+
+// WithProtocols restricts the ffmpeg and ffprobe processes run with the returned context to the protocols
+func WithProtocols(ctx context.Context, protocols ...string) context.Context {
+	s := sandboxFrom(ctx)
+	s.Protocols = protocols
+	return WithSandbox(ctx, s)
+}
+
+// allowWrites lets confined processes run with the returned context write into dir
+func allowWrites(ctx context.Context, dir string) context.Context {
+	s := sandboxFrom(ctx)
+	if !s.Confine {
+		return ctx
+	}
+	s.WritableDirs = append(append([]string(nil), s.WritableDirs...), dir)
+	return WithSandbox(ctx, s)
+}
+
+// args adds the protocol whitelist to the arguments of ffmpeg and ffprobe with NoNetwork or Protocols
+func (s Sandbox) args(name string, args []string) []string {
+	protocols := strings.Join(s.Protocols, ",")
+	if s.NoNetwork || s.Confine && !ConfinesNetwork() {
+		protocols = localProtocols
+	}
+	if protocols == "" {
+		return args
+	}
+	whitelist := []string{"-protocol_whitelist", protocols}
+	switch name {
+	case "ffprobe":
+		return append(whitelist, args...)
+	case "ffmpeg":
+		// it's an input option, so it has to come before each input
+		var out []string
+		for _, arg := range args {
+			if arg == "-i" {
+				out = append(out, whitelist...)
+			}
+			out = append(out, arg)
+		}
+		return out
+	}
+	return args
+}
+
+// ExecConfined restricts the process and executes the command in its arguments in its place, if it was started
+// to run a confined process. Otherwise it returns right away. Programs using Sandbox.Confine must call it
+// at the start of main, before their own flags are parsed.
+func ExecConfined() {
+	dirs, ok := os.LookupEnv(confineEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(confineEnv)
+	if err := execConfined(filepath.SplitList(dirs), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to confine %s: %v\n", strings.Join(os.Args[1:], " "), err)
+		os.Exit(126)
+	}
+}
+
+func execConfined(writable, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command")
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+	// restrictions apply to the thread that makes them, which must be the one that executes the command
+	runtime.LockOSThread()
+	if err := restrictSelf(writable); err != nil {
+		return err
+	}
+	return syscall.Exec(path, args, os.Environ())
+}
@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfinesNetwork reports whether Sandbox.Confine denies network access, which sandbox-exec always does
+func ConfinesNetwork() bool {
+	return true
+}
+
+// confine runs cmd with sandbox-exec
+func confine(cmd *exec.Cmd, writable []string) {
+	args := []string{"sandbox-exec", "-p", sandboxProfile(writable), cmd.Path}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = "/usr/bin/sandbox-exec"
+}
+
+// sandboxProfile denies network access and writes outside of writable and /dev/null
+func sandboxProfile(writable []string) string {
+	var b strings.Builder
+	b.WriteString(`(version 1)(allow default)(deny network*)(deny file-write*)(allow file-write* (literal "/dev/null"))`)
+	for _, dir := range writable {
+		// the profile matches resolved paths, e.g. /private/tmp instead of /tmp
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		fmt.Fprintf(&b, "(allow file-write* (subpath %s))", strconv.Quote(dir))
+	}
+	return b.String()
+}
+
+// restrictSelf isn't needed, processes are confined by sandbox-exec
+func restrictSelf(writable []string) error {
+	return fmt.Errorf("not supported on this platform")
+}
@@ -0,0 +1,108 @@
+package thumber
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// confine runs cmd through the thumber executable, which restricts itself in ExecConfined before executing it
+func confine(cmd *exec.Cmd, writable []string) {
+	exe, err := os.Executable()
+	if err != nil {
+		cmd.Err = fmt.Errorf("failed to confine %s: %w", cmd.Args[0], err)
+		return
+	}
+	cmd.Args = append([]string{exe}, cmd.Args...)
+	cmd.Path = exe
+	cmd.Env = append(cmd.Env, confineEnv+"="+strings.Join(writable, string(os.PathListSeparator)))
+}
+
+// landlockWrites are the filesystem rights of the first Landlock ABI that modify files
+const landlockWrites = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+	unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+	unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+var (
+	landlockOnce sync.Once
+	landlockABI  uintptr
+)
+
+// ConfinesNetwork reports whether Sandbox.Confine denies network access, which Landlock does from Linux 6.7.
+// Where it doesn't, confined processes are kept to local files and pipes with the NoNetwork whitelist instead.
+func ConfinesNetwork() bool {
+	landlockOnce.Do(func() {
+		abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+		if errno == 0 {
+			landlockABI = abi
+		}
+	})
+	return landlockABI >= 4
+}
+
+// restrictSelf denies the calling thread writes outside of writable, and TCP connections if the kernel supports it.
+// Reading stays allowed, so ffmpeg can load its libraries and read its input, but also any other file it's
+// pointed to, e.g. by a playlist.
+func restrictSelf(writable []string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not available: %w", errno)
+	}
+	access := uint64(landlockWrites)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	if abi >= 4 {
+		// older kernels don't restrict the network, but accept the zero value
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, dir := range writable {
+		if err := allowBeneath(int(fd), dir, access); err != nil {
+			return err
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to drop privileges: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to restrict: %w", errno)
+	}
+	return nil
+}
+
+// allowBeneath grants access to everything under dir
+func allowBeneath(ruleset int, dir string, access uint64) error {
+	parent, err := unix.Open(dir, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	defer unix.Close(parent)
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(parent)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to allow writes to %s: %w", dir, errno)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package thumber
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestConfine(t *testing.T) {
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION); errno != 0 {
+		t.Skip("landlock is not available")
+	}
+	writable, readOnly := t.TempDir(), t.TempDir()
+	ctx := WithSandbox(context.Background(), Sandbox{Confine: true, WritableDirs: []string{writable}})
+
+	out, err := command(ctx, "sh", "-c", `echo ok > "$0"`, filepath.Join(writable, "file")).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.FileExists(t, filepath.Join(writable, "file"))
+
+	out, err = command(ctx, "sh", "-c", `echo ok > "$0"`, filepath.Join(readOnly, "file")).CombinedOutput()
+	assert.Error(t, err, string(out))
+	_, err = os.Stat(filepath.Join(readOnly, "file"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestConfineFallsBackToWhitelist(t *testing.T) {
+	args := Sandbox{Confine: true}.args("ffprobe", []string{"a.m3u8"})
+	if ConfinesNetwork() {
+		assert.Equal(t, []string{"a.m3u8"}, args, "landlock denies network access")
+	} else {
+		assert.Equal(t, []string{"-protocol_whitelist", localProtocols, "a.m3u8"}, args)
+	}
+}
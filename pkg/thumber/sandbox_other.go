@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+
+package thumber
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errConfineUnsupported = errors.New("confining processes is only supported on Linux and macOS")
+
+// ConfinesNetwork is true, as confined processes fail to start anyway
+func ConfinesNetwork() bool {
+	return true
+}
+
+// confine fails cmd, since running it unconfined would defeat the sandbox
+func confine(cmd *exec.Cmd, writable []string) {
+	cmd.Err = errConfineUnsupported
+}
+
+func restrictSelf(writable []string) error {
+	return errConfineUnsupported
+}
@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// confined processes are started through the test binary on Linux
+	ExecConfined()
+	os.Exit(m.Run())
+}
+
+func TestSandboxArgs(t *testing.T) {
+	s := Sandbox{NoNetwork: true}
+	assert.Equal(t,
+		[]string{"-hide_banner", "-protocol_whitelist", "file,pipe,crypto,data", "-i", "a.mp4", "-protocol_whitelist", "file,pipe,crypto,data", "-i", "pipe:0", "pipe:1"},
+		s.args("ffmpeg", []string{"-hide_banner", "-i", "a.mp4", "-i", "pipe:0", "pipe:1"}),
+	)
+	assert.Equal(t, []string{"-protocol_whitelist", "file,pipe,crypto,data", "-v", "error", "a.mp4"}, s.args("ffprobe", []string{"-v", "error", "a.mp4"}))
+	assert.Equal(t, []string{"stdin", "stdout"}, s.args("tesseract", []string{"stdin", "stdout"}))
+	assert.Equal(t, []string{"-i", "a.mp4"}, Sandbox{}.args("ffmpeg", []string{"-i", "a.mp4"}))
+
+	s = sandboxFrom(WithProtocols(context.Background(), "https", "tls", "tcp"))
+	assert.Equal(t, []string{"-protocol_whitelist", "https,tls,tcp", "-i", "https://example.com/a.m3u8"}, s.args("ffmpeg", []string{"-i", "https://example.com/a.m3u8"}))
+	s.NoNetwork = true
+	assert.Equal(t, []string{"-protocol_whitelist", "file,pipe,crypto,data", "a.m3u8"}, s.args("ffprobe", []string{"a.m3u8"}), "NoNetwork takes precedence")
+}
+
+func TestAllowWrites(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Sandbox{}, sandboxFrom(allowWrites(ctx, "/out")), "unconfined processes write anywhere")
+
+	ctx = WithSandbox(ctx, Sandbox{Confine: true, WritableDirs: []string{"/tmp"}})
+	assert.Equal(t, []string{"/tmp", "/out"}, sandboxFrom(allowWrites(ctx, "/out")).WritableDirs)
+	assert.Equal(t, []string{"/tmp"}, sandboxFrom(ctx).WritableDirs)
+}
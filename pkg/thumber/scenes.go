@@ -0,0 +1,113 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Scene is a shot between two cuts
+type Scene struct {
+	Start time.Duration
+	End   time.Duration
+	// Score is how much the first frame differs from the frame before the cut, from 0 to 1, and 0 for the first scene
+	Score float64
+}
+
+// Middle is the timestamp halfway through the scene, which represents it better than the frame right after the cut
+func (s Scene) Middle() time.Duration {
+	return s.Start + (s.End-s.Start)/2
+}
+
+// DefaultSceneThreshold is the scene score above which a frame counts as a cut
+const DefaultSceneThreshold = 0.4
+
+// DetectScenes finds cuts where ffmpeg's scene change score exceeds threshold,
+// and returns the scenes between them covering the whole video
+func DetectScenes(ctx context.Context, videoPath string, threshold float64, opts ThumbOptions) (_ []Scene, err error) {
+	if threshold <= 0 || threshold >= 1 {
+		return nil, fmt.Errorf("scene threshold must be between 0 and 1")
+	}
+	ctx, stage := startStage(withVideoPath(ctx, videoPath), "scenes", attribute.Float64("thumber.threshold", threshold))
+	defer func() { stage.End(err) }()
+
+	media, err := opts.media(ctx, videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	args = append(args,
+		"-i", videoPath,
+		"-an",
+		// scores are computed at a low resolution, which is much faster and finds the same cuts
+		"-vf", fmt.Sprintf("scale=160:-2,select='gt(scene,%s)',metadata=print:key=lavfi.scene_score", strconv.FormatFloat(threshold, 'f', -1, 64)),
+	)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "null", "-")
+	cmd := command(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		return nil, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	return scenesFromCuts(parseMetadataLog(stderr.String(), "lavfi.scene_score", 0), media.Duration), nil
+}
+
+// scenesFromCuts splits the video at each cut
+func scenesFromCuts(cuts []metadataSample, duration time.Duration) []Scene {
+	scenes := []Scene{{Start: 0}}
+	for _, c := range cuts {
+		if c.At <= scenes[len(scenes)-1].Start || c.At >= duration {
+			continue
+		}
+		scenes[len(scenes)-1].End = c.At
+		scenes = append(scenes, Scene{Start: c.At, Score: c.Value})
+	}
+	scenes[len(scenes)-1].End = duration
+	return scenes
+}
+
+// ReadFrameRate reads the average frame rate of the first video stream
+func ReadFrameRate(ctx context.Context, videoPath string) (float64, error) {
+	out, err := runProbe(ctx,
+		"-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate",
+		videoPath,
+	)
+	if err != nil {
+		return 0, err
+	}
+	p, err := decodeProbe(out)
+	if err != nil {
+		return 0, err
+	}
+	stream, err := p.firstStream()
+	if err != nil {
+		return 0, err
+	}
+	return parseFrameRate(stream.AvgFrameRate)
+}
+
+// parseFrameRate parses rates as ffprobe prints them, e.g. 30000/1001
+func parseFrameRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		den = "1"
+	}
+	n, errN := strconv.ParseFloat(num, 64)
+	d, errD := strconv.ParseFloat(den, 64)
+	if errN != nil || errD != nil || n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", s)
+	}
+	return n / d, nil
+}
@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenesFromCuts(t *testing.T) {
+	cuts := []metadataSample{
+		{At: 4 * time.Second, Value: 0.6},
+		{At: 4 * time.Second, Value: 0.5},
+		{At: 10 * time.Second, Value: 0.9},
+		{At: time.Minute, Value: 0.7},
+	}
+	scenes := scenesFromCuts(cuts, 30*time.Second)
+	assert.Equal(t, []Scene{
+		{Start: 0, End: 4 * time.Second},
+		{Start: 4 * time.Second, End: 10 * time.Second, Score: 0.6},
+		{Start: 10 * time.Second, End: 30 * time.Second, Score: 0.9},
+	}, scenes)
+	assert.Equal(t, 7*time.Second, scenes[1].Middle())
+
+	assert.Equal(t, []Scene{{Start: 0, End: time.Minute}}, scenesFromCuts(nil, time.Minute))
+}
+
+func TestParseFrameRate(t *testing.T) {
+	fps, err := parseFrameRate("30000/1001\n")
+	require.NoError(t, err)
+	assert.InDelta(t, 29.97, fps, 0.001)
+
+	fps, err = parseFrameRate("25")
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, fps)
+
+	_, err = parseFrameRate("0/0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package thumber
+
+import (
+	"image"
+	"time"
+)
+
+// Sheet is a composed contact sheet along with where each tile was placed on it
+type Sheet struct {
+	image.Image
+	Tiles []TilePlacement
+	// Window is the span of video the sheet covers with ThumbOptions.SheetDuration, and zero otherwise
+	Window TimeRange
+}
+
+// page is the tiles of one sheet, from index start up to end, and the window they fall in with SheetDuration
+type page struct {
+	start, end int
+	window     TimeRange
+}
+
+// paginate splits the tiles at timestamps over sheets of at most MaxTilesPerSheet tiles, or by the window of
+// SheetDuration they fall in. Windows are counted from the start of the video, and windows without tiles are skipped.
+func (o ThumbOptions) paginate(timestamps []time.Duration) []page {
+	var pages []page
+	if o.SheetDuration > 0 {
+		for i, t := range timestamps {
+			start := t / o.SheetDuration * o.SheetDuration
+			if len(pages) > 0 && pages[len(pages)-1].window.Start == start {
+				pages[len(pages)-1].end = i + 1
+				continue
+			}
+			window := TimeRange{Start: start, End: start + o.SheetDuration}
+			if o.To > 0 && window.End > o.To {
+				window.End = o.To
+			}
+			pages = append(pages, page{start: i, end: i + 1, window: window})
+		}
+		return pages
+	}
+
+	perSheet := o.MaxTilesPerSheet
+	if perSheet <= 0 {
+		perSheet = len(timestamps)
+	}
+	for start := 0; start < len(timestamps); start += perSheet {
+		end := start + perSheet
+		if end > len(timestamps) {
+			end = len(timestamps)
+		}
+		pages = append(pages, page{start: start, end: end})
+	}
+	return pages
+}
+
+// TilePlacement locates a tile on the sheet
+type TilePlacement struct {
+	Rect image.Rectangle
+	// Timestamp is the presentation time of the frame in the tile
+	Timestamp time.Duration
+	// Frame is the thumbnail in the tile, before timestamps are overlaid
+	Frame Thumbnail
+}
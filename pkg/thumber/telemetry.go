@@ -0,0 +1,94 @@
+package thumber
+
+import (
+	"context"
+	"runtime/trace"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// tracer records spans for each stage of the pipeline. Spans are dropped unless the application
+// registers an OpenTelemetry tracer provider.
+var tracer = otel.Tracer("github.com/abdusco/thumber/pkg/thumber")
+
+type stageFieldsKey struct{}
+
+// stageFields identify what a stage works on in timing logs
+type stageFields struct {
+	path       string
+	frameIndex int
+}
+
+// withVideoPath sets the path logged by stages started with ctx
+func withVideoPath(ctx context.Context, path string) context.Context {
+	f := stageFieldsFrom(ctx)
+	f.path = path
+	return context.WithValue(ctx, stageFieldsKey{}, f)
+}
+
+// withFrameIndex sets the frame index logged by stages started with ctx
+func withFrameIndex(ctx context.Context, i int) context.Context {
+	f := stageFieldsFrom(ctx)
+	f.frameIndex = i
+	return context.WithValue(ctx, stageFieldsKey{}, f)
+}
+
+func stageFieldsFrom(ctx context.Context) stageFields {
+	if f, ok := ctx.Value(stageFieldsKey{}).(stageFields); ok {
+		return f
+	}
+	return stageFields{frameIndex: -1}
+}
+
+// stage marks a pipeline stage in runtime traces, OpenTelemetry spans and debug logs
+type stage struct {
+	name    string
+	fields  stageFields
+	started time.Time
+	span    oteltrace.Span
+	region  *trace.Region
+	task    *trace.Task
+}
+
+// startStage starts a stage that's shown as a region in runtime traces
+func startStage(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, stage) {
+	region := trace.StartRegion(ctx, name)
+	ctx, span := tracer.Start(ctx, "thumber."+name, oteltrace.WithAttributes(attrs...))
+	return ctx, stage{name: name, fields: stageFieldsFrom(ctx), started: time.Now(), span: span, region: region}
+}
+
+// startTask starts a stage that's shown as a task in runtime traces, grouping the stages started within it
+func startTask(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, stage) {
+	ctx, task := trace.NewTask(ctx, name)
+	ctx, span := tracer.Start(ctx, "thumber."+name, oteltrace.WithAttributes(attrs...))
+	return ctx, stage{name: name, fields: stageFieldsFrom(ctx), started: time.Now(), span: span, task: task}
+}
+
+// End ends the stage, marking it failed if err is set
+func (s stage) End(err error) {
+	args := []any{"stage", s.name, "duration_ms", time.Since(s.started).Milliseconds()}
+	if s.fields.path != "" {
+		args = append(args, "path", s.fields.path)
+	}
+	if s.fields.frameIndex >= 0 {
+		args = append(args, "frame_index", s.fields.frameIndex)
+	}
+	if err != nil {
+		args = append(args, "error", err)
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	slog.Debug("stage finished", args...)
+	s.span.End()
+	if s.region != nil {
+		s.region.End()
+	}
+	if s.task != nil {
+		s.task.End()
+	}
+}
@@ -0,0 +1,342 @@
+package thumber
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"gopkg.in/yaml.v3"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
+)
+
+//go:embed templates/*.json
+var shippedTemplates embed.FS
+
+// Template describes a sheet as bands stacked from top to bottom, one of which is the grid of tiles.
+// It's read from a JSON or YAML file, and the presets are shipped templates.
+type Template struct {
+	// Tiles, Columns and TileWidth fill in the options that aren't set, like a preset
+	Tiles     int `json:"tiles" yaml:"tiles"`
+	Columns   int `json:"columns" yaml:"columns"`
+	TileWidth int `json:"tile_width" yaml:"tile_width"`
+	// Background is the color of the canvas, defaults to the background of the theme
+	Background string `json:"background" yaml:"background"`
+	// Bands are drawn in order, a template without bands is just the grid
+	Bands []Band `json:"bands" yaml:"bands"`
+
+	background color.Color
+}
+
+// BandType is what a band of a template draws
+type BandType string
+
+const (
+	BandGrid  BandType = "grid"
+	BandText  BandType = "text"
+	BandImage BandType = "image"
+)
+
+// Band is a horizontal strip of a template
+type Band struct {
+	Type BandType `json:"type" yaml:"type"`
+	// Text is a Go template of a text band, executed with TemplateData
+	Text string `json:"text" yaml:"text"`
+	// Path is the image of an image band, relative to the template file
+	Path string `json:"path" yaml:"path"`
+	// Size is the font size of a text band in pt, defaults to 14
+	Size float64 `json:"size" yaml:"size"`
+	// Color of a text band, defaults to the text color of the theme
+	Color string `json:"color" yaml:"color"`
+	// Align places text and images, defaults to aligning right to left text to the right
+	Align TextAlign `json:"align" yaml:"align"`
+	// Height defaults to the line height of a text band, or the height of the image
+	Height int `json:"height" yaml:"height"`
+
+	text  *template.Template
+	color color.Color
+	image image.Image
+}
+
+// TemplateData is what the text of bands can refer to, e.g. {{.Name}} or {{size .Size}}.
+// Sizes, bitrates and durations are numbers, and functions size, bitrate and duration format them in the locale.
+type TemplateData struct {
+	HeaderInfo
+	// Page is the number of the sheet from 1, out of Pages
+	Page, Pages int
+	// Tiles is the number of tiles on the sheet, from First to Last
+	Tiles       int
+	First, Last time.Duration
+}
+
+// bandPadding is the space above and below the text of a band in px
+const bandPadding = 8
+
+// ParseTemplate reads a template in format json or yaml. Image paths are relative to dir.
+func ParseTemplate(data []byte, format, dir string) (*Template, error) {
+	var t Template
+	var err error
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&t)
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&t)
+	default:
+		return nil, fmt.Errorf("invalid template format %q, must be one of json, yaml", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if err := t.prepare(dir); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// LoadTemplate reads a template file, or a shipped template by name, e.g. titled
+func LoadTemplate(nameOrPath string) (*Template, error) {
+	if data, err := shippedTemplates.ReadFile(path.Join("templates", nameOrPath+".json")); err == nil {
+		return ParseTemplate(data, "json", "")
+	}
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		if os.IsNotExist(err) && filepath.Ext(nameOrPath) == "" {
+			return nil, fmt.Errorf("unknown template %q, must be a file or one of %s", nameOrPath, strings.Join(ShippedTemplates(), ", "))
+		}
+		return nil, err
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(nameOrPath)), ".")
+	return ParseTemplate(data, format, filepath.Dir(nameOrPath))
+}
+
+// ShippedTemplates lists the names of the templates built into thumber
+func ShippedTemplates() []string {
+	entries, _ := shippedTemplates.ReadDir("templates")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prepare checks the bands, and parses their colors, texts and images
+func (t *Template) prepare(dir string) error {
+	if len(t.Bands) == 0 {
+		t.Bands = []Band{{Type: BandGrid}}
+	}
+	if t.Background != "" {
+		c, err := ParseColor(t.Background)
+		if err != nil {
+			return fmt.Errorf("invalid template background: %w", err)
+		}
+		t.background = c
+	}
+
+	grids := 0
+	for i := range t.Bands {
+		b := &t.Bands[i]
+		if b.Height < 0 {
+			return fmt.Errorf("band %d: height cannot be negative", i+1)
+		}
+		switch b.Align {
+		case "", TextAlignAuto, TextAlignLeft, TextAlignRight:
+		default:
+			return fmt.Errorf("band %d: invalid align %q, must be one of auto, left, right", i+1, b.Align)
+		}
+		switch b.Type {
+		case BandGrid:
+			grids++
+		case BandText:
+			tmpl, err := template.New(fmt.Sprintf("band %d", i+1)).Funcs(templateFuncs(Locale{})).Parse(b.Text)
+			if err != nil {
+				return fmt.Errorf("band %d: %w", i+1, err)
+			}
+			b.text = tmpl
+			if b.Size == 0 {
+				b.Size = 14
+			}
+			if b.Color != "" {
+				if b.color, err = ParseColor(b.Color); err != nil {
+					return fmt.Errorf("band %d: invalid color: %w", i+1, err)
+				}
+			}
+		case BandImage:
+			p := b.Path
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			img, err := imaging.Open(p)
+			if err != nil {
+				return fmt.Errorf("band %d: failed to read image: %w", i+1, err)
+			}
+			b.image = img
+			if b.Height == 0 {
+				b.Height = img.Bounds().Dy()
+			}
+		default:
+			return fmt.Errorf("band %d: invalid type %q, must be one of grid, text, image", i+1, b.Type)
+		}
+	}
+	if grids != 1 {
+		return fmt.Errorf("template must have exactly one grid band, found %d", grids)
+	}
+	return nil
+}
+
+// Apply fills in the tile count, columns and tile width unless they're already set, and draws sheets with the template
+func (t *Template) Apply(opts ThumbOptions) ThumbOptions {
+	opts = t.applyGrid(opts)
+	opts.Template = t
+	return opts
+}
+
+func (t *Template) applyGrid(opts ThumbOptions) ThumbOptions {
+	if opts.TileCount == 0 && opts.Interval == 0 && len(opts.AtFrames) == 0 {
+		opts.TileCount = t.Tiles
+	}
+	if opts.TileColumns == 0 {
+		opts.TileColumns = t.Columns
+	}
+	if opts.TileWidth == 0 && opts.TileHeight == 0 && opts.MaxTileDimension == 0 {
+		opts.TileWidth = t.TileWidth
+	}
+	return opts
+}
+
+// needsInfo reports whether any band draws text, which may refer to the details of the video
+func (t *Template) needsInfo() bool {
+	for _, b := range t.Bands {
+		if b.Type == BandText {
+			return true
+		}
+	}
+	return false
+}
+
+func (b Band) renderer(l LayoutOptions) defaultRenderer {
+	fg := b.color
+	if fg == nil {
+		fg = l.Theme.orDefault().Text
+	}
+	return defaultRenderer{
+		Font:            fonts.RobotoMonoMedium,
+		Fallbacks:       l.FallbackFonts,
+		FontSizePt:      b.Size,
+		BackgroundColor: color.Transparent,
+		ForegroundColor: fg,
+	}
+}
+
+// height is how tall the band is drawn, which for text bands without a height is the line height of the font
+func (b Band) height(l LayoutOptions) (int, error) {
+	if b.Height > 0 || b.Type != BandText {
+		return b.Height, nil
+	}
+	sample, err := b.renderer(l).Render("Ag")
+	if err != nil {
+		return 0, err
+	}
+	return sample.Bounds().Dy() + 2*bandPadding, nil
+}
+
+// heights sums the heights of the bands above and below the grid
+func (t *Template) heights(l LayoutOptions) (above, below int, err error) {
+	grid := false
+	for _, b := range t.Bands {
+		if b.Type == BandGrid {
+			grid = true
+			continue
+		}
+		h, err := b.height(l)
+		if err != nil {
+			return 0, 0, err
+		}
+		if grid {
+			below += h
+		} else {
+			above += h
+		}
+	}
+	return above, below, nil
+}
+
+// place stacks the bands above the grid from the top of the sheet, and the bands below it from belowTop.
+// It returns their rectangles in order without the grid band, and expects heights to have succeeded.
+func (t *Template) place(l LayoutOptions, width, belowTop int) []image.Rectangle {
+	var rects []image.Rectangle
+	y := 0
+	for _, b := range t.Bands {
+		if b.Type == BandGrid {
+			y = belowTop
+			continue
+		}
+		h, _ := b.height(l)
+		rects = append(rects, image.Rect(0, y, width, y+h))
+		y += h
+	}
+	return rects
+}
+
+// drawBands draws the text and image bands into their rectangles
+func (t *Template) drawBands(canvas *image.NRGBA, rects []image.Rectangle, l LayoutOptions, data TemplateData) (*image.NRGBA, error) {
+	i := 0
+	for _, b := range t.Bands {
+		if b.Type == BandGrid {
+			continue
+		}
+		rect := rects[i]
+		i++
+		// bands keep clear of the sheet edges like the tiles
+		inner := image.Rect(rect.Min.X+l.Padding, rect.Min.Y, rect.Max.X-l.Padding, rect.Max.Y)
+		switch b.Type {
+		case BandText:
+			// cloned, as the functions of the shared template can't be swapped concurrently
+			tmpl, err := b.text.Clone()
+			if err != nil {
+				return canvas, err
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Funcs(templateFuncs(l.Locale)).Execute(&buf, data); err != nil {
+				return canvas, fmt.Errorf("failed to execute band template: %w", err)
+			}
+			if canvas, err = drawLabel(canvas, b.renderer(l), Label{Rect: inner, Text: buf.String()}, b.Align); err != nil {
+				return canvas, err
+			}
+		case BandImage:
+			img := imaging.Resize(b.image, 0, rect.Dy(), imaging.Lanczos)
+			x := inner.Min.X
+			if b.Align == TextAlignRight {
+				x = inner.Max.X - img.Bounds().Dx()
+			}
+			canvas = imaging.Overlay(canvas, img, image.Pt(x, rect.Min.Y), 1)
+		}
+	}
+	return canvas, nil
+}
+
+// templateFuncs format numbers in band texts in the locale
+func templateFuncs(l Locale) template.FuncMap {
+	return template.FuncMap{
+		"size":     func(bytes int64) string { return FormatSize(bytes, l) },
+		"bitrate":  func(bps int64) string { return FormatBitrate(bps, l) },
+		"duration": FormatDuration,
+		"number":   func(f float64, prec int) string { return l.formatFloat(f, prec) },
+		"upper":    strings.ToUpper,
+	}
+}
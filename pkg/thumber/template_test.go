@@ -0,0 +1,145 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	yaml := `tiles: 12
+columns: 3
+background: "#102030"
+bands:
+  - type: text
+    text: "{{.Name}}"
+    height: 30
+  - type: grid
+  - type: text
+    text: "{{.Page}}/{{.Pages}}"
+    align: right
+`
+	tmpl, err := ParseTemplate([]byte(yaml), "yaml", "")
+	require.NoError(t, err)
+	assert.Equal(t, 12, tmpl.Tiles)
+	assert.Equal(t, color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, tmpl.background)
+	require.Len(t, tmpl.Bands, 3)
+	assert.Equal(t, TextAlignRight, tmpl.Bands[2].Align)
+	assert.Equal(t, 14.0, tmpl.Bands[2].Size)
+
+	json := `{"columns": 2, "bands": [{"type": "grid"}, {"type": "text", "text": "{{duration .Last}}"}]}`
+	tmpl, err = ParseTemplate([]byte(json), "json", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, tmpl.Columns)
+	assert.Nil(t, tmpl.background, "the background of the theme")
+
+	tmpl, err = ParseTemplate([]byte(`{}`), "json", "")
+	require.NoError(t, err)
+	assert.Equal(t, []Band{{Type: BandGrid}}, tmpl.Bands, "a template without bands is just the grid")
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data, format string
+	}{
+		"no grid":       {`{"bands": [{"type": "text", "text": "x"}]}`, "json"},
+		"two grids":     {`{"bands": [{"type": "grid"}, {"type": "grid"}]}`, "json"},
+		"unknown type":  {`{"bands": [{"type": "video"}, {"type": "grid"}]}`, "json"},
+		"unknown field": {`{"rows": 3}`, "json"},
+		"yaml field":    {"rows: 3\n", "yaml"},
+		"bad text":      {`{"bands": [{"type": "text", "text": "{{.Name"}, {"type": "grid"}]}`, "json"},
+		"bad align":     {`{"bands": [{"type": "grid", "align": "center"}]}`, "json"},
+		"bad color":     {`{"background": "grey-ish"}`, "json"},
+		"missing image": {`{"bands": [{"type": "image", "path": "missing.png"}, {"type": "grid"}]}`, "json"},
+		"format":        {`tiles = 3`, "toml"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTemplate([]byte(tc.data), tc.format, t.TempDir())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	assert.Equal(t, []string{"dense", "quick", "standard", "titled"}, ShippedTemplates())
+	for _, name := range ShippedTemplates() {
+		_, err := LoadTemplate(name)
+		assert.NoError(t, err, name)
+	}
+
+	_, err := LoadTemplate("fancy")
+	assert.ErrorContains(t, err, "unknown template")
+
+	dir := t.TempDir()
+	require.NoError(t, imaging.Save(imaging.New(40, 20, color.White), filepath.Join(dir, "logo.png")))
+	path := filepath.Join(dir, "logo.yml")
+	require.NoError(t, os.WriteFile(path, []byte("bands:\n  - type: image\n    path: logo.png\n  - type: grid\n"), 0o644))
+	tmpl, err := LoadTemplate(path)
+	require.NoError(t, err)
+	assert.Equal(t, 20, tmpl.Bands[0].Height, "images are relative to the template")
+}
+
+func TestPresetsMatchTemplates(t *testing.T) {
+	opts, err := PresetStandard.Apply(ThumbOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, opts.TileCount, "picked from the duration")
+	assert.Equal(t, 8, opts.MinTiles)
+	assert.Equal(t, 20, opts.MaxTiles, "the tiles of the template")
+	assert.Equal(t, 4, opts.TileColumns)
+	assert.Nil(t, opts.Template, "presets only pick the grid")
+
+	opts, err = PresetDense.Apply(ThumbOptions{TileCount: 12})
+	require.NoError(t, err)
+	assert.Equal(t, 12, opts.TileCount)
+	assert.Zero(t, opts.MaxTiles, "explicit tile counts aren't bounded")
+
+	opts, err = PresetQuick.Apply(ThumbOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, opts.autoTileCount(20*time.Second), "short clips get a row")
+	assert.Equal(t, 9, opts.autoTileCount(2*time.Hour))
+
+	_, err = Preset("huge").Apply(ThumbOptions{})
+	assert.Error(t, err)
+
+	tmpl, err := LoadTemplate("titled")
+	require.NoError(t, err)
+	opts = tmpl.Apply(ThumbOptions{TileColumns: 6})
+	assert.Equal(t, 6, opts.TileColumns, "explicit options take precedence")
+	assert.Equal(t, 480, opts.TileWidth)
+	assert.Same(t, tmpl, opts.Template)
+}
+
+func TestComposeSheetWithTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate([]byte(`{
+		"background": "#ff0000",
+		"bands": [
+			{"type": "text", "text": "{{.Name}}", "height": 30},
+			{"type": "grid"},
+			{"type": "text", "text": "{{.Tiles}} tiles until {{duration .Last}}", "height": 20}
+		]
+	}`), "json", "")
+	require.NoError(t, err)
+
+	tiles := []Thumbnail{
+		{Image: imaging.New(100, 50, color.White), Timestamp: time.Second},
+		{Image: imaging.New(100, 50, color.White), Timestamp: 2 * time.Second},
+	}
+	opts := LayoutOptions{Columns: 2, Padding: 10, Template: tmpl, TemplateData: TemplateData{HeaderInfo: HeaderInfo{Name: "movie.mkv"}}}
+	plan, err := opts.Plan([]LayoutTile{{Size: image.Pt(100, 50)}, {Size: image.Pt(100, 50)}})
+	require.NoError(t, err)
+	assert.Equal(t, []image.Rectangle{image.Rect(0, 0, 230, 30), image.Rect(0, 100, 230, 120)}, plan.Bands)
+
+	sheet, err := ComposeSheet(tiles, opts)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 230, 120), sheet.Bounds())
+	assert.Equal(t, image.Rect(120, 40, 220, 90), sheet.Tiles[1].Rect)
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0xff}, sheet.Image.(*image.NRGBA).NRGBAAt(0, 35), "the background is the color of the template")
+	assert.NotEqual(t, color.NRGBA{R: 0xff, A: 0xff}, sheet.Image.(*image.NRGBA).NRGBAAt(12, 15), "text is drawn in the band")
+}
@@ -0,0 +1,170 @@
+package thumber
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is the palette of a sheet, shared by the header, tiles and overlays. Unset colors keep the defaults:
+// a black canvas, white text, no tile borders and timestamps without a badge.
+type Theme struct {
+	// Background fills the canvas around and between the tiles
+	Background color.Color
+	// Border outlines each tile in the padding around it
+	Border color.Color
+	// Text is the color of the header, labels, captions and template bands
+	Text color.Color
+	// Badge is the background of timestamps on tiles, unless LayoutOptions.TimestampBackground is set
+	Badge color.Color
+	// BadgeText is the color of timestamps on tiles
+	BadgeText color.Color
+}
+
+var (
+	ThemeDark = Theme{
+		Background: color.RGBA{A: 0xff},
+		Border:     color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff},
+		Text:       color.White,
+		Badge:      color.RGBA{A: 0x99},
+		BadgeText:  color.White,
+	}
+	ThemeLight = Theme{
+		Background: color.White,
+		Border:     color.RGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff},
+		Text:       color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff},
+		// premultiplied, white at 75%
+		Badge:     color.RGBA{R: 0xbf, G: 0xbf, B: 0xbf, A: 0xbf},
+		BadgeText: color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff},
+	}
+)
+
+// defaultTheme is how sheets looked before themes, the transparent canvas is black in JPEGs
+var defaultTheme = Theme{
+	Background: color.RGBA{},
+	Text:       color.White,
+	Badge:      color.Transparent,
+	BadgeText:  color.White,
+}
+
+// themeFile is a custom theme, where colors are strings for ParseColor and unset colors are taken from the base theme
+type themeFile struct {
+	// Base is dark or light, defaults to dark
+	Base       string `yaml:"base"`
+	Background string `yaml:"background"`
+	Border     string `yaml:"border"`
+	Text       string `yaml:"text"`
+	Badge      string `yaml:"badge"`
+	BadgeText  string `yaml:"badge_text"`
+}
+
+// LoadTheme picks the dark or light theme, or reads a custom theme from a YAML file like:
+//
+//	base: light
+//	background: "#fdf6e3"
+//	badge_text: navy
+func LoadTheme(nameOrPath string) (Theme, error) {
+	if t, ok := builtinTheme(nameOrPath); ok {
+		return t, nil
+	}
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		if os.IsNotExist(err) && filepath.Ext(nameOrPath) == "" {
+			return Theme{}, fmt.Errorf("unknown theme %q, must be a file or one of dark, light", nameOrPath)
+		}
+		return Theme{}, err
+	}
+	return ParseTheme(data)
+}
+
+// ParseTheme reads a custom theme in YAML, which includes JSON
+func ParseTheme(data []byte) (Theme, error) {
+	var f themeFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse theme: %w", err)
+	}
+
+	base := "dark"
+	if f.Base != "" {
+		base = f.Base
+	}
+	t, ok := builtinTheme(base)
+	if !ok {
+		return Theme{}, fmt.Errorf("invalid base theme %q, must be one of dark, light", f.Base)
+	}
+	for _, c := range []struct {
+		name  string
+		value string
+		dst   *color.Color
+	}{
+		{"background", f.Background, &t.Background},
+		{"border", f.Border, &t.Border},
+		{"text", f.Text, &t.Text},
+		{"badge", f.Badge, &t.Badge},
+		{"badge_text", f.BadgeText, &t.BadgeText},
+	} {
+		if c.value == "" {
+			continue
+		}
+		parsed, err := ParseColor(c.value)
+		if err != nil {
+			return Theme{}, fmt.Errorf("invalid %s color: %w", c.name, err)
+		}
+		*c.dst = parsed
+	}
+	return t, nil
+}
+
+func builtinTheme(name string) (Theme, bool) {
+	switch strings.ToLower(name) {
+	case "dark":
+		return ThemeDark, true
+	case "light":
+		return ThemeLight, true
+	}
+	return Theme{}, false
+}
+
+// orDefault fills in the unset colors
+func (t Theme) orDefault() Theme {
+	for _, c := range []struct {
+		dst *color.Color
+		def color.Color
+	}{
+		{&t.Background, defaultTheme.Background},
+		{&t.Text, defaultTheme.Text},
+		{&t.Badge, defaultTheme.Badge},
+		{&t.BadgeText, defaultTheme.BadgeText},
+	} {
+		if *c.dst == nil {
+			*c.dst = c.def
+		}
+	}
+	return t
+}
+
+// drawBorder outlines rect with a line of the border color just outside it
+func (t Theme) drawBorder(canvas draw.Image, rect image.Rectangle) {
+	if t.Border == nil {
+		return
+	}
+	border := &image.Uniform{C: t.Border}
+	outer := rect.Inset(-1)
+	for _, r := range []image.Rectangle{
+		image.Rect(outer.Min.X, outer.Min.Y, outer.Max.X, rect.Min.Y),
+		image.Rect(outer.Min.X, rect.Max.Y, outer.Max.X, outer.Max.Y),
+		image.Rect(outer.Min.X, rect.Min.Y, rect.Min.X, rect.Max.Y),
+		image.Rect(rect.Max.X, rect.Min.Y, outer.Max.X, rect.Max.Y),
+	} {
+		draw.Draw(canvas, r.Intersect(canvas.Bounds()), border, image.Point{}, draw.Over)
+	}
+}
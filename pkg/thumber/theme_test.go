@@ -0,0 +1,59 @@
+package thumber
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := LoadTheme("light")
+	require.NoError(t, err)
+	assert.Equal(t, ThemeLight, theme)
+
+	_, err = LoadTheme("solarized")
+	assert.ErrorContains(t, err, "unknown theme")
+
+	path := filepath.Join(t.TempDir(), "sepia.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("base: light\nbackground: \"#f4ecd8\"\nbadge_text: navy\n"), 0o644))
+	theme, err = LoadTheme(path)
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0xf4, G: 0xec, B: 0xd8, A: 0xff}, theme.Background)
+	assert.Equal(t, ThemeLight.Text, theme.Text, "unset colors are taken from the base")
+	assert.Equal(t, color.RGBA{B: 0x80, A: 0xff}, theme.BadgeText)
+}
+
+func TestParseThemeErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"base":  "base: sepia\n",
+		"color": "text: not-a-color\n",
+		"field": "foreground: white\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTheme([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestComposeSheetWithTheme(t *testing.T) {
+	tiles := []Thumbnail{{Image: imaging.New(100, 50, color.Black)}, {Image: imaging.New(100, 50, color.Black)}}
+	sheet, err := ComposeSheet(tiles, LayoutOptions{Columns: 2, Padding: 10, Theme: ThemeLight})
+	require.NoError(t, err)
+	img := sheet.Image.(*image.NRGBA)
+
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.NRGBAAt(0, 0), "background")
+	assert.Equal(t, color.NRGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}, img.NRGBAAt(9, 30), "border left of the tile")
+	assert.Equal(t, color.NRGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}, img.NRGBAAt(50, 60), "border under the tile")
+	assert.Equal(t, color.NRGBA{A: 0xff}, img.NRGBAAt(10, 10), "the frame isn't covered")
+
+	sheet, err = ComposeSheet(tiles, LayoutOptions{Columns: 2, Padding: 10})
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{}, sheet.Image.(*image.NRGBA).NRGBAAt(9, 30), "no border without a theme")
+}
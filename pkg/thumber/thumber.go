@@ -8,15 +8,20 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/freetype-go/freetype"
+	"github.com/BurntSushi/freetype-go/freetype/raster"
 	"github.com/BurntSushi/freetype-go/freetype/truetype"
 	"github.com/disintegration/imaging"
 	"github.com/sourcegraph/conc/pool"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 	"golang.org/x/image/draw"
@@ -26,148 +31,575 @@ import (
 
 func checkFfmpegInstalled() error {
 	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		return fmt.Errorf("ffmpeg not installed or not in PATH")
+		return ErrFfmpegNotFound
 	}
 	if _, err := exec.LookPath("ffprobe"); err != nil {
-		return fmt.Errorf("ffprobe not installed or not in PATH")
+		return ErrFfprobeNotFound
 	}
 
 	return nil
 }
 
-func extractThumbnail(ctx context.Context, filename string, timestamp time.Duration, width, height int) (Thumbnail, error) {
-	if width == 0 {
-		width = -1
-	} else if height == 0 {
-		height = -1
+func videoFilter(opts ThumbOptions) string {
+	// showinfo logs the pts of the decoded frame, see parseFramePTS
+	filters := []string{"showinfo"}
+	for i, r := range opts.BlurRegions {
+		filters = append(filters, r.blurFilter(i))
+	}
+	if opts.Crop != nil {
+		filters = append(filters, opts.Crop.cropFilter())
+	}
+	if opts.DetailRow {
+		filters = append(filters, fmt.Sprintf("split[main][detail];[detail]%s[detailout];[main]%s[mainout];[mainout][detailout]vstack", detailFilter(opts), scaleFilter(opts)))
+	} else {
+		filters = append(filters, scaleFilter(opts))
 	}
 
-	cmd := exec.CommandContext(
-		ctx,
-		"ffmpeg",
-		"-ss", fmt.Sprintf("%dms", timestamp.Milliseconds()),
-		"-i", filename,
-		"-vf", fmt.Sprintf("scale=%d:%d", width, height),
-		"-vframes", "1",
-		"-q:v", "1",
-		"-f", "image2",
-		"pipe:1",
-	)
+	return strings.Join(filters, ",")
+}
 
-	output, err := cmd.Output()
-	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			return Thumbnail{}, fmt.Errorf("failed to run ffmpeg: %w\nstderr=%s", err, string(exitErr.Stderr))
+// detailFilter returns a filter chain that renders a detail strip as wide as the tile.
+// Without a DetailRegion, it's a 100% crop from the center of the frame.
+func detailFilter(opts ThumbOptions) string {
+	width, height := opts.TileWidth, opts.detailHeight()
+	pad := fmt.Sprintf("pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2", width, height)
+	if opts.DetailRegion != nil {
+		return fmt.Sprintf("%s,scale=%d:%d:force_original_aspect_ratio=decrease,%s", opts.DetailRegion.cropFilter(), width, height, pad)
+	}
+	return fmt.Sprintf("crop=w='min(iw,%d)':h='min(ih,%d)',%s", width, height, pad)
+}
+
+// extractThumbnail extracts the frame at timestamp, and reads its timecode with ThumbOptions.TimecodeRegion
+func extractThumbnail(ctx context.Context, filename string, timestamp time.Duration, filter string, opts ThumbOptions) (Thumbnail, error) {
+	var outputs []string
+	var timecodePath string
+	if opts.TimecodeRegion != nil {
+		dir, err := os.MkdirTemp("", "thumber-timecode-")
+		if err != nil {
+			return Thumbnail{}, err
 		}
-		return Thumbnail{}, fmt.Errorf("failed to run ffmpeg: %w", err)
+		defer os.RemoveAll(dir)
+		ctx = allowWrites(ctx, dir)
+		timecodePath = filepath.Join(dir, "timecode.png")
+		outputs = timecodeOutput(ctx, filename, *opts.TimecodeRegion, timecodePath, opts)
+	}
+	data, actual, err := extractFrame(ctx, filename, timestamp, filter, opts, outputs...)
+	if err != nil && timecodePath != "" && ctx.Err() == nil {
+		// e.g. the region is outside of the frame, which shouldn't cost the tile
+		slog.Warn("failed to extract the timecode region, extracting the frame without it", "timestamp", timestamp, "error", err)
+		timecodePath = ""
+		data, actual, err = extractFrame(ctx, filename, timestamp, filter, opts)
+	}
+	if err != nil {
+		return Thumbnail{}, err
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(output))
+	img, err := opts.Limits.decodeImage(data)
 	if err != nil {
 		return Thumbnail{}, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	return Thumbnail{Image: img, Timestamp: timestamp}, nil
+	th := Thumbnail{Image: img, Timestamp: actual, RequestedTimestamp: timestamp}
+	if opts.DetailRow {
+		th.DetailHeight = opts.detailHeight()
+	}
+	if opts.KeepJPEG && opts.Intermediate == IntermediateJPEG {
+		th.JPEG = data
+	}
+	if timecodePath != "" {
+		recordTimecode(ctx, &th, timecodePath)
+	}
+	return th, nil
 }
 
-func readDuration(ctx context.Context, videoPath string) (time.Duration, error) {
-	cmd := exec.CommandContext(
-		ctx,
-		"ffprobe",
-		"-v", "error",
-		"-show_entries",
-		"format=duration",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		videoPath,
+// extractFrame returns the frame at timestamp as encoded by ffmpeg in opts.Intermediate, along with its actual presentation time.
+// outputs are more ffmpeg outputs of the same decoded frame, e.g. timecodeOutput.
+func extractFrame(ctx context.Context, filename string, timestamp time.Duration, filter string, opts ThumbOptions, outputs ...string) (_ []byte, _ time.Duration, err error) {
+	ctx, stage := startStage(ctx, "extract", attribute.Int64("thumber.timestamp_ms", timestamp.Milliseconds()))
+	defer func() { stage.End(err) }()
+
+	limits := opts.Limits
+	args := []string{"-hide_banner"}
+	args = append(args, limits.inputArgs()...)
+	if opts.Seek == SeekFast {
+		// use the keyframe before the timestamp instead of decoding up to it
+		args = append(args, "-noaccurate_seek")
+	}
+	args = append(args,
+		"-ss", fmt.Sprintf("%dms", timestamp.Milliseconds()),
+		"-i", filename,
 	)
+	args = append(args, variantArgs(ctx, filename, opts.Variant)...)
+	args = append(args,
+		"-vf", filter,
+		"-vframes", "1",
+	)
+	args = append(args, opts.Intermediate.codecArgs()...)
+	args = append(args, limits.outputArgs()...)
+	args = append(args, "-f", "image2", "pipe:1")
+	args = append(args, outputs...)
 
-	out, err := cmd.Output()
+	// the source is locked first, so waiting for it doesn't hold a worker other files could use
+	unlock, err := lockSource(ctx, filename, opts.IO)
 	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			return 0, fmt.Errorf("failed to run ffprobe: %w\nstderr=%s", err, string(exitErr.Stderr))
+		return nil, 0, err
+	}
+	defer unlock()
+	if err := opts.Workers.acquire(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer opts.Workers.release()
+	cmd := command(ctx, "ffmpeg", args...)
+
+	stdout := limits.outputBuffer()
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := runLimited(cmd, limits); err != nil {
+		if err := stdout.err(); err != nil {
+			return nil, 0, err
 		}
-		return 0, fmt.Errorf("failed to run ffprobe: %w", err)
+		return nil, 0, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: err}
+	}
+	if stdout.Len() == 0 {
+		return nil, 0, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: fmt.Errorf("no frame at %s", timestamp)}
+	}
+
+	// seeking lands on the first frame at or after the requested timestamp, which can drift for variable frame rate videos
+	actual := timestamp
+	pts, ok := parseFramePTS(stderr.String())
+	if ok {
+		actual = timestamp + pts
+		stage.span.SetAttributes(attribute.Int64("thumber.pts_ms", actual.Milliseconds()))
+	} else {
+		slog.Debug("failed to read frame pts, using requested timestamp", "timestamp", timestamp)
+	}
+	if opts.PTSTolerance > 0 {
+		checkPTS(ctx, timestamp, actual, ok, opts.PTSTolerance)
 	}
 
-	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	return stdout.Bytes(), actual, nil
+}
+
+var ptsTimePattern = regexp.MustCompile(`pts_time:\s*(-?[0-9.]+)`)
+
+// parseFramePTS extracts the pts of the first frame logged by the showinfo filter.
+// Since the input is seeked with -ss, it's relative to the seek point.
+func parseFramePTS(log string) (time.Duration, bool) {
+	m := ptsTimePattern.FindStringSubmatch(log)
+	if m == nil {
+		return 0, false
+	}
+	pts, err := parseSeconds(m[1])
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse seconds: %w", err)
+		return 0, false
 	}
+	return pts, true
+}
 
-	return time.Second * time.Duration(seconds), nil
+// checkPTS warns when the decoded frame at actual is further than tolerance from the requested timestamp,
+// or when its pts couldn't be read, and reports whether the frame passed
+func checkPTS(ctx context.Context, requested, actual time.Duration, ok bool, tolerance time.Duration) bool {
+	path := stageFieldsFrom(ctx).path
+	if !ok {
+		slog.Warn("failed to verify frame pts", "path", path, "timestamp", requested)
+		return false
+	}
+	deviation := actual - requested
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	if deviation > tolerance {
+		slog.Warn("frame pts deviates from requested timestamp", "path", path, "timestamp", requested, "pts", actual, "deviation", deviation, "tolerance", tolerance)
+		return false
+	}
+	return true
 }
 
 type ThumbOptions struct {
-	From                time.Duration
-	To                  time.Duration
-	TileColumns         int
-	TileCount           int
-	Interval            time.Duration
-	TileWidth           int
-	TileHeight          int
+	From time.Duration
+	// DefaultFrom is the starting point when From isn't set, e.g. to skip intros. Videos and ranges up to To
+	// that end before it start at 0 instead.
+	DefaultFrom time.Duration
+	To          time.Duration
+	TileColumns int
+	TileCount   int
+	Interval    time.Duration
+	// MinTiles and MaxTiles bound the tile count picked from the duration without TileCount or Interval,
+	// 4 and 30 if unset. A single row may still have more than MaxTiles.
+	MinTiles int
+	MaxTiles int
+	// AtFrames extracts these frame numbers, counted from 0 at the average frame rate, instead of spreading tiles.
+	// Frame numbers are exact for constant frame rate videos only.
+	AtFrames []int
+	// TileWidth and TileHeight are the size of tiles in px. Setting one scales the other with the aspect ratio.
+	// Without either or MaxTileDimension, tiles are DefaultTileWidth wide
+	TileWidth  int
+	TileHeight int
+	// MaxTileDimension keeps frames at source resolution when neither TileWidth nor TileHeight is set,
+	// scaling them down so their longer side is at most this many px
+	MaxTileDimension int
+	// DimensionMultiple rounds tile and sheet sizes to a multiple of this many px,
+	// e.g. 2 for video encoders that need even dimensions with 4:2:0 chroma subsampling
+	DimensionMultiple int
+	// TileAspect derives the tile height from the width or the other way around, and defaults Fit to cover
+	TileAspect          AspectRatio
 	OverlayTimestamps   bool
 	TimestampBackground color.Color
-	Padding             int
+	TimestampOrigin     TimestampOrigin
+	Annotations         []Annotation
+	TimecodeRegion      *Region
+	QRMarkers           bool
+	// MotionHeatmap measures motion between tiles in an extra low resolution pass,
+	// and borders each tile from blue for still to red for the most motion
+	MotionHeatmap bool
+	// AudioTimeline measures silences and loudness in an extra pass, and draws them in a strip under each sheet
+	AudioTimeline bool
+	// Header draws the file name, size, duration and video details from HeaderInfo above the tiles
+	Header bool
+	// Locale is the language of the header and other text drawn on sheets, defaults to English
+	Locale Locale
+	// Theme colors the canvas, tile borders, text and timestamp badges, e.g. ThemeDark or from LoadTheme
+	Theme Theme
+	// Template draws bands of text and images around the tiles, see Template.Apply
+	Template *Template
+	// FallbackFonts draw characters of captions, labels and the header the bundled font has no glyph for, in order
+	FallbackFonts []*truetype.Font
+	// TextAlign aligns the header and chapter titles, defaults to aligning right to left text to the right
+	TextAlign          TextAlign
+	Layout             Layout
+	Padding            int
+	BlurRegions        []Region
+	Crop               *Region
+	Fit                Fit
+	PadColor           color.Color
+	PadBlur            bool
+	DetailRow          bool
+	DetailRegion       *Region
+	DetailHeight       int
+	MaxCanvasDimension int
+	OnOversize         Oversize
+	Seek               SeekMode
+	// Variant picks the rendition of HLS and DASH sources to extract frames from, e.g. VariantLowest
+	Variant Variant
+	// IO controls whether frames of the same file or mount are read in parallel, defaults to IOParallel
+	IO IOMode
+	// Jitter moves each tile by a random offset within ±Jitter, at most half the interval, so episodes of a series
+	// don't all show the same recap or title card at the same offsets
+	Jitter time.Duration
+	// Seed makes random choices such as Jitter the same on every run, unset picks a new seed each time
+	Seed *int64
+	// PTSTolerance warns about frames whose decoded presentation time is further than this from the requested timestamp,
+	// e.g. with SeekFast, which lands on the keyframe before it. Zero doesn't check.
+	PTSTolerance time.Duration
+	// Intermediate is the codec frames are piped from ffmpeg in, defaults to JPEG.
+	// The lossless codecs avoid compressing frames twice before the sheet is encoded
+	Intermediate Intermediate
+	// KeepJPEG keeps the JPEG ffmpeg encoded each frame in next to the decoded image, so SaveTiles can write it as is.
+	// Otherwise it's dropped once decoded, as it would double the memory of each frame
+	KeepJPEG bool
+	// MaxTilesPerSheet splits the tiles over several sheets in GenerateSheets, 0 puts all tiles on one sheet
+	MaxTilesPerSheet int
+	// SheetDuration splits tiles into one sheet per window of this length, counted from the start of the video,
+	// e.g. one sheet per 10 minutes of a long recording. It can't be combined with MaxTilesPerSheet
+	SheetDuration    time.Duration
+	ShortVideoPolicy ShortVideoPolicy
+	SkipUnreadable   bool
+	AlignKeyframes   bool
+	// SnapKeyframes moves each tile to the nearest keyframe within a quarter of the interval, if there's one,
+	// which is almost as fast as AlignKeyframes while keeping tiles evenly spaced
+	SnapKeyframes bool
+	Concurrency   int
+	// Workers is shared with other generations to limit their ffmpeg processes together, on top of Concurrency
+	Workers *Workers
+	// Progress is called as the stages of each file start and frames are extracted, from several goroutines
+	// but never concurrently for the same file
+	Progress func(Progress)
+	// Interrupt stops extraction when it's closed, e.g. on Ctrl-C, and sheets are made of the frames extracted so far.
+	// Canceling the context instead fails the generation.
+	Interrupt <-chan struct{}
+	Limits    ProcessLimits
+	Media     *MediaInfo
 }
 
-func ParseColor(hex string) (color.Color, error) {
-	switch hex {
-	case "transparent":
-		return color.Transparent, nil
+// concurrency is how many frames are extracted in parallel
+func (o ThumbOptions) concurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
 	}
+	return 4
+}
+
+// ShortVideoPolicy controls what happens when the interval is longer than the video
+type ShortVideoPolicy string
+
+const (
+	ShortVideoError ShortVideoPolicy = "error"
+	// ShortVideoShrink shrinks the interval to fit a single row of tiles
+	ShortVideoShrink ShortVideoPolicy = "shrink"
+	// ShortVideoSpread ignores the interval and spreads tiles evenly based on the duration
+	ShortVideoSpread ShortVideoPolicy = "spread"
+)
+
+// SeekMode controls how precisely frames are seeked to
+type SeekMode string
+
+const (
+	// SeekAccurate decodes from the keyframe before the timestamp up to the timestamp
+	SeekAccurate SeekMode = "accurate"
+	// SeekFast uses the keyframe before the timestamp, which is much faster for videos with sparse keyframes
+	SeekFast SeekMode = "fast"
+)
+
+// TimestampOrigin controls what overlaid timestamps are measured from
+type TimestampOrigin string
+
+const (
+	// TimestampAbsolute shows the position of the frame in the video
+	TimestampAbsolute TimestampOrigin = "absolute"
+	// TimestampRelative shows the offset of the frame from the starting point, so excerpts start at 00:00
+	TimestampRelative TimestampOrigin = "relative"
+)
+
+// Oversize controls what happens when the contact sheet exceeds MaxCanvasDimension
+type Oversize string
+
+const (
+	OversizeError Oversize = "error"
+	OversizeScale Oversize = "scale"
+)
+
+// maxJPEGDimension is slightly below the 65535 px limit of the format, as some decoders reject larger images
+const maxJPEGDimension = 65500
 
-	// Remove hash symbol if present
-	if hex[0] == '#' {
-		hex = hex[1:]
+func (o ThumbOptions) detailHeight() int {
+	if o.DetailHeight != 0 {
+		return roundToMultiple(o.DetailHeight, o.dimensionMultiple())
 	}
+	return roundToMultiple(o.TileWidth/2, o.dimensionMultiple())
+}
 
-	var r, g, b, a int64
+func (o ThumbOptions) dimensionMultiple() int {
+	if o.DimensionMultiple > 1 {
+		return o.DimensionMultiple
+	}
+	return 1
+}
 
-	if len(hex) == 6 {
-		// RGB only
-		r, _ = strconv.ParseInt(hex[0:2], 16, 64)
-		g, _ = strconv.ParseInt(hex[2:4], 16, 64)
-		b, _ = strconv.ParseInt(hex[4:6], 16, 64)
-		a = 255 // Default alpha: 100%
-	} else if len(hex) == 8 {
-		// RGB + alpha
-		r, _ = strconv.ParseInt(hex[0:2], 16, 64)
-		g, _ = strconv.ParseInt(hex[2:4], 16, 64)
-		b, _ = strconv.ParseInt(hex[4:6], 16, 64)
-		a, _ = strconv.ParseInt(hex[6:8], 16, 64)
-	} else {
-		return color.RGBA{}, fmt.Errorf("invalid hex color string: %s", hex)
+// roundToMultiple rounds n to the nearest multiple of m, but not below m
+func roundToMultiple(n, m int) int {
+	n = (n + m/2) / m * m
+	if n < m {
+		return m
 	}
+	return n
+}
 
-	return color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}, nil
+// DefaultTileColumns is used for contact sheets when TileColumns isn't set, and DefaultTileWidth when no tile size or limit is
+const (
+	DefaultTileColumns = 3
+	DefaultTileWidth   = 540
+)
+
+// withDefaults resolves unset options of a contact sheet to their defaults
+func (o ThumbOptions) withDefaults() ThumbOptions {
+	if o.TileColumns == 0 {
+		o.TileColumns = DefaultTileColumns
+	}
+	if o.TileWidth == 0 && o.TileHeight == 0 && o.MaxTileDimension == 0 {
+		o.TileWidth = DefaultTileWidth
+	}
+	if m := o.dimensionMultiple(); m > 1 {
+		if o.TileWidth > 0 {
+			o.TileWidth = roundToMultiple(o.TileWidth, m)
+		}
+		if o.TileHeight > 0 {
+			o.TileHeight = roundToMultiple(o.TileHeight, m)
+		}
+	}
+	o = o.withTileAspect()
+	if o.Fit == "" {
+		o.Fit = FitStretch
+	}
+	if o.Seek == "" {
+		o.Seek = SeekAccurate
+	}
+	if o.IO == "" {
+		o.IO = IOParallel
+	}
+	if o.Intermediate == "" {
+		o.Intermediate = IntermediateJPEG
+	}
+	if o.TimestampOrigin == "" {
+		o.TimestampOrigin = TimestampAbsolute
+	}
+	if o.OnOversize == "" {
+		o.OnOversize = OversizeError
+	}
+	if o.ShortVideoPolicy == "" {
+		o.ShortVideoPolicy = ShortVideoError
+	}
+	return o
 }
 
+// Validate reports every problem with the options at once, after resolving defaults
 func (o ThumbOptions) Validate() error {
-	if o.From != 0 && o.To != 0 && o.From > o.To {
-		return fmt.Errorf("starting point cannot be after ending point")
+	given := o
+	o = o.withDefaults()
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
 	}
-	if o.Interval != 0 && o.TileCount != 0 {
-		return fmt.Errorf("interval and tile count cannot be set together")
+
+	if !given.TileAspect.IsSource() {
+		check(given.TileAspect.W > 0 && given.TileAspect.H > 0, "tile aspect ratio must be positive")
+		check(given.TileWidth == 0 || given.TileHeight == 0, "tile aspect ratio cannot be set together with both tile width and height")
+		check(given.MaxTileDimension == 0, "tile aspect ratio cannot be set together with max tile dimension")
 	}
 
-	return nil
+	check(o.From >= 0, "starting point cannot be negative")
+	check(o.DefaultFrom >= 0, "default starting point cannot be negative")
+	check(o.To >= 0, "ending point cannot be negative")
+	check(o.PTSTolerance >= 0, "pts tolerance cannot be negative")
+	check(o.Jitter >= 0, "jitter cannot be negative")
+	check(o.Jitter == 0 || len(o.AtFrames) == 0, "frame numbers cannot be jittered")
+	check(o.To == 0 || o.From <= o.To, "starting point cannot be after ending point")
+	check(o.Interval >= 0, "interval cannot be negative")
+	check(o.TileCount >= 0, "tile count cannot be negative")
+	check(o.Interval == 0 || o.TileCount == 0, "interval and tile count cannot be set together")
+	check(o.MinTiles >= 0 && o.MaxTiles >= 0, "tile bounds cannot be negative")
+	check(o.MaxTiles == 0 || o.MinTiles <= o.MaxTiles, "min tiles cannot be more than max tiles")
+	check(!o.AlignKeyframes || !o.SnapKeyframes, "tiles cannot be both aligned and snapped to keyframes")
+	if len(o.AtFrames) > 0 {
+		check(o.Interval == 0 && o.TileCount == 0, "frame numbers cannot be set together with interval or tile count")
+		check(!o.AlignKeyframes, "frame numbers cannot be aligned to keyframes")
+		check(!o.SnapKeyframes, "frame numbers cannot be snapped to keyframes")
+		for _, n := range o.AtFrames {
+			check(n >= 0, "frame number %d cannot be negative", n)
+		}
+	}
+	check(o.TileColumns > 0, "tile columns must be positive")
+	check(o.TileWidth >= 0, "tile width cannot be negative")
+	check(o.TileHeight >= 0, "tile height cannot be negative")
+	check(o.MaxTileDimension >= 0, "max tile dimension cannot be negative")
+	check(o.DimensionMultiple >= 0, "dimension multiple cannot be negative")
+	check(o.MaxTileDimension == 0 || (o.TileWidth == 0 && o.TileHeight == 0), "max tile dimension cannot be set together with tile width or height")
+	check(o.Padding >= 0, "padding cannot be negative")
+	check(o.MaxCanvasDimension >= 0, "max canvas dimension cannot be negative")
+	check(o.MaxTilesPerSheet >= 0, "max tiles per sheet cannot be negative")
+	check(o.SheetDuration >= 0, "sheet duration cannot be negative")
+	check(o.SheetDuration == 0 || o.MaxTilesPerSheet == 0, "sheet duration cannot be set together with max tiles per sheet")
+	check(o.Concurrency >= 0, "concurrency cannot be negative")
+	check(o.DetailHeight >= 0, "detail height cannot be negative")
+	check(!o.DetailRow || o.TileWidth > 0, "detail row requires tile width")
+
+	if _, err := ParseFit(string(o.Fit)); err != nil {
+		errs = append(errs, err)
+	}
+	switch o.Seek {
+	case SeekAccurate, SeekFast:
+	default:
+		errs = append(errs, fmt.Errorf("invalid seek mode %q, must be one of accurate, fast", o.Seek))
+	}
+	if _, err := o.Variant.minHeight(); err != nil {
+		errs = append(errs, err)
+	}
+	switch o.IO {
+	case IOParallel, IOSequential, IOMount:
+	default:
+		errs = append(errs, fmt.Errorf("invalid io mode %q, must be one of parallel, sequential, mount", o.IO))
+	}
+	switch o.TextAlign {
+	case "", TextAlignAuto, TextAlignLeft, TextAlignRight:
+	default:
+		errs = append(errs, fmt.Errorf("invalid text align %q, must be one of auto, left, right", o.TextAlign))
+	}
+	switch o.Intermediate {
+	case IntermediateJPEG, IntermediatePNG, IntermediatePPM:
+	default:
+		errs = append(errs, fmt.Errorf("invalid intermediate codec %q, must be one of jpeg, png, ppm", o.Intermediate))
+	}
+	switch o.TimestampOrigin {
+	case TimestampAbsolute, TimestampRelative:
+	default:
+		errs = append(errs, fmt.Errorf("invalid timestamp origin %q, must be one of absolute, relative", o.TimestampOrigin))
+	}
+	switch o.OnOversize {
+	case OversizeError, OversizeScale:
+	default:
+		errs = append(errs, fmt.Errorf("invalid oversize policy %q, must be one of error, scale", o.OnOversize))
+	}
+	switch o.ShortVideoPolicy {
+	case ShortVideoError, ShortVideoShrink, ShortVideoSpread:
+	default:
+		errs = append(errs, fmt.Errorf("invalid short video policy %q, must be one of error, shrink, spread", o.ShortVideoPolicy))
+	}
+
+	if o.Crop != nil {
+		if err := o.Crop.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid crop: %w", err))
+		}
+	}
+	if o.DetailRegion != nil {
+		if err := o.DetailRegion.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid detail region: %w", err))
+		}
+	}
+	for _, r := range o.BlurRegions {
+		if err := r.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid blur region: %w", err))
+		}
+	}
+	if o.TimecodeRegion != nil {
+		if err := o.TimecodeRegion.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid timecode region: %w", err))
+		}
+	}
+	for _, a := range o.Annotations {
+		if err := a.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 type Thumbnail struct {
 	image.Image
+	// Timestamp is the presentation time of the extracted frame
 	Timestamp time.Duration
+	// RequestedTimestamp is where the frame was seeked to
+	RequestedTimestamp time.Duration
+	// JPEG is the frame as ffmpeg encoded it, it's not updated when Image is changed.
+	// It's nil unless ThumbOptions.KeepJPEG is set and ThumbOptions.Intermediate is JPEG
+	JPEG []byte
+	// Caption is rendered under the tile, it's set from ThumbOptions.Annotations
+	Caption string
+	// Timecode is the burned-in timecode read from ThumbOptions.TimecodeRegion, empty if it couldn't be read
+	Timecode string
+	// Motion is the amount of motion from the frame up to the next tile, from 0 for none to 1 for the most of any tile.
+	// It's set with ThumbOptions.MotionHeatmap
+	Motion float64
+	// DetailHeight is the height of the detail strip stacked under the frame with ThumbOptions.DetailRow, 0 without one
+	DetailHeight int
+}
+
+// resize scales the image and its detail strip to width and height
+func (t *Thumbnail) resize(width, height int) {
+	t.DetailHeight = t.DetailHeight * height / t.Bounds().Dy()
+	t.Image = imaging.Resize(t.Image, width, height, imaging.Lanczos)
 }
 
-func (t *Thumbnail) overlayTimestamp(r timestampRenderer) error {
-	textImg, err := r.Render(formatDuration(t.Timestamp))
+func (t *Thumbnail) overlayTimestamp(r timestampRenderer, ts time.Duration) error {
+	textImg, err := r.Render(FormatDuration(ts))
 	if err != nil {
 		return err
 	}
-	padding := 10 // from the edges of the tile
+	padding := 10 // from the edges of the frame, above the detail strip
 	x := t.Image.Bounds().Dx() - textImg.Bounds().Dx() - padding
-	y := t.Image.Bounds().Dy() - textImg.Bounds().Dy() - padding
+	y := t.Image.Bounds().Dy() - t.DetailHeight - textImg.Bounds().Dy() - padding
 	opacity := float64(1)
 	t.Image = imaging.Overlay(t.Image, textImg, image.Pt(x, y), opacity)
 
@@ -179,7 +611,9 @@ type timestampRenderer interface {
 }
 
 type defaultRenderer struct {
-	Font            *truetype.Font
+	Font *truetype.Font
+	// Fallbacks draw the characters Font has no glyph for, in order
+	Fallbacks       []*truetype.Font
 	FontSizePt      float64
 	BackgroundColor color.Color
 	ForegroundColor color.Color
@@ -191,9 +625,22 @@ func (r defaultRenderer) Render(text string) (image.Image, error) {
 	fontSizePx := int(c.PointToFix32(r.FontSizePt)) / 256
 	c.SetFontSize(r.FontSizePt)
 
-	tw, th, err := c.MeasureString(text)
-	if err != nil {
-		return nil, fmt.Errorf("failed to measure string: %w", err)
+	segments := r.segments(visualOrder(stripEmojiModifiers(text)))
+	if len(segments) == 0 {
+		// measured to keep the line height of the font
+		segments = []fontSegment{{font: r.Font}}
+	}
+	var tw, th raster.Fix32
+	for _, seg := range segments {
+		c.SetFont(seg.font)
+		w, h, err := c.MeasureString(seg.text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure string: %w", err)
+		}
+		tw += w
+		if h > th {
+			th = h
+		}
 	}
 
 	// freetype.Fix32 is a fixed-point representation of a number with 16 bits of precision for the fractional part.
@@ -207,81 +654,176 @@ func (r defaultRenderer) Render(text string) (image.Image, error) {
 
 	c.SetClip(img.Bounds())
 	c.SetDst(img)
-	c.SetSrc(image.White)
+	c.SetSrc(image.NewUniform(r.ForegroundColor))
 
 	x := padding / 2
 	// adjust y position by 5% to account for baseline shift
 	y := int(math.Ceil(float64(fontSizePx))*0.95) + padding/2
-	if _, err := c.DrawString(text, freetype.Pt(x, y)); err != nil {
-		return nil, fmt.Errorf("failed to draw string: %w", err)
+	pt := freetype.Pt(x, y)
+	for _, seg := range segments {
+		c.SetFont(seg.font)
+		var err error
+		if pt, err = c.DrawString(seg.text, pt); err != nil {
+			return nil, fmt.Errorf("failed to draw string: %w", err)
+		}
 	}
 
 	return img, nil
 }
 
-func MakeThumbnails(ctx context.Context, videoPath string, opts ThumbOptions) ([]Thumbnail, error) {
-	if err := opts.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid options: %w", err)
+type fontSegment struct {
+	font *truetype.Font
+	text string
+}
+
+// segments splits text into runs drawn with the same font, picking the first font with a glyph for each character.
+// Characters no font has, e.g. emoji, are drawn with the bundled emoji font as a last resort.
+func (r defaultRenderer) segments(text string) []fontSegment {
+	var segments []fontSegment
+	for _, ch := range text {
+		font, glyph := r.fontFor(ch)
+		if n := len(segments); n > 0 && segments[n-1].font == font {
+			segments[n-1].text += string(glyph)
+			continue
+		}
+		segments = append(segments, fontSegment{font: font, text: string(glyph)})
 	}
+	return segments
+}
 
-	if err := checkFfmpegInstalled(); err != nil {
-		return nil, err
+// fontFor picks the font to draw ch with, and the character to draw, which differs for emoji
+// the bundled emoji font has moved, see fonts.EmojiRune
+func (r defaultRenderer) fontFor(ch rune) (*truetype.Font, rune) {
+	if hasGlyph(r.Font, ch) {
+		return r.Font, ch
+	}
+	for _, f := range r.Fallbacks {
+		if hasGlyph(f, ch) {
+			return f, ch
+		}
+	}
+	if glyph := fonts.EmojiRune(ch); hasGlyph(fonts.Emoji, glyph) {
+		return fonts.Emoji, glyph
 	}
+	return r.Font, ch
+}
 
-	duration, err := readDuration(ctx, videoPath)
+// LoadFont reads a TrueType font, e.g. to draw scripts or symbols the bundled font doesn't cover
+func LoadFont(path string) (*truetype.Font, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read video duration: %w", err)
+		return nil, err
 	}
-
-	start := opts.From
-	end := duration
-	if opts.To != 0 {
-		end = opts.To
+	f, err := freetype.ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s, only TrueType fonts are supported: %w", path, err)
 	}
-	duration = end - start
+	return f, nil
+}
 
-	if opts.Interval > duration {
-		return nil, fmt.Errorf("interval is larger than available video duration %s: %w", duration, err)
+// CheckFonts renders a sample timestamp with the bundled font
+func CheckFonts() error {
+	r := defaultRenderer{
+		Font:            fonts.RobotoMonoMedium,
+		FontSizePt:      12,
+		BackgroundColor: color.Black,
+		ForegroundColor: color.White,
+	}
+	img, err := r.Render(FormatDuration(time.Hour + 23*time.Minute + 45*time.Second))
+	if err != nil {
+		return err
+	}
+	if img.Bounds().Empty() {
+		return fmt.Errorf("rendered an empty image")
 	}
+	return nil
+}
 
-	totalTiles := opts.TileCount
-	if opts.Interval != 0 {
-		if opts.Interval > duration {
-			return nil, fmt.Errorf("interval is larger than available video duration %s: %w", duration, err)
-		}
-		totalTiles = int(duration / opts.Interval)
+func MakeThumbnails(ctx context.Context, videoPath string, opts ThumbOptions) ([]Thumbnail, error) {
+	opts = opts.withDefaults()
+	timestamps, err := PlanTimestamps(ctx, videoPath, opts)
+	if err != nil {
+		return nil, err
 	}
-	interval := duration / time.Duration(totalTiles)
+	return extractThumbnails(ctx, videoPath, timestamps, opts)
+}
 
-	if interval < time.Second*10 {
-		slog.Warn("interval is very small", "interval", interval)
+// ExtractThumbnails extracts a thumbnail at each of the timestamps, e.g. from PlanTimestamps,
+// so callers can merge the timestamps of several outputs and extract each frame once
+func ExtractThumbnails(ctx context.Context, videoPath string, timestamps []time.Duration, opts ThumbOptions) ([]Thumbnail, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
 	}
+	if err := checkFfmpegInstalled(); err != nil {
+		return nil, err
+	}
+	if opts.TimecodeRegion != nil {
+		if err := checkTesseractInstalled(); err != nil {
+			return nil, err
+		}
+	}
+	return extractThumbnails(ctx, videoPath, timestamps, opts)
+}
 
+func extractThumbnails(ctx context.Context, videoPath string, timestamps []time.Duration, opts ThumbOptions) ([]Thumbnail, error) {
 	type indexedThumb struct {
 		Thumbnail
 		Index int
 	}
 
+	// closing Interrupt kills the ffmpeg processes still running, like canceling ctx
+	extractCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.Interrupt != nil {
+		go func() {
+			select {
+			case <-opts.Interrupt:
+				cancel()
+			case <-extractCtx.Done():
+			}
+		}()
+	}
+
 	p := pool.NewWithResults[indexedThumb]().
-		WithContext(ctx).
-		WithMaxGoroutines(4).
+		WithContext(extractCtx).
+		WithMaxGoroutines(opts.concurrency()).
 		WithCollectErrored()
 
-	for i := 0; i < totalTiles; i++ {
-		i := i
-		p.Go(func(ctx context.Context) (indexedThumb, error) {
-			t := start + time.Duration(i)*interval
-			slog.Debug("extracting thumbnail", "current", i+1, "total", totalTiles)
-			th, err := extractThumbnail(ctx, videoPath, t, opts.TileWidth, opts.TileHeight)
+	filter := videoFilter(opts)
+	progress := opts.newFrameProgress(videoPath, len(timestamps))
+	for i, t := range timestamps {
+		i, t := i, t
+		p.Go(func(ctx context.Context) (_ indexedThumb, err error) {
+			defer RecoverPanic(&err)
+			ctx = withFrameIndex(withVideoPath(ctx, videoPath), i)
+			slog.Debug("extracting thumbnail", "current", i+1, "total", len(timestamps))
+			th, err := extractThumbnail(ctx, videoPath, t, filter, opts)
 			if err != nil {
 				slog.Error("failed to extract thumbnail", "timestamp", t, "error", err)
 				return indexedThumb{}, err
 			}
+			progress.frameDone()
 			return indexedThumb{Thumbnail: th, Index: i}, nil
 		})
 	}
 
 	results, err := p.Wait()
+	if err != nil && interrupted(opts.Interrupt) && ctx.Err() == nil {
+		// errored frames are collected too, without an image
+		extracted := results[:0]
+		for _, r := range results {
+			if r.Image != nil {
+				extracted = append(extracted, r)
+			}
+		}
+		results = extracted
+		if len(results) == 0 {
+			return nil, fmt.Errorf("interrupted before any frames were extracted")
+		}
+		slog.Warn("interrupted, using the frames extracted so far", "path", videoPath, "frames", len(results), "total", len(timestamps))
+		err = nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -294,11 +836,221 @@ func MakeThumbnails(ctx context.Context, videoPath string, opts ThumbOptions) ([
 	for _, r := range results {
 		thumbnails = append(thumbnails, r.Thumbnail)
 	}
+	annotate(thumbnails, opts.Annotations)
+	if opts.MotionHeatmap {
+		if err := measureMotion(ctx, videoPath, thumbnails, opts); err != nil {
+			return nil, fmt.Errorf("failed to measure motion: %w", err)
+		}
+	}
 
 	return thumbnails, nil
 }
 
-func formatDuration(d time.Duration) string {
+// PlanTimestamps validates the options, probes the video and picks where each tile is extracted from
+func PlanTimestamps(ctx context.Context, videoPath string, opts ThumbOptions) ([]time.Duration, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	if err := checkFfmpegInstalled(); err != nil {
+		return nil, err
+	}
+	if opts.TimecodeRegion != nil {
+		if err := checkTesseractInstalled(); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = withVideoPath(ctx, videoPath)
+	opts.reportProgress(ctx, "probe", 0)
+	probeCtx, stage := startStage(ctx, "probe")
+	media, err := opts.media(probeCtx, videoPath)
+	stage.End(err)
+	if err != nil {
+		return nil, err
+	}
+	if opts.needsSize() {
+		if err := opts.checkRegions(media.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.AtFrames) > 0 {
+		return planFrames(ctx, videoPath, opts.AtFrames, media.Duration)
+	}
+
+	start, end, err := opts.span(media.Duration)
+	if err != nil {
+		return nil, err
+	}
+	duration := end - start
+
+	totalTiles := opts.TileCount
+	if opts.Interval == 0 && opts.TileCount == 0 {
+		totalTiles = opts.autoTileCount(duration)
+		slog.Debug("picked tile count from duration", "tiles", totalTiles, "duration", duration)
+	}
+	if opts.Interval != 0 {
+		if opts.Interval > duration {
+			switch opts.ShortVideoPolicy {
+			case ShortVideoShrink:
+				totalTiles = opts.TileColumns
+				if totalTiles < 1 {
+					totalTiles = 1
+				}
+				slog.Warn("interval is larger than video duration, shrinking interval to fill a row", "interval", opts.Interval, "duration", duration, "tiles", totalTiles)
+			case ShortVideoSpread:
+				totalTiles = opts.autoTileCount(duration)
+				slog.Warn("interval is larger than video duration, spreading tiles evenly", "interval", opts.Interval, "duration", duration, "tiles", totalTiles)
+			default:
+				return nil, fmt.Errorf("interval is larger than available video duration %s", duration)
+			}
+		} else {
+			totalTiles = int(duration / opts.Interval)
+		}
+	}
+	if totalTiles < 1 {
+		return nil, fmt.Errorf("no tiles fit between %s and %s", start, end)
+	}
+	interval := duration / time.Duration(totalTiles)
+
+	if interval < time.Second*10 {
+		slog.Warn("interval is very small", "interval", interval)
+	}
+
+	var verification *Verification
+	if opts.SkipUnreadable {
+		verifyCtx, stage := startStage(ctx, "verify")
+		v, err := verify(verifyCtx, videoPath, media.Duration)
+		stage.End(err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify video: %w", err)
+		}
+		if !v.OK() {
+			slog.Warn("video has unreadable portions, moving tiles to readable ranges", "errors", len(v.Errors), "truncated", v.Truncated)
+		}
+		verification = &v
+	}
+
+	var keyframes []time.Duration
+	if opts.needsKeyframes() {
+		keyframes = media.Keyframes
+		if len(keyframes) == 0 {
+			slog.Warn("no keyframes found, tiles won't be aligned")
+		}
+	}
+
+	window := jitterWindow(opts.Jitter, interval)
+	if window < opts.Jitter {
+		slog.Warn("jitter is larger than half the interval, limiting it", "jitter", opts.Jitter, "interval", interval)
+	}
+	rng := opts.rand()
+
+	timestamps := make([]time.Duration, totalTiles)
+	for i := range timestamps {
+		t := jitter(rng, start+time.Duration(i)*interval, window, start, end)
+		if verification != nil {
+			if readable, ok := verification.nearestReadable(t); ok {
+				t = readable
+			}
+		}
+		timestamps[i] = t
+	}
+	if len(keyframes) > 0 && opts.AlignKeyframes {
+		timestamps = alignToKeyframes(keyframes, timestamps)
+	}
+	if len(keyframes) > 0 && opts.SnapKeyframes {
+		// only keyframes within the range, so tiles don't move before the starting point
+		lo := sort.Search(len(keyframes), func(i int) bool { return keyframes[i] >= start })
+		hi := sort.Search(len(keyframes), func(i int) bool { return keyframes[i] >= end })
+		timestamps = snapToKeyframes(keyframes[lo:hi], timestamps, interval/4)
+	}
+	return timestamps, nil
+}
+
+// span is the range of the video tiles are spread over
+func (o ThumbOptions) span(duration time.Duration) (start, end time.Duration, err error) {
+	end = duration
+	if o.To != 0 {
+		end = o.To
+	}
+	start = o.From
+	if start == 0 && o.DefaultFrom < end {
+		start = o.DefaultFrom
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("starting point %s is past the end of the video at %s", FormatDuration(start), FormatDuration(end))
+	}
+	return start, end, nil
+}
+
+// planFrames returns the timestamps of frame numbers at the frame rate of the video
+func planFrames(ctx context.Context, videoPath string, frames []int, duration time.Duration) ([]time.Duration, error) {
+	fps, err := ReadFrameRate(ctx, videoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame rate: %w", err)
+	}
+	timestamps := make([]time.Duration, len(frames))
+	for i, n := range frames {
+		t := frameTimestamp(n, fps)
+		if t >= duration {
+			return nil, fmt.Errorf("frame %d is at %s, past the end of the video at %s", n, t, duration)
+		}
+		timestamps[i] = t
+	}
+	return timestamps, nil
+}
+
+// frameTimestamp is the presentation time of frame n, rounded down to the millisecond precision of seeking.
+// Seeking lands on the first frame at or after it, which is frame n, as frames are much longer than a millisecond.
+func frameTimestamp(n int, fps float64) time.Duration {
+	return time.Duration(float64(n) / fps * float64(time.Second)).Truncate(time.Millisecond)
+}
+
+const (
+	minAutoTiles = 4
+	maxAutoTiles = 30
+)
+
+// autoTileCount picks a tile count between MinTiles and MaxTiles for the duration
+func (o ThumbOptions) autoTileCount(duration time.Duration) int {
+	lo, hi := minAutoTiles, maxAutoTiles
+	if o.MinTiles != 0 {
+		lo = o.MinTiles
+	}
+	if o.MaxTiles != 0 {
+		hi = o.MaxTiles
+	}
+	if lo > hi {
+		lo = hi
+	}
+	return autoTileCount(duration, o.TileColumns, lo, hi)
+}
+
+// autoTileCount picks a tile count that grows with the square root of the duration,
+// so short clips still get a few tiles and long films don't end up with hundreds.
+func autoTileCount(duration time.Duration, columns, lo, hi int) int {
+	n := int(math.Round(4 * math.Sqrt(duration.Minutes())))
+	if n < lo {
+		n = lo
+	}
+	if n > hi {
+		n = hi
+	}
+
+	// round up to fill the last row, but a single row may go over the cap
+	if columns > 1 {
+		n = (n + columns - 1) / columns * columns
+		if n > hi && n > columns {
+			n -= columns
+		}
+	}
+	return n
+}
+
+// FormatDuration formats a duration as hh:mm:ss, truncating fractions of a second like the timestamps on tiles
+func FormatDuration(d time.Duration) string {
 	h := d / time.Hour
 	d -= h * time.Hour
 	m := d / time.Minute
@@ -307,52 +1059,151 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
 }
 
+// ExtractFrame extracts a single thumbnail at the given timestamp, applying the same filters as MakeThumbnails
+func ExtractFrame(ctx context.Context, videoPath string, timestamp time.Duration, opts ThumbOptions) (Thumbnail, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return Thumbnail{}, fmt.Errorf("invalid options: %w", err)
+	}
+	if err := checkFfmpegInstalled(); err != nil {
+		return Thumbnail{}, err
+	}
+	if opts.TimecodeRegion != nil {
+		if err := checkTesseractInstalled(); err != nil {
+			return Thumbnail{}, err
+		}
+	}
+	return extractThumbnail(ctx, videoPath, timestamp, videoFilter(opts), opts)
+}
+
+// MakeContactSheet lays out thumbnails in a grid, e.g. after picking frames from MakeThumbnails
+func MakeContactSheet(thumbs []Thumbnail, opts ThumbOptions) (image.Image, error) {
+	opts = opts.withDefaults()
+	sheet, err := ComposeSheet(thumbs, opts.LayoutOptions())
+	if err != nil {
+		return nil, err
+	}
+	return sheet.Image, nil
+}
+
 func Generate(ctx context.Context, videoPath string, opts ThumbOptions) (image.Image, error) {
+	sheet, err := GenerateSheet(ctx, videoPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sheet.Image, nil
+}
+
+// GenerateSheet is like Generate, but also reports where each tile was placed
+func GenerateSheet(ctx context.Context, videoPath string, opts ThumbOptions) (_ Sheet, err error) {
+	opts = opts.withDefaults()
+	ctx, stage := startTask(withVideoPath(ctx, videoPath), "generate", attribute.String("thumber.video", videoPath))
+	defer func() { stage.End(err) }()
+
 	thumbs, err := MakeThumbnails(ctx, videoPath, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make thumbnails: %w", err)
+		return Sheet{}, fmt.Errorf("failed to make thumbnails: %w", err)
 	}
 	if len(thumbs) == 0 {
-		return nil, fmt.Errorf("generated 0 images")
+		return Sheet{}, fmt.Errorf("generated 0 images")
 	}
 
-	return makeContactSheet(
-		thumbs,
-		opts,
-	), nil
+	return composeSheet(ctx, thumbs, opts.LayoutOptions())
 }
 
-func makeContactSheet(thumbs []Thumbnail, opts ThumbOptions) image.Image {
-	rows := int(math.Ceil(float64(len(thumbs)) / float64(opts.TileColumns)))
+// GenerateSheets is like GenerateSheet, but splits the tiles into several sheets of at most MaxTilesPerSheet tiles,
+// or one per SheetDuration
+func GenerateSheets(ctx context.Context, videoPath string, opts ThumbOptions) (_ []Sheet, err error) {
+	opts = opts.withDefaults()
+	ctx, stage := startTask(withVideoPath(ctx, videoPath), "generate", attribute.String("thumber.video", videoPath))
+	defer func() { stage.End(err) }()
 
-	tileWidth := thumbs[0].Bounds().Dx()
-	tileHeight := thumbs[0].Bounds().Dy()
+	thumbs, err := MakeThumbnails(ctx, videoPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make thumbnails: %w", err)
+	}
+	if len(thumbs) == 0 {
+		return nil, fmt.Errorf("generated 0 images")
+	}
 
-	black := color.RGBA{}
-	w := tileWidth*opts.TileColumns + (opts.TileColumns+1)*opts.Padding
-	h := tileHeight*rows + (rows+1)*opts.Padding
-	canvas := imaging.New(w, h, black)
+	layout := opts.LayoutOptions()
+	if opts.AudioTimeline {
+		profile, err := readSheetAudio(ctx, videoPath, thumbs, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audio: %w", err)
+		}
+		layout.Audio = &profile
+	}
+	if opts.Header {
+		info, err := ReadHeaderInfo(ctx, videoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header details: %w", err)
+		}
+		layout.Header = info.Lines(opts.Locale)
+	}
+	if opts.Template != nil && opts.Template.needsInfo() {
+		info, err := ReadHeaderInfo(ctx, videoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template details: %w", err)
+		}
+		layout.TemplateData.HeaderInfo = info
+	}
+	return composeSheets(ctx, thumbs, opts, layout)
+}
 
-	renderer := defaultRenderer{
-		Font:            fonts.RobotoMonoMedium,
-		FontSizePt:      12,
-		BackgroundColor: opts.TimestampBackground,
-		ForegroundColor: color.White,
+// readSheetAudio reads the audio profile of the span covered by the tiles, from the first tile to the end
+func readSheetAudio(ctx context.Context, videoPath string, thumbs []Thumbnail, opts ThumbOptions) (AudioProfile, error) {
+	end := opts.To
+	if end == 0 {
+		media, err := opts.media(ctx, videoPath)
+		if err != nil {
+			return AudioProfile{}, err
+		}
+		end = media.Duration
 	}
+	return ReadAudioProfile(ctx, videoPath, TimeRange{Start: thumbs[0].Timestamp, End: end}, opts)
+}
+
+// ComposeSheets lays out thumbnails over as many sheets as needed for at most MaxTilesPerSheet tiles each,
+// or one per SheetDuration
+func ComposeSheets(ctx context.Context, thumbs []Thumbnail, opts ThumbOptions) ([]Sheet, error) {
+	opts = opts.withDefaults()
+	return composeSheets(ctx, thumbs, opts, opts.LayoutOptions())
+}
 
-	for i, img := range thumbs {
-		row := i / opts.TileColumns
-		col := i % opts.TileColumns
-		x := opts.Padding + col*tileWidth + col*opts.Padding
-		y := opts.Padding + row*tileHeight + row*opts.Padding
+// composeSheets paginates thumbs, and splits the audio timeline of layout between the sheets
+func composeSheets(ctx context.Context, thumbs []Thumbnail, opts ThumbOptions, layout LayoutOptions) ([]Sheet, error) {
+	audio := layout.Audio
+	if err := layout.validate(thumbs); err != nil {
+		return nil, err
+	}
 
-		if opts.OverlayTimestamps {
-			if err := img.overlayTimestamp(renderer); err != nil {
-				slog.Error("failed to overlay timestamp text", "timestamp", img.Timestamp, "error", err)
-				continue
+	timestamps := make([]time.Duration, len(thumbs))
+	for i, th := range thumbs {
+		timestamps[i] = th.Timestamp
+	}
+	pages := opts.paginate(timestamps)
+	opts.reportProgress(ctx, "compose", extractPercent)
+	var sheets []Sheet
+	layout.TemplateData.Pages = len(pages)
+	for _, p := range pages {
+		start, end := p.start, p.end
+		if audio != nil {
+			// each sheet shows the span from its first tile up to the next sheet
+			span := TimeRange{Start: thumbs[start].Timestamp, End: audio.Span.End}
+			if end < len(thumbs) {
+				span.End = thumbs[end].Timestamp
 			}
+			page := audio.within(span)
+			layout.Audio = &page
+		}
+		layout.TemplateData.Page = len(sheets) + 1
+		sheet, err := composeSheet(ctx, thumbs[start:end], layout)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %d: %w", len(sheets)+1, err)
 		}
-		canvas = imaging.Paste(canvas, img, image.Pt(x, y))
+		sheet.Window = p.window
+		sheets = append(sheets, sheet)
 	}
-	return canvas
+	return sheets, nil
 }
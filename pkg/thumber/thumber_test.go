@@ -1,10 +1,20 @@
 package thumber
 
 import (
+	"context"
+	"image"
 	"image/color"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/freetype-go/freetype"
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+	"github.com/disintegration/imaging"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/abdusco/thumber/pkg/thumber/internal/fonts"
 )
 
 func TestParseColor(t *testing.T) {
@@ -31,11 +41,374 @@ func TestParseColor(t *testing.T) {
 				assert.Equal(t, color.RGBA{R: 0x10, G: 0x11, B: 0x12, A: 0xcc}, res)
 			},
 		},
+		{
+			name: "shorthand rgb",
+			hex:  "#FA0",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, color.RGBA{R: 0xff, G: 0xaa, B: 0x00, A: 0xff}, res)
+			},
+		},
+		{
+			name: "shorthand rgba without hash",
+			hex:  "fffc",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xcc}, res)
+			},
+		},
+		{
+			name: "named",
+			hex:  "Navy",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, color.RGBA{R: 0x00, G: 0x00, B: 0x80, A: 0xff}, res)
+			},
+		},
+		{
+			name: "rgba function",
+			hex:  "rgba(255, 128, 0, 0.5)",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, color.RGBA{R: 0xff, G: 0x80, B: 0x00, A: 0x80}, res)
+			},
+		},
+		{
+			name: "rgb function with percentages",
+			hex:  "rgb(100% 0% 50% / 25%)",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, color.RGBA{R: 0xff, G: 0x00, B: 0x80, A: 0x40}, res)
+			},
+		},
+		{
+			name: "invalid hex digits",
+			hex:  "#GG0000",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "invalid length",
+			hex:  "#12345",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "channel out of range",
+			hex:  "rgb(256, 0, 0)",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "empty",
+			hex:  "",
+			assertRes: func(t *testing.T, res color.RGBA, err error) {
+				assert.Error(t, err)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c, err := ParseColor(tt.hex)
-			tt.assertRes(t, c, err)
+			rgba, _ := c.(color.RGBA)
+			tt.assertRes(t, rgba, err)
+		})
+	}
+}
+
+func TestVideoFilter(t *testing.T) {
+	crop := &Region{X: 10, Y: 20, W: 640, H: 360}
+	detail := &Region{X: 100, Y: 50, W: 200, H: 80}
+	const center = "crop=w='min(iw,320)':h='min(ih,160)',pad=320:160:(ow-iw)/2:(oh-ih)/2"
+	tests := []struct {
+		name string
+		opts ThumbOptions
+		want string
+	}{
+		{name: "scale", opts: ThumbOptions{TileWidth: 320}, want: "showinfo,scale=320:-1"},
+		{name: "crop", opts: ThumbOptions{TileWidth: 320, Crop: crop}, want: "showinfo,crop=640:360:10:20,scale=320:-1"},
+		{
+			name: "detail from the center",
+			opts: ThumbOptions{TileWidth: 320, DetailRow: true},
+			want: "showinfo,split[main][detail];[detail]" + center + "[detailout];[main]scale=320:-1[mainout];[mainout][detailout]vstack",
+		},
+		{
+			name: "detail region",
+			opts: ThumbOptions{TileWidth: 320, DetailRow: true, DetailRegion: detail, DetailHeight: 100},
+			want: "showinfo,split[main][detail];[detail]crop=200:80:100:50,scale=320:100:force_original_aspect_ratio=decrease,pad=320:100:(ow-iw)/2:(oh-ih)/2" +
+				"[detailout];[main]scale=320:-1[mainout];[mainout][detailout]vstack",
+		},
+		{
+			name: "detail of a cropped and covered frame",
+			opts: ThumbOptions{TileWidth: 320, TileHeight: 180, Fit: FitCover, Crop: crop, DetailRow: true},
+			want: "showinfo,crop=640:360:10:20,split[main][detail];[detail]" + center +
+				"[detailout];[main]scale=320:180:force_original_aspect_ratio=increase,crop=320:180[mainout];[mainout][detailout]vstack",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, videoFilter(tt.opts))
+		})
+	}
+}
+
+// solidRenderer renders every text as a rectangle of a single color
+type solidRenderer struct {
+	size image.Point
+	c    color.Color
+}
+
+func (r solidRenderer) Render(string) (image.Image, error) {
+	return imaging.New(r.size.X, r.size.Y, r.c), nil
+}
+
+func TestOverlayTimestampAboveDetail(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	th := Thumbnail{Image: imaging.New(320, 240, color.White), DetailHeight: 80}
+	require.NoError(t, th.overlayTimestamp(solidRenderer{size: image.Pt(40, 12), c: red}, time.Minute))
+
+	assert.Equal(t, red, th.Image.(*image.NRGBA).NRGBAAt(320-10-1, 240-80-10-1), "bottom right of the frame")
+	for y := 160; y < 240; y++ {
+		assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, th.Image.(*image.NRGBA).NRGBAAt(300, y), "detail strip at %d", y)
+	}
+
+	th.resize(160, 120)
+	assert.Equal(t, 40, th.DetailHeight, "scaled with the tile")
+}
+
+func TestAutoTileCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		columns  int
+		want     int
+	}{
+		{name: "short clip", duration: 20 * time.Second, columns: 3, want: 6},
+		{name: "episode", duration: 22 * time.Minute, columns: 3, want: 21},
+		{name: "film", duration: 3 * time.Hour, columns: 4, want: 28},
+		{name: "single column", duration: 3 * time.Hour, columns: 1, want: 30},
+		{name: "row wider than the cap", duration: 3 * time.Hour, columns: 40, want: 40},
+		{name: "short clip in a wide row", duration: 20 * time.Second, columns: 31, want: 31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, autoTileCount(tt.duration, tt.columns, minAutoTiles, maxAutoTiles))
+		})
+	}
+}
+
+func TestThumbOptionsSpan(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       ThumbOptions
+		duration   time.Duration
+		start, end time.Duration
+		err        string
+	}{
+		{name: "default start", opts: ThumbOptions{DefaultFrom: 10 * time.Second}, duration: time.Minute, start: 10 * time.Second, end: time.Minute},
+		{name: "clip shorter than the default start", opts: ThumbOptions{DefaultFrom: 10 * time.Second}, duration: 8 * time.Second, end: 8 * time.Second},
+		{name: "to before the default start", opts: ThumbOptions{DefaultFrom: 10 * time.Second, To: 5 * time.Second}, duration: time.Minute, end: 5 * time.Second},
+		{name: "from overrides the default", opts: ThumbOptions{From: 20 * time.Second, DefaultFrom: 10 * time.Second}, duration: time.Minute, start: 20 * time.Second, end: time.Minute},
+		{name: "from past the end", opts: ThumbOptions{From: 2 * time.Minute}, duration: time.Minute, err: "starting point 00:02:00 is past the end of the video at 00:01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := tt.opts.span(tt.duration)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.start, start)
+			assert.Equal(t, tt.end, end)
 		})
 	}
 }
+
+func TestParseFramePTS(t *testing.T) {
+	log := `[Parsed_showinfo_0 @ 0x7f8] config in time_base: 1/90000, frame_rate: 30000/1001
+[Parsed_showinfo_0 @ 0x7f8] n:   0 pts:  37537 pts_time:0.417078 duration:   3003 duration_time:0.0333667 fmt:yuv420p`
+
+	pts, ok := parseFramePTS(log)
+	assert.True(t, ok)
+	assert.Equal(t, 417078*time.Microsecond, pts)
+
+	_, ok = parseFramePTS("no frames here")
+	assert.False(t, ok)
+}
+
+func TestCheckPTS(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, checkPTS(ctx, 10*time.Second, 10*time.Second+40*time.Millisecond, true, 100*time.Millisecond))
+	assert.True(t, checkPTS(ctx, 10*time.Second, 9900*time.Millisecond, true, 100*time.Millisecond), "within tolerance before the timestamp")
+	assert.False(t, checkPTS(ctx, 10*time.Second, 8*time.Second, true, 100*time.Millisecond), "fast seek landed on an earlier keyframe")
+	assert.False(t, checkPTS(ctx, 10*time.Second, 10*time.Second, false, time.Second), "unreadable pts")
+}
+
+func TestRecoverPanic(t *testing.T) {
+	run := func() (err error) {
+		defer RecoverPanic(&err)
+		var img image.Image
+		_ = img.Bounds()
+		return nil
+	}
+
+	err := run()
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Contains(t, err.Error(), "nil pointer dereference")
+	assert.NotEmpty(t, panicErr.Stack)
+}
+
+func TestDisplayTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin TimestampOrigin
+		ts     time.Duration
+		want   time.Duration
+	}{
+		{name: "default is absolute", ts: 11 * time.Minute, want: 11 * time.Minute},
+		{name: "absolute", origin: TimestampAbsolute, ts: 11 * time.Minute, want: 11 * time.Minute},
+		{name: "relative", origin: TimestampRelative, ts: 11 * time.Minute, want: time.Minute},
+		{name: "relative before start", origin: TimestampRelative, ts: 9 * time.Minute, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ThumbOptions{From: 10 * time.Minute, TimestampOrigin: tt.origin}
+			assert.Equal(t, tt.want, opts.LayoutOptions().displayTimestamp(tt.ts))
+		})
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	opts := ThumbOptions{}.withDefaults()
+	assert.Equal(t, DefaultTileColumns, opts.TileColumns)
+	assert.Equal(t, DefaultTileWidth, opts.TileWidth)
+	assert.Equal(t, FitStretch, opts.Fit)
+	assert.Equal(t, SeekAccurate, opts.Seek)
+	assert.Equal(t, OversizeError, opts.OnOversize)
+	assert.Equal(t, ShortVideoError, opts.ShortVideoPolicy)
+
+	opts = ThumbOptions{TileColumns: 5, TileHeight: 200, Fit: FitCover}.withDefaults()
+	assert.Equal(t, 5, opts.TileColumns)
+	assert.Equal(t, 0, opts.TileWidth, "width is left to scale with the height")
+	assert.Equal(t, FitCover, opts.Fit)
+
+	opts = ThumbOptions{MaxTileDimension: 720}.withDefaults()
+	assert.Equal(t, 0, opts.TileWidth, "frames keep the source resolution up to the max dimension")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   ThumbOptions
+		errors []string
+	}{
+		{name: "defaults", opts: ThumbOptions{}},
+		{name: "height only", opts: ThumbOptions{TileHeight: 200}},
+		{name: "max dimension", opts: ThumbOptions{MaxTileDimension: 720}},
+		{name: "max dimension with width", opts: ThumbOptions{TileWidth: 540, MaxTileDimension: 720}, errors: []string{"max tile dimension cannot be set together with tile width or height"}},
+		{name: "frames", opts: ThumbOptions{AtFrames: []int{0, 100}}},
+		{name: "frames with interval", opts: ThumbOptions{AtFrames: []int{100}, Interval: time.Second}, errors: []string{"frame numbers cannot be set together with interval or tile count"}},
+		{name: "negative frame", opts: ThumbOptions{AtFrames: []int{-1}}, errors: []string{"frame number -1 cannot be negative"}},
+		{name: "negative padding", opts: ThumbOptions{Padding: -1}, errors: []string{"padding cannot be negative"}},
+		{name: "negative columns", opts: ThumbOptions{TileColumns: -2}, errors: []string{"tile columns must be positive"}},
+		{name: "negative tile count", opts: ThumbOptions{TileCount: -1}, errors: []string{"tile count cannot be negative"}},
+		{name: "negative size", opts: ThumbOptions{TileWidth: -1, TileHeight: -1}, errors: []string{"tile width cannot be negative", "tile height cannot be negative"}},
+		{name: "from after to", opts: ThumbOptions{From: time.Minute, To: time.Second}, errors: []string{"starting point cannot be after ending point"}},
+		{name: "to before the default from", opts: ThumbOptions{DefaultFrom: 10 * time.Second, To: 5 * time.Second}},
+		{name: "negative from", opts: ThumbOptions{From: -time.Second}, errors: []string{"starting point cannot be negative"}},
+		{name: "interval with tile count", opts: ThumbOptions{Interval: time.Second, TileCount: 4}, errors: []string{"interval and tile count cannot be set together"}},
+		{name: "lossless intermediate", opts: ThumbOptions{Intermediate: IntermediatePPM}},
+		{name: "unknown intermediate", opts: ThumbOptions{Intermediate: "bmp"}, errors: []string{`invalid intermediate codec "bmp"`}},
+		{name: "unknown seek mode", opts: ThumbOptions{Seek: "slow"}, errors: []string{`invalid seek mode "slow"`}},
+		{name: "invalid crop", opts: ThumbOptions{Crop: &Region{W: -1, H: 10}}, errors: []string{"invalid crop"}},
+		{
+			name: "every problem at once",
+			opts: ThumbOptions{Padding: -1, TileCount: -1, Concurrency: -1, Fit: "squash", OnOversize: "ignore"},
+			errors: []string{
+				"padding cannot be negative",
+				"tile count cannot be negative",
+				"concurrency cannot be negative",
+				`invalid fit mode "squash"`,
+				`invalid oversize policy "ignore"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if len(tt.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), len(tt.errors))
+			for _, msg := range tt.errors {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestFrameTimestamp(t *testing.T) {
+	tests := []struct {
+		frame int
+		fps   float64
+		want  time.Duration
+	}{
+		{frame: 0, fps: 25, want: 0},
+		{frame: 3, fps: 25, want: 120 * time.Millisecond},
+		{frame: 100, fps: 30000.0 / 1001, want: 3336 * time.Millisecond},
+		{frame: 60000, fps: 24, want: 2500 * time.Second},
+	}
+	for _, tt := range tests {
+		got := frameTimestamp(tt.frame, tt.fps)
+		assert.Equal(t, tt.want, got, "frame %d at %.3f fps", tt.frame, tt.fps)
+		if tt.frame > 0 {
+			previous := time.Duration(float64(tt.frame-1) / tt.fps * float64(time.Second))
+			assert.Greater(t, got, previous, "seeking must not land on the frame before")
+		}
+	}
+}
+
+func TestRendererFallbacks(t *testing.T) {
+	fallback, err := freetype.ParseFont(goregular.TTF)
+	require.NoError(t, err)
+	r := defaultRenderer{Font: fonts.RobotoMonoMedium, Fallbacks: []*truetype.Font{fallback}, FontSizePt: 12, BackgroundColor: color.Transparent}
+
+	segments := r.segments("Hǎn 1")
+	require.Len(t, segments, 3)
+	assert.Equal(t, fontSegment{font: fonts.RobotoMonoMedium, text: "H"}, segments[0])
+	assert.Equal(t, fontSegment{font: fallback, text: "ǎ"}, segments[1], "the bundled font has no glyph for ǎ")
+	assert.Equal(t, fontSegment{font: fonts.RobotoMonoMedium, text: "n 1"}, segments[2])
+
+	img, err := r.Render("")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, img.Bounds().Dy(), 16, "empty text keeps the height of the font")
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "00:00:00", FormatDuration(999*time.Millisecond))
+	assert.Equal(t, "01:23:45", FormatDuration(time.Hour+23*time.Minute+45*time.Second))
+	assert.Equal(t, "100:00:00", FormatDuration(100*time.Hour))
+}
+
+func TestPaginate(t *testing.T) {
+	timestamps := []time.Duration{time.Minute, 4 * time.Minute, 11 * time.Minute, 25 * time.Minute, 27 * time.Minute}
+
+	pages := ThumbOptions{MaxTilesPerSheet: 2}.paginate(timestamps)
+	assert.Equal(t, []page{{start: 0, end: 2}, {start: 2, end: 4}, {start: 4, end: 5}}, pages)
+
+	pages = ThumbOptions{SheetDuration: 10 * time.Minute, To: 28 * time.Minute}.paginate(timestamps)
+	assert.Equal(t, []page{
+		{start: 0, end: 2, window: TimeRange{Start: 0, End: 10 * time.Minute}},
+		{start: 2, end: 3, window: TimeRange{Start: 10 * time.Minute, End: 20 * time.Minute}},
+		{start: 3, end: 5, window: TimeRange{Start: 20 * time.Minute, End: 28 * time.Minute}},
+	}, pages, "one sheet per window with tiles, the last one ends at To")
+
+	assert.ErrorContains(t, ThumbOptions{SheetDuration: time.Minute, MaxTilesPerSheet: 4}.Validate(), "sheet duration cannot be set together with max tiles per sheet")
+}
@@ -0,0 +1,59 @@
+package thumber
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+)
+
+// SaveTiles writes the frames of the sheet into dir as tile-0001.jpg, tile-0002.jpg and so on numbered from first,
+// and returns their paths.
+// Frames are written exactly as ffmpeg encoded them if extracted with ThumbOptions.KeepJPEG, unless timestamps
+// or markers are overlaid, in which case they're decoded and re-encoded at the given JPEG quality.
+func SaveTiles(dir string, tiles []TilePlacement, opts ThumbOptions, quality int, first int) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	layout := opts.LayoutOptions()
+	renderer := layout.timestampRenderer()
+	var paths []string
+	for i, tile := range tiles {
+		path := filepath.Join(dir, fmt.Sprintf("tile-%04d.jpg", first+i))
+		data := tile.Frame.JPEG
+		if opts.OverlayTimestamps || opts.QRMarkers || data == nil {
+			t := tile.Frame
+			if t.JPEG != nil {
+				// the image may have been scaled down to fit the sheet
+				img, _, err := image.Decode(bytes.NewReader(t.JPEG))
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode tile: %w", err)
+				}
+				t.Image = img
+			}
+			if opts.OverlayTimestamps {
+				if err := t.overlayTimestamp(renderer, layout.displayTimestamp(t.Timestamp)); err != nil {
+					return nil, fmt.Errorf("failed to overlay timestamp: %w", err)
+				}
+			}
+			if opts.QRMarkers {
+				if err := t.overlayMarker(); err != nil {
+					return nil, fmt.Errorf("failed to overlay qr marker: %w", err)
+				}
+			}
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, t.Image, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, fmt.Errorf("failed to encode tile: %w", err)
+			}
+			data = buf.Bytes()
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,40 @@
+package thumber
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveTiles(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 320, 180)), nil))
+	frame := Thumbnail{Image: image.NewRGBA(image.Rect(0, 0, 160, 90)), Timestamp: time.Minute, JPEG: buf.Bytes()}
+	tiles := []TilePlacement{{Frame: frame}, {Frame: frame}}
+
+	dir := t.TempDir()
+	paths, err := SaveTiles(dir, tiles, ThumbOptions{}, 80, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "tile-0003.jpg"), filepath.Join(dir, "tile-0004.jpg")}, paths)
+
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), data, "frames are saved as extracted")
+
+	paths, err = SaveTiles(dir, tiles, ThumbOptions{OverlayTimestamps: true, TimestampBackground: color.Black}, 80, 1)
+	require.NoError(t, err)
+	data, err = os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.NotEqual(t, buf.Bytes(), data, "overlaid frames are re-encoded")
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 320, img.Bounds().Dx(), "frames are saved at their extracted size")
+}
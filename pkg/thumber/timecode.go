@@ -0,0 +1,79 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// timecodePattern matches HH:MM:SS:FF timecodes, with ; or . before the frames for drop-frame timecodes
+var timecodePattern = regexp.MustCompile(`\d{1,2}[:;.]\d{2}[:;.]\d{2}(?:[:;.]\d{2})?`)
+
+// timecodeOutput makes ffmpeg also save the region of the frame it extracts as a PNG at path, so the timecode
+// is read from the same decoded frame as the tile, at the resolution of the video
+func timecodeOutput(ctx context.Context, videoPath string, region Region, path string, opts ThumbOptions) []string {
+	args := variantArgs(ctx, videoPath, opts.Variant)
+	return append(args,
+		// grayscale and upscale the region, tesseract struggles with the small text of typical timecode burn-ins
+		"-vf", region.cropFilter()+",format=gray,scale=iw*2:ih*2:flags=lanczos",
+		"-vframes", "1",
+		"-c:v", "png",
+		"-f", "image2", path,
+	)
+}
+
+// readTimecode OCRs the burned-in timecode in an image of the timecode region using tesseract.
+// It returns an empty string when no timecode is recognized.
+func readTimecode(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := command(
+		ctx,
+		"tesseract",
+		"stdin", "stdout",
+		// treat the image as a single line of text
+		"--psm", "7",
+		"-c", "tessedit_char_whitelist=0123456789:;.",
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", &CommandError{Command: "tesseract", Stderr: stderr.String(), Err: err}
+	}
+	return parseTimecode(stdout.String()), nil
+}
+
+func parseTimecode(text string) string {
+	return timecodePattern.FindString(strings.ReplaceAll(text, " ", ""))
+}
+
+func checkTesseractInstalled() error {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return ErrTesseractNotFound
+	}
+	return nil
+}
+
+// recordTimecode sets the timecode of the thumbnail from the image of its timecode region at path,
+// logging failures instead of returning them, since a missed timecode shouldn't cost the whole sheet
+func recordTimecode(ctx context.Context, th *Thumbnail, path string) {
+	tc, err := readTimecode(ctx, path)
+	if err != nil {
+		slog.Warn("failed to read timecode", "timestamp", th.Timestamp, "error", err)
+		return
+	}
+	if tc == "" {
+		slog.Debug("no timecode recognized", "timestamp", th.Timestamp)
+	}
+	th.Timecode = tc
+}
@@ -0,0 +1,37 @@
+package thumber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimecode(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "01:02:03:04\n", want: "01:02:03:04"},
+		{text: "10:00:00;12\n", want: "10:00:00;12"},
+		{text: "1:02:03", want: "1:02:03"},
+		{text: "01: 02:03:04", want: "01:02:03:04"},
+		{text: "..:\n", want: ""},
+		{text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseTimecode(tt.text))
+		})
+	}
+}
+
+func TestTimecodeOutput(t *testing.T) {
+	region := Region{X: 10, Y: 20, W: 300, H: 60}
+	assert.Equal(t, []string{
+		"-vf", "crop=300:60:10:20,format=gray,scale=iw*2:ih*2:flags=lanczos",
+		"-vframes", "1",
+		"-c:v", "png",
+		"-f", "image2", "/tmp/timecode.png",
+	}, timecodeOutput(context.Background(), "video.mp4", region, "/tmp/timecode.png", ThumbOptions{}))
+}
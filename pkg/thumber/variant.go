@@ -0,0 +1,242 @@
+package thumber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Variant picks the rendition of adaptive streams (HLS or DASH) frames are extracted from:
+// VariantLowest, VariantHighest, or a height like 540p for the smallest rendition at least that tall.
+// Empty leaves it to ffmpeg, which picks the highest.
+type Variant string
+
+const (
+	VariantLowest  Variant = "lowest"
+	VariantHighest Variant = "highest"
+)
+
+// minHeight is the height of a variant like 540p, or 0 for lowest and highest
+func (v Variant) minHeight() (int, error) {
+	switch v {
+	case "", VariantLowest, VariantHighest:
+		return 0, nil
+	}
+	h, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(string(v)), "p"))
+	if err != nil || h <= 0 {
+		return 0, fmt.Errorf("invalid variant %q, must be one of lowest, highest or a height like 540p", v)
+	}
+	return h, nil
+}
+
+// IsAdaptive reports whether the input is an HLS or DASH manifest, which ffmpeg reads directly instead of downloading it
+func IsAdaptive(input string) bool {
+	p := input
+	if u, err := url.Parse(input); err == nil && u.Path != "" {
+		p = u.Path
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".m3u8", ".mpd":
+		return true
+	}
+	return false
+}
+
+// variantStream is a video stream of an adaptive source
+type variantStream struct {
+	index         int
+	width, height int
+}
+
+// readVariantStreams lists the video streams of every rendition of an adaptive source
+func readVariantStreams(ctx context.Context, videoPath string) ([]variantStream, error) {
+	out, err := runProbe(ctx,
+		"-show_entries", "stream=index,codec_type,width,height",
+		videoPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseVariantStreams(out)
+}
+
+func parseVariantStreams(out []byte) ([]variantStream, error) {
+	p, err := decodeProbe(out)
+	if err != nil {
+		return nil, err
+	}
+	var streams []variantStream
+	for _, s := range p.Streams {
+		if s.CodecType != "video" || s.Width == 0 || s.Height == 0 {
+			continue
+		}
+		streams = append(streams, variantStream{index: s.Index, width: s.Width, height: s.Height})
+	}
+	return streams, nil
+}
+
+// selectVariant returns the stream of the variant, or false if there are no video streams
+func selectVariant(streams []variantStream, v Variant) (variantStream, bool) {
+	if len(streams) == 0 {
+		return variantStream{}, false
+	}
+	smaller := func(a, b variantStream) bool {
+		return a.height < b.height || (a.height == b.height && a.width < b.width)
+	}
+	lowest, highest := streams[0], streams[0]
+	for _, s := range streams[1:] {
+		if smaller(s, lowest) {
+			lowest = s
+		}
+		if smaller(highest, s) {
+			highest = s
+		}
+	}
+	minHeight, _ := v.minHeight()
+	switch {
+	case v == VariantLowest:
+		return lowest, true
+	case minHeight == 0:
+		return highest, true
+	}
+	best, found := highest, false
+	for _, s := range streams {
+		if s.height >= minHeight && (!found || smaller(s, best)) {
+			best, found = s, true
+		}
+	}
+	return best, true
+}
+
+type variantKey struct {
+	path    string
+	variant Variant
+}
+
+// variantCacheTTL and variantCacheSize bound the variants cached by a long running process, e.g. a server
+// seeing a new URL with every request
+const (
+	variantCacheTTL  = 10 * time.Minute
+	variantCacheSize = 256
+)
+
+type variantEntry struct {
+	// done is closed once args and err are set
+	done    chan struct{}
+	args    []string
+	err     error
+	expires time.Time
+}
+
+// variantCache holds the stream picked for each source, so frames extracted in parallel probe it once.
+// Failed probes aren't kept, so the next caller tries again.
+type variantCache struct {
+	mu      sync.Mutex
+	entries map[variantKey]*variantEntry
+}
+
+var variants = &variantCache{entries: map[variantKey]*variantEntry{}}
+
+// get returns the cached args of key, or probes them while concurrent callers wait for the result
+func (c *variantCache) get(ctx context.Context, key variantKey, probe func(context.Context) ([]string, error)) ([]string, error) {
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		e, ok := c.entries[key]
+		if ok && isDone(e) && now.After(e.expires) {
+			delete(c.entries, key)
+			ok = false
+		}
+		if !ok {
+			c.evict(now)
+			e = &variantEntry{done: make(chan struct{})}
+			c.entries[key] = e
+			c.mu.Unlock()
+
+			e.args, e.err = probe(ctx)
+			e.expires = time.Now().Add(variantCacheTTL)
+			if e.err != nil {
+				c.mu.Lock()
+				if c.entries[key] == e {
+					delete(c.entries, key)
+				}
+				c.mu.Unlock()
+			}
+			close(e.done)
+			return e.args, e.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// a probe canceled by its own caller is tried again by those still waiting
+		if e.err == nil || !errors.Is(e.err, context.Canceled) && !errors.Is(e.err, context.DeadlineExceeded) {
+			return e.args, e.err
+		}
+	}
+}
+
+// evict removes expired entries, and the one that expires first if the cache is full. It's called with mu held.
+func (c *variantCache) evict(now time.Time) {
+	var oldest variantKey
+	var oldestEntry *variantEntry
+	for k, e := range c.entries {
+		if !isDone(e) {
+			continue
+		}
+		if now.After(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldestEntry == nil || e.expires.Before(oldestEntry.expires) {
+			oldest, oldestEntry = k, e
+		}
+	}
+	if len(c.entries) >= variantCacheSize && oldestEntry != nil {
+		delete(c.entries, oldest)
+	}
+}
+
+func isDone(e *variantEntry) bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// variantArgs are the ffmpeg arguments that select the variant of an adaptive source, so only its segments are fetched.
+// Sources that aren't adaptive or can't be probed are left to ffmpeg.
+func variantArgs(ctx context.Context, videoPath string, v Variant) []string {
+	if v == "" || !IsAdaptive(videoPath) {
+		return nil
+	}
+	args, err := variants.get(ctx, variantKey{videoPath, v}, func(ctx context.Context) ([]string, error) {
+		streams, err := readVariantStreams(ctx, videoPath)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := selectVariant(streams, v)
+		if !ok {
+			return nil, nil
+		}
+		slog.Debug("picked variant", "path", videoPath, "stream", s.index, "width", s.width, "height", s.height)
+		return []string{"-map", fmt.Sprintf("0:%d", s.index)}, nil
+	})
+	if err != nil {
+		slog.Warn("failed to list variants, leaving it to ffmpeg", "path", videoPath, "error", err)
+	}
+	return args
+}
@@ -0,0 +1,129 @@
+package thumber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAdaptive(t *testing.T) {
+	assert.True(t, IsAdaptive("https://cdn.example.com/movie/master.m3u8?token=x"))
+	assert.True(t, IsAdaptive("https://cdn.example.com/movie/manifest.MPD"))
+	assert.True(t, IsAdaptive("/videos/playlist.m3u8"))
+	assert.False(t, IsAdaptive("https://cdn.example.com/movie.mp4"))
+}
+
+func TestSelectVariant(t *testing.T) {
+	out := `{"streams": [
+		{"index": 0, "codec_type": "audio"},
+		{"index": 1, "codec_type": "video", "width": 1920, "height": 1080},
+		{"index": 2, "codec_type": "video", "width": 640, "height": 360},
+		{"index": 3, "codec_type": "video", "width": 1280, "height": 720},
+		{"index": 4, "codec_type": "video", "width": 960, "height": 540},
+		{"index": 5, "codec_type": "data"}
+	]}`
+	streams, err := parseVariantStreams([]byte(out))
+	require.NoError(t, err)
+	assert.Len(t, streams, 4)
+
+	tests := []struct {
+		variant Variant
+		want    int
+	}{
+		{VariantLowest, 2},
+		{VariantHighest, 1},
+		{"", 1},
+		{"540p", 4},
+		{"600", 3},
+		{"2160p", 1},
+	}
+	for _, tt := range tests {
+		s, ok := selectVariant(streams, tt.variant)
+		assert.True(t, ok)
+		assert.Equal(t, tt.want, s.index, "variant %q", tt.variant)
+	}
+
+	_, ok := selectVariant(nil, VariantLowest)
+	assert.False(t, ok)
+}
+
+func TestVariantValidation(t *testing.T) {
+	for _, v := range []Variant{"", "lowest", "highest", "540p", "720"} {
+		_, err := v.minHeight()
+		assert.NoError(t, err, v)
+	}
+	for _, v := range []Variant{"low", "0p", "-720"} {
+		_, err := v.minHeight()
+		assert.Error(t, err, v)
+	}
+}
+
+func TestVariantCache(t *testing.T) {
+	c := &variantCache{entries: map[variantKey]*variantEntry{}}
+	key := variantKey{"a.m3u8", VariantLowest}
+	probes := 0
+	probe := func(args []string, err error) func(context.Context) ([]string, error) {
+		return func(context.Context) ([]string, error) {
+			probes++
+			return args, err
+		}
+	}
+
+	_, err := c.get(context.Background(), key, probe(nil, context.Canceled))
+	assert.ErrorIs(t, err, context.Canceled)
+	args, err := c.get(context.Background(), key, probe([]string{"-map", "0:1"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-map", "0:1"}, args, "failures aren't cached")
+	args, err = c.get(context.Background(), key, probe(nil, errors.New("unexpected")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-map", "0:1"}, args)
+	assert.Equal(t, 2, probes)
+
+	c.entries[key].expires = time.Now().Add(-time.Second)
+	args, err = c.get(context.Background(), key, probe([]string{"-map", "0:2"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-map", "0:2"}, args, "expired entries are probed again")
+
+	for i := 0; i < 2*variantCacheSize; i++ {
+		_, err := c.get(context.Background(), variantKey{fmt.Sprintf("%d.m3u8", i), VariantLowest}, probe(nil, nil))
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, len(c.entries), variantCacheSize)
+}
+
+func TestVariantCacheRetriesCanceledProbe(t *testing.T) {
+	c := &variantCache{entries: map[variantKey]*variantEntry{}}
+	key := variantKey{"a.m3u8", VariantLowest}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := c.get(ctx, key, func(ctx context.Context) ([]string, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	<-started
+
+	done := make(chan []string)
+	go func() {
+		args, err := c.get(context.Background(), key, func(context.Context) ([]string, error) {
+			return []string{"-map", "0:1"}, nil
+		})
+		assert.NoError(t, err)
+		done <- args
+	}()
+	cancel()
+	assert.Equal(t, []string{"-map", "0:1"}, <-done, "the waiter probes again instead of taking the canceled result")
+	wg.Wait()
+}
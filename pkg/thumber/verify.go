@@ -0,0 +1,164 @@
+package thumber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (r TimeRange) Contains(t time.Duration) bool {
+	return t >= r.Start && t < r.End
+}
+
+func (r TimeRange) String() string {
+	return fmt.Sprintf("%s-%s", FormatDuration(r.Start), FormatDuration(r.End))
+}
+
+type Verification struct {
+	Duration time.Duration
+	// Readable lists the ranges between keyframes that decoded without errors
+	Readable []TimeRange
+	// Errors are decoder messages, in the order ffmpeg reported them
+	Errors []string
+	// Truncated is set when decoding stopped well before the reported duration
+	Truncated bool
+}
+
+func (v Verification) OK() bool {
+	return len(v.Errors) == 0 && !v.Truncated
+}
+
+// nearestReadable returns the closest readable timestamp to t
+func (v Verification) nearestReadable(t time.Duration) (time.Duration, bool) {
+	var best time.Duration
+	found := false
+	distance := func(d time.Duration) time.Duration {
+		if d < t {
+			return t - d
+		}
+		return d - t
+	}
+	for _, r := range v.Readable {
+		if r.Contains(t) {
+			return t, true
+		}
+		candidate := r.Start
+		if t >= r.End {
+			candidate = r.End - time.Millisecond
+		}
+		if !found || distance(candidate) < distance(best) {
+			best, found = candidate, true
+		}
+	}
+	return best, found
+}
+
+// truncationTolerance is how far before the reported duration the last keyframe may be, before the file is considered truncated
+const truncationTolerance = 10 * time.Second
+
+// Verify decodes the keyframes of the video and reports the ranges that decode cleanly.
+// It's much faster than a full decode, but errors in non-key frames are not detected.
+func Verify(ctx context.Context, videoPath string) (Verification, error) {
+	if err := checkFfmpegInstalled(); err != nil {
+		return Verification{}, err
+	}
+
+	duration, err := readDuration(ctx, videoPath)
+	if err != nil {
+		return Verification{}, fmt.Errorf("failed to read video duration: %w", err)
+	}
+	return verify(ctx, videoPath, duration)
+}
+
+func verify(ctx context.Context, videoPath string, duration time.Duration) (Verification, error) {
+	cmd := command(
+		ctx,
+		"ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-loglevel", "level+info",
+		"-skip_frame", "nokey",
+		"-i", videoPath,
+		"-map", "0:v:0",
+		"-vf", "showinfo",
+		"-f", "null",
+		"-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	v := parseVerifyLog(stderr.String(), duration)
+	if runErr != nil {
+		if len(v.Readable) == 0 {
+			return Verification{}, &CommandError{Command: "ffmpeg", Stderr: stderr.String(), Err: runErr}
+		}
+		// ffmpeg bails out on severe corruption, but what it read until then is still useful
+		v.Errors = append(v.Errors, fmt.Sprintf("ffmpeg exited early: %s", runErr))
+	}
+	return v, nil
+}
+
+func parseVerifyLog(log string, duration time.Duration) Verification {
+	v := Verification{Duration: duration}
+
+	var keyframes []time.Duration
+	// errorsAfter[i] is set when an error was logged after keyframes[i]
+	var errorsAfter []bool
+	errorsBeforeFirst := false
+
+	for _, line := range strings.Split(log, "\n") {
+		if strings.Contains(line, "[error]") || strings.Contains(line, "[fatal]") {
+			v.Errors = append(v.Errors, strings.TrimSpace(line))
+			if len(keyframes) == 0 {
+				errorsBeforeFirst = true
+			} else {
+				errorsAfter[len(errorsAfter)-1] = true
+			}
+			continue
+		}
+		if pts, ok := parseFramePTS(line); ok {
+			keyframes = append(keyframes, pts)
+			errorsAfter = append(errorsAfter, false)
+		}
+	}
+
+	for i, k := range keyframes {
+		end := duration
+		if i+1 < len(keyframes) {
+			end = keyframes[i+1]
+		}
+		if errorsAfter[i] || end <= k {
+			continue
+		}
+		start := k
+		if i == 0 && !errorsBeforeFirst {
+			start = 0
+		}
+		if n := len(v.Readable); n > 0 && v.Readable[n-1].End == start {
+			v.Readable[n-1].End = end
+		} else {
+			v.Readable = append(v.Readable, TimeRange{Start: start, End: end})
+		}
+	}
+
+	if len(keyframes) == 0 || duration-keyframes[len(keyframes)-1] > truncationTolerance {
+		v.Truncated = true
+		if n := len(v.Readable); n > 0 && v.Readable[n-1].End == duration {
+			// the tail after the last keyframe isn't actually there
+			v.Readable[n-1].End = keyframes[len(keyframes)-1]
+			if v.Readable[n-1].End <= v.Readable[n-1].Start {
+				v.Readable = v.Readable[:n-1]
+			}
+		}
+	}
+
+	return v
+}
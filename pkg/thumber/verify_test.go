@@ -0,0 +1,38 @@
+package thumber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVerifyLog(t *testing.T) {
+	log := `[Parsed_showinfo_0 @ 0x1] [info] n:   0 pts:      0 pts_time:0 duration:   3003
+[Parsed_showinfo_0 @ 0x1] [info] n:   1 pts: 900000 pts_time:10 duration:   3003
+[h264 @ 0x2] [error] Invalid NAL unit size (1234 > 56).
+[h264 @ 0x2] [error] error while decoding MB 12 3
+[Parsed_showinfo_0 @ 0x1] [info] n:   2 pts:1800000 pts_time:20 duration:   3003
+[Parsed_showinfo_0 @ 0x1] [info] n:   3 pts:2700000 pts_time:30 duration:   3003`
+
+	v := parseVerifyLog(log, 35*time.Second)
+	assert.Len(t, v.Errors, 2)
+	assert.False(t, v.Truncated)
+	assert.Equal(t, []TimeRange{
+		{Start: 0, End: 10 * time.Second},
+		{Start: 20 * time.Second, End: 35 * time.Second},
+	}, v.Readable)
+
+	ts, ok := v.nearestReadable(12 * time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second-time.Millisecond, ts)
+}
+
+func TestParseVerifyLogTruncated(t *testing.T) {
+	log := `[Parsed_showinfo_0 @ 0x1] [info] n:   0 pts:      0 pts_time:0 duration:   3003
+[Parsed_showinfo_0 @ 0x1] [info] n:   1 pts: 900000 pts_time:10 duration:   3003`
+
+	v := parseVerifyLog(log, 120*time.Second)
+	assert.True(t, v.Truncated)
+	assert.Equal(t, []TimeRange{{Start: 0, End: 10 * time.Second}}, v.Readable)
+}
@@ -0,0 +1,53 @@
+package thumber
+
+import "context"
+
+// Workers is a budget of ffmpeg extraction processes shared by generations running at the same time, e.g. the files
+// of a batch, so the next file can use the workers the current one leaves idle without oversubscribing the machine.
+// A nil Workers doesn't limit anything beyond ThumbOptions.Concurrency.
+type Workers struct {
+	slots chan struct{}
+}
+
+// NewWorkers returns a budget of n processes
+func NewWorkers(n int) *Workers {
+	if n < 1 {
+		n = 1
+	}
+	return &Workers{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot, or until ctx is done
+func (w *Workers) acquire(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Workers) release() {
+	if w == nil {
+		return
+	}
+	<-w.slots
+}
+
+// Busy returns how many processes are running, and Size how many may run at once. Both are zero for a nil Workers.
+func (w *Workers) Busy() int {
+	if w == nil {
+		return 0
+	}
+	return len(w.slots)
+}
+
+func (w *Workers) Size() int {
+	if w == nil {
+		return 0
+	}
+	return cap(w.slots)
+}
@@ -0,0 +1,28 @@
+package thumber
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkers(t *testing.T) {
+	w := NewWorkers(2)
+	ctx := context.Background()
+	require.NoError(t, w.acquire(ctx))
+	require.NoError(t, w.acquire(ctx))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.acquire(timeout), context.DeadlineExceeded, "the budget is used up")
+
+	w.release()
+	assert.NoError(t, w.acquire(ctx))
+
+	var unlimited *Workers
+	assert.NoError(t, unlimited.acquire(ctx))
+	unlimited.release()
+}
@@ -0,0 +1,169 @@
+package thumber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	workspacePrefix  = "thumber-"
+	workspacePIDFile = ".pid"
+	// staleWorkspaceAge is how old a workspace must be before it's removed even if its process might still be alive
+	staleWorkspaceAge = 24 * time.Hour
+)
+
+var ErrWorkspaceFull = errors.New("workspace size limit exceeded")
+
+// Workspace is a temporary directory for intermediate files, removed on Close.
+// Workspaces left behind by crashed processes are removed by CleanStaleWorkspaces.
+type Workspace struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+// NewWorkspace creates a workspace under parent, or the system temp dir if empty.
+// maxBytes limits the total size of tracked files, 0 means unlimited.
+func NewWorkspace(parent string, maxBytes int64) (*Workspace, error) {
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	dir, err := os.MkdirTemp(parent, fmt.Sprintf("%s%d-", workspacePrefix, os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, workspacePIDFile), []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return &Workspace{dir: dir, maxBytes: maxBytes}, nil
+}
+
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Size returns the total size of tracked files
+func (w *Workspace) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Reserve accounts n bytes towards the size limit
+func (w *Workspace) Reserve(n int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxBytes > 0 && w.size+n > w.maxBytes {
+		return fmt.Errorf("%w: %d bytes used of %d", ErrWorkspaceFull, w.size, w.maxBytes)
+	}
+	w.size += n
+	return nil
+}
+
+// Release gives back n bytes, e.g. after removing a file
+func (w *Workspace) Release(n int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.size -= n
+}
+
+// CopyFrom writes r to a new file in the workspace, accounting its size as it goes, and returns its path
+func (w *Workspace) CopyFrom(name string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(w.dir, "*-"+filepath.Base(name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := w.copy(f, r); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// copy writes r to f, accounting its size as it goes
+func (w *Workspace) copy(f *os.File, r io.Reader) error {
+	buf := make([]byte, 1<<20)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if err := w.Reserve(int64(n)); err != nil {
+				return err
+			}
+			if _, err := f.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.dir)
+}
+
+// CleanStaleWorkspaces removes workspaces under parent left behind by processes that are no longer running.
+// Workspaces whose process can't be told are removed once they're older than a day, while those of running
+// processes are kept however old, e.g. of a long-running server. It returns the number of workspaces removed.
+func CleanStaleWorkspaces(parent string) (int, error) {
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), workspacePrefix) {
+			continue
+		}
+		dir := filepath.Join(parent, e.Name())
+		if !isStaleWorkspace(dir) {
+			continue
+		}
+		slog.Debug("removing stale workspace", "dir", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("failed to remove stale workspace", "dir", dir, "error", err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func isStaleWorkspace(dir string) bool {
+	pidFile := filepath.Join(dir, workspacePIDFile)
+	stat, err := os.Stat(pidFile)
+	if err != nil {
+		// not one of ours
+		return false
+	}
+	old := time.Since(stat.ModTime()) > staleWorkspaceAge
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return old
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return old
+	}
+	return pid != os.Getpid() && !processAlive(pid)
+}
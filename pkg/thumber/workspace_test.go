@@ -0,0 +1,62 @@
+package thumber
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	parent := t.TempDir()
+
+	ws, err := NewWorkspace(parent, 10)
+	require.NoError(t, err)
+
+	_, err = ws.CopyFrom("small", strings.NewReader("12345"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, ws.Size())
+
+	_, err = ws.CopyFrom("large", strings.NewReader("1234567890"))
+	assert.ErrorIs(t, err, ErrWorkspaceFull)
+
+	require.NoError(t, ws.Close())
+	assert.NoDirExists(t, ws.Dir())
+}
+
+func TestCleanStaleWorkspaces(t *testing.T) {
+	parent := t.TempDir()
+
+	live, err := NewWorkspace(parent, 0)
+	require.NoError(t, err)
+	defer live.Close()
+
+	old := time.Now().Add(-2 * staleWorkspaceAge)
+	longLived, err := NewWorkspace(parent, 0)
+	require.NoError(t, err)
+	defer longLived.Close()
+	require.NoError(t, os.Chtimes(filepath.Join(longLived.Dir(), workspacePIDFile), old, old))
+
+	stale, err := NewWorkspace(parent, 0)
+	require.NoError(t, err)
+	pidFile := filepath.Join(stale.Dir(), workspacePIDFile)
+	require.NoError(t, os.WriteFile(pidFile, []byte("garbled"), 0o644))
+	require.NoError(t, os.Chtimes(pidFile, old, old))
+
+	unknown, err := NewWorkspace(parent, 0)
+	require.NoError(t, err)
+	defer unknown.Close()
+	require.NoError(t, os.WriteFile(filepath.Join(unknown.Dir(), workspacePIDFile), []byte("garbled"), 0o644))
+
+	removed, err := CleanStaleWorkspaces(parent)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.DirExists(t, live.Dir())
+	assert.DirExists(t, longLived.Dir(), "the process is running, however old its workspace")
+	assert.DirExists(t, unknown.Dir(), "its process can't be told, but it's recent")
+	assert.NoDirExists(t, stale.Dir())
+}
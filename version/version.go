@@ -6,18 +6,30 @@ import (
 	"time"
 )
 
+// Tag is the release the binary was built from, e.g. v1.2.0. Release builds set it with
+// -ldflags "-X github.com/abdusco/thumber/version.Tag=v1.2.0", and go install sets the module version instead.
+var Tag string
+
 type VersionInfo struct {
+	// Tag is empty for builds that aren't of a release
+	Tag        string
 	Commit     string
 	CommitTime string
 }
 
 func (v VersionInfo) String() string {
+	if v.Tag != "" {
+		return fmt.Sprintf("%s (%s.%s)", v.Tag, v.CommitTime, v.Commit)
+	}
 	return fmt.Sprintf("%s.%s", v.CommitTime, v.Commit)
 }
 
 var Version = func() VersionInfo {
-	var info VersionInfo
+	info := VersionInfo{Tag: Tag}
 	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Tag == "" && build.Main.Version != "(devel)" {
+			info.Tag = build.Main.Version
+		}
 		for _, setting := range build.Settings {
 			switch setting.Key {
 			case "vcs.revision":